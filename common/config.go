@@ -24,6 +24,34 @@ const (
 	CfgP2PSeeds = "p2p.seeds"
 	// CfgP2PMessageQueueSize sets the message queue size for network interface.
 	CfgP2PMessageQueueSize = "p2p.messageQueueSize"
+	// CfgP2PUPNP determines whether to perform UPNP port mapping to detect the external network address.
+	CfgP2PUPNP = "p2p.upnp"
+
+	// CfgMempoolOrderingStrategy sets the order in which transactions are reaped from the mempool ("fifo" or "fee_priority").
+	CfgMempoolOrderingStrategy = "mempool.orderingStrategy"
+	// CfgMempoolMaxTxsPerSender limits the number of pending transactions of a sender in the mempool (0 means no limit).
+	CfgMempoolMaxTxsPerSender = "mempool.maxTxsPerSender"
+	// CfgMempoolPeerTxRate limits the number of transactions per second a peer may relay (0 means no limit).
+	CfgMempoolPeerTxRate = "mempool.peerTxRate"
+	// CfgMempoolPeerTxBurst limits the number of transactions a peer may relay in a burst.
+	CfgMempoolPeerTxBurst = "mempool.peerTxBurst"
+	// CfgMempoolPersistent sets whether the pending transactions are persisted to survive restarts.
+	CfgMempoolPersistent = "mempool.persistent"
+	// CfgMempoolMaxTotalBytes limits the total size in bytes of the pending transactions (0 means no limit).
+	CfgMempoolMaxTotalBytes = "mempool.maxTotalBytes"
+
+	// CfgLedgerCanonicalTxOrdering determines whether the proposer sorts the regular transactions of a block by (sender, sequence).
+	CfgLedgerCanonicalTxOrdering = "ledger.canonicalTxOrdering"
+	// CfgLedgerMinGasPrice sets the gas price floor in GammaWei below which transactions are rejected by the node.
+	CfgLedgerMinGasPrice = "ledger.minGasPrice"
+	// CfgLedgerProposalDeadline sets the time in milliseconds after which the proposer stops adding regular transactions to a block (0 means no deadline).
+	CfgLedgerProposalDeadline = "ledger.proposalDeadline"
+	// CfgLedgerMaxReorgDepth overrides the max number of blocks a reset of the ledger state can roll back or replay (0 means core.MaxReorgDepth).
+	CfgLedgerMaxReorgDepth = "ledger.maxReorgDepth"
+	// CfgLedgerGenesisRoot sets the expected state root in hex of the genesis state, which fails to load on a mismatch (empty means no verification).
+	CfgLedgerGenesisRoot = "ledger.genesisRoot"
+	// CfgLedgerStateRetention sets the number of blocks below the finalized block whose states are kept, the older states are pruned on finalization (0 means no pruning).
+	CfgLedgerStateRetention = "ledger.stateRetention"
 
 	// CfgRPCPort sets the port of RPC service.
 	CfgRPCPort = "rpc.port"
@@ -35,6 +63,8 @@ const (
 	// CfgLogPrintSelfID determines whether to print node's ID in log (Useful in simulation when
 	// there are more than one node running).
 	CfgLogPrintSelfID = "log.printSelfID"
+	// CfgLogJSON determines whether to format the logs as JSON, with one object per line.
+	CfgLogJSON = "log.json"
 )
 
 // InitialConfig is the default configuartion produced by init command.
@@ -56,12 +86,28 @@ func init() {
 	viper.SetDefault(CfgP2PName, "Anonymous")
 	viper.SetDefault(CfgP2PPort, 50001)
 	viper.SetDefault(CfgP2PSeeds, "")
+	viper.SetDefault(CfgP2PUPNP, false)
+
+	viper.SetDefault(CfgMempoolOrderingStrategy, "fifo")
+	viper.SetDefault(CfgMempoolMaxTxsPerSender, 1000)
+	viper.SetDefault(CfgMempoolPeerTxRate, 100)
+	viper.SetDefault(CfgMempoolPeerTxBurst, 200)
+	viper.SetDefault(CfgMempoolPersistent, false)
+	viper.SetDefault(CfgMempoolMaxTotalBytes, 64*1024*1024)
+
+	viper.SetDefault(CfgLedgerCanonicalTxOrdering, false)
+	viper.SetDefault(CfgLedgerMinGasPrice, 1000000000) // types.MinimumGasPrice
+	viper.SetDefault(CfgLedgerProposalDeadline, 0)
+	viper.SetDefault(CfgLedgerMaxReorgDepth, 0)
+	viper.SetDefault(CfgLedgerGenesisRoot, "")
+	viper.SetDefault(CfgLedgerStateRetention, 0)
 
 	viper.SetDefault(CfgRPCPort, "16888")
 	viper.SetDefault(CfgRPCMaxConnections, 200)
 
 	viper.SetDefault(CfgLogLevels, "*:debug")
 	viper.SetDefault(CfgLogPrintSelfID, false)
+	viper.SetDefault(CfgLogJSON, false)
 }
 
 // WriteInitialConfig writes initial config file to file system.
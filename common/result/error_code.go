@@ -13,6 +13,14 @@ const (
 	CodeEmptyPubKeyWithSequence1 ErrorCode = 100004
 	CodeUnauthorizedTx           ErrorCode = 100005
 	CodeInvalidFee               ErrorCode = 100006
+	CodeTxDataTooLarge           ErrorCode = 100007
+	CodeImmatureCoinbaseReward   ErrorCode = 100008
+	CodeFeeTooLow                ErrorCode = 100009
+	CodeCancelled                ErrorCode = 100010
+	CodeTxExpired                ErrorCode = 100011
+	CodeInvalidProposer          ErrorCode = 100012
+	CodeCoinOverflow             ErrorCode = 100013
+	CodeAccountFrozen            ErrorCode = 100014
 
 	// ReserveFund Errors
 	CodeReserveFundCheckFailed   ErrorCode = 101001
@@ -33,4 +41,22 @@ const (
 	CodeInvalidValueToTransfer ErrorCode = 105002
 	CodeInvalidGasPrice        ErrorCode = 105003
 	CodeFeeLimitTooHigh        ErrorCode = 105004
+	CodeBlockGasLimitExceeded  ErrorCode = 105005
+
+	// Mempool Errors
+	CodeMempoolSenderQuotaExceeded ErrorCode = 106001
+	CodeMempoolFull                ErrorCode = 106002
+
+	// Ledger Errors
+	CodeTxParseError        ErrorCode = 107001 // a transaction cannot be decoded
+	CodeTxExecutionFailed   ErrorCode = 107002 // a block transaction fails without a more specific code
+	CodeStateRootMismatch   ErrorCode = 107003 // the state root after a block differs from the expected one
+	CodeStateNotAvailable   ErrorCode = 107004 // the designated state root is not in the database
+	CodeBlockReplayFailed   ErrorCode = 107005 // the blocks to rebuild a state cannot be replayed
+	CodeBatchAlreadyStarted ErrorCode = 107006 // BeginBatch is called within a batch
+	CodeNoBatchInProgress   ErrorCode = 107007 // CommitBatch is called outside of a batch
+	CodeBatchCommitFailed   ErrorCode = 107008 // the states of a batch cannot be flushed to the database
+	CodeReorgBelowFinalized ErrorCode = 107009 // a reset would revert a finalized block
+	CodeReorgTooDeep        ErrorCode = 107010 // a reset would roll back or replay more blocks than the max reorg depth
+	CodeGenesisRootMismatch ErrorCode = 107011 // the state root of the genesis state differs from the expected one
 )
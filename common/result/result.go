@@ -6,6 +6,7 @@ import "fmt"
 type Result struct {
 	Code    ErrorCode
 	Message string
+	Info    interface{} // optional structured details, e.g. the expected sequence of an account
 }
 
 // IsOK indicates if the execution succeeded
@@ -35,6 +36,12 @@ func (res Result) WithMessage(message string) Result {
 	return res
 }
 
+// WithInfo attaches the structured details to the result
+func (res Result) WithInfo(info interface{}) Result {
+	res.Info = info
+	return res
+}
+
 // -------------- Constructors -------------- //
 
 // OK represents the success result
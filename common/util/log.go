@@ -21,6 +21,8 @@ const (
 )
 const defaultLevel = warnLevel
 
+const logTimestampFormat = "2006-01-02 15:04:05"
+
 func parseLogLevelConfig(config string) map[string]string {
 	levels := make(map[string]string)
 
@@ -39,20 +41,32 @@ func parseLogLevelConfig(config string) map[string]string {
 	return levels
 }
 
+// newLogFormatter returns the JSON formatter if the JSON logs are enabled, and the custom text
+// formatter otherwise. The fields of the log entries, e.g. the transaction hash, are kept as
+// separate keys by the JSON formatter, so the logs can be filtered by them.
+func newLogFormatter() log.Formatter {
+	if viper.GetBool(common.CfgLogJSON) {
+		return &log.JSONFormatter{TimestampFormat: logTimestampFormat}
+	}
+
+	customFormatter := new(TextFormatter)
+	customFormatter.TimestampFormat = logTimestampFormat
+	customFormatter.FullTimestamp = true
+	customFormatter.ForceFormatting = true
+	return customFormatter
+}
+
 // GetLoggerForModule returns the logger for given module.
 func GetLoggerForModule(module string) *log.Entry {
 	if logLevels == nil {
 		logLevels = parseLogLevelConfig(viper.GetString(common.CfgLogLevels))
 		log.Infof("Log settings: %v, %v", logLevels, viper.GetString(common.CfgLogLevels))
 	}
-	customFormatter := new(TextFormatter)
-	customFormatter.TimestampFormat = "2006-01-02 15:04:05"
-	log.SetFormatter(customFormatter)
-	customFormatter.FullTimestamp = true
-	customFormatter.ForceFormatting = true
+	formatter := newLogFormatter()
+	log.SetFormatter(formatter)
 
 	logger := log.New()
-	logger.Formatter = customFormatter
+	logger.Formatter = formatter
 
 	level, ok := logLevels[module]
 	if !ok {
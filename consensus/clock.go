@@ -0,0 +1,152 @@
+package consensus
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and the timers to the consensus engine and state.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer sends the current time on its channel once its duration has elapsed on its clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+var _ Clock = (*RealClock)(nil)
+
+// RealClock is the Clock backed by the system time.
+type RealClock struct{}
+
+// Now implements the Clock interface.
+func (c *RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer implements the Clock interface.
+func (c *RealClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{time.NewTimer(d)}
+}
+
+// realTimer is the Timer backed by the system time.
+type realTimer struct {
+	*time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+var _ Clock = (*MockClock)(nil)
+
+// MockClock is a Clock that only moves when advanced explicitly, for deterministic tests.
+type MockClock struct {
+	mu     *sync.Mutex
+	now    time.Time
+	timers map[*mockTimer]bool // Timers that may be active
+}
+
+// NewMockClock creates a MockClock set to the given time.
+func NewMockClock(now time.Time) *MockClock {
+	return &MockClock{
+		mu:     &sync.Mutex{},
+		now:    now,
+		timers: make(map[*mockTimer]bool),
+	}
+}
+
+// Now implements the Clock interface.
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements the Clock interface. The timer fires when the clock is advanced past its
+// deadline.
+func (c *MockClock) NewTimer(d time.Duration) Timer {
+	t := &mockTimer{clock: c, c: make(chan time.Time, 1), mu: &sync.Mutex{}}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by the given duration, and fires the timers due.
+func (c *MockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	timers := make([]*mockTimer, 0, len(c.timers))
+	for t := range c.timers {
+		timers = append(timers, t)
+	}
+	c.mu.Unlock()
+
+	for _, t := range timers {
+		t.fireIfDue(now)
+	}
+
+	// Drop the inactive timers, which are registered again when reset
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t := range c.timers {
+		t.mu.Lock()
+		if !t.active {
+			delete(c.timers, t)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// mockTimer is a Timer of a MockClock.
+type mockTimer struct {
+	clock    *MockClock
+	c        chan time.Time
+	mu       *sync.Mutex
+	deadline time.Time
+	active   bool
+}
+
+func (t *mockTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *mockTimer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+func (t *mockTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	t.mu.Lock()
+	now := t.clock.now
+	wasActive := t.active
+	t.deadline = now.Add(d)
+	t.active = true
+	t.clock.timers[t] = true
+	t.mu.Unlock()
+	t.clock.mu.Unlock()
+
+	t.fireIfDue(now)
+	return wasActive
+}
+
+func (t *mockTimer) fireIfDue(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.active || now.Before(t.deadline) {
+		return
+	}
+	t.active = false
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/thetatoken/ukulele/blockchain"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
 	"github.com/thetatoken/ukulele/common/util"
 	"github.com/thetatoken/ukulele/core"
 	"github.com/thetatoken/ukulele/crypto"
@@ -47,15 +48,16 @@ type ConsensusEngine struct {
 	stopped bool
 
 	mu         *sync.Mutex
-	epochTimer *time.Timer
+	clock      Clock
+	epochTimer Timer
 
 	state *State
 
 	rand *rand.Rand
 }
 
-// NewConsensusEngine creates a instance of ConsensusEngine.
-func NewConsensusEngine(privateKey *crypto.PrivateKey, db store.Store, chain *blockchain.Chain, network p2p.Network, validatorManager core.ValidatorManager) *ConsensusEngine {
+// NewConsensusEngine creates a instance of ConsensusEngine. The epochs are timed by the given clock.
+func NewConsensusEngine(privateKey *crypto.PrivateKey, db store.Store, chain *blockchain.Chain, network p2p.Network, validatorManager core.ValidatorManager, clock Clock) *ConsensusEngine {
 	e := &ConsensusEngine{
 		chain:   chain,
 		network: network,
@@ -67,11 +69,18 @@ func NewConsensusEngine(privateKey *crypto.PrivateKey, db store.Store, chain *bl
 
 		wg: &sync.WaitGroup{},
 
-		mu:    &sync.Mutex{},
-		state: NewState(db, chain),
+		mu: &sync.Mutex{},
 
 		validatorManager: validatorManager,
+
+		clock: clock,
+	}
+
+	var id string
+	if privateKey != nil {
+		id = e.ID()
 	}
+	e.state = NewStateWithClock(db, chain, id, clock)
 
 	logger = util.GetLoggerForModule("consensus")
 	if viper.GetBool(common.CfgLogPrintSelfID) {
@@ -81,7 +90,7 @@ func NewConsensusEngine(privateKey *crypto.PrivateKey, db store.Store, chain *bl
 
 	e.logger.WithFields(log.Fields{"state": e.state}).Info("Starting state")
 
-	e.rand = rand.New(rand.NewSource(time.Now().Unix()))
+	e.rand = rand.New(rand.NewSource(e.state.Now().Unix()))
 
 	return e
 }
@@ -156,7 +165,12 @@ func (e *ConsensusEngine) mainLoop() {
 				if endEpoch {
 					break Epoch
 				}
-			case <-e.epochTimer.C:
+			case <-e.epochTimer.C():
+				if !e.state.EpochTimedOut(e.maxEpochLength()) {
+					// The epoch is timed by the clock of the state, wait for the rest of it
+					e.epochTimer.Reset(e.state.EpochTimeRemaining(e.maxEpochLength()))
+					continue
+				}
 				e.logger.WithFields(log.Fields{"e.epoch": e.GetEpoch()}).Debug("Epoch timeout. Repeating epoch")
 				e.vote()
 				break Epoch
@@ -170,13 +184,18 @@ func (e *ConsensusEngine) enterEpoch() {
 	if e.epochTimer != nil {
 		e.epochTimer.Stop()
 	}
-	e.epochTimer = time.NewTimer(time.Duration(viper.GetInt(common.CfgConsensusMaxEpochLength)) * time.Second)
+	e.state.StartEpochTimer()
+	e.epochTimer = e.clock.NewTimer(e.maxEpochLength())
 
 	if e.shouldPropose(e.GetEpoch()) {
 		e.propose()
 	}
 }
 
+func (e *ConsensusEngine) maxEpochLength() time.Duration {
+	return time.Duration(viper.GetInt(common.CfgConsensusMaxEpochLength)) * time.Second
+}
+
 // GetChannelIDs implements the p2p.MessageHandler interface.
 func (e *ConsensusEngine) GetChannelIDs() []common.ChannelIDEnum {
 	return []common.ChannelIDEnum{
@@ -238,7 +257,7 @@ func (e *ConsensusEngine) handleBlock(block *core.Block) {
 		}).Error("Failed to find parent block")
 		return
 	}
-	result := e.ledger.ResetState(parent.Height, parent.StateHash)
+	result := e.resetLedgerState(parent)
 	if result.IsError() {
 		e.logger.WithFields(log.Fields{
 			"error":            result.Message,
@@ -246,7 +265,7 @@ func (e *ConsensusEngine) handleBlock(block *core.Block) {
 		}).Error("Failed to reset state to parent.StateHash")
 		return
 	}
-	result = e.ledger.ApplyBlockTxs(block.Txs, block.StateHash)
+	result = e.ledger.ApplyBlock(block)
 	if result.IsError() {
 		e.logger.WithFields(log.Fields{
 			"error":           result.String(),
@@ -260,6 +279,28 @@ func (e *ConsensusEngine) handleBlock(block *core.Block) {
 	e.vote()
 }
 
+// resetLedgerState resets the ledger state to the state of the given block. If the state root of
+// the block is not available, the ledger replays the blocks from the last finalized block.
+func (e *ConsensusEngine) resetLedgerState(block *core.ExtendedBlock) result.Result {
+	return e.ledger.ResetState(block.Height, block.StateHash, e.getBranch(block)...)
+}
+
+// getBranch returns the blocks from the last finalized block to the given block, ordered by height.
+func (e *ConsensusEngine) getBranch(block *core.ExtendedBlock) []*core.Block {
+	lastFinalizedHeight := e.state.GetLastFinalizedBlock().Height
+	branch := []*core.Block{block.Block}
+	curr := block
+	for curr.Height > lastFinalizedHeight {
+		parent, err := e.chain.FindBlock(curr.Parent)
+		if err != nil {
+			break
+		}
+		branch = append([]*core.Block{parent.Block}, branch...)
+		curr = parent
+	}
+	return branch
+}
+
 func (e *ConsensusEngine) vote() {
 	previousTip := e.state.GetTip()
 	tip := e.state.SetTip()
@@ -280,6 +321,21 @@ func (e *ConsensusEngine) vote() {
 		ID:    e.ID(),
 		Epoch: e.GetEpoch(),
 	}
+	if vote.Epoch < e.state.GetHighestParticipatedEpoch() {
+		e.logger.WithFields(log.Fields{
+			"vote.Epoch":               vote.Epoch,
+			"highestParticipatedEpoch": e.state.GetHighestParticipatedEpoch(),
+		}).Warn("Not voting since already participated in a higher epoch")
+		return
+	}
+	err := e.state.SetHighestParticipatedEpoch(vote.Epoch)
+	if err != nil {
+		e.logger.WithFields(log.Fields{"err": err}).Panic("Failed to save highest participated epoch")
+	}
+	if err := vote.Sign(e.privateKey); err != nil {
+		e.logger.WithFields(log.Fields{"err": err}).Error("Failed to sign vote")
+		return
+	}
 
 	e.logger.WithFields(log.Fields{"vote.block": vote.Block}).Debug("Sending vote")
 
@@ -328,6 +384,10 @@ func (e *ConsensusEngine) handleVote(vote core.Vote) (endEpoch bool) {
 
 	validators := e.validatorManager.GetValidatorSetForEpoch(0)
 	err := e.state.AddVote(&vote)
+	if err == ErrVoteEpochTooLow {
+		e.logger.WithFields(log.Fields{"vote": vote}).Warn("Ignoring own vote for an epoch lower than the highest participated epoch")
+		return
+	}
 	if err != nil {
 		e.logger.WithFields(log.Fields{"err": err}).Panic("Failed to add vote")
 	}
@@ -464,7 +524,7 @@ func (e *ConsensusEngine) shouldPropose(epoch uint64) bool {
 
 func (e *ConsensusEngine) propose() {
 	tip := e.GetTip()
-	result := e.ledger.ResetState(tip.Height, tip.StateHash)
+	result := e.resetLedgerState(tip)
 	if result.IsError() {
 		e.logger.WithFields(log.Fields{
 			"error":         result.Message,
@@ -478,7 +538,7 @@ func (e *ConsensusEngine) propose() {
 	block.Parent = tip.Hash()
 	block.Height = tip.Height + 1
 	block.Proposer = e.privateKey.PublicKey().Address()
-	block.Timestamp = big.NewInt(time.Now().Unix())
+	block.Timestamp = big.NewInt(e.state.Now().Unix())
 
 	newRoot, txs, result := e.ledger.ProposeBlockTxs()
 	if result.IsError() {
@@ -488,6 +548,11 @@ func (e *ConsensusEngine) propose() {
 	block.Txs = txs
 	block.StateHash = newRoot
 
+	err := e.state.SetHighestParticipatedEpoch(block.Epoch)
+	if err != nil {
+		e.logger.WithFields(log.Fields{"err": err}).Panic("Failed to save highest participated epoch")
+	}
+
 	lastCC := e.state.GetHighestCCBlock()
 	proposal := core.Proposal{Block: block, ProposerID: e.ID()}
 	if lastCC.CommitCertificate != nil {
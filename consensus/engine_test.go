@@ -0,0 +1,115 @@
+// +build unit
+
+package consensus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/blockchain"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
+	"github.com/thetatoken/ukulele/dispatcher"
+	p2psim "github.com/thetatoken/ukulele/p2p/simulation"
+	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
+	"github.com/thetatoken/ukulele/rlp"
+	"github.com/thetatoken/ukulele/store/database/backend"
+	"github.com/thetatoken/ukulele/store/kvstore"
+)
+
+// voteCollector collects the votes broadcast to its endpoint
+type voteCollector struct {
+	votes chan core.Vote
+}
+
+func (c *voteCollector) GetChannelIDs() []common.ChannelIDEnum {
+	return []common.ChannelIDEnum{common.ChannelIDVote}
+}
+
+func (c *voteCollector) ParseMessage(peerID string, channelID common.ChannelIDEnum, rawMessageBytes common.Bytes) (p2ptypes.Message, error) {
+	return p2ptypes.Message{}, nil
+}
+
+func (c *voteCollector) EncodeMessage(message interface{}) (common.Bytes, error) {
+	return nil, nil
+}
+
+func (c *voteCollector) HandleMessage(message p2ptypes.Message) error {
+	data, ok := message.Content.(dispatcher.DataResponse)
+	if !ok || data.ChannelID != common.ChannelIDVote {
+		return nil
+	}
+	vote := core.Vote{}
+	if err := rlp.DecodeBytes(data.Payload, &vote); err != nil {
+		return err
+	}
+	c.votes <- vote
+	return nil
+}
+
+func TestConsensusEngineEpochTimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	core.ResetTestBlocks()
+
+	maxEpochLength := viper.GetInt(common.CfgConsensusMaxEpochLength)
+	viper.Set(common.CfgConsensusMaxEpochLength, 10)
+	defer viper.Set(common.CfgConsensusMaxEpochLength, maxEpochLength)
+
+	privKey, _, err := crypto.TEST_GenerateKeyPairWithSeed("engine")
+	require.Nil(err)
+	_, proposerPubKey, err := crypto.TEST_GenerateKeyPairWithSeed("proposer")
+	require.Nil(err)
+	// The engine is not the proposer, so it only votes on the epoch timeout
+	validators := core.NewValidatorSet()
+	validators.AddValidator(core.NewValidator(proposerPubKey.ToBytes(), 1))
+
+	simnet := p2psim.NewSimnet()
+	network := simnet.AddEndpoint("node")
+	collector := &voteCollector{votes: make(chan core.Vote, 16)}
+	simnet.AddEndpoint("peer").RegisterMessageHandler(collector)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	simnet.Start(ctx)
+
+	chain := blockchain.CreateTestChainByBlocks([]string{"A1", "A0"})
+	db := kvstore.NewKVStore(backend.NewMemDatabase())
+	clock := NewMockClock(time.Unix(1000, 0))
+	engine := NewConsensusEngine(privKey, db, chain, network, NewFixedValidatorManager(validators), clock)
+	engine.Start(ctx)
+	defer engine.Stop()
+
+	// The engine does not vote until the epoch times out on its clock
+	select {
+	case vote := <-collector.votes:
+		assert.Fail("Unexpected vote before the epoch timeout", "%v", vote)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// The engine has entered the epoch, and started its timer
+	clock.mu.Lock()
+	numTimers := len(clock.timers)
+	clock.mu.Unlock()
+	require.Equal(1, numTimers)
+
+	clock.Advance(9 * time.Second)
+	select {
+	case vote := <-collector.votes:
+		assert.Fail("Unexpected vote before the epoch timeout", "%v", vote)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case vote := <-collector.votes:
+		assert.Nil(vote.Block)
+		assert.Nil(vote.Verify(privKey.PublicKey()))
+	case <-time.After(5 * time.Second):
+		assert.Fail("No vote after the epoch timeout")
+	}
+}
@@ -1,7 +1,9 @@
 package consensus
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/thetatoken/ukulele/blockchain"
@@ -14,8 +16,11 @@ type StateStub struct {
 	Root               common.Hash
 	HighestCCBlock     common.Hash
 	LastFinalizedBlock common.Hash
+	Tip                common.Hash
 	LastVoteHeight     uint64
 	Epoch              uint64
+
+	HighestParticipatedEpoch uint64
 }
 
 const (
@@ -23,27 +28,58 @@ const (
 	DBVoteByHeightPrefix = "cs/vbh/"
 	DBVoteByBlockPrefix  = "cs/vbb/"
 	DBEpochVotesKey      = "cs/ev"
+	DBVotedBlocksKey     = "cs/vb"
 )
 
+// ErrRollbackBelowFinalized is returned when the consensus state is asked to roll back to a block
+// that does not descend from the last finalized block.
+var ErrRollbackBelowFinalized = errors.New("RollbackBelowFinalized")
+
+// EpochChangeCallback is invoked when the epoch of the consensus state increases
+type EpochChangeCallback func(oldEpoch, newEpoch uint64)
+
+// ErrVoteEpochTooLow is returned when a vote of the node is for an epoch lower than the highest
+// epoch the node has participated in.
+var ErrVoteEpochTooLow = errors.New("VoteEpochTooLow")
+
 type State struct {
 	db    store.Store
 	chain *blockchain.Chain
+	id    string
 
 	highestCCBlock     *core.ExtendedBlock
 	lastFinalizedBlock *core.ExtendedBlock
 	tip                *core.ExtendedBlock
 	lastVoteHeight     uint64
 	epoch              uint64
+
+	// highestParticipatedEpoch is the highest epoch the node has voted or proposed in.
+	highestParticipatedEpoch uint64
+
+	epochChangeCallbacks []EpochChangeCallback
+
+	clock          Clock
+	epochStartTime time.Time
+}
+
+// NewState creates the consensus state of the node with the given ID, which reads the system time.
+func NewState(db store.Store, chain *blockchain.Chain, id string) *State {
+	return NewStateWithClock(db, chain, id, &RealClock{})
 }
 
-func NewState(db store.Store, chain *blockchain.Chain) *State {
+// NewStateWithClock creates the consensus state of the node with the given ID, which reads the time
+// from the given clock.
+func NewStateWithClock(db store.Store, chain *blockchain.Chain, id string, clock Clock) *State {
 	s := &State{
 		db:                 db,
 		chain:              chain,
+		id:                 id,
 		highestCCBlock:     chain.Root,
 		lastFinalizedBlock: chain.Root,
 		tip:                chain.Root,
 		epoch:              chain.Root.Epoch,
+		clock:              clock,
+		epochStartTime:     clock.Now(),
 	}
 	err := s.Load()
 	if err != nil {
@@ -67,8 +103,8 @@ func (s *State) String() string {
 	if s.tip != nil {
 		tipStr = s.tip.Hash().Hex()
 	}
-	return fmt.Sprintf("State{highestCCBlock: %v, lastFinalizedBlock: %v, tip: %v, lastVoteHeight: %d, epoch: %d}",
-		highestCCBlockStr, lastFinalizedBlockStr, tipStr, s.lastVoteHeight, s.epoch)
+	return fmt.Sprintf("State{highestCCBlock: %v, lastFinalizedBlock: %v, tip: %v, lastVoteHeight: %d, epoch: %d, highestParticipatedEpoch: %d}",
+		highestCCBlockStr, lastFinalizedBlockStr, tipStr, s.lastVoteHeight, s.epoch, s.highestParticipatedEpoch)
 }
 
 func (s *State) commit() error {
@@ -76,6 +112,8 @@ func (s *State) commit() error {
 		LastVoteHeight: s.lastVoteHeight,
 		Epoch:          s.epoch,
 		Root:           s.chain.Root.Hash(),
+
+		HighestParticipatedEpoch: s.highestParticipatedEpoch,
 	}
 	if s.highestCCBlock != nil {
 		stub.HighestCCBlock = s.highestCCBlock.Hash()
@@ -83,6 +121,9 @@ func (s *State) commit() error {
 	if s.lastFinalizedBlock != nil {
 		stub.LastFinalizedBlock = s.lastFinalizedBlock.Hash()
 	}
+	if s.tip != nil {
+		stub.Tip = s.tip.Hash()
+	}
 	key := []byte(DBStateStubKey)
 
 	return s.db.Put(key, stub)
@@ -104,6 +145,7 @@ func (s *State) Load() (err error) {
 
 	s.lastVoteHeight = stub.LastVoteHeight
 	s.epoch = stub.Epoch
+	s.highestParticipatedEpoch = stub.HighestParticipatedEpoch
 	if !stub.LastFinalizedBlock.IsEmpty() {
 		lastFinalizedBlock, err := s.chain.FindBlock(stub.LastFinalizedBlock)
 		if err == nil {
@@ -116,6 +158,13 @@ func (s *State) Load() (err error) {
 			s.highestCCBlock = highestCCBlock
 		}
 	}
+	// Restore the persisted tip, which may have been set by a rollback rather than by the fork-choice rule
+	if !stub.Tip.IsEmpty() {
+		if tip, err := s.chain.FindBlock(stub.Tip); err == nil {
+			s.tip = tip
+			return nil
+		}
+	}
 	s.SetTip()
 	return
 }
@@ -124,8 +173,64 @@ func (s *State) GetEpoch() uint64 {
 	return s.epoch
 }
 
+// SetEpoch sets and persists the epoch. If the epoch increases, the epoch change callbacks are
+// invoked synchronously after the new epoch has been persisted.
 func (s *State) SetEpoch(epoch uint64) error {
+	oldEpoch := s.epoch
 	s.epoch = epoch
+	if err := s.commit(); err != nil {
+		return err
+	}
+	if epoch > oldEpoch {
+		for _, callback := range s.epochChangeCallbacks {
+			callback(oldEpoch, epoch)
+		}
+	}
+	return nil
+}
+
+// OnEpochChange registers a callback to be invoked whenever the epoch increases
+func (s *State) OnEpochChange(callback EpochChangeCallback) {
+	s.epochChangeCallbacks = append(s.epochChangeCallbacks, callback)
+}
+
+// Now returns the current time of the clock of the state.
+func (s *State) Now() time.Time {
+	return s.clock.Now()
+}
+
+// StartEpochTimer records the current time as the start of the epoch, from which the epoch
+// timeout is measured.
+func (s *State) StartEpochTimer() {
+	s.epochStartTime = s.clock.Now()
+}
+
+// EpochTimeRemaining returns the time left before the current epoch times out, given the maximum
+// epoch length. It is zero once the epoch has timed out.
+func (s *State) EpochTimeRemaining(maxEpochLength time.Duration) time.Duration {
+	remaining := maxEpochLength - s.clock.Now().Sub(s.epochStartTime)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// EpochTimedOut returns whether the current epoch has lasted for the maximum epoch length.
+func (s *State) EpochTimedOut(maxEpochLength time.Duration) bool {
+	return s.EpochTimeRemaining(maxEpochLength) == 0
+}
+
+func (s *State) GetHighestParticipatedEpoch() uint64 {
+	return s.highestParticipatedEpoch
+}
+
+// SetHighestParticipatedEpoch records that the node has voted or proposed in the given epoch.
+// The watermark never decreases.
+func (s *State) SetHighestParticipatedEpoch(epoch uint64) error {
+	if epoch <= s.highestParticipatedEpoch {
+		return nil
+	}
+	s.highestParticipatedEpoch = epoch
 	return s.commit()
 }
 
@@ -151,9 +256,14 @@ func (s *State) GetLastFinalizedBlock() *core.ExtendedBlock {
 	return s.lastFinalizedBlock
 }
 
+// SetLastFinalizedBlock sets and persists the last finalized block. The votes for the epochs lower
+// than the epoch of the block are no longer needed, and are pruned to bound the memory usage.
 func (s *State) SetLastFinalizedBlock(block *core.ExtendedBlock) error {
 	s.lastFinalizedBlock = block
-	return s.commit()
+	if err := s.commit(); err != nil {
+		return err
+	}
+	return s.PruneVotesBeforeEpoch(block.Epoch)
 }
 
 // SetTip sets the block to extended from by next proposal. Currently we use the highest block among highestCCBlock's
@@ -169,7 +279,50 @@ func (s *State) GetTip() *core.ExtendedBlock {
 	return s.tip
 }
 
+// Rollback resets the consensus state to the given block when a competing branch wins. The tip
+// is set to the block, and the highest CC block is reset to the closest ancestor of the block with
+// a commit certificate unless it is already an ancestor of the block. The block has to descend from
+// the last finalized block, which stays unchanged, since finalized blocks cannot be rolled back.
+func (s *State) Rollback(toBlock *core.ExtendedBlock) error {
+	lastFinalizedHash := s.lastFinalizedBlock.Hash()
+	highestCCBlockIsAncestor := false
+	var closestCCBlock *core.ExtendedBlock
+
+	block := toBlock
+	for {
+		if s.highestCCBlock != nil && block.Hash() == s.highestCCBlock.Hash() {
+			highestCCBlockIsAncestor = true
+		}
+		if closestCCBlock == nil && block.CommitCertificate != nil {
+			closestCCBlock = block
+		}
+		if block.Hash() == lastFinalizedHash {
+			break
+		}
+		parent, err := s.chain.FindBlock(block.Parent)
+		if err != nil {
+			return ErrRollbackBelowFinalized
+		}
+		block = parent
+	}
+
+	if !highestCCBlockIsAncestor {
+		if closestCCBlock == nil {
+			closestCCBlock = s.lastFinalizedBlock
+		}
+		s.highestCCBlock = closestCCBlock
+	}
+	s.tip = toBlock
+	return s.commit()
+}
+
+// AddVote records the vote. A vote of the node for an epoch lower than the highest epoch the
+// node has participated in is refused, since the node may have voted differently in that epoch
+// before a restart.
 func (s *State) AddVote(vote *core.Vote) error {
+	if vote.ID == s.id && vote.Epoch < s.highestParticipatedEpoch {
+		return ErrVoteEpochTooLow
+	}
 	if err := s.AddEpochVote(vote); err != nil {
 		return err
 	}
@@ -220,10 +373,43 @@ func (s *State) AddVoteByBlock(vote *core.Vote) error {
 		voteset = core.NewVoteSet()
 	}
 	voteset.AddVote(*vote)
+	if err := s.addVotedBlock(vote.Block.Height, hash); err != nil {
+		return err
+	}
 	key := append([]byte(DBVoteByBlockPrefix), hash[:]...)
 	return s.db.Put(key, voteset)
 }
 
+// votedBlock locates the vote sets by height and by block of a block voted for, so that they can
+// be pruned along with the epoch votes
+type votedBlock struct {
+	Height uint64
+	Hash   common.Hash
+}
+
+func (s *State) getVotedBlocks() ([]votedBlock, error) {
+	votedBlocks := []votedBlock{}
+	err := s.db.Get([]byte(DBVotedBlocksKey), &votedBlocks)
+	if err == store.ErrKeyNotFound {
+		return votedBlocks, nil
+	}
+	return votedBlocks, err
+}
+
+func (s *State) addVotedBlock(height uint64, hash common.Hash) error {
+	votedBlocks, err := s.getVotedBlocks()
+	if err != nil {
+		return err
+	}
+	for _, block := range votedBlocks {
+		if block.Hash == hash {
+			return nil
+		}
+	}
+	votedBlocks = append(votedBlocks, votedBlock{Height: height, Hash: hash})
+	return s.db.Put([]byte(DBVotedBlocksKey), votedBlocks)
+}
+
 func (s *State) GetEpochVotes() (*core.VoteSet, error) {
 	key := []byte(DBEpochVotesKey)
 	ret := core.NewVoteSet()
@@ -240,3 +426,57 @@ func (s *State) AddEpochVote(vote *core.Vote) error {
 	key := []byte(DBEpochVotesKey)
 	return s.db.Put(key, voteset)
 }
+
+// PruneVotesBeforeEpoch drops the persisted votes for the epochs lower than the given epoch, from
+// the epoch votes as well as the vote sets by height and by block.
+func (s *State) PruneVotesBeforeEpoch(epoch uint64) error {
+	voteset, err := s.GetEpochVotes()
+	if err == nil {
+		voteset.PruneBeforeEpoch(epoch)
+		err = s.db.Put([]byte(DBEpochVotesKey), voteset)
+	}
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
+	}
+
+	votedBlocks, err := s.getVotedBlocks()
+	if err != nil {
+		return err
+	}
+	remaining := []votedBlock{}
+	heights := make(map[uint64]bool)
+	for _, block := range votedBlocks {
+		heights[block.Height] = true
+		empty, err := s.pruneVoteSet(append([]byte(DBVoteByBlockPrefix), block.Hash[:]...), epoch)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			remaining = append(remaining, block)
+		}
+	}
+	for height := range heights {
+		if _, err := s.pruneVoteSet([]byte(fmt.Sprintf("%s:%d", DBVoteByHeightPrefix, height)), epoch); err != nil {
+			return err
+		}
+	}
+	return s.db.Put([]byte(DBVotedBlocksKey), remaining)
+}
+
+// pruneVoteSet drops the votes for the epochs lower than the given epoch from the vote set stored
+// under the key, and deletes the vote set if no vote is left.
+func (s *State) pruneVoteSet(key common.Bytes, epoch uint64) (empty bool, err error) {
+	voteset := core.NewVoteSet()
+	err = s.db.Get(key, voteset)
+	if err == store.ErrKeyNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	voteset.PruneBeforeEpoch(epoch)
+	if voteset.Size() == 0 {
+		return true, s.db.Delete(key)
+	}
+	return false, s.db.Put(key, voteset)
+}
@@ -4,10 +4,13 @@ package consensus
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/thetatoken/ukulele/blockchain"
 	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/store"
 	"github.com/thetatoken/ukulele/store/database/backend"
 	"github.com/thetatoken/ukulele/store/kvstore"
 )
@@ -23,12 +26,12 @@ func TestConsensusStateBasic(t *testing.T) {
 	})
 	cc, _ := chain.FindBlock(core.GetTestBlock("A1").Hash())
 
-	state1 := NewState(db, chain)
+	state1 := NewState(db, chain, "")
 	state1.SetEpoch(3)
 	state1.SetLastVoteHeight(10)
 	state1.SetHighestCCBlock(cc)
 
-	state2 := NewState(db, chain)
+	state2 := NewState(db, chain, "")
 	state2.Load()
 	assert.Equal(uint64(3), state2.GetEpoch())
 	assert.Equal(uint64(10), state2.GetLastVoteHeight())
@@ -40,6 +43,89 @@ func TestConsensusStateBasic(t *testing.T) {
 	assert.Equal(core.GetTestBlock("A0").Hash(), state2.GetLastFinalizedBlock().Hash())
 }
 
+func TestConsensusStateOnEpochChange(t *testing.T) {
+	assert := assert.New(t)
+
+	db := kvstore.NewKVStore(backend.NewMemDatabase())
+	chain := blockchain.CreateTestChainByBlocks([]string{
+		"A1", "A0",
+	})
+	state := NewState(db, chain, "")
+	state.SetEpoch(1)
+
+	type epochChange struct{ oldEpoch, newEpoch uint64 }
+	changes := []epochChange{}
+	state.OnEpochChange(func(oldEpoch, newEpoch uint64) {
+		// The new epoch is visible to the listeners, and has been persisted
+		assert.Equal(newEpoch, state.GetEpoch())
+		reloaded := NewState(db, chain, "")
+		assert.Equal(newEpoch, reloaded.GetEpoch())
+
+		changes = append(changes, epochChange{oldEpoch, newEpoch})
+	})
+
+	state.SetEpoch(2)
+	state.SetEpoch(2) // not an increase
+	state.SetEpoch(5)
+	state.SetEpoch(4) // not an increase
+
+	assert.Equal([]epochChange{{1, 2}, {2, 5}}, changes)
+}
+
+func TestConsensusStateRollback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	core.ResetTestBlocks()
+
+	db := kvstore.NewKVStore(backend.NewMemDatabase())
+	chain := blockchain.CreateTestChainByBlocks([]string{
+		"A1", "A0",
+		"A2", "A1",
+		"A3", "A2",
+		"B2", "A1",
+		"B3", "B2",
+	})
+	findBlock := func(name string) *core.ExtendedBlock {
+		block, err := chain.FindBlock(core.GetTestBlock(name).Hash())
+		require.Nil(err)
+		return block
+	}
+
+	// B2 has a commit certificate
+	b2 := findBlock("B2")
+	b2.CommitCertificate = &core.CommitCertificate{BlockHash: b2.Hash()}
+	require.Nil(chain.SaveBlock(b2))
+
+	state := NewState(db, chain, "")
+	require.Nil(state.SetLastFinalizedBlock(findBlock("A1")))
+	require.Nil(state.SetHighestCCBlock(findBlock("A2")))
+	state.SetTip()
+	require.Equal(findBlock("A3").Hash(), state.GetTip().Hash())
+
+	// Roll back to the competing branch
+	err := state.Rollback(findBlock("B3"))
+	require.Nil(err)
+	assert.Equal(findBlock("B3").Hash(), state.GetTip().Hash())
+	assert.Equal(b2.Hash(), state.GetHighestCCBlock().Hash())
+	assert.Equal(findBlock("A1").Hash(), state.GetLastFinalizedBlock().Hash())
+
+	// The change is persisted
+	reloaded := NewState(db, chain, "")
+	assert.Equal(b2.Hash(), reloaded.GetHighestCCBlock().Hash())
+	assert.Equal(findBlock("B3").Hash(), reloaded.GetTip().Hash())
+
+	// The tip survives a restart even if it is not the deepest descendant of the highest CC block
+	require.Nil(state.Rollback(b2))
+	reloaded = NewState(db, chain, "")
+	assert.Equal(b2.Hash(), reloaded.GetTip().Hash())
+
+	// Cannot roll back below the last finalized block
+	err = state.Rollback(findBlock("A0"))
+	assert.Equal(ErrRollbackBelowFinalized, err)
+	assert.Equal(b2.Hash(), state.GetTip().Hash())
+	assert.Equal(b2.Hash(), state.GetHighestCCBlock().Hash())
+}
+
 func TestConsensusStateVoteSet(t *testing.T) {
 	assert := assert.New(t)
 
@@ -51,7 +137,7 @@ func TestConsensusStateVoteSet(t *testing.T) {
 	block1 := core.CreateTestBlock("A1", "A0")
 	block2 := core.CreateTestBlock("A2", "A1")
 
-	state1 := NewState(db, chain)
+	state1 := NewState(db, chain, "")
 	vote1 := &core.Vote{
 		Block: block1.BlockHeader,
 		ID:    "Alice",
@@ -71,7 +157,7 @@ func TestConsensusStateVoteSet(t *testing.T) {
 	state1.AddVote(vote2)
 	state1.AddVote(vote3)
 
-	state2 := NewState(db, chain)
+	state2 := NewState(db, chain, "")
 	state2.Load()
 	vs1, _ := state2.GetEpochVotes()
 	votes := vs1.Votes()
@@ -79,3 +165,123 @@ func TestConsensusStateVoteSet(t *testing.T) {
 	assert.Equal("Alice", votes[0].ID)
 	assert.Equal(uint64(20), votes[0].Epoch)
 }
+
+func TestConsensusStatePruneVotes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	db := kvstore.NewKVStore(backend.NewMemDatabase())
+	chain := blockchain.CreateTestChainByBlocks([]string{
+		"A1", "A0",
+		"A2", "A1",
+	})
+	block1 := core.CreateTestBlock("A1", "A0")
+
+	state := NewState(db, chain, "")
+	for epoch, id := range []string{"Alice", "Bob", "Carol", "Dave"} {
+		require.Nil(state.AddVote(&core.Vote{Block: block1.BlockHeader, ID: id, Epoch: uint64(epoch + 10)}))
+	}
+	require.Nil(state.PruneVotesBeforeEpoch(12))
+
+	// The pruning is persisted
+	reloaded := NewState(db, chain, "")
+	vs, err := reloaded.GetEpochVotes()
+	require.Nil(err)
+	votes := vs.Votes()
+	require.Equal(2, len(votes))
+	assert.Equal("Carol", votes[0].ID)
+	assert.Equal("Dave", votes[1].ID)
+	vs, err = reloaded.GetVoteSetByBlock(block1.Hash())
+	require.Nil(err)
+	assert.Equal(2, vs.Size())
+	vs, err = reloaded.GetVoteSetByHeight(block1.Height)
+	require.Nil(err)
+	assert.Equal(2, vs.Size())
+
+	// The votes before the epoch of the finalized block are pruned
+	block2 := core.CreateTestBlock("A2", "A1")
+	block2.Epoch = 13
+	require.Nil(reloaded.SetLastFinalizedBlock(&core.ExtendedBlock{Block: block2}))
+	vs, err = reloaded.GetEpochVotes()
+	require.Nil(err)
+	votes = vs.Votes()
+	require.Equal(1, len(votes))
+	assert.Equal("Dave", votes[0].ID)
+
+	// The vote sets by height and by block are dropped once all their votes are pruned
+	require.Nil(reloaded.PruneVotesBeforeEpoch(20))
+	_, err = reloaded.GetVoteSetByBlock(block1.Hash())
+	assert.Equal(store.ErrKeyNotFound, err)
+	_, err = reloaded.GetVoteSetByHeight(block1.Height)
+	assert.Equal(store.ErrKeyNotFound, err)
+	votedBlocks, err := reloaded.getVotedBlocks()
+	require.Nil(err)
+	assert.Equal(0, len(votedBlocks))
+}
+
+func TestConsensusStateHighestParticipatedEpoch(t *testing.T) {
+	assert := assert.New(t)
+
+	db := kvstore.NewKVStore(backend.NewMemDatabase())
+	chain := blockchain.CreateTestChainByBlocks([]string{
+		"A1", "A0",
+		"A2", "A1",
+	})
+	block1 := core.CreateTestBlock("A1", "A0")
+	block2 := core.CreateTestBlock("A2", "A1")
+
+	state1 := NewState(db, chain, "Alice")
+	assert.Nil(state1.SetHighestParticipatedEpoch(20))
+	assert.Nil(state1.SetHighestParticipatedEpoch(10))
+	assert.Equal(uint64(20), state1.GetHighestParticipatedEpoch())
+
+	// The watermark survives a restart
+	state2 := NewState(db, chain, "Alice")
+	assert.Equal(uint64(20), state2.GetHighestParticipatedEpoch())
+
+	// Own votes for a lower epoch are refused
+	err := state2.AddVote(&core.Vote{Block: block1.BlockHeader, ID: "Alice", Epoch: 13})
+	assert.Equal(ErrVoteEpochTooLow, err)
+	vs, _ := state2.GetVoteSetByBlock(block1.Hash())
+	assert.Equal(0, len(vs.Votes()))
+
+	// Votes of other nodes and own votes for the current epoch are recorded
+	assert.Nil(state2.AddVote(&core.Vote{Block: block1.BlockHeader, ID: "Bob", Epoch: 13}))
+	assert.Nil(state2.AddVote(&core.Vote{Block: block2.BlockHeader, ID: "Alice", Epoch: 20}))
+	vs, _ = state2.GetEpochVotes()
+	assert.Equal(2, len(vs.Votes()))
+}
+
+func TestConsensusStateEpochTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	db := kvstore.NewKVStore(backend.NewMemDatabase())
+	chain := blockchain.CreateTestChainByBlocks([]string{
+		"A1", "A0",
+	})
+	start := time.Unix(1000, 0)
+	clock := NewMockClock(start)
+	state := NewStateWithClock(db, chain, "", clock)
+	assert.Equal(start, state.Now())
+
+	maxEpochLength := 10 * time.Second
+	state.StartEpochTimer()
+	assert.False(state.EpochTimedOut(maxEpochLength))
+	assert.Equal(maxEpochLength, state.EpochTimeRemaining(maxEpochLength))
+
+	clock.Advance(9 * time.Second)
+	assert.False(state.EpochTimedOut(maxEpochLength))
+	assert.Equal(time.Second, state.EpochTimeRemaining(maxEpochLength))
+
+	clock.Advance(time.Second)
+	assert.True(state.EpochTimedOut(maxEpochLength))
+	assert.Equal(time.Duration(0), state.EpochTimeRemaining(maxEpochLength))
+
+	// Entering a new epoch restarts the timeout
+	state.SetEpoch(1)
+	state.StartEpochTimer()
+	assert.False(state.EpochTimedOut(maxEpochLength))
+	clock.Advance(15 * time.Second)
+	assert.True(state.EpochTimedOut(maxEpochLength))
+	assert.Equal(time.Duration(0), state.EpochTimeRemaining(maxEpochLength))
+}
@@ -1,7 +1,8 @@
 package consensus
 
 import (
-	"math/rand"
+	"fmt"
+	"sync"
 
 	"github.com/thetatoken/ukulele/core"
 )
@@ -31,6 +32,23 @@ func (m *FixedValidatorManager) GetProposerForEpoch(epoch uint64) core.Validator
 	return m.validators.Validators()[0]
 }
 
+// GetProposerProofForEpoch implements ValidatorManager interface. The fixed proposer does not depend
+// on the draw, which is always zero.
+func (m *FixedValidatorManager) GetProposerProofForEpoch(epoch uint64) core.ProposerProof {
+	return core.ProposerProof{Epoch: epoch, Seed: core.ProposerSeed(epoch)}
+}
+
+// VerifyProposerProof implements ValidatorManager interface.
+func (m *FixedValidatorManager) VerifyProposerProof(proposer core.Validator, proof core.ProposerProof) error {
+	if proof != m.GetProposerProofForEpoch(proof.Epoch) {
+		return fmt.Errorf("Invalid proposer proof for epoch %v", proof.Epoch)
+	}
+	if expected := m.GetProposerForEpoch(proof.Epoch); proposer.ID() != expected.ID() {
+		return fmt.Errorf("Validator %v is not the proposer, expected: %v", proposer.ID(), expected.ID())
+	}
+	return nil
+}
+
 // GetValidatorSetForEpoch returns the validator set for given epoch.
 func (m *FixedValidatorManager) GetValidatorSetForEpoch(_ uint64) *core.ValidatorSet {
 	return m.validators
@@ -40,56 +58,85 @@ func (m *FixedValidatorManager) GetValidatorSetForEpoch(_ uint64) *core.Validato
 // -------------------------------- RotatingValidatorManager ----------------------------------
 //
 var _ core.ValidatorManager = &RotatingValidatorManager{}
+var _ core.ValidatorSetUpdater = &RotatingValidatorManager{}
 
 // RotatingValidatorManager is an implementation of ValidatorManager interface that selects a random validator as
 // the proposer using validator's stake as weight.
 type RotatingValidatorManager struct {
+	mu         *sync.RWMutex
+	validators *core.ValidatorSet   // Validator set effective from epoch 0
+	updates    []validatorSetUpdate // Validator sets effective from later epochs, sorted by epoch
+}
+
+// validatorSetUpdate is a validator set effective from the given epoch onward
+type validatorSetUpdate struct {
+	epoch      uint64
 	validators *core.ValidatorSet
 }
 
 // NewRotatingValidatorManager creates an instance of RotatingValidatorManager.
 func NewRotatingValidatorManager(validators *core.ValidatorSet) *RotatingValidatorManager {
-	m := &RotatingValidatorManager{}
+	m := &RotatingValidatorManager{
+		mu: &sync.RWMutex{},
+	}
 	m.validators = validators.Copy()
 	return m
 }
 
-// Generate a random uint64 in [0, max)
-func randUint64(rnd *rand.Rand, max uint64) uint64 {
-	const maxInt64 uint64 = 1<<63 - 1
-	if max <= maxInt64 {
-		return uint64(rnd.Int63n(int64(max)))
-	}
-	for {
-		r := rnd.Uint64()
-		if r < max {
-			return r
+// SetValidatorSetForEpoch implements ValidatorSetUpdater interface. It overrides the validator sets
+// previously set for the given epoch or later.
+func (m *RotatingValidatorManager) SetValidatorSetForEpoch(epoch uint64, validators *core.ValidatorSet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	updates := m.updates[:0]
+	for _, update := range m.updates {
+		if update.epoch < epoch {
+			updates = append(updates, update)
 		}
 	}
+	m.updates = append(updates, validatorSetUpdate{epoch: epoch, validators: validators.Copy()})
 }
 
 // GetProposerForEpoch implements ValidatorManager interface.
 func (m *RotatingValidatorManager) GetProposerForEpoch(epoch uint64) core.Validator {
-	if m.validators.Size() == 0 {
+	proposer, _ := m.selectProposer(epoch)
+	return proposer
+}
+
+// GetProposerProofForEpoch implements ValidatorManager interface.
+func (m *RotatingValidatorManager) GetProposerProofForEpoch(epoch uint64) core.ProposerProof {
+	_, proof := m.selectProposer(epoch)
+	return proof
+}
+
+// VerifyProposerProof implements ValidatorManager interface.
+func (m *RotatingValidatorManager) VerifyProposerProof(proposer core.Validator, proof core.ProposerProof) error {
+	return core.VerifyProposerProof(m.GetValidatorSetForEpoch(proof.Epoch), proposer, proof)
+}
+
+func (m *RotatingValidatorManager) selectProposer(epoch uint64) (core.Validator, core.ProposerProof) {
+	validators := m.GetValidatorSetForEpoch(epoch)
+	if validators.Size() == 0 {
 		panic("No validators have been added")
 	}
 	// TODO: replace with more secure randomness.
-	rnd := rand.New(rand.NewSource(int64(epoch)))
-	totalStake := m.validators.TotalStake()
-	r := randUint64(rnd, totalStake)
-	curr := uint64(0)
-	validators := m.validators.Validators()
-	for _, v := range validators {
-		curr += v.Stake()
-		if r < curr {
-			return v
-		}
+	proposer, proof, err := core.SelectProposer(validators, epoch)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to randomly select a validator: %v", err))
 	}
-	// Should not reach here.
-	panic("Failed to randomly select a validator")
+	return proposer, proof
 }
 
 // GetValidatorSetForEpoch returns the validator set for given epoch.
-func (m *RotatingValidatorManager) GetValidatorSetForEpoch(_ uint64) *core.ValidatorSet {
+func (m *RotatingValidatorManager) GetValidatorSetForEpoch(epoch uint64) *core.ValidatorSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := len(m.updates) - 1; i >= 0; i-- {
+		if m.updates[i].epoch <= epoch {
+			return m.updates[i].validators
+		}
+	}
 	return m.validators
 }
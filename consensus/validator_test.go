@@ -0,0 +1,40 @@
+package consensus
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+func TestValidatorManagerProposerProof(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	validatorSet := core.NewValidatorSet()
+	for i, stake := range []uint64{100, 200, 300} {
+		_, pubKey, err := crypto.TEST_GenerateKeyPairWithSeed(fmt.Sprintf("val%v", i))
+		require.Nil(err)
+		validatorSet.AddValidator(core.NewValidator(pubKey.ToBytes(), stake))
+	}
+
+	for _, m := range []core.ValidatorManager{
+		NewFixedValidatorManager(validatorSet),
+		NewRotatingValidatorManager(validatorSet),
+	} {
+		for epoch := uint64(0); epoch < 10; epoch++ {
+			proposer := m.GetProposerForEpoch(epoch)
+			proof := m.GetProposerProofForEpoch(epoch)
+			assert.Nil(m.VerifyProposerProof(proposer, proof))
+
+			for _, v := range validatorSet.Validators() {
+				if v.ID() != proposer.ID() {
+					assert.NotNil(m.VerifyProposerProof(v, proof))
+				}
+			}
+		}
+	}
+}
@@ -14,6 +14,15 @@ import (
 const (
 	// MaxNumRegularTxsPerBlock represents the max number of regular transaction can be inclulded in one block
 	MaxNumRegularTxsPerBlock int = 100
+
+	// MaxBlockGas represents the max amount of gas the smart contract transactions in one block can consume
+	MaxBlockGas uint64 = 20000000
+
+	// MaxTxDataBytes represents the max size of the arbitrary data a transaction can carry
+	MaxTxDataBytes int = 64 * 1024
+
+	// MaxReorgDepth represents the max number of blocks a reset of the ledger state can roll back or replay
+	MaxReorgDepth uint64 = 1000
 )
 
 // Block represents a block in chain.
@@ -17,5 +17,14 @@ type ConsensusEngine interface {
 // ValidatorManager is the component for managing validator related logic for consensus engine.
 type ValidatorManager interface {
 	GetProposerForEpoch(epoch uint64) Validator
+	GetProposerProofForEpoch(epoch uint64) ProposerProof
+	VerifyProposerProof(proposer Validator, proof ProposerProof) error
 	GetValidatorSetForEpoch(epoch uint64) *ValidatorSet
 }
+
+// ValidatorSetUpdater is implemented by the validator managers whose validator set can change
+// across epochs
+type ValidatorSetUpdater interface {
+	// SetValidatorSetForEpoch sets the validator set effective from the given epoch onward
+	SetValidatorSetForEpoch(epoch uint64, validators *ValidatorSet)
+}
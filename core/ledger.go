@@ -19,7 +19,7 @@ const (
 type Ledger interface {
 	ScreenTx(rawTx common.Bytes) result.Result
 	ProposeBlockTxs() (stateRootHash common.Hash, blockRawTxs []common.Bytes, res result.Result)
-	ApplyBlockTxs(blockRawTxs []common.Bytes, expectedStateRoot common.Hash) result.Result
-	ResetState(height uint64, rootHash common.Hash) result.Result
+	ApplyBlock(block *Block) result.Result
+	ResetState(height uint64, rootHash common.Hash, blocks ...*Block) result.Result
 	FinalizeState(height uint64, rootHash common.Hash) result.Result
 }
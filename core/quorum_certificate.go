@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+var (
+	// ErrNoQuorum is returned when the voters of a block do not hold more than 2/3 of the stake.
+	ErrNoQuorum = errors.New("NoQuorum")
+
+	// ErrInvalidSigners is returned when the signers of a quorum certificate are duplicated or
+	// not sorted.
+	ErrInvalidSigners = errors.New("InvalidSigners")
+
+	// ErrInvalidVoteSignature is returned when a vote is not signed by its voter.
+	ErrInvalidVoteSignature = errors.New("InvalidVoteSignature")
+)
+
+// VoteSignature is the signature of a vote for the block of a quorum certificate.
+type VoteSignature struct {
+	ID        string
+	Epoch     uint64
+	Signature *crypto.Signature
+}
+
+// QuorumCertificate is a compact proof that a quorum of the validators voted for a block. It
+// records the signatures of the votes for the block, without the block headers, so each vote
+// can be verified against the key of its voter and the block hash.
+type QuorumCertificate struct {
+	BlockHash common.Hash
+	Votes     []VoteSignature // Signatures of the validators who voted for the block, sorted by ID
+}
+
+// BuildQuorumCertificate aggregates the votes for the given block into a quorum certificate. The
+// votes for other blocks, the votes of the equivocating voters, the votes of the voters not in
+// the validator set, and the votes not signed by their voters are left out. It returns
+// ErrNoQuorum if the remaining votes do not reach a quorum.
+func BuildQuorumCertificate(votes *VoteSet, validatorSet *ValidatorSet, blockHash common.Hash) (*QuorumCertificate, error) {
+	quorum := validatorSet.TotalStake()*2/3 + 1
+	signedStake := uint64(0)
+	signatures := []VoteSignature{}
+	for _, vote := range votes.Votes() {
+		if vote.Block == nil || vote.blockHash() != blockHash || votes.IsEquivocator(vote.ID) {
+			continue
+		}
+		validator, err := validatorSet.GetValidator(vote.ID)
+		if err != nil {
+			continue
+		}
+		pubKey := validator.PublicKey()
+		if vote.Verify(&pubKey) != nil {
+			continue
+		}
+		signatures = append(signatures, VoteSignature{ID: vote.ID, Epoch: vote.Epoch, Signature: vote.Signature})
+		signedStake += validator.Stake()
+	}
+	if signedStake < quorum {
+		return nil, ErrNoQuorum
+	}
+	return &QuorumCertificate{
+		BlockHash: blockHash,
+		Votes:     signatures,
+	}, nil
+}
+
+// Signers returns the IDs of the validators who voted for the block.
+func (qc *QuorumCertificate) Signers() []string {
+	signers := make([]string, len(qc.Votes))
+	for i, vote := range qc.Votes {
+		signers[i] = vote.ID
+	}
+	return signers
+}
+
+// Verify checks that the signers are distinct validators holding more than 2/3 of the total
+// stake of the validator set, and that each of them signed a vote for the block.
+func (qc *QuorumCertificate) Verify(validatorSet *ValidatorSet) error {
+	quorum := validatorSet.TotalStake()*2/3 + 1
+	signedStake := uint64(0)
+	for i, vote := range qc.Votes {
+		if i > 0 && qc.Votes[i-1].ID >= vote.ID {
+			return ErrInvalidSigners
+		}
+		validator, err := validatorSet.GetValidator(vote.ID)
+		if err != nil {
+			return err
+		}
+		pubKey := validator.PublicKey()
+		if vote.Signature == nil || !pubKey.VerifySignature(voteSignBytes(qc.BlockHash, vote.ID, vote.Epoch), vote.Signature) {
+			return ErrInvalidVoteSignature
+		}
+		signedStake += validator.Stake()
+	}
+	if signedStake < quorum {
+		return ErrNoQuorum
+	}
+	return nil
+}
+
+func (qc *QuorumCertificate) String() string {
+	return fmt.Sprintf("QC{block: %v, signers: %v}", qc.BlockHash.Hex(), qc.Signers())
+}
@@ -0,0 +1,127 @@
+// +build unit
+
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/crypto"
+	"github.com/thetatoken/ukulele/rlp"
+)
+
+func TestQuorumCertificate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	validatorSet := NewValidatorSet()
+	ids := []string{}
+	privKeys := []*crypto.PrivateKey{}
+	for i, stake := range []uint64{33, 33, 33, 1} {
+		privKey, pubKey, err := crypto.TEST_GenerateKeyPairWithSeed(fmt.Sprintf("val%v", i))
+		require.Nil(err)
+		validator := NewValidator(pubKey.ToBytes(), stake)
+		validatorSet.AddValidator(validator)
+		ids = append(ids, validator.ID())
+		privKeys = append(privKeys, privKey)
+	}
+	blockA := CreateTestBlock("A", "").BlockHeader
+	blockB := CreateTestBlock("B", "").BlockHeader
+	signedVote := func(block *BlockHeader, i int) Vote {
+		vote := Vote{Block: block, ID: ids[i], Epoch: 1}
+		require.Nil(vote.Sign(privKeys[i]))
+		return vote
+	}
+
+	votes := NewVoteSet()
+	votes.AddVote(signedVote(blockA, 0))
+	votes.AddVote(signedVote(blockA, 1))
+	votes.AddVote(signedVote(blockB, 2))
+	votes.AddVote(Vote{Block: blockA, ID: "unknown", Epoch: 1})
+
+	// No quorum: 66 out of 100
+	_, err := BuildQuorumCertificate(votes, validatorSet, blockA.Hash())
+	assert.Equal(ErrNoQuorum, err)
+
+	// The unsigned votes do not count
+	unsigned := votes.Copy()
+	unsigned.AddVote(Vote{Block: blockA, ID: ids[3], Epoch: 1})
+	_, err = BuildQuorumCertificate(unsigned, validatorSet, blockA.Hash())
+	assert.Equal(ErrNoQuorum, err)
+
+	// Quorum: 67 out of 100. Only the validators who voted for the block are signers.
+	votes.AddVote(signedVote(blockA, 3))
+	qc, err := BuildQuorumCertificate(votes, validatorSet, blockA.Hash())
+	require.Nil(err)
+	assert.Equal(blockA.Hash(), qc.BlockHash)
+	assert.Equal(3, len(qc.Signers()))
+	assert.NotContains(qc.Signers(), ids[2])
+	assert.NotContains(qc.Signers(), "unknown")
+	assert.Nil(qc.Verify(validatorSet))
+
+	// Round trip
+	b, err := rlp.EncodeToBytes(qc)
+	require.Nil(err)
+	qc2 := &QuorumCertificate{}
+	require.Nil(rlp.DecodeBytes(b, qc2))
+	assert.Equal(qc, qc2)
+	assert.Nil(qc2.Verify(validatorSet))
+
+	// Tampered certificates
+	insufficient := &QuorumCertificate{BlockHash: qc.BlockHash, Votes: qc.Votes[1:]}
+	assert.Equal(ErrNoQuorum, insufficient.Verify(validatorSet))
+
+	duplicated := &QuorumCertificate{BlockHash: qc.BlockHash, Votes: append([]VoteSignature{qc.Votes[0]}, qc.Votes...)}
+	assert.Equal(ErrInvalidSigners, duplicated.Verify(validatorSet))
+
+	unknown := &QuorumCertificate{BlockHash: qc.BlockHash, Votes: append(append([]VoteSignature{}, qc.Votes...), VoteSignature{ID: "zzz"})}
+	assert.Equal(ErrValidatorNotFound, unknown.Verify(validatorSet))
+
+	otherBlock := &QuorumCertificate{BlockHash: blockB.Hash(), Votes: qc.Votes}
+	assert.Equal(ErrInvalidVoteSignature, otherBlock.Verify(validatorSet))
+}
+
+func TestQuorumCertificateForged(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	validatorSet := NewValidatorSet()
+	ids := []string{}
+	for i := 0; i < 4; i++ {
+		_, pubKey, err := crypto.TEST_GenerateKeyPairWithSeed(fmt.Sprintf("val%v", i))
+		require.Nil(err)
+		validator := NewValidator(pubKey.ToBytes(), 25)
+		validatorSet.AddValidator(validator)
+		ids = append(ids, validator.ID())
+	}
+	forger, _, err := crypto.TEST_GenerateKeyPairWithSeed("forger")
+	require.Nil(err)
+	block := CreateTestBlock("A", "").BlockHeader
+
+	// Naming the validators is not enough
+	forged := &QuorumCertificate{BlockHash: block.Hash()}
+	for _, id := range ids {
+		forged.Votes = append(forged.Votes, VoteSignature{ID: id, Epoch: 1})
+	}
+	assert.Equal(ErrInvalidVoteSignature, forged.Verify(validatorSet))
+
+	// Neither are the votes signed by someone else
+	for i := range forged.Votes {
+		vote := Vote{Block: block, ID: ids[i], Epoch: 1}
+		require.Nil(vote.Sign(forger))
+		forged.Votes[i].Signature = vote.Signature
+	}
+	assert.Equal(ErrInvalidVoteSignature, forged.Verify(validatorSet))
+
+	// Nor can the forged votes be aggregated
+	votes := NewVoteSet()
+	for i := range ids {
+		vote := Vote{Block: block, ID: ids[i], Epoch: 1}
+		require.Nil(vote.Sign(forger))
+		votes.AddVote(vote)
+	}
+	_, err = BuildQuorumCertificate(votes, validatorSet, block.Hash())
+	assert.Equal(ErrNoQuorum, err)
+}
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"math/big"
 	"sort"
 
 	"github.com/thetatoken/ukulele/common"
@@ -11,6 +14,9 @@ import (
 var (
 	// ErrValidatorNotFound for ID is not found in validator set.
 	ErrValidatorNotFound = errors.New("ValidatorNotFound")
+
+	// ErrNoStake for a validator set without stake.
+	ErrNoStake = errors.New("NoStake")
 )
 
 // Validator contains the public information of a validator.
@@ -123,3 +129,80 @@ func (s *ValidatorSet) HasMajority(votes *VoteSet) bool {
 func (s *ValidatorSet) Validators() []Validator {
 	return s.validators
 }
+
+//
+// ProposerProof allows the validators to verify that the proposer of a block was legitimately
+// selected for the epoch. Draw is the random number in [0, total stake) derived from the epoch
+// seed which determines the selected validator.
+//
+type ProposerProof struct {
+	Epoch uint64      `json:"epoch"`
+	Seed  common.Hash `json:"seed"`
+	Draw  uint64      `json:"draw"`
+}
+
+// ProposerSeed returns the seed used to select the proposer of the given epoch.
+func ProposerSeed(epoch uint64) common.Hash {
+	epochBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(epochBytes, epoch)
+	return crypto.Keccak256Hash(epochBytes)
+}
+
+// proposerDraw derives a number in [0, totalStake) from the seed.
+func proposerDraw(seed common.Hash, totalStake uint64) uint64 {
+	draw := new(big.Int).SetBytes(seed[:])
+	draw.Mod(draw, new(big.Int).SetUint64(totalStake))
+	return draw.Uint64()
+}
+
+// validatorAtDraw returns the validator whose stake range contains the draw.
+func (s *ValidatorSet) validatorAtDraw(draw uint64) (Validator, error) {
+	curr := uint64(0)
+	for _, v := range s.validators {
+		curr += v.Stake()
+		if draw < curr {
+			return v, nil
+		}
+	}
+	return Validator{}, ErrValidatorNotFound
+}
+
+// SelectProposer selects the proposer of the given epoch from the validator set using the
+// validator's stake as weight, and returns it along with the proof of the selection.
+func SelectProposer(validators *ValidatorSet, epoch uint64) (Validator, ProposerProof, error) {
+	totalStake := validators.TotalStake()
+	if totalStake == 0 {
+		return Validator{}, ProposerProof{}, ErrNoStake
+	}
+	seed := ProposerSeed(epoch)
+	draw := proposerDraw(seed, totalStake)
+	proposer, err := validators.validatorAtDraw(draw)
+	if err != nil {
+		return Validator{}, ProposerProof{}, err
+	}
+	return proposer, ProposerProof{Epoch: epoch, Seed: seed, Draw: draw}, nil
+}
+
+// VerifyProposerProof checks that the proof is derived from the seed of its epoch, and that the
+// proposer is the validator selected by SelectProposer from the validator set.
+func VerifyProposerProof(validators *ValidatorSet, proposer Validator, proof ProposerProof) error {
+	if proof.Seed != ProposerSeed(proof.Epoch) {
+		return fmt.Errorf("Invalid seed for epoch %v: %v", proof.Epoch, proof.Seed.Hex())
+	}
+	totalStake := validators.TotalStake()
+	if totalStake == 0 {
+		return ErrNoStake
+	}
+	if proof.Draw != proposerDraw(proof.Seed, totalStake) {
+		return fmt.Errorf("Invalid draw for epoch %v: %v", proof.Epoch, proof.Draw)
+	}
+	selected, err := validators.validatorAtDraw(proof.Draw)
+	if err != nil {
+		return err
+	}
+	if selected.ID() != proposer.ID() {
+		return fmt.Errorf("Validator %v was not selected as the proposer of epoch %v, expected: %v",
+			proposer.ID(), proof.Epoch, selected.ID())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// +build unit
+
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+func TestProposerProof(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	validatorSet := NewValidatorSet()
+	for i, stake := range []uint64{10, 20, 30, 40} {
+		_, pubKey, err := crypto.TEST_GenerateKeyPairWithSeed(fmt.Sprintf("val%v", i))
+		require.Nil(err)
+		validatorSet.AddValidator(NewValidator(pubKey.ToBytes(), stake))
+	}
+
+	for epoch := uint64(0); epoch < 20; epoch++ {
+		proposer, proof, err := SelectProposer(validatorSet, epoch)
+		require.Nil(err)
+		assert.Equal(epoch, proof.Epoch)
+		assert.Equal(ProposerSeed(epoch), proof.Seed)
+		assert.True(proof.Draw < validatorSet.TotalStake())
+		assert.Nil(VerifyProposerProof(validatorSet, proposer, proof))
+
+		// The selection is deterministic
+		proposer2, proof2, err := SelectProposer(validatorSet, epoch)
+		require.Nil(err)
+		assert.Equal(proposer.ID(), proposer2.ID())
+		assert.Equal(proof, proof2)
+
+		// The other validators are rejected
+		for _, v := range validatorSet.Validators() {
+			if v.ID() != proposer.ID() {
+				assert.NotNil(VerifyProposerProof(validatorSet, v, proof))
+			}
+		}
+	}
+
+	proposer, proof, err := SelectProposer(validatorSet, 1)
+	require.Nil(err)
+
+	// Tampered proofs
+	wrongSeed := proof
+	wrongSeed.Seed = ProposerSeed(2)
+	assert.NotNil(VerifyProposerProof(validatorSet, proposer, wrongSeed))
+
+	wrongDraw := proof
+	wrongDraw.Draw = (proof.Draw + 1) % validatorSet.TotalStake()
+	assert.NotNil(VerifyProposerProof(validatorSet, proposer, wrongDraw))
+
+	wrongEpoch := proof
+	wrongEpoch.Epoch = 2
+	assert.NotNil(VerifyProposerProof(validatorSet, proposer, wrongEpoch))
+
+	// No validators
+	_, _, err = SelectProposer(NewValidatorSet(), 1)
+	assert.Equal(ErrNoStake, err)
+	assert.Equal(ErrNoStake, VerifyProposerProof(NewValidatorSet(), proposer, proof))
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/rlp"
 )
 
@@ -48,9 +49,58 @@ func (cc *CommitCertificate) IsValid() bool {
 
 // Vote represents a vote on a block by a validaor.
 type Vote struct {
-	Block *BlockHeader `rlp:"nil"`
-	ID    string
-	Epoch uint64
+	Block     *BlockHeader `rlp:"nil"`
+	ID        string
+	Epoch     uint64
+	Signature *crypto.Signature `rlp:"optional"` // signature of the voter over SignBytes, missing from the older votes
+}
+
+// voteContent is the content of a vote signed by the voter
+type voteContent struct {
+	BlockHash common.Hash
+	ID        string
+	Epoch     uint64
+}
+
+// voteSignBytes returns the bytes signed by the voter of a vote for the given block.
+func voteSignBytes(blockHash common.Hash, id string, epoch uint64) common.Bytes {
+	signBytes, _ := rlp.EncodeToBytes(&voteContent{BlockHash: blockHash, ID: id, Epoch: epoch})
+	return signBytes
+}
+
+// SignBytes returns the bytes signed by the voter. Only the hash of the block is signed, so the
+// signature can be verified without the block header, e.g. in a quorum certificate.
+func (v Vote) SignBytes() common.Bytes {
+	return voteSignBytes(v.blockHash(), v.ID, v.Epoch)
+}
+
+// Sign signs the vote with the private key of the voter.
+func (v *Vote) Sign(privKey *crypto.PrivateKey) error {
+	sig, err := privKey.Sign(v.SignBytes())
+	if err != nil {
+		return err
+	}
+	v.Signature = sig
+	return nil
+}
+
+// Verify checks that the vote is signed by the voter with the given public key.
+func (v Vote) Verify(pubKey *crypto.PublicKey) error {
+	if pubKey.Address().Hex() != v.ID {
+		return ErrInvalidVoteSignature
+	}
+	if v.Signature == nil || v.Signature.IsEmpty() || !pubKey.VerifySignature(v.SignBytes(), v.Signature) {
+		return ErrInvalidVoteSignature
+	}
+	return nil
+}
+
+// blockHash returns the hash of the block voted for, or the empty hash for a nil vote.
+func (v Vote) blockHash() common.Hash {
+	if v.Block == nil {
+		return common.Hash{}
+	}
+	return v.Block.Hash()
 }
 
 func (v Vote) String() string {
@@ -62,13 +112,15 @@ func (v Vote) String() string {
 
 // VoteSet represents a set of votes on a proposal.
 type VoteSet struct {
-	votes map[string]Vote // Voter ID to vote
+	votes       map[string]Vote // Voter ID to vote
+	conflicting map[string]Vote // Voter ID to an earlier vote for a different block in the same epoch
 }
 
 // NewVoteSet creates an instance of VoteSet.
 func NewVoteSet() *VoteSet {
 	return &VoteSet{
-		votes: make(map[string]Vote),
+		votes:       make(map[string]Vote),
+		conflicting: make(map[string]Vote),
 	}
 }
 
@@ -78,14 +130,57 @@ func (s *VoteSet) Copy() *VoteSet {
 	for _, vote := range s.Votes() {
 		ret.AddVote(vote)
 	}
+	for id, vote := range s.conflicting {
+		ret.conflicting[id] = vote
+	}
 	return ret
 }
 
-// AddVote adds a vote to vote set.
+// AddVote adds a vote to vote set. If the voter has voted for a different block in the same
+// epoch, the earlier vote is kept as the evidence of the equivocation.
 func (s *VoteSet) AddVote(vote Vote) {
+	prev, ok := s.votes[vote.ID]
+	if _, equivocated := s.conflicting[vote.ID]; ok && !equivocated &&
+		prev.Epoch == vote.Epoch && prev.blockHash() != vote.blockHash() {
+		s.conflicting[vote.ID] = prev
+	}
 	s.votes[vote.ID] = vote
 }
 
+// PruneBeforeEpoch drops the votes for the epochs lower than the given epoch.
+func (s *VoteSet) PruneBeforeEpoch(epoch uint64) {
+	for id, vote := range s.votes {
+		if vote.Epoch < epoch {
+			delete(s.votes, id)
+			delete(s.conflicting, id)
+		}
+	}
+}
+
+// HasQuorum checks whether the voters who voted for the given block hold more than 2/3 of
+// the total stake of the validator set. The votes for other blocks, and the votes of the
+// voters who voted for different blocks in the same epoch are ignored.
+func (s *VoteSet) HasQuorum(validatorSet *ValidatorSet, blockHash common.Hash) bool {
+	quorum := validatorSet.TotalStake()*2/3 + 1
+	votedStake := uint64(0)
+	for _, vote := range s.Votes() {
+		if vote.Block == nil || vote.blockHash() != blockHash || s.IsEquivocator(vote.ID) {
+			continue
+		}
+		validator, err := validatorSet.GetValidator(vote.ID)
+		if err == nil {
+			votedStake += validator.Stake()
+		}
+	}
+	return votedStake >= quorum
+}
+
+// IsEquivocator returns whether the voter has voted for different blocks in the same epoch.
+func (s *VoteSet) IsEquivocator(id string) bool {
+	_, ok := s.conflicting[id]
+	return ok
+}
+
 // Size returns the number of votes in the vote set.
 func (s *VoteSet) Size() int {
 	return len(s.votes)
@@ -107,12 +202,20 @@ func (s *VoteSet) String() string {
 
 var _ rlp.Encoder = (*VoteSet)(nil)
 
-// EncodeRLP implements RLP Encoder interface.
+// EncodeRLP implements RLP Encoder interface. The earlier vote of an equivocator immediately
+// precedes its latest vote, so the encoding of a vote set without equivocators is unchanged.
 func (s *VoteSet) EncodeRLP(w io.Writer) error {
 	if s == nil {
 		return rlp.Encode(w, []Vote{})
 	}
-	return rlp.Encode(w, s.Votes())
+	votes := make([]Vote, 0, len(s.votes)+len(s.conflicting))
+	for _, vote := range s.Votes() {
+		if conflicting, ok := s.conflicting[vote.ID]; ok {
+			votes = append(votes, conflicting)
+		}
+		votes = append(votes, vote)
+	}
+	return rlp.Encode(w, votes)
 }
 
 var _ rlp.Decoder = (*VoteSet)(nil)
@@ -125,7 +228,11 @@ func (s *VoteSet) DecodeRLP(stream *rlp.Stream) error {
 		return err
 	}
 	s.votes = make(map[string]Vote)
+	s.conflicting = make(map[string]Vote)
 	for _, v := range votes {
+		if prev, ok := s.votes[v.ID]; ok {
+			s.conflicting[v.ID] = prev
+		}
 		s.votes[v.ID] = v
 	}
 	return nil
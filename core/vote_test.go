@@ -3,9 +3,11 @@
 package core
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/rlp"
 )
 
@@ -42,3 +44,108 @@ func TestEncoding(t *testing.T) {
 	assert.NotNil(vs[1].Block)
 	assert.Equal(vs0[1].Block.Hash(), vs[1].Block.Hash())
 }
+
+func TestVoteSetEncodingKeepsEquivocations(t *testing.T) {
+	assert := assert.New(t)
+
+	blockA := CreateTestBlock("A", "").BlockHeader
+	blockB := CreateTestBlock("B", "").BlockHeader
+	votes := NewVoteSet()
+	votes.AddVote(Vote{Block: blockA, ID: "Alice", Epoch: 1})
+	votes.AddVote(Vote{Block: blockA, ID: "Bob", Epoch: 1})
+	votes.AddVote(Vote{Block: blockB, ID: "Bob", Epoch: 1})
+	votes.AddVote(Vote{Block: blockA, ID: "Carol", Epoch: 1})
+	votes.AddVote(Vote{Block: blockB, ID: "Carol", Epoch: 1})
+	votes.AddVote(Vote{Block: blockA, ID: "Carol", Epoch: 2})
+	assert.True(votes.IsEquivocator("Bob"))
+	assert.True(votes.IsEquivocator("Carol"))
+
+	b, err := rlp.EncodeToBytes(votes)
+	assert.Nil(err)
+	votes2 := NewVoteSet()
+	err = rlp.DecodeBytes(b, &votes2)
+	assert.Nil(err)
+
+	assert.Equal(votes.String(), votes2.String())
+	assert.False(votes2.IsEquivocator("Alice"))
+	assert.True(votes2.IsEquivocator("Bob"))
+	assert.True(votes2.IsEquivocator("Carol"))
+	assert.Equal(votes.conflicting["Carol"].String(), votes2.conflicting["Carol"].String())
+
+	// Re-encoding the decoded vote set gives the same bytes
+	b2, err := rlp.EncodeToBytes(votes2)
+	assert.Nil(err)
+	assert.Equal(b, b2)
+
+	// A vote set without equivocators is encoded as the list of its votes
+	votes3 := NewVoteSet()
+	votes3.AddVote(Vote{Block: blockA, ID: "Alice", Epoch: 1})
+	b3, err := rlp.EncodeToBytes(votes3)
+	assert.Nil(err)
+	b4, err := rlp.EncodeToBytes(votes3.Votes())
+	assert.Nil(err)
+	assert.Equal(b4, b3)
+}
+
+func TestVoteSetHasQuorum(t *testing.T) {
+	assert := assert.New(t)
+
+	validatorSet := NewValidatorSet()
+	ids := []string{}
+	for i, stake := range []uint64{33, 33, 33, 1} {
+		_, pubKey, err := crypto.TEST_GenerateKeyPairWithSeed(fmt.Sprintf("val%v", i))
+		assert.Nil(err)
+		validator := NewValidator(pubKey.ToBytes(), stake)
+		validatorSet.AddValidator(validator)
+		ids = append(ids, validator.ID())
+	}
+	blockA := CreateTestBlock("A", "").BlockHeader
+	blockB := CreateTestBlock("B", "").BlockHeader
+
+	// Just below the threshold: 66 out of 100
+	votes := NewVoteSet()
+	votes.AddVote(Vote{Block: blockA, ID: ids[0], Epoch: 1})
+	votes.AddVote(Vote{Block: blockA, ID: ids[1], Epoch: 1})
+	votes.AddVote(Vote{Block: blockB, ID: ids[2], Epoch: 1})
+	votes.AddVote(Vote{Block: nil, ID: "unknown", Epoch: 1})
+	assert.False(votes.HasQuorum(validatorSet, blockA.Hash()))
+	assert.False(votes.HasQuorum(validatorSet, blockB.Hash()))
+
+	// Just above the threshold: 67 out of 100
+	aboveVotes := votes.Copy()
+	aboveVotes.AddVote(Vote{Block: blockA, ID: ids[3], Epoch: 1})
+	assert.True(aboveVotes.HasQuorum(validatorSet, blockA.Hash()))
+	assert.False(aboveVotes.HasQuorum(validatorSet, blockB.Hash()))
+
+	// The votes of an equivocating voter are ignored
+	aboveVotes.AddVote(Vote{Block: blockB, ID: ids[3], Epoch: 1})
+	aboveVotes.AddVote(Vote{Block: blockA, ID: ids[3], Epoch: 1})
+	assert.False(aboveVotes.HasQuorum(validatorSet, blockA.Hash()))
+	assert.False(aboveVotes.Copy().HasQuorum(validatorSet, blockA.Hash()))
+
+	// Voting for a different block in a later epoch is not an equivocation
+	laterVotes := votes.Copy()
+	laterVotes.AddVote(Vote{Block: blockB, ID: ids[3], Epoch: 1})
+	laterVotes.AddVote(Vote{Block: blockA, ID: ids[3], Epoch: 2})
+	assert.True(laterVotes.HasQuorum(validatorSet, blockA.Hash()))
+}
+
+func TestVoteSetPruneBeforeEpoch(t *testing.T) {
+	assert := assert.New(t)
+
+	block := CreateTestBlock("A", "").BlockHeader
+	votes := NewVoteSet()
+	votes.AddVote(Vote{Block: block, ID: "Alice", Epoch: 1})
+	votes.AddVote(Vote{Block: block, ID: "Bob", Epoch: 2})
+	votes.AddVote(Vote{Block: block, ID: "Carol", Epoch: 3})
+	votes.AddVote(Vote{Block: nil, ID: "Dave", Epoch: 4})
+
+	votes.PruneBeforeEpoch(3)
+	vs := votes.Votes()
+	assert.Equal(2, len(vs))
+	assert.Equal("Carol", vs[0].ID)
+	assert.Equal("Dave", vs[1].ID)
+
+	votes.PruneBeforeEpoch(10)
+	assert.Equal(0, votes.Size())
+}
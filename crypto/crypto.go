@@ -3,11 +3,14 @@ package crypto
 import (
 	"bytes"
 	"crypto/ecdsa"
+	"errors"
+	"fmt"
 	"io"
 	"math/big"
 
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/rlp"
+	"golang.org/x/crypto/ed25519"
 )
 
 //
@@ -29,26 +32,58 @@ func Keccak256Hash(data ...[]byte) (h common.Hash) {
 // ----------------------- Digital Signature APIs ----------------------- //
 //
 
+//
+// Signer signs messages on behalf of a key pair, e.g. with an in-process private key, or by
+// delegating to a hardware security module or a remote signing service
+//
+type Signer interface {
+	Sign(msg common.Bytes) (*Signature, error)
+	PublicKey() *PublicKey
+}
+
+var _ Signer = (*PrivateKey)(nil)
+
 //
 // PrivateKey represents the private key
 //
 type PrivateKey struct {
-	privKey *ecdsa.PrivateKey
+	privKey   *ecdsa.PrivateKey
+	edPrivKey ed25519.PrivateKey // set instead of privKey for the ed25519 keys
+}
+
+// Scheme returns the signature scheme of the private key
+func (sk *PrivateKey) Scheme() SignatureScheme {
+	if sk.edPrivKey != nil {
+		return SchemeEd25519
+	}
+	return SchemeSecp256k1
 }
 
-// ToBytes returns the bytes representation of the private key
+// ToBytes returns the bytes representation of the private key, which is the seed for
+// the ed25519 keys
 func (sk *PrivateKey) ToBytes() common.Bytes {
+	if sk.edPrivKey != nil {
+		return common.Bytes(sk.edPrivKey.Seed())
+	}
 	skbytes := fromECDSA(sk.privKey)
 	return skbytes
 }
 
-// D returns the D parameter of the ECDSA private key
+// D returns the D parameter of the ECDSA private key, nil for the ed25519 keys
 func (sk *PrivateKey) D() *big.Int {
+	if sk.edPrivKey != nil {
+		return nil
+	}
 	return sk.privKey.D
 }
 
 // PublicKey returns the public key corresponding to the private key
 func (sk *PrivateKey) PublicKey() *PublicKey {
+	if sk.edPrivKey != nil {
+		return &PublicKey{
+			edPubKey: sk.edPrivKey.Public().(ed25519.PublicKey),
+		}
+	}
 	pke := &sk.privKey.PublicKey
 	return &PublicKey{
 		pubKey: pke,
@@ -57,12 +92,20 @@ func (sk *PrivateKey) PublicKey() *PublicKey {
 
 // SaveToFile saves the private key to the designated file
 func (sk *PrivateKey) SaveToFile(filepath string) error {
+	if sk.edPrivKey != nil {
+		return errors.New("Only secp256k1 private keys can be saved to file")
+	}
 	err := saveECDSA(filepath, sk.privKey)
 	return err
 }
 
-// Sign signs the given message with the private key
+// Sign signs the given message with the private key. The signature carries the scheme of the key.
 func (sk *PrivateKey) Sign(msg common.Bytes) (*Signature, error) {
+	if sk.edPrivKey != nil {
+		sigBytes := ed25519.Sign(sk.edPrivKey, msg)
+		sig := &Signature{scheme: SchemeEd25519, data: sigBytes}
+		return sig, nil
+	}
 	msgHash := keccak256(msg)
 	sigBytes, err := sign(msgHash, sk.privKey)
 	sig := &Signature{data: sigBytes}
@@ -70,10 +113,20 @@ func (sk *PrivateKey) Sign(msg common.Bytes) (*Signature, error) {
 }
 
 //
-// PublicKey represents the public key
+// PublicKey represents the public key. The secp256k1 and ed25519 keys are told apart by
+// the length of their bytes representation.
 //
 type PublicKey struct {
-	pubKey *ecdsa.PublicKey
+	pubKey   *ecdsa.PublicKey
+	edPubKey ed25519.PublicKey // set instead of pubKey for the ed25519 keys
+}
+
+// Scheme returns the signature scheme of the public key
+func (pk *PublicKey) Scheme() SignatureScheme {
+	if pk.edPubKey != nil {
+		return SchemeEd25519
+	}
+	return SchemeSecp256k1
 }
 
 var _ rlp.Encoder = (*PublicKey)(nil)
@@ -99,6 +152,10 @@ func (pk *PublicKey) DecodeRLP(stream *rlp.Stream) error {
 	if len(b) == 0 {
 		return nil
 	}
+	if len(b) == ed25519.PublicKeySize {
+		pk.edPubKey = ed25519.PublicKey(b)
+		return nil
+	}
 	pubKey, err := unmarshalPubkey(b)
 	if err != nil {
 		return err
@@ -109,12 +166,18 @@ func (pk *PublicKey) DecodeRLP(stream *rlp.Stream) error {
 
 // ToBytes returns the bytes representation of the public key
 func (pk *PublicKey) ToBytes() common.Bytes {
+	if pk.edPubKey != nil {
+		return common.Bytes(pk.edPubKey)
+	}
 	pkbytes := fromECDSAPub(pk.pubKey)
 	return pkbytes
 }
 
 // Address returns the address corresponding to the public key
 func (pk *PublicKey) Address() common.Address {
+	if pk.edPubKey != nil {
+		return common.BytesToAddress(keccak256(pk.edPubKey)[12:])
+	}
 	pubBytes := fromECDSAPub(pk.pubKey)
 	address := common.BytesToAddress(keccak256(pubBytes[1:])[12:])
 	return address
@@ -122,16 +185,35 @@ func (pk *PublicKey) Address() common.Address {
 
 // IsEmpty indicates whether the public key is empty
 func (pk *PublicKey) IsEmpty() bool {
+	if pk.edPubKey != nil {
+		return false
+	}
 	isEmpty := (pk.pubKey == nil || pk.pubKey.X == nil || pk.pubKey.Y == nil)
 	return isEmpty
 }
 
-// VerifySignature verifies the signature with the public key (using ecrecover)
+// VerifySignature verifies the signature with the public key, using the algorithm of the
+// signature scheme. The scheme of the signature has to match the one of the key.
 func (pk *PublicKey) VerifySignature(msg common.Bytes, sig *Signature) bool {
 	if sig == nil {
 		return false
 	}
+	if sig.scheme != pk.Scheme() {
+		return false
+	}
+
+	switch sig.scheme {
+	case SchemeSecp256k1:
+		return pk.verifySecp256k1Signature(msg, sig)
+	case SchemeEd25519:
+		return len(sig.data) == ed25519.SignatureSize && ed25519.Verify(pk.edPubKey, msg, sig.data)
+	default:
+		return false
+	}
+}
 
+// verifySecp256k1Signature verifies the signature with the public key (using ecrecover)
+func (pk *PublicKey) verifySecp256k1Signature(msg common.Bytes, sig *Signature) bool {
 	msgHash := keccak256(msg)
 	recoveredUncompressedPubKey, err := ecrecover(msgHash, sig.ToBytes())
 	if err != nil {
@@ -168,26 +250,56 @@ func (pk *PublicKey) VerifySignature(msg common.Bytes, sig *Signature) bool {
 // Signature represents the digital signature
 //
 type Signature struct {
-	data common.Bytes
+	scheme SignatureScheme
+	data   common.Bytes
+}
+
+// signatureWithScheme is the RLP encoding of the signatures of a non-default scheme
+type signatureWithScheme struct {
+	Scheme SignatureScheme
+	Data   common.Bytes
 }
 
 var _ rlp.Encoder = (*Signature)(nil)
 
-// EncodeRLP implements RLP Encoder interface.
+// EncodeRLP implements RLP Encoder interface. The signatures of the default scheme are
+// encoded as plain bytes, so their encoding is unchanged, and the signatures of the other
+// schemes as a list of the scheme identifier and the bytes.
 func (sig *Signature) EncodeRLP(w io.Writer) error {
 	if sig == nil {
 		return rlp.Encode(w, []byte{})
 	}
 	b := sig.ToBytes()
-	return rlp.Encode(w, b)
+	if sig.scheme == DefaultSignatureScheme {
+		return rlp.Encode(w, b)
+	}
+	return rlp.Encode(w, &signatureWithScheme{Scheme: sig.scheme, Data: b})
 }
 
 var _ rlp.Decoder = (*Signature)(nil)
 
 // DecodeRLP implements RLP Decoder interface.
 func (sig *Signature) DecodeRLP(stream *rlp.Stream) error {
+	kind, _, err := stream.Kind()
+	if err != nil {
+		return err
+	}
+	if kind == rlp.List {
+		ws := &signatureWithScheme{}
+		if err := stream.Decode(ws); err != nil {
+			return err
+		}
+		// Otherwise the same signature would have two encodings
+		if ws.Scheme == DefaultSignatureScheme {
+			return fmt.Errorf("Signature of the default scheme cannot carry a scheme identifier")
+		}
+		sig.scheme = ws.Scheme
+		sig.data = ws.Data
+		return nil
+	}
+
 	var b []byte
-	err := stream.Decode(&b)
+	err = stream.Decode(&b)
 	if err != nil {
 		return err
 	}
@@ -195,6 +307,11 @@ func (sig *Signature) DecodeRLP(stream *rlp.Stream) error {
 	return nil
 }
 
+// Scheme returns the signature scheme of the signature
+func (sig *Signature) Scheme() SignatureScheme {
+	return sig.scheme
+}
+
 // ToBytes returns the bytes representation of the signature
 func (sig *Signature) ToBytes() common.Bytes {
 	return sig.data
@@ -207,6 +324,9 @@ func (sig *Signature) IsEmpty() bool {
 
 // RecoverSignerAddress recovers the address of the signer for the given message
 func (sig *Signature) RecoverSignerAddress(msg common.Bytes) (common.Address, error) {
+	if sig.scheme != SchemeSecp256k1 {
+		return common.Address{}, fmt.Errorf("Signer cannot be recovered from %v signatures", sig.scheme)
+	}
 	msgHash := keccak256(msg)
 	recoveredUncompressedPubKey, err := ecrecover(msgHash, sig.ToBytes())
 	if err != nil {
@@ -245,6 +365,9 @@ func PrivateKeyFromBytes(skBytes common.Bytes) (*PrivateKey, error) {
 
 // PublicKeyFromBytes converts the given bytes to a public key
 func PublicKeyFromBytes(pkBytes common.Bytes) (*PublicKey, error) {
+	if len(pkBytes) == ed25519.PublicKeySize {
+		return &PublicKey{edPubKey: ed25519.PublicKey(pkBytes)}, nil
+	}
 	key, err := unmarshalPubkey(pkBytes)
 	pk := &PublicKey{pubKey: key}
 	return pk, err
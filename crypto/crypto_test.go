@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/rlp"
 )
 
 func TestHash(t *testing.T) {
@@ -191,3 +192,81 @@ func TestSignatureVerification2(t *testing.T) {
 	assert.False(pubKeyA.VerifySignature(msg2, sig2B))
 	assert.False(pubKeyB.VerifySignature(msg2, sig2A))
 }
+
+func TestSignatureSchemes(t *testing.T) {
+	assert := assert.New(t)
+
+	msg := common.Bytes("Hello world!")
+	for _, scheme := range []SignatureScheme{SchemeSecp256k1, SchemeEd25519} {
+		privKey, pubKey, err := GenerateKeyPairWithScheme(scheme)
+		assert.Nil(err)
+		assert.Equal(scheme, privKey.Scheme())
+		assert.Equal(scheme, pubKey.Scheme())
+
+		sig, err := privKey.Sign(msg)
+		assert.Nil(err)
+		assert.Equal(scheme, sig.Scheme())
+		assert.True(pubKey.VerifySignature(msg, sig))
+		assert.False(pubKey.VerifySignature(common.Bytes("Hello World!"), sig))
+
+		// The scheme identifier survives the encoding
+		encoded, err := rlp.EncodeToBytes(sig)
+		assert.Nil(err)
+		decoded := &Signature{}
+		assert.Nil(rlp.DecodeBytes(encoded, decoded))
+		assert.Equal(sig, decoded)
+		assert.True(pubKey.VerifySignature(msg, decoded))
+
+		// So does the key
+		decodedPubKey, err := PublicKeyFromBytes(pubKey.ToBytes())
+		assert.Nil(err)
+		assert.Equal(scheme, decodedPubKey.Scheme())
+		assert.Equal(pubKey.Address(), decodedPubKey.Address())
+	}
+
+	// The signatures of the default scheme are encoded as before
+	privKey, _, err := GenerateKeyPair()
+	assert.Nil(err)
+	sig, err := privKey.Sign(msg)
+	assert.Nil(err)
+	encoded, err := rlp.EncodeToBytes(sig)
+	assert.Nil(err)
+	legacyEncoded, err := rlp.EncodeToBytes(sig.ToBytes())
+	assert.Nil(err)
+	assert.Equal(legacyEncoded, encoded)
+
+	// The default scheme cannot be tagged explicitly
+	tagged, err := rlp.EncodeToBytes(&signatureWithScheme{Scheme: SchemeSecp256k1, Data: sig.ToBytes()})
+	assert.Nil(err)
+	assert.NotNil(rlp.DecodeBytes(tagged, &Signature{}))
+}
+
+func TestSignatureSchemeMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	msg := common.Bytes("Hello world!")
+	secpPrivKey, secpPubKey, err := GenerateKeyPairWithScheme(SchemeSecp256k1)
+	assert.Nil(err)
+	edPrivKey, edPubKey, err := GenerateKeyPairWithScheme(SchemeEd25519)
+	assert.Nil(err)
+
+	secpSig, err := secpPrivKey.Sign(msg)
+	assert.Nil(err)
+	edSig, err := edPrivKey.Sign(msg)
+	assert.Nil(err)
+
+	// The signature bytes are rejected under the tag of the other scheme
+	mistaggedSecpSig, _ := SignatureFromBytesWithScheme(SchemeEd25519, secpSig.ToBytes())
+	mistaggedEdSig, _ := SignatureFromBytesWithScheme(SchemeSecp256k1, edSig.ToBytes())
+	assert.False(secpPubKey.VerifySignature(msg, mistaggedSecpSig))
+	assert.False(edPubKey.VerifySignature(msg, mistaggedEdSig))
+	assert.False(secpPubKey.VerifySignature(msg, edSig))
+	assert.False(edPubKey.VerifySignature(msg, secpSig))
+
+	unknownSig, _ := SignatureFromBytesWithScheme(SignatureScheme(7), edSig.ToBytes())
+	assert.False(edPubKey.VerifySignature(msg, unknownSig))
+
+	// The ed25519 signer cannot be recovered from the signature
+	_, err = edSig.RecoverSignerAddress(msg)
+	assert.NotNil(err)
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/thetatoken/ukulele/common"
+	"golang.org/x/crypto/ed25519"
+)
+
+// SignatureScheme identifies the algorithm of a key pair and of the signatures it produces
+type SignatureScheme uint8
+
+const (
+	// SchemeSecp256k1 is the ECDSA scheme over the secp256k1 curve, the default scheme
+	SchemeSecp256k1 SignatureScheme = iota
+
+	// SchemeEd25519 is the EdDSA scheme over Curve25519
+	SchemeEd25519
+)
+
+// DefaultSignatureScheme is the scheme of the keys and signatures without a scheme identifier
+const DefaultSignatureScheme = SchemeSecp256k1
+
+// String returns the name of the scheme
+func (scheme SignatureScheme) String() string {
+	switch scheme {
+	case SchemeSecp256k1:
+		return "secp256k1"
+	case SchemeEd25519:
+		return "ed25519"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(scheme))
+	}
+}
+
+// IsSupportedSignatureScheme indicates whether signatures of the given scheme can be verified
+func IsSupportedSignatureScheme(scheme SignatureScheme) bool {
+	return scheme == SchemeSecp256k1 || scheme == SchemeEd25519
+}
+
+// GenerateKeyPairWithScheme generates a random private/public key pair of the given scheme
+func GenerateKeyPairWithScheme(scheme SignatureScheme) (*PrivateKey, *PublicKey, error) {
+	switch scheme {
+	case SchemeSecp256k1:
+		return GenerateKeyPair()
+	case SchemeEd25519:
+		pke, ske, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &PrivateKey{edPrivKey: ske}, &PublicKey{edPubKey: pke}, nil
+	default:
+		return nil, nil, fmt.Errorf("Unsupported signature scheme: %v", scheme)
+	}
+}
+
+// Ed25519PrivateKeyFromSeed converts the given 32 byte seed to an ed25519 private key
+func Ed25519PrivateKeyFromSeed(seed common.Bytes) (*PrivateKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("Invalid ed25519 seed length: %v", len(seed))
+	}
+	return &PrivateKey{edPrivKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// SignatureFromBytesWithScheme converts the given bytes to a signature of the given scheme
+func SignatureFromBytesWithScheme(scheme SignatureScheme, sigBytes common.Bytes) (*Signature, error) {
+	sig := &Signature{scheme: scheme, data: sigBytes}
+	return sig, nil
+}
@@ -0,0 +1,134 @@
+package ledger
+
+import (
+	"context"
+	"io"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/rlp"
+	"github.com/thetatoken/ukulele/store"
+)
+
+// blockArchiveVersion is the version of the block archive format
+const blockArchiveVersion uint64 = 2
+
+// blockArchiveHeader is the first item of a block archive
+type blockArchiveHeader struct {
+	Version    uint64
+	ChainID    string
+	FromHeight uint64
+	ToHeight   uint64
+}
+
+// blockArchiveRecord is a committed block of a block archive
+type blockArchiveRecord struct {
+	Height          uint64
+	Epoch           uint64
+	ParentStateRoot common.Hash
+	StateRoot       common.Hash
+	RawTxs          []common.Bytes
+}
+
+// ExportBlocks streams the blocks committed at the heights from the given range, inclusive, to w
+// for archival. The archive consists of a header followed by a record for each block, which holds
+// the epoch and the raw transactions of the block together with the state roots before and after applying them.
+// All the items are RLP encoded, and hence are length-prefixed.
+func (ledger *Ledger) ExportBlocks(from, to uint64, w io.Writer) result.Result {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	if from > to {
+		return result.Error("Invalid height range to export: [%v, %v]", from, to)
+	}
+
+	header := &blockArchiveHeader{
+		Version:    blockArchiveVersion,
+		ChainID:    ledger.state.GetChainID(),
+		FromHeight: from,
+		ToHeight:   to,
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return result.Error("Failed to write the archive header: %v", err)
+	}
+
+	for height := from; height <= to; height++ {
+		blockIndexEntry := &BlockIndexEntry{}
+		err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+		if err == store.ErrKeyNotFound {
+			return result.Error("No block is committed at height %v", height).
+				WithErrorCode(result.CodeStateNotAvailable)
+		}
+		if err != nil {
+			return result.Error("Failed to read the block index at height %v: %v", height, err)
+		}
+
+		record := &blockArchiveRecord{
+			Height:          height,
+			Epoch:           blockIndexEntry.Epoch,
+			ParentStateRoot: blockIndexEntry.ParentStateRoot,
+			StateRoot:       blockIndexEntry.StateRoot,
+			RawTxs:          blockIndexEntry.RawTxs,
+		}
+		if err := rlp.Encode(w, record); err != nil {
+			return result.Error("Failed to write the block at height %v: %v", height, err)
+		}
+	}
+
+	return result.OK
+}
+
+// ImportBlocks re-applies the blocks of the archive read from r, and verifies the state root after
+// each block. The state of the parent of the first block needs to be available, e.g. imported with
+// ImportStateSnapshot. On success, the ledger state is at the last block of the archive.
+func (ledger *Ledger) ImportBlocks(r io.Reader) result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	stream := rlp.NewStream(r, 0)
+	header := &blockArchiveHeader{}
+	if err := stream.Decode(header); err != nil {
+		return result.Error("Failed to read the archive header: %v", err)
+	}
+	if header.Version != blockArchiveVersion {
+		return result.Error("Unsupported archive version: %v", header.Version)
+	}
+	if chainID := ledger.state.GetChainID(); header.ChainID != chainID {
+		return result.Error("Archive of chain %v cannot be imported to chain %v", header.ChainID, chainID)
+	}
+
+	var prev *blockArchiveRecord
+	expectedHeight := header.FromHeight
+	for {
+		record := &blockArchiveRecord{}
+		err := stream.Decode(record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result.Error("Failed to read the archive: %v", err)
+		}
+		if record.Height != expectedHeight || record.Height > header.ToHeight {
+			return result.Error("Unexpected block at height %v in the archive of range [%v, %v]",
+				record.Height, header.FromHeight, header.ToHeight)
+		}
+		if prev != nil && record.ParentStateRoot != prev.StateRoot {
+			return result.Error("Block at height %v does not extend the block at height %v", record.Height, prev.Height)
+		}
+
+		if res := ledger.resetState(record.Height-1, record.ParentStateRoot); res.IsError() {
+			return res
+		}
+		if res := ledger.applyBlockTxs(context.Background(), record.Epoch, record.RawTxs, record.StateRoot); res.IsError() {
+			return result.Error("Failed to import block at height %v: %v", record.Height, res.Message).
+				WithErrorCode(res.Code)
+		}
+		prev = record
+		expectedHeight++
+	}
+	if prev == nil || prev.Height != header.ToHeight {
+		return result.Error("The archive is truncated, expecting blocks up to height %v", header.ToHeight)
+	}
+
+	return result.OK
+}
@@ -0,0 +1,57 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+func TestLedgerExportImportBlocks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 3)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	firstBlock, lastBlock := blocks[1], blocks[len(blocks)-1]
+
+	var archive bytes.Buffer
+	res := ledger.ExportBlocks(firstBlock.Height, lastBlock.Height, &archive)
+	require.True(res.IsOK(), res.Message)
+
+	// Only the committed blocks can be exported
+	res = ledger.ExportBlocks(firstBlock.Height, lastBlock.Height+1, &bytes.Buffer{})
+	assert.Equal(result.CodeStateNotAvailable, res.Code, res.Message)
+	res = ledger.ExportBlocks(lastBlock.Height, firstBlock.Height, &bytes.Buffer{})
+	assert.True(res.IsError())
+
+	// Import into a ledger with the same initial state
+	importLedger := newTestLedgerWithConsensus(chainID, "peer1", ledger.consensus, ledger.valMgr)
+	setInitLedgerState(importLedger, accOut, accIns)
+	res = importLedger.ImportBlocks(bytes.NewReader(archive.Bytes()))
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(lastBlock.Height, importLedger.state.Height())
+	assert.Equal(lastBlock.StateHash, importLedger.state.Delivered().Hash())
+
+	// The imported blocks are indexed
+	sendTxHash := crypto.Keccak256Hash(lastBlock.Txs[len(lastBlock.Txs)-1])
+	_, txHeight, err := importLedger.GetTransaction(sendTxHash)
+	require.Nil(err)
+	assert.Equal(lastBlock.Height, txHeight)
+
+	// A truncated archive is rejected
+	truncatedLedger := newTestLedgerWithConsensus(chainID, "peer2", ledger.consensus, ledger.valMgr)
+	setInitLedgerState(truncatedLedger, accOut, accIns)
+	truncated := archive.Bytes()[:archive.Len()-1]
+	res = truncatedLedger.ImportBlocks(bytes.NewReader(truncated))
+	assert.True(res.IsError())
+
+	// The parent state of the first block needs to be available
+	_, emptyLedger, _ := newTestLedger()
+	res = emptyLedger.ImportBlocks(bytes.NewReader(archive.Bytes()))
+	assert.Equal(result.CodeStateNotAvailable, res.Code, res.Message)
+}
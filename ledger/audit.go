@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+)
+
+// HeightMismatch is a block whose replay does not reproduce the stored state root
+type HeightMismatch struct {
+	Height       uint64
+	StoredRoot   common.Hash
+	ComputedRoot common.Hash // empty if the replay failed
+	Message      string      // why the replay failed, empty if the roots just differ
+}
+
+// AuditChain replays each block committed in the given height range, inclusive, from its stored
+// parent state, and compares the recomputed state root with the stored one. Unlike ReplayBlock
+// on each height, it collects all the mismatches rather than stopping at the first one, so it
+// catches the nondeterminism across the whole history. The replays run on throwaway ledger states,
+// so the live ledger state is not modified. It returns an error if a block or its parent state
+// in the range is not available, e.g. after the state has been pruned.
+func (ledger *Ledger) AuditChain(from, to uint64) ([]HeightMismatch, error) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	if from == 0 || from > to {
+		return nil, fmt.Errorf("Invalid height range to audit: [%v, %v]", from, to)
+	}
+
+	mismatches := []HeightMismatch{}
+	for height := from; height <= to; height++ {
+		blockIndexEntry := &BlockIndexEntry{}
+		err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+		if err != nil {
+			return mismatches, fmt.Errorf("Block at height %v is not available: %v", height, err)
+		}
+
+		computedRoot, res := ledger.replayBlock(height, blockIndexEntry)
+		if res.IsError() && res.Code == result.CodeStateNotAvailable {
+			return mismatches, errors.New(res.Message)
+		}
+		if res.IsError() {
+			mismatches = append(mismatches, HeightMismatch{
+				Height:     height,
+				StoredRoot: blockIndexEntry.StateRoot,
+				Message:    res.Message,
+			})
+			continue
+		}
+		if computedRoot != blockIndexEntry.StateRoot {
+			mismatches = append(mismatches, HeightMismatch{
+				Height:       height,
+				StoredRoot:   blockIndexEntry.StateRoot,
+				ComputedRoot: computedRoot,
+			})
+		}
+	}
+
+	log.Infof("Audited the blocks in [%v, %v], %v mismatches", from, to, len(mismatches))
+	return mismatches, nil
+}
@@ -0,0 +1,90 @@
+package ledger
+
+import (
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+)
+
+// blockBatch tracks the blocks applied since BeginBatch
+type blockBatch struct {
+	height    uint64      // height of the ledger state before the batch
+	stateRoot common.Hash // state root of the ledger state before the batch
+	blocks    []batchedBlock
+}
+
+// batchedBlock is a block applied within a batch, which is announced by CommitBatch
+type batchedBlock struct {
+	height    uint64
+	stateRoot common.Hash
+	rawTxs    []common.Bytes
+}
+
+// BeginBatch starts a batch of blocks, e.g. during fast sync. The blocks applied by ApplyBlockTxs
+// within the batch are committed in memory, and written to the persistent storage together by
+// CommitBatch. If any block fails to apply, the whole batch is rolled back to the state before
+// BeginBatch.
+func (ledger *Ledger) BeginBatch() result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	return ledger.beginBatch()
+}
+
+// beginBatch is the non-locking version of BeginBatch
+func (ledger *Ledger) beginBatch() result.Result {
+	if ledger.batch != nil {
+		return result.Error("A batch is already in progress").
+			WithErrorCode(result.CodeBatchAlreadyStarted)
+	}
+	ledger.batch = &blockBatch{
+		height:    ledger.state.Height(),
+		stateRoot: ledger.state.Delivered().Hash(),
+		blocks:    []batchedBlock{},
+	}
+	ledger.state.BeginBatch()
+	return result.OK
+}
+
+// CommitBatch writes the states of the blocks applied since BeginBatch to the persistent storage
+// together with their indexes with a single flush, and then clears their transactions from the
+// mempool.
+func (ledger *Ledger) CommitBatch() result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	return ledger.commitBatch()
+}
+
+// commitBatch is the non-locking version of CommitBatch
+func (ledger *Ledger) commitBatch() result.Result {
+	if ledger.batch == nil {
+		return result.Error("No batch in progress").
+			WithErrorCode(result.CodeNoBatchInProgress)
+	}
+	batch := ledger.batch
+	ledger.batch = nil
+
+	if err := ledger.state.CommitBatch(); err != nil {
+		ledger.resetState(batch.height, batch.stateRoot)
+		return result.Error("Failed to commit the batch: %v", err).
+			WithErrorCode(result.CodeBatchCommitFailed)
+	}
+
+	for _, block := range batch.blocks {
+		ledger.mempool.Update(block.rawTxs)
+		ledger.notifyNewBlock(block.height, block.stateRoot, len(block.rawTxs))
+	}
+	ledger.mempool.EvictExpiredTransactions(ledger.state.Height())
+
+	return result.OK
+}
+
+// rollbackBatch discards the blocks applied since BeginBatch, and resets the ledger state to the
+// state before the batch
+func (ledger *Ledger) rollbackBatch() {
+	batch := ledger.batch
+	ledger.batch = nil
+
+	ledger.state.DiscardBatch()
+	ledger.resetState(batch.height, batch.stateRoot)
+}
@@ -0,0 +1,182 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+	mp "github.com/thetatoken/ukulele/mempool"
+)
+
+func TestLedgerBatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numBlocks := 3
+	accOut, accIns := prepareInitLedgerState(ledger, numBlocks)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+
+	batchLedger := newTestLedgerWithConsensus(chainID, "peer1", ledger.consensus, ledger.valMgr)
+	setInitLedgerState(batchLedger, accOut, accIns)
+
+	res := batchLedger.BeginBatch()
+	require.True(res.IsOK(), res.Message)
+	res = batchLedger.BeginBatch()
+	assert.True(res.IsError())
+
+	applyTestBlocks(t, batchLedger, blocks)
+	targetBlock := blocks[len(blocks)-1]
+	assert.Equal(targetBlock.Height, batchLedger.state.Height())
+	assert.Equal(targetBlock.StateHash, batchLedger.state.Delivered().Hash())
+
+	// Neither the states nor the tx index are persisted before the batch is committed
+	state := st.NewLedgerState(chainID, batchLedger.db)
+	res = state.ResetState(targetBlock.Height, targetBlock.StateHash)
+	assert.True(res.IsError())
+	sendTxHash := crypto.Keccak256Hash(targetBlock.Txs[len(targetBlock.Txs)-1])
+	_, _, err := batchLedger.GetTransaction(sendTxHash)
+	assert.Equal(ErrTxNotFound, err)
+
+	res = batchLedger.CommitBatch()
+	require.True(res.IsOK(), res.Message)
+	res = batchLedger.CommitBatch()
+	assert.True(res.IsError())
+
+	for _, block := range blocks[1:] {
+		res = state.ResetState(block.Height, block.StateHash)
+		assert.True(res.IsOK(), res.Message)
+	}
+	_, txHeight, err := batchLedger.GetTransaction(sendTxHash)
+	require.Nil(err)
+	assert.Equal(targetBlock.Height, txHeight)
+}
+
+func TestLedgerBatchRollback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numBlocks := 2
+	accOut, accIns := prepareInitLedgerState(ledger, numBlocks)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+
+	batchLedger := newTestLedgerWithConsensus(chainID, "peer1", ledger.consensus, ledger.valMgr)
+	setInitLedgerState(batchLedger, accOut, accIns)
+	initHeight := batchLedger.state.Height()
+	initStateRoot := batchLedger.state.Delivered().Hash()
+
+	res := batchLedger.BeginBatch()
+	require.True(res.IsOK(), res.Message)
+	applyTestBlocks(t, batchLedger, blocks[:2])
+
+	// A block failing to apply rolls back the whole batch
+	block := blocks[2]
+	res = batchLedger.ResetState(blocks[1].Height, blocks[1].StateHash)
+	require.True(res.IsOK(), res.Message)
+	res = batchLedger.ApplyBlockTxs(block.Txs, common.Hash{})
+	require.True(res.IsError())
+
+	assert.Nil(batchLedger.batch)
+	assert.Equal(initHeight, batchLedger.state.Height())
+	assert.Equal(initStateRoot, batchLedger.state.Delivered().Hash())
+	sendTxHash := crypto.Keccak256Hash(blocks[1].Txs[len(blocks[1].Txs)-1])
+	_, _, err := batchLedger.GetTransaction(sendTxHash)
+	assert.Equal(ErrTxNotFound, err)
+	res = batchLedger.CommitBatch()
+	assert.True(res.IsError())
+
+	// The blocks can be applied again afterwards
+	res = batchLedger.BeginBatch()
+	require.True(res.IsOK(), res.Message)
+	applyTestBlocks(t, batchLedger, blocks)
+	res = batchLedger.CommitBatch()
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(block.StateHash, batchLedger.state.Delivered().Hash())
+}
+
+func BenchmarkLedgerApplyBlocksWithoutBatch(b *testing.B) {
+	benchmarkLedgerApplyBlocks(b, false)
+}
+
+func BenchmarkLedgerApplyBlocksWithBatch(b *testing.B) {
+	benchmarkLedgerApplyBlocks(b, true)
+}
+
+func benchmarkLedgerApplyBlocks(b *testing.B, batched bool) {
+	numBlocks := 16
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, numBlocks)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		benchLedger := newTestLedgerWithConsensus(chainID, "peer1", ledger.consensus, ledger.valMgr)
+		setInitLedgerState(benchLedger, accOut, accIns)
+		b.StartTimer()
+
+		if batched {
+			benchLedger.BeginBatch()
+		}
+		applyTestBlocks(b, benchLedger, blocks)
+		if batched {
+			benchLedger.CommitBatch()
+		}
+	}
+}
+
+// newTestBlocks proposes and applies a block for each of the input accounts, and returns the blocks
+// preceded by a block for the initial state
+func newTestBlocks(chainID string, ledger *Ledger, mempool *mp.Mempool, accOut types.PrivAccount, accIns []types.PrivAccount) []*core.Block {
+	initBlock := core.NewBlock()
+	initBlock.Height = ledger.state.Height()
+	initBlock.StateHash = ledger.state.Delivered().Hash()
+
+	blocks := []*core.Block{initBlock}
+	for _, accIn := range accIns {
+		parent := blocks[len(blocks)-1]
+		if res := ledger.ResetState(parent.Height, parent.StateHash); res.IsError() {
+			panic(res.Message)
+		}
+
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIn)
+		if err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes)); err != nil {
+			panic(err)
+		}
+
+		stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+		if res.IsError() {
+			panic(res.Message)
+		}
+		if res = ledger.ApplyBlockTxs(blockTxs, stateRoot); res.IsError() {
+			panic(res.Message)
+		}
+
+		block := core.NewBlock()
+		block.Height = ledger.state.Height()
+		block.StateHash = stateRoot
+		block.Txs = blockTxs
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// applyTestBlocks applies the blocks following the first one, each on a fresh view of its parent
+// state, same as the consensus engine does
+func applyTestBlocks(tb testing.TB, ledger *Ledger, blocks []*core.Block) {
+	for idx := 1; idx < len(blocks); idx++ {
+		parent := blocks[idx-1]
+		res := ledger.ResetState(parent.Height, parent.StateHash)
+		require.True(tb, res.IsOK(), res.Message)
+
+		block := blocks[idx]
+		res = ledger.ApplyBlockTxs(block.Txs, block.StateHash)
+		require.True(tb, res.IsOK(), res.Message)
+	}
+}
@@ -0,0 +1,84 @@
+package ledger
+
+import (
+	"container/list"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+)
+
+const (
+	// checkTxCacheSize is the max number of CheckTx results kept in the cache
+	checkTxCacheSize = 4096
+)
+
+type checkTxCacheEntry struct {
+	txHash       common.Hash
+	stateVersion uint64
+	res          result.Result
+}
+
+// checkTxCache is an LRU cache of the CheckTx results. Each result is tagged with the version
+// of the state it was obtained against, and is only valid for that version.
+type checkTxCache struct {
+	capacity int
+	entries  map[common.Hash]*list.Element
+	lru      *list.List // front is the most recently used
+}
+
+func newCheckTxCache(capacity int) *checkTxCache {
+	return &checkTxCache{
+		capacity: capacity,
+		entries:  make(map[common.Hash]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get returns the cached result for the given transaction if it was obtained against the given
+// state version.
+func (cache *checkTxCache) get(txHash common.Hash, stateVersion uint64) (result.Result, bool) {
+	elem, ok := cache.entries[txHash]
+	if !ok {
+		return result.Result{}, false
+	}
+	entry := elem.Value.(*checkTxCacheEntry)
+	if entry.stateVersion != stateVersion {
+		cache.remove(elem)
+		return result.Result{}, false
+	}
+	cache.lru.MoveToFront(elem)
+	return entry.res, true
+}
+
+// put adds the result for the given transaction, and evicts the least recently used entry
+// if the cache is full.
+func (cache *checkTxCache) put(txHash common.Hash, stateVersion uint64, res result.Result) {
+	if elem, ok := cache.entries[txHash]; ok {
+		entry := elem.Value.(*checkTxCacheEntry)
+		entry.stateVersion = stateVersion
+		entry.res = res
+		cache.lru.MoveToFront(elem)
+		return
+	}
+
+	entry := &checkTxCacheEntry{
+		txHash:       txHash,
+		stateVersion: stateVersion,
+		res:          res,
+	}
+	cache.entries[txHash] = cache.lru.PushFront(entry)
+
+	if cache.lru.Len() > cache.capacity {
+		cache.remove(cache.lru.Back())
+	}
+}
+
+// size returns the number of entries in the cache.
+func (cache *checkTxCache) size() int {
+	return cache.lru.Len()
+}
+
+func (cache *checkTxCache) remove(elem *list.Element) {
+	entry := cache.lru.Remove(elem).(*checkTxCacheEntry)
+	delete(cache.entries, entry.txHash)
+}
@@ -0,0 +1,127 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+func TestCheckTxCache(t *testing.T) {
+	assert := assert.New(t)
+
+	cache := newCheckTxCache(2)
+	hash1 := common.BytesToHash([]byte("tx1"))
+	hash2 := common.BytesToHash([]byte("tx2"))
+	hash3 := common.BytesToHash([]byte("tx3"))
+
+	cache.put(hash1, 1, result.Error("tx1"))
+	cache.put(hash2, 1, result.Error("tx2"))
+
+	res, ok := cache.get(hash1, 1)
+	assert.True(ok)
+	assert.Equal("tx1", res.Message)
+
+	// The result is only valid for the state version it was obtained against
+	_, ok = cache.get(hash2, 2)
+	assert.False(ok)
+	assert.Equal(1, cache.size())
+
+	// The least recently used entry is evicted
+	cache.put(hash2, 2, result.Error("tx2"))
+	cache.put(hash3, 2, result.Error("tx3"))
+	assert.Equal(2, cache.size())
+	_, ok = cache.get(hash1, 1)
+	assert.False(ok)
+	_, ok = cache.get(hash2, 2)
+	assert.True(ok)
+	_, ok = cache.get(hash3, 2)
+	assert.True(ok)
+}
+
+func TestLedgerCheckTxCache(t *testing.T) {
+	assert := assert.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+
+	// A transaction with a future sequence fails the check until the state changes
+	futureTxBytes := newRawSendTxWithoutPubKey(chainID, 2, accOut, accIns[0])
+	futureTx, err := types.TxFromBytes(futureTxBytes)
+	assert.Nil(err)
+
+	res := ledger.checkTx(futureTxBytes, futureTx)
+	assert.True(res.IsError())
+	assert.Equal(1, ledger.checkTxCache.size())
+
+	res = ledger.checkTx(futureTxBytes, futureTx)
+	assert.True(res.IsError())
+
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	sendTx, err := types.TxFromBytes(sendTxBytes)
+	assert.Nil(err)
+	res = ledger.checkTx(sendTxBytes, sendTx)
+	assert.True(res.IsOK(), res.Message)
+
+	// The cached result is stale after the checked view has changed
+	res = ledger.checkTx(futureTxBytes, futureTx)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func BenchmarkLedgerCheckTxWithoutCache(b *testing.B) {
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+	invalidTx, _ := types.TxFromBytes(newRawSendTxWithInvalidSignature(chainID, accOut, accIns[0]))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ledger.executor.CheckTx(invalidTx)
+	}
+}
+
+func BenchmarkLedgerCheckTxWithCache(b *testing.B) {
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+	invalidTxBytes := newRawSendTxWithInvalidSignature(chainID, accOut, accIns[0])
+	invalidTx, _ := types.TxFromBytes(invalidTxBytes)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ledger.checkTx(invalidTxBytes, invalidTx)
+	}
+}
+
+// newRawSendTxWithInvalidSignature creates a send transaction that only fails at the signature
+// verification, which is the most expensive part of the check
+func newRawSendTxWithInvalidSignature(chainID string, accOut, accIn types.PrivAccount) common.Bytes {
+	tx, err := types.TxFromBytes(newRawSendTx(chainID, 1, true, accOut, accIn))
+	if err != nil {
+		panic(err)
+	}
+	sendTx := tx.(*types.SendTx)
+	sendTx.Inputs[0].Signature = accOut.Sign(sendTx.SignBytes(chainID))
+	sendTxBytes, err := types.TxToBytes(sendTx)
+	if err != nil {
+		panic(err)
+	}
+	return sendTxBytes
+}
+
+// newRawSendTxWithoutPubKey creates a send transaction for an account that has already
+// sent transactions, i.e. the public key is not included
+func newRawSendTxWithoutPubKey(chainID string, sequence int, accOut, accIn types.PrivAccount) common.Bytes {
+	tx, err := types.TxFromBytes(newRawSendTx(chainID, sequence, true, accOut, accIn))
+	if err != nil {
+		panic(err)
+	}
+	sendTx := tx.(*types.SendTx)
+	sendTx.Inputs[0].PubKey = nil
+	sendTx.Inputs[0].Signature = accIn.Sign(sendTx.SignBytes(chainID))
+	sendTxBytes, err := types.TxToBytes(sendTx)
+	if err != nil {
+		panic(err)
+	}
+	return sendTxBytes
+}
@@ -36,9 +36,22 @@ import (
 // }
 
 // getValidatorAddresses returns validators' addresses
-func getValidatorAddresses(consensus core.ConsensusEngine, valMgr core.ValidatorManager) []common.Address {
-	epoch := consensus.GetEpoch()
-	validators := valMgr.GetValidatorSetForEpoch(epoch).Validators()
+func getValidators(view *state.StoreView, consensus core.ConsensusEngine, valMgr core.ValidatorManager) []core.Validator {
+	epoch := getEpoch(view, consensus)
+	return valMgr.GetValidatorSetForEpoch(epoch).Validators()
+}
+
+// getEpoch returns the epoch of the block being executed, or the current epoch of the consensus
+// engine if the view is not bound to a block, e.g. when the block is being proposed
+func getEpoch(view *state.StoreView, consensus core.ConsensusEngine) uint64 {
+	if epoch, ok := view.Epoch(); ok {
+		return epoch
+	}
+	return consensus.GetEpoch()
+}
+
+func getValidatorAddresses(view *state.StoreView, consensus core.ConsensusEngine, valMgr core.ValidatorManager) []common.Address {
+	validators := getValidators(view, consensus, valMgr)
 	validatorAddresses := make([]common.Address, len(validators))
 	for i, v := range validators {
 		validatorAddresses[i] = v.Address()
@@ -160,6 +173,42 @@ func getOrMakeOutputs(view *state.StoreView, accounts map[string]*types.Account,
 	return accounts, result.OK
 }
 
+// validateTxDataSize checks the arbitrary data carried by the transaction, i.e. its free-form
+// fields such as the smart contract data, the slash proof and the resource IDs, does not exceed
+// the limit
+func validateTxDataSize(tx types.Tx) result.Result {
+	dataSize := 0
+	switch tx := tx.(type) {
+	case *types.SmartContractTx:
+		dataSize = len(tx.Data)
+	case *types.SlashTx:
+		dataSize = len(tx.SlashProof)
+	case *types.ReserveFundTx:
+		for _, resourceID := range tx.ResourceIDs {
+			dataSize += len(resourceID)
+		}
+	case *types.ServicePaymentTx:
+		dataSize = len(tx.ResourceID)
+	case *types.SplitRuleTx:
+		dataSize = len(tx.ResourceID)
+	}
+	if dataSize > core.MaxTxDataBytes {
+		return result.Error("Transaction data size %v exceeds the limit %v", dataSize, core.MaxTxDataBytes).
+			WithErrorCode(result.CodeTxDataTooLarge)
+	}
+	return result.OK
+}
+
+// validateTxExpiry checks the transaction has not expired at the height of the given view
+func validateTxExpiry(view *state.StoreView, tx types.Tx) result.Result {
+	validUntilHeight := types.GetValidUntilHeight(tx)
+	if validUntilHeight != 0 && view.Height() > validUntilHeight {
+		return result.Error("Transaction expired at height %v, current height: %v", validUntilHeight, view.Height()).
+			WithErrorCode(result.CodeTxExpired)
+	}
+	return result.OK
+}
+
 // Validate inputs basic structure
 func validateInputsBasic(ins []types.TxInput) result.Result {
 	for _, in := range ins {
@@ -172,30 +221,37 @@ func validateInputsBasic(ins []types.TxInput) result.Result {
 }
 
 // Validate inputs and compute total amount of coins
-func validateInputsAdvanced(accounts map[string]*types.Account, signBytes []byte, ins []types.TxInput) (total types.Coins, res result.Result) {
+func validateInputsAdvanced(view *state.StoreView, accounts map[string]*types.Account, signBytes []byte, ins []types.TxInput) (total types.Coins, res result.Result) {
 	total = types.NewCoins(0, 0)
 	for _, in := range ins {
 		acc := accounts[string(in.Address[:])]
 		if acc == nil {
 			panic("validateInputsAdvanced() expects account in accounts")
 		}
-		res = validateInputAdvanced(acc, signBytes, in)
+		res = validateInputAdvanced(view, acc, signBytes, in)
 		if res.IsError() {
 			return
 		}
 		// Good. Add amount to total
-		total = total.Plus(in.Coins)
+		var err error
+		total, err = total.CheckedPlus(in.Coins)
+		if err != nil {
+			return total, result.Error("Invalid input total: %v", err).WithErrorCode(result.CodeCoinOverflow)
+		}
 	}
 	return total, result.OK
 }
 
-func validateInputAdvanced(acc *types.Account, signBytes []byte, in types.TxInput) result.Result {
+func validateInputAdvanced(view *state.StoreView, acc *types.Account, signBytes []byte, in types.TxInput) result.Result {
+	if res := checkAccountNotFrozen(acc, in.Address); res.IsError() {
+		return res
+	}
+
 	// Check sequence/coins
-	seq, balance := acc.Sequence, acc.Balance
-	if seq+1 != in.Sequence {
-		return result.Error("Got %v, expected %v. (acc.seq=%v)",
-			in.Sequence, seq+1, acc.Sequence).WithErrorCode(result.CodeInvalidSequence)
+	if res := checkSequence(acc, in); res.IsError() {
+		return res
 	}
+	balance := acc.Balance
 
 	// Check amount
 	if !balance.IsGTE(in.Coins) {
@@ -203,13 +259,39 @@ func validateInputAdvanced(acc *types.Account, signBytes []byte, in types.TxInpu
 			balance, in.Coins).WithErrorCode(result.CodeInsufficientFund)
 	}
 
+	// Check the amount does not include the coinbase rewards that have not matured yet
+	lockedRewards := view.GetLockedRewards(in.Address)
+	if !balance.Minus(lockedRewards).IsGTE(in.Coins) {
+		return result.Error("balance is %v including immature coinbase rewards %v, tried to send %v",
+			balance, lockedRewards, in.Coins).WithErrorCode(result.CodeImmatureCoinbaseReward)
+	}
+
+	// Check the signatures of a multisig account against its authorized keys
+	if policy := view.GetMultisigPolicy(in.Address); policy != nil {
+		if !in.IsMultisig() {
+			return result.Error("Multisig account %v requires multiple signatures", in.Address).
+				WithErrorCode(result.CodeInvalidSignature)
+		}
+		return policy.VerifySignatures(signBytes, in.Signatures)
+	}
+	if in.IsMultisig() {
+		return result.Error("Account %v does not have a multisig policy", in.Address).
+			WithErrorCode(result.CodeInvalidSignature)
+	}
+
 	// Check pubkey
 	if acc.PubKey.IsEmpty() {
 		return result.Error("Account pubkey is nil!")
 	}
 
+	// Check the signature is of the scheme of the pubkey, the verification dispatches on it
+	if in.Signature != nil && in.Signature.Scheme() != acc.PubKey.Scheme() {
+		return result.Error("Signature scheme %v does not match the %v pubkey of the account",
+			in.Signature.Scheme(), acc.PubKey.Scheme()).WithErrorCode(result.CodeInvalidSignature)
+	}
+
 	// Check signatures
-	if !acc.PubKey.VerifySignature(signBytes, in.Signature) {
+	if !verifySignature(view, acc.PubKey, signBytes, in.Signature) {
 		return result.Error("Signature verification failed, SignBytes: %v",
 			hex.EncodeToString(signBytes)).WithErrorCode(result.CodeInvalidSignature)
 	}
@@ -217,6 +299,34 @@ func validateInputAdvanced(acc *types.Account, signBytes []byte, in types.TxInpu
 	return result.OK
 }
 
+// verifySignature verifies the signature against the public key, unless the signature has been
+// verified ahead of the execution of the block
+func verifySignature(view *state.StoreView, pubKey *crypto.PublicKey, signBytes []byte, sig *crypto.Signature) bool {
+	return view.IsSignatureVerified(pubKey, signBytes, sig) || pubKey.VerifySignature(signBytes, sig)
+}
+
+// checkSequence verifies that the input carries the next sequence of the sending account. Every
+// tx type verifies the sequence of its sender with checkSequence in sanityCheck(), and increments
+// it with incrementSequence() in process(), so that the sequence is left untouched on failure.
+func checkSequence(acc *types.Account, in types.TxInput) result.Result {
+	if acc.Sequence+1 != in.Sequence {
+		return result.Error("Got %v, expected %v. (acc.seq=%v)",
+			in.Sequence, acc.Sequence+1, acc.Sequence).WithErrorCode(result.CodeInvalidSequence).
+			WithInfo(&types.SequenceGap{
+				Address:          in.Address,
+				Sequence:         in.Sequence,
+				ExpectedSequence: acc.Sequence + 1,
+			})
+	}
+	return result.OK
+}
+
+// incrementSequence bumps the sequence of the sending account by one. It should be called exactly
+// once for each sender of a successfully processed transaction.
+func incrementSequence(acc *types.Account) {
+	acc.Sequence++
+}
+
 func validateOutputsBasic(outs []types.TxOutput) result.Result {
 	for _, out := range outs {
 		// Check TxOutput basic
@@ -227,12 +337,31 @@ func validateOutputsBasic(outs []types.TxOutput) result.Result {
 	return result.OK
 }
 
-func sumOutputs(outs []types.TxOutput) types.Coins {
+// Validate outputs, advanced, i.e. that the balances of the output accounts do not overflow
+func validateOutputsAdvanced(accounts map[string]*types.Account, outs []types.TxOutput) result.Result {
+	for _, out := range outs {
+		acc := accounts[string(out.Address[:])]
+		if acc == nil {
+			panic("validateOutputsAdvanced() expects account in accounts")
+		}
+		if _, err := acc.Balance.CheckedPlus(out.Coins); err != nil {
+			return result.Error("Invalid balance of output %v: %v", out.Address.Hex(), err).
+				WithErrorCode(result.CodeCoinOverflow)
+		}
+	}
+	return result.OK
+}
+
+func sumOutputs(outs []types.TxOutput) (types.Coins, result.Result) {
 	total := types.NewCoins(0, 0)
 	for _, out := range outs {
-		total = total.Plus(out.Coins)
+		var err error
+		total, err = total.CheckedPlus(out.Coins)
+		if err != nil {
+			return total, result.Error("Invalid output total: %v", err).WithErrorCode(result.CodeCoinOverflow)
+		}
 	}
-	return total
+	return total, result.OK
 }
 
 // Note: Since totalInput == totalOutput + fee, the transaction fee is charged implicitly
@@ -243,11 +372,12 @@ func adjustByInputs(view *state.StoreView, accounts map[string]*types.Account, i
 		if acc == nil {
 			panic("adjustByInputs() expects account in accounts")
 		}
-		if !acc.Balance.IsGTE(in.Coins) {
+		balance, err := acc.Balance.CheckedMinus(in.Coins)
+		if err != nil {
 			panic("adjustByInputs() expects sufficient funds")
 		}
-		acc.Balance = acc.Balance.Minus(in.Coins)
-		acc.Sequence++
+		acc.Balance = balance
+		incrementSequence(acc)
 		view.SetAccount(in.Address, acc)
 	}
 }
@@ -258,17 +388,21 @@ func adjustByOutputs(view *state.StoreView, accounts map[string]*types.Account,
 		if acc == nil {
 			panic("adjustByOutputs() expects account in accounts")
 		}
-		acc.Balance = acc.Balance.Plus(out.Coins)
+		balance, err := acc.Balance.CheckedPlus(out.Coins)
+		if err != nil {
+			panic("adjustByOutputs() expects the balances not to overflow")
+		}
+		acc.Balance = balance
 		view.SetAccount(out.Address, acc)
 	}
 }
 
-func sanityCheckForGasPrice(gasPrice *big.Int) bool {
+func sanityCheckForGasPrice(view *state.StoreView, gasPrice *big.Int) bool {
 	if gasPrice == nil {
 		return false
 	}
 
-	minimumGasPrice := new(big.Int).SetUint64(types.MinimumGasPrice)
+	minimumGasPrice := new(big.Int).SetUint64(view.GetChainParams().MinimumGasPrice)
 	if gasPrice.Cmp(minimumGasPrice) < 0 {
 		return false
 	}
@@ -276,17 +410,26 @@ func sanityCheckForGasPrice(gasPrice *big.Int) bool {
 	return true
 }
 
-func sanityCheckForFee(fee types.Coins) bool {
+func sanityCheckForFee(view *state.StoreView, fee types.Coins) bool {
 	fee = fee.NoNil()
-	minimumFee := new(big.Int).SetUint64(types.MinimumTransactionFeeGammaWei)
+	minimumFee := new(big.Int).SetUint64(view.GetChainParams().MinimumTransactionFeeGammaWei)
 	return fee.ThetaWei.Cmp(types.Zero) == 0 && fee.GammaWei.Cmp(minimumFee) >= 0
 }
 
 func chargeFee(account *types.Account, fee types.Coins) bool {
-	if !account.Balance.IsGTE(fee) {
+	balance, err := account.Balance.CheckedMinus(fee)
+	if err != nil {
 		return false
 	}
 
-	account.Balance = account.Balance.Minus(fee)
+	account.Balance = balance
 	return true
 }
+
+// checkAccountNotFrozen rejects spending from a frozen account
+func checkAccountNotFrozen(acc *types.Account, address common.Address) result.Result {
+	if acc.Frozen {
+		return result.Error("Account %v is frozen", address.Hex()).WithErrorCode(result.CodeAccountFrozen)
+	}
+	return result.OK
+}
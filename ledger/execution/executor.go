@@ -28,11 +28,14 @@ type Executor struct {
 	slashTxExec           *SlashTxExecutor
 	updateValidatorTxExec *UpdateValidatorsTxExecutor
 	sendTxExec            *SendTxExecutor
+	multiSendTxExec       *MultiSendTxExecutor
 	reserveFundTxExec     *ReserveFundTxExecutor
 	releaseFundTxExec     *ReleaseFundTxExecutor
 	servicePaymentTxExec  *ServicePaymentTxExecutor
 	splitRuleTxExec       *SplitRuleTxExecutor
 	smartContractTxExec   *SmartContractTxExecutor
+	paramUpdateTxExec     *ParamUpdateTxExecutor
+	freezeAccountTxExec   *FreezeAccountTxExecutor
 
 	skipSanityCheck bool
 }
@@ -45,13 +48,16 @@ func NewExecutor(state *st.LedgerState, consensus core.ConsensusEngine, valMgr c
 		valMgr:                valMgr,
 		coinbaseTxExec:        NewCoinbaseTxExecutor(state, consensus, valMgr),
 		slashTxExec:           NewSlashTxExecutor(consensus, valMgr),
-		updateValidatorTxExec: NewUpdateValidatorsTxExecutor(state),
+		updateValidatorTxExec: NewUpdateValidatorsTxExecutor(state, consensus),
 		sendTxExec:            NewSendTxExecutor(),
+		multiSendTxExec:       NewMultiSendTxExecutor(),
 		reserveFundTxExec:     NewReserveFundTxExecutor(state),
 		releaseFundTxExec:     NewReleaseFundTxExecutor(state),
 		servicePaymentTxExec:  NewServicePaymentTxExecutor(state),
 		splitRuleTxExec:       NewSplitRuleTxExecutor(state),
 		smartContractTxExec:   NewSmartContractTxExecutor(),
+		paramUpdateTxExec:     NewParamUpdateTxExecutor(),
+		freezeAccountTxExec:   NewFreezeAccountTxExecutor(),
 		skipSanityCheck:       false,
 	}
 
@@ -64,16 +70,41 @@ func (exec *Executor) SetSkipSanityCheck(skip bool) {
 	exec.skipSanityCheck = skip
 }
 
+// CalculateReward calculates the reward of each validator for the block at the current height.
+func (exec *Executor) CalculateReward(view *st.StoreView, validatorAddresses []common.Address) map[string]types.Coins {
+	return NewRewardPolicyFromChainParams(view.GetChainParams()).CalculateReward(view, exec.state.Height(), validatorAddresses)
+}
+
+// CalculateRewardSorted is the same as CalculateReward, except that it returns the rewards sorted
+// by the account addresses.
+func (exec *Executor) CalculateRewardSorted(view *st.StoreView, validatorAddresses []common.Address) []AccountReward {
+	return SortRewards(exec.CalculateReward(view, validatorAddresses))
+}
+
 // ExecuteTx executes the given transaction
 func (exec *Executor) ExecuteTx(tx types.Tx) (common.Hash, result.Result) {
 	return exec.processTx(tx, core.DeliveredView)
 }
 
-// CheckTx checks the validity of the given transaction
+// CheckTx checks the validity of the given transaction, and applies it to the checked view if valid
 func (exec *Executor) CheckTx(tx types.Tx) (common.Hash, result.Result) {
 	return exec.processTx(tx, core.CheckedView)
 }
 
+// CheckTxReadOnly fully validates the given transaction, i.e. the signatures, the sequences, the
+// balances and the gas, by executing it on a copy of the checked view, which is left unchanged.
+// Since it runs the same checks and processing as ExecuteTx, a transaction passing CheckTxReadOnly
+// is guaranteed to pass ExecuteTx on a delivered view with the same state as the checked view,
+// unless the state changes in between.
+func (exec *Executor) CheckTxReadOnly(tx types.Tx) (common.Hash, result.Result) {
+	view, err := exec.state.Checked().Copy()
+	if err != nil {
+		return common.Hash{}, result.Error("Failed to copy the checked view: %v", err).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+	return exec.CheckTxOnView(view, tx)
+}
+
 // ScreenTx checks the validity of the given transaction
 func (exec *Executor) ScreenTx(tx types.Tx) (common.Hash, result.Result) {
 	return exec.processTx(tx, core.ScreenedView)
@@ -92,6 +123,16 @@ func (exec *Executor) processTx(tx types.Tx, viewSel core.ViewSelector) (common.
 		view = exec.state.Screened()
 	}
 
+	return exec.processTxOnView(chainID, view, tx)
+}
+
+// CheckTxOnView checks the validity of the given transaction against the given view instead of
+// the checked view of the ledger state, e.g. to simulate transactions on a copy of a view.
+func (exec *Executor) CheckTxOnView(view *st.StoreView, tx types.Tx) (common.Hash, result.Result) {
+	return exec.processTxOnView(exec.state.GetChainID(), view, tx)
+}
+
+func (exec *Executor) processTxOnView(chainID string, view *st.StoreView, tx types.Tx) (common.Hash, result.Result) {
 	sanityCheckResult := exec.sanityCheck(chainID, view, tx)
 	if sanityCheckResult.IsError() {
 		return common.Hash{}, sanityCheckResult
@@ -106,6 +147,14 @@ func (exec *Executor) sanityCheck(chainID string, view *st.StoreView, tx types.T
 		return result.OK
 	}
 
+	if res := validateTxDataSize(tx); res.IsError() {
+		return res
+	}
+
+	if res := validateTxExpiry(view, tx); res.IsError() {
+		return res
+	}
+
 	var sanityCheckResult result.Result
 	txExecutor := exec.getTxExecutor(tx)
 	if txExecutor != nil {
@@ -139,6 +188,8 @@ func (exec *Executor) getTxExecutor(tx types.Tx) TxExecutor {
 		txExecutor = exec.slashTxExec
 	case *types.SendTx:
 		txExecutor = exec.sendTxExec
+	case *types.MultiSendTx:
+		txExecutor = exec.multiSendTxExec
 	case *types.ReserveFundTx:
 		txExecutor = exec.reserveFundTxExec
 	case *types.ReleaseFundTx:
@@ -151,6 +202,10 @@ func (exec *Executor) getTxExecutor(tx types.Tx) TxExecutor {
 		txExecutor = exec.updateValidatorTxExec
 	case *types.SmartContractTx:
 		txExecutor = exec.smartContractTxExec
+	case *types.ParamUpdateTx:
+		txExecutor = exec.paramUpdateTxExec
+	case *types.FreezeAccountTx:
+		txExecutor = exec.freezeAccountTxExec
 	default:
 		txExecutor = nil
 	}
@@ -1,13 +1,20 @@
 package execution
 
 import (
+	"bytes"
 	"encoding/hex"
+	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/consensus"
+	"github.com/thetatoken/ukulele/core"
 	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/ledger/types"
 )
@@ -134,12 +141,12 @@ func TestValidateInputsAdvanced(t *testing.T) {
 	signBytes := tx.SignBytes(et.chainID)
 
 	//test bad case, unsigned
-	totalCoins, res := validateInputsAdvanced(accMap, signBytes, tx.Inputs)
+	totalCoins, res := validateInputsAdvanced(et.state().Delivered(), accMap, signBytes, tx.Inputs)
 	assert.True(res.IsError(), "validateInputsAdvanced: expected an error on an unsigned tx input")
 
 	//test good case sgined
 	et.signSendTx(tx, accIn1, accIn2, accIn3, et.accOut)
-	totalCoins, res = validateInputsAdvanced(accMap, signBytes, tx.Inputs)
+	totalCoins, res = validateInputsAdvanced(et.state().Delivered(), accMap, signBytes, tx.Inputs)
 	assert.True(res.IsOK(), "validateInputsAdvanced: expected no error on good tx input. Error: %v", res.Message)
 
 	txTotalCoins := tx.Inputs[0].Coins.
@@ -161,25 +168,29 @@ func TestValidateInputAdvanced(t *testing.T) {
 	signBytes := tx.SignBytes(et.chainID)
 
 	//unsigned case
-	res := validateInputAdvanced(&et.accIn.Account, signBytes, tx.Inputs[0])
+	res := validateInputAdvanced(et.state().Delivered(), &et.accIn.Account, signBytes, tx.Inputs[0])
 	assert.True(res.IsError(), "validateInputAdvanced: expected error on tx input without signature")
 
 	//good signed case
 	et.signSendTx(tx, et.accIn, et.accOut)
-	res = validateInputAdvanced(&et.accIn.Account, signBytes, tx.Inputs[0])
+	res = validateInputAdvanced(et.state().Delivered(), &et.accIn.Account, signBytes, tx.Inputs[0])
 	assert.True(res.IsOK(), "validateInputAdvanced: expected no error on good tx input. Error: %v", res.Message)
 
 	//bad sequence case
 	et.accIn.Sequence = 1
 	et.signSendTx(tx, et.accIn, et.accOut)
-	res = validateInputAdvanced(&et.accIn.Account, signBytes, tx.Inputs[0])
+	res = validateInputAdvanced(et.state().Delivered(), &et.accIn.Account, signBytes, tx.Inputs[0])
 	assert.Equal(result.CodeInvalidSequence, res.Code, "validateInputAdvanced: expected error on tx input with bad sequence")
+	sequenceGap, ok := res.Info.(*types.SequenceGap)
+	assert.True(ok)
+	assert.Equal(tx.Inputs[0].Sequence, sequenceGap.Sequence)
+	assert.Equal(uint64(2), sequenceGap.ExpectedSequence)
 	et.accIn.Sequence = 0 //restore sequence
 
 	//bad balance case
 	et.accIn.Balance = types.NewCoins(2, 0)
 	et.signSendTx(tx, et.accIn, et.accOut)
-	res = validateInputAdvanced(&et.accIn.Account, signBytes, tx.Inputs[0])
+	res = validateInputAdvanced(et.state().Delivered(), &et.accIn.Account, signBytes, tx.Inputs[0])
 	assert.Equal(result.CodeInsufficientFund, res.Code,
 		"validateInputAdvanced: expected error on tx input with insufficient funds %v", et.accIn.Sequence)
 }
@@ -204,7 +215,8 @@ func TestSumOutput(t *testing.T) {
 
 	//SumOutput
 	tx := types.Accs2TxOutputs(et.accIn, et.accOut)
-	total := sumOutputs(tx)
+	total, res := sumOutputs(tx)
+	assert.True(res.IsOK(), res.Message)
 	assert.True(total.IsEqual(tx[0].Coins.Plus(tx[1].Coins)), "sumOutputs: total coins are not equal")
 }
 
@@ -281,6 +293,353 @@ func TestSendTx(t *testing.T) {
 		"ExecTx/good DeliverTx: unexpected change in output balance, got: %v, expected: %v", balOut, balOutExp)
 }
 
+func TestSendTxBalanceOverflow(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+
+	// The balance of the output account is at the maximum amount
+	et.accOut.Balance = types.Coins{
+		ThetaWei: new(big.Int).Set(types.MaxCoinAmount),
+		GammaWei: big.NewInt(0),
+	}
+	et.acc2State(et.accIn, et.accOut)
+	et.fastforwardBy(1)
+	tx := types.MakeSendTx(1, et.accOut, et.accIn)
+	et.signSendTx(tx, et.accIn)
+
+	_, res := et.executor.ScreenTx(tx)
+	assert.Equal(result.CodeCoinOverflow, res.Code, res.Message)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.Equal(result.CodeCoinOverflow, res.Code, res.Message)
+	accOut := et.state().Delivered().GetAccount(et.accOut.PubKey.Address())
+	assert.Equal(types.MaxCoinAmount, accOut.Balance.ThetaWei)
+
+	// Output total overflows
+	outputs := []types.TxOutput{
+		{Address: et.accOut.PubKey.Address(), Coins: types.Coins{ThetaWei: types.MaxCoinAmount}},
+		{Address: et.accIn.PubKey.Address(), Coins: types.NewCoins(1, 0)},
+	}
+	_, res = sumOutputs(outputs)
+	assert.Equal(result.CodeCoinOverflow, res.Code, res.Message)
+}
+
+func TestSendTxChainParams(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+
+	et.acc2State(et.accIn, et.accOut)
+	params := et.state().Delivered().GetChainParams()
+	assert.Equal(types.DefaultChainParams(), params)
+
+	// Double the minimum transaction fee
+	params.MinimumTransactionFeeGammaWei = 2 * types.MinimumTransactionFeeGammaWei
+	et.state().Delivered().SetChainParams(params)
+	et.fastforwardBy(1)
+	assert.Equal(params, et.state().Screened().GetChainParams())
+
+	tx := types.MakeSendTx(1, et.accOut, et.accIn)
+	et.signSendTx(tx, et.accIn)
+	_, res := et.executor.ScreenTx(tx)
+	assert.Equal(result.CodeInvalidFee, res.Code, res.Message)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.Equal(result.CodeInvalidFee, res.Code, res.Message)
+
+	fee := int64(params.MinimumTransactionFeeGammaWei)
+	tx.Fee = types.NewCoins(0, fee)
+	tx.Inputs[0].Coins = types.NewCoins(tx.Outputs[0].Coins.ThetaWei.Int64(), fee)
+	et.signSendTx(tx, et.accIn)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestSendTxCrossChainReplay(t *testing.T) {
+	assert := assert.New(t)
+	et := newExecTestWithChainID("main")
+
+	// The transaction is signed for chain "test"
+	tx := types.MakeSendTx(1, et.accOut, et.accIn)
+	types.SignSendTx("test", tx, et.accIn)
+	et.acc2State(et.accIn)
+	et.acc2State(et.accOut)
+
+	res, _, _, _, _ := et.execSendTx(tx, true)
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.Message)
+
+	res, balIn, balInExp, balOut, _ := et.execSendTx(tx, false)
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.Message)
+	assert.False(balIn.IsEqual(balInExp), "the input balance should not change")
+	assert.True(balOut.IsEqual(et.accOut.Balance), "the output balance should not change")
+
+	// The same transaction signed for chain "main" is accepted
+	types.SignSendTx("main", tx, et.accIn)
+	res, _, _, _, _ = et.execSendTx(tx, false)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestSendTxValidUntilHeight(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+	et.acc2State(et.accIn, et.accOut)
+
+	// Expired transaction
+	et.fastforwardTo(100)
+	tx := types.MakeSendTx(1, et.accOut, et.accIn)
+	tx.ValidUntilHeight = 99
+	et.signSendTx(tx, et.accIn)
+	_, res := et.executor.ScreenTx(tx)
+	assert.Equal(result.CodeTxExpired, res.Code, res.Message)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.Equal(result.CodeTxExpired, res.Code, res.Message)
+
+	// The transaction is still valid at its last height
+	tx.ValidUntilHeight = 100
+	et.signSendTx(tx, et.accIn)
+	_, res = et.executor.ScreenTx(tx)
+	assert.True(res.IsOK(), res.Message)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestSendTxSignatureSchemes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	edPrivKey, err := crypto.Ed25519PrivateKeyFromSeed(common.Bytes("ed25519_seed_of_thirty_two_bytes"))
+	require.Nil(err)
+	edAcc := types.PrivAccount{
+		PrivKey: edPrivKey,
+		Account: types.Account{
+			PubKey:                 edPrivKey.PublicKey(),
+			Balance:                types.NewCoins(7*10e12, 5*10e12),
+			LastUpdatedBlockHeight: 1,
+		},
+	}
+
+	for _, scheme := range []crypto.SignatureScheme{crypto.SchemeSecp256k1, crypto.SchemeEd25519} {
+		et := NewExecTest()
+		if scheme == crypto.SchemeEd25519 {
+			et.accIn = edAcc
+		}
+		et.acc2State(et.accIn, et.accOut)
+
+		tx := types.MakeSendTx(1, et.accOut, et.accIn)
+		et.signSendTx(tx, et.accIn)
+		sig := tx.Inputs[0].Signature
+		assert.Equal(scheme, sig.Scheme())
+
+		// The signature tagged with the other scheme is rejected
+		otherScheme := crypto.SchemeEd25519
+		if scheme == crypto.SchemeEd25519 {
+			otherScheme = crypto.SchemeSecp256k1
+		}
+		tx.Inputs[0].Signature, _ = crypto.SignatureFromBytesWithScheme(otherScheme, sig.ToBytes())
+		_, res := et.executor.ScreenTx(tx)
+		assert.True(res.IsError(), "scheme %v", scheme)
+		assert.Equal(result.CodeInvalidSignature, res.Code)
+
+		// The signature of an unsupported scheme is rejected
+		tx.Inputs[0].Signature, _ = crypto.SignatureFromBytesWithScheme(crypto.SignatureScheme(7), sig.ToBytes())
+		_, res = et.executor.ScreenTx(tx)
+		assert.True(res.IsError(), "scheme %v", scheme)
+
+		// The signature with the scheme of the key is accepted, also after the encoding round trip
+		tx.Inputs[0].Signature = sig
+		raw, err := types.TxToBytes(tx)
+		require.Nil(err)
+		decoded, err := types.TxFromBytes(raw)
+		require.Nil(err)
+		assert.Equal(scheme, decoded.(*types.SendTx).Inputs[0].Signature.Scheme())
+
+		res, balIn, balInExp, balOut, balOutExp := et.execSendTx(decoded.(*types.SendTx), false)
+		assert.True(res.IsOK(), "scheme %v: %v", scheme, res.Message)
+		assert.True(balIn.IsEqual(balInExp))
+		assert.True(balOut.IsEqual(balOutExp))
+	}
+}
+
+func TestMultiSendTx(t *testing.T) {
+	assert := assert.New(t)
+
+	txFee := getMinimumTxFee()
+	newMultiSendTx := func(et *execTest, outAddrs []common.Address, amount int64) *types.MultiSendTx {
+		outputs := []types.TxOutput{}
+		for _, outAddr := range outAddrs {
+			outputs = append(outputs, types.TxOutput{Address: outAddr, Coins: types.NewCoins(amount, 0)})
+		}
+		tx := &types.MultiSendTx{
+			Fee: types.NewCoins(0, txFee),
+			Input: types.TxInput{
+				Address:  et.accIn.PubKey.Address(),
+				PubKey:   et.accIn.PubKey,
+				Coins:    types.NewCoins(amount*int64(len(outAddrs)), txFee),
+				Sequence: 1,
+			},
+			Outputs: outputs,
+		}
+		tx.Input.Signature = et.accIn.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	// Successful transfer
+	et := NewExecTest()
+	et.acc2State(et.accIn, et.accOut)
+	newAddr := types.MakeAcc("new").PubKey.Address()
+	tx := newMultiSendTx(et, []common.Address{et.accOut.PubKey.Address(), newAddr}, 1000)
+
+	_, res := et.executor.ScreenTx(tx)
+	assert.True(res.IsOK(), res.Message)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.True(res.IsOK(), res.Message)
+
+	view := et.state().Delivered()
+	inAcc := view.GetAccount(et.accIn.PubKey.Address())
+	assert.True(inAcc.Balance.IsEqual(et.accIn.Balance.Minus(types.NewCoins(2000, txFee))), "%v", inAcc.Balance)
+	assert.Equal(uint64(1), inAcc.Sequence)
+	outAcc := view.GetAccount(et.accOut.PubKey.Address())
+	assert.True(outAcc.Balance.IsEqual(et.accOut.Balance.Plus(types.NewCoins(1000, 0))), "%v", outAcc.Balance)
+	newAcc := view.GetAccount(newAddr)
+	assert.True(newAcc.Balance.IsEqual(types.NewCoins(1000, 0)), "%v", newAcc.Balance)
+
+	// Insufficient balance for the total of the outputs
+	et = NewExecTest()
+	et.acc2State(et.accIn, et.accOut)
+	amount := et.accIn.Balance.ThetaWei.Int64()/2 + 1
+	tx = newMultiSendTx(et, []common.Address{et.accOut.PubKey.Address(), newAddr}, amount)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.Equal(result.CodeInsufficientFund, res.Code, res.Message)
+
+	// Duplicated output addresses
+	et = NewExecTest()
+	et.acc2State(et.accIn, et.accOut)
+	tx = newMultiSendTx(et, []common.Address{et.accOut.PubKey.Address(), et.accOut.PubKey.Address()}, 1000)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.True(res.IsError())
+	outAcc = et.state().Delivered().GetAccount(et.accOut.PubKey.Address())
+	assert.True(outAcc.Balance.IsEqual(et.accOut.Balance), "%v", outAcc.Balance)
+}
+
+func TestTxSequenceConsistency(t *testing.T) {
+	assert := assert.New(t)
+
+	sequenceOf := func(et *execTest, addr common.Address) uint64 {
+		return et.state().Delivered().GetAccount(addr).Sequence
+	}
+
+	// SendTx
+	et := NewExecTest()
+	et.acc2State(et.accIn, et.accOut)
+	inAddr, outAddr := et.accIn.PubKey.Address(), et.accOut.PubKey.Address()
+
+	sendTx := types.MakeSendTx(2, et.accOut, et.accIn)
+	et.signSendTx(sendTx, et.accIn)
+	_, res := et.executor.ExecuteTx(sendTx)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+	assert.Equal(uint64(0), sequenceOf(et, inAddr))
+
+	sendTx = types.MakeSendTx(1, et.accOut, et.accIn)
+	sendTx.Outputs[0].Coins = et.accIn.Balance
+	sendTx.Inputs[0].Coins = et.accIn.Balance.Plus(sendTx.Fee)
+	et.signSendTx(sendTx, et.accIn)
+	_, res = et.executor.ExecuteTx(sendTx)
+	assert.Equal(result.CodeInsufficientFund, res.Code, res.Message)
+	assert.Equal(uint64(0), sequenceOf(et, inAddr))
+
+	sendTx = types.MakeSendTx(1, et.accOut, et.accIn)
+	et.signSendTx(sendTx, et.accIn)
+	_, res = et.executor.ExecuteTx(sendTx)
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(uint64(1), sequenceOf(et, inAddr))
+	assert.Equal(uint64(0), sequenceOf(et, outAddr))
+
+	// Replaying the transaction does not bump the sequence again
+	_, res = et.executor.ExecuteTx(sendTx)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+	assert.Equal(uint64(1), sequenceOf(et, inAddr))
+
+	// MultiSendTx
+	txFee := getMinimumTxFee()
+	newMultiSendTx := func(et *execTest, sequence uint64, amount int64) *types.MultiSendTx {
+		tx := &types.MultiSendTx{
+			Fee: types.NewCoins(0, txFee),
+			Input: types.TxInput{
+				Address:  inAddr,
+				PubKey:   et.accIn.PubKey,
+				Coins:    types.NewCoins(2*amount, txFee),
+				Sequence: sequence,
+			},
+			Outputs: []types.TxOutput{
+				{Address: outAddr, Coins: types.NewCoins(amount, 0)},
+				{Address: types.MakeAcc("new").PubKey.Address(), Coins: types.NewCoins(amount, 0)},
+			},
+		}
+		tx.Input.Signature = et.accIn.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	et = NewExecTest()
+	et.acc2State(et.accIn, et.accOut)
+	inAddr, outAddr = et.accIn.PubKey.Address(), et.accOut.PubKey.Address() // new accounts of the new test
+
+	_, res = et.executor.ExecuteTx(newMultiSendTx(et, 1, et.accIn.Balance.ThetaWei.Int64()))
+	assert.Equal(result.CodeInsufficientFund, res.Code, res.Message)
+	assert.Equal(uint64(0), sequenceOf(et, inAddr))
+
+	multiSendTx := newMultiSendTx(et, 1, 1000)
+	_, res = et.executor.ExecuteTx(multiSendTx)
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(uint64(1), sequenceOf(et, inAddr))
+	assert.Equal(uint64(0), sequenceOf(et, outAddr))
+
+	_, res = et.executor.ExecuteTx(multiSendTx)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+	assert.Equal(uint64(1), sequenceOf(et, inAddr))
+
+	// SmartContractTx
+	et, privAccounts := setupForSmartContract(assert, 2)
+	fromAcc, toAcc := &privAccounts[0], &privAccounts[1]
+	fromAddr, toAddr := fromAcc.PubKey.Address(), toAcc.PubKey.Address()
+	newSmartContractTx := func(to common.Address, sequence uint64, gasLimit uint64) *types.SmartContractTx {
+		tx := &types.SmartContractTx{
+			From: types.TxInput{
+				Address:  fromAddr,
+				Coins:    types.NewCoins(0, 1000),
+				Sequence: sequence,
+			},
+			To:       types.TxOutput{Address: to},
+			GasLimit: gasLimit,
+			GasPrice: new(big.Int).SetUint64(types.MinimumGasPrice),
+		}
+		if sequence == 1 {
+			tx.From.PubKey = fromAcc.PubKey // only included in the first transaction of the account
+		}
+		tx.From.Signature = fromAcc.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	_, res = et.executor.ExecuteTx(newSmartContractTx(toAddr, 2, 100000))
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+	assert.Equal(uint64(0), sequenceOf(et, fromAddr))
+
+	_, res = et.executor.ExecuteTx(newSmartContractTx(toAddr, 1, 1e12))
+	assert.Equal(result.CodeInsufficientFund, res.Code, res.Message)
+	assert.Equal(uint64(0), sequenceOf(et, fromAddr))
+
+	scTx := newSmartContractTx(toAddr, 1, 100000)
+	_, res = et.executor.ExecuteTx(scTx)
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(uint64(1), sequenceOf(et, fromAddr))
+	assert.Equal(uint64(0), sequenceOf(et, toAddr))
+
+	_, res = et.executor.ExecuteTx(scTx)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+	assert.Equal(uint64(1), sequenceOf(et, fromAddr))
+
+	// The contract deployment also bumps the sequence exactly once
+	_, res = et.executor.ExecuteTx(newSmartContractTx(common.Address{}, 2, 100000))
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(uint64(2), sequenceOf(et, fromAddr))
+}
+
 // func TestCalculateThetaReward(t *testing.T) {
 // 	assert := assert.New(t)
 
@@ -374,6 +733,161 @@ func TestNonEmptyPubKey(t *testing.T) {
 	assert.NotEqual(userPubKey2, acc.PubKey) // acc.PukKey should not change
 }
 
+func TestSendTxMultisig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	et := NewExecTest()
+
+	signer1 := types.MakeAcc("signer 1")
+	signer2 := types.MakeAcc("signer 2")
+	signer3 := types.MakeAcc("signer 3")
+
+	// The multisig account does not have a public key of its own
+	msAcc := types.MakeAcc("multisig")
+	msAcc.Account.PubKey = nil
+	msAddr := msAcc.PrivKey.PublicKey().Address()
+	et.state().Delivered().SetAccount(msAddr, &msAcc.Account)
+	et.acc2State(et.accOut)
+
+	policy := &types.MultisigPolicy{
+		Threshold: 2,
+		PubKeys:   []*crypto.PublicKey{signer1.PubKey, signer2.PubKey, signer3.PubKey},
+	}
+	require.True(policy.ValidateBasic().IsOK())
+	et.state().Delivered().SetMultisigPolicy(msAddr, policy)
+
+	txFee := getMinimumTxFee()
+	tx := &types.SendTx{
+		Fee: types.NewCoins(0, txFee),
+		Inputs: []types.TxInput{{
+			Address:  msAddr,
+			Coins:    types.NewCoins(1000, txFee),
+			Sequence: 1,
+		}},
+		Outputs: []types.TxOutput{{
+			Address: et.accOut.PubKey.Address(),
+			Coins:   types.NewCoins(1000, 0),
+		}},
+	}
+	signBytes := tx.SignBytes(et.chainID)
+	sig1 := signer1.Sign(signBytes)
+	sig3 := signer3.Sign(signBytes)
+
+	// The sign bytes do not depend on the signatures or their order
+	tx.Inputs[0].Signatures = []*crypto.Signature{sig3, sig1}
+	assert.Equal(signBytes, tx.SignBytes(et.chainID))
+	tx.Inputs[0].Signatures = []*crypto.Signature{sig1, sig3}
+	assert.Equal(signBytes, tx.SignBytes(et.chainID))
+
+	// The signatures survive the serialization
+	txBytes, err := types.TxToBytes(tx)
+	require.Nil(err)
+	decodedTx, err := types.TxFromBytes(txBytes)
+	require.Nil(err)
+	assert.Equal(2, len(decodedTx.(*types.SendTx).Inputs[0].Signatures))
+
+	// Unsatisfied threshold
+	tx.Inputs[0].Signatures = []*crypto.Signature{sig1}
+	res := et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.String())
+
+	// The same signer cannot sign twice to reach the threshold
+	tx.Inputs[0].Signatures = []*crypto.Signature{sig1, signer1.Sign(signBytes)}
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.String())
+
+	// An unauthorized signer does not count
+	tx.Inputs[0].Signatures = []*crypto.Signature{sig1, et.accIn.Sign(signBytes)}
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.String())
+
+	// A single signature is not accepted for a multisig account
+	tx.Inputs[0].Signatures = nil
+	tx.Inputs[0].Signature = sig1
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.True(res.IsError(), res.String())
+	tx.Inputs[0].Signature = nil
+
+	// Satisfied threshold
+	tx.Inputs[0].Signatures = []*crypto.Signature{sig3, sig1}
+	_, res = et.executor.ExecuteTx(tx)
+	require.True(res.IsOK(), res.String())
+	msAccount := et.state().Delivered().GetAccount(msAddr)
+	assert.Equal(uint64(1), msAccount.Sequence)
+	assert.Equal(msAcc.Account.Balance.Minus(types.NewCoins(1000, txFee)), msAccount.Balance)
+}
+
+func TestSendTxFeePayer(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	et := NewExecTest()
+
+	// The user does not have any Gamma to pay the fee
+	user := types.MakeAccWithInitBalance("user", types.NewCoins(5000, 0))
+	payer := types.MakeAcc("fee payer")
+	et.acc2State(user, payer, et.accOut)
+
+	userAddr := user.PubKey.Address()
+	payerAddr := payer.PubKey.Address()
+	txFee := getMinimumTxFee()
+	makeTx := func() *types.SendTx {
+		tx := &types.SendTx{
+			Fee:     types.NewCoins(0, txFee),
+			Inputs:  []types.TxInput{types.NewTxInput(user.PubKey, types.NewCoins(1000, 0), 1)},
+			Outputs: []types.TxOutput{{Address: et.accOut.PubKey.Address(), Coins: types.NewCoins(1000, 0)}},
+		}
+		feePayer := types.NewTxInput(payer.PubKey, types.NewCoins(0, txFee), 1)
+		tx.FeePayer = &feePayer
+		return tx
+	}
+
+	// The fee payer survives the serialization
+	tx := makeTx()
+	signBytes := tx.SignBytes(et.chainID)
+	tx.SetSignature(userAddr, user.Sign(signBytes))
+	tx.SetSignature(payerAddr, payer.Sign(signBytes))
+	txBytes, err := types.TxToBytes(tx)
+	require.Nil(err)
+	decodedTx, err := types.TxFromBytes(txBytes)
+	require.Nil(err)
+	assert.Equal(tx, decodedTx)
+
+	// Invalid fee payer signature
+	invalidTx := makeTx()
+	invalidTx.SetSignature(userAddr, user.Sign(signBytes))
+	invalidTx.SetSignature(payerAddr, et.accIn.Sign(signBytes))
+	res := et.executor.getTxExecutor(invalidTx).sanityCheck(et.chainID, et.state().Delivered(), invalidTx)
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.String())
+
+	// The fee payer must cover exactly the fee
+	invalidTx = makeTx()
+	invalidTx.FeePayer.Coins = types.NewCoins(0, 2*txFee)
+	invalidSignBytes := invalidTx.SignBytes(et.chainID)
+	invalidTx.SetSignature(userAddr, user.Sign(invalidSignBytes))
+	invalidTx.SetSignature(payerAddr, payer.Sign(invalidSignBytes))
+	res = et.executor.getTxExecutor(invalidTx).sanityCheck(et.chainID, et.state().Delivered(), invalidTx)
+	assert.Equal(result.CodeInvalidFee, res.Code, res.String())
+
+	// Valid delegated-fee transaction
+	_, res = et.executor.ExecuteTx(tx)
+	require.True(res.IsOK(), res.String())
+
+	userAcc := et.state().Delivered().GetAccount(userAddr)
+	assert.Equal(uint64(1), userAcc.Sequence)
+	assert.Equal(types.NewCoins(4000, 0), userAcc.Balance)
+
+	payerAcc := et.state().Delivered().GetAccount(payerAddr)
+	assert.Equal(uint64(1), payerAcc.Sequence)
+	assert.Equal(payer.Balance.Minus(types.NewCoins(0, txFee)), payerAcc.Balance)
+
+	outAcc := et.state().Delivered().GetAccount(et.accOut.PubKey.Address())
+	assert.Equal(et.accOut.Balance.Plus(types.NewCoins(1000, 0)), outAcc.Balance)
+
+	// The transaction cannot be replayed
+	_, res = et.executor.ExecuteTx(tx)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.String())
+}
+
 func TestCoinbaseTx(t *testing.T) {
 	assert := assert.New(t)
 	et := NewExecTest()
@@ -404,7 +918,8 @@ func TestCoinbaseTx(t *testing.T) {
 		}, {
 			va2.Account.PubKey.Address(), types.NewCoins(0, 0),
 		}},
-		BlockHeight: 1e7,
+		BlockHeight:   1e7,
+		ProposerProof: et.proposerProof(),
 	}
 	tx.Proposer.Signature = va1.Sign(tx.SignBytes(et.chainID))
 
@@ -420,7 +935,8 @@ func TestCoinbaseTx(t *testing.T) {
 		}, {
 			va2.Account.PubKey.Address(), types.NewCoins(317, 0),
 		}},
-		BlockHeight: 1e7,
+		BlockHeight:   1e7,
+		ProposerProof: et.proposerProof(),
 	}
 	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
 	assert.True(res.IsError(), res.String())
@@ -434,7 +950,8 @@ func TestCoinbaseTx(t *testing.T) {
 		}, {
 			va2.Account.PubKey.Address(), types.NewCoins(0, 0),
 		}},
-		BlockHeight: 1e7,
+		BlockHeight:   1e7,
+		ProposerProof: et.proposerProof(),
 	}
 	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
 	assert.True(res.IsError(), res.String())
@@ -539,6 +1056,303 @@ func TestCoinbaseTx(t *testing.T) {
 	// assert.Equal(int64(0), user1balance.GammaWei.Int64())
 }
 
+func TestCoinbaseTxProposerProof(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+
+	va1 := et.accProposer
+	va2 := et.accVal2
+	et.acc2State(va1, va2)
+	et.fastforwardTo(1e7)
+
+	makeCoinbaseTx := func(proposer types.PrivAccount, proof core.ProposerProof) *types.CoinbaseTx {
+		tx := &types.CoinbaseTx{
+			Proposer: types.TxInput{
+				Address: proposer.PubKey.Address(), PubKey: proposer.PubKey},
+			Outputs: []types.TxOutput{{
+				va1.Account.PubKey.Address(), types.NewCoins(0, 0),
+			}, {
+				va2.Account.PubKey.Address(), types.NewCoins(0, 0),
+			}},
+			BlockHeight:   1e7,
+			ProposerProof: proof,
+		}
+		tx.Proposer.Signature = proposer.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	tx := makeCoinbaseTx(va1, et.proposerProof())
+	res := et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.True(res.IsOK(), res.String())
+
+	// A validator which was not selected for the epoch
+	tx = makeCoinbaseTx(va2, et.proposerProof())
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.Equal(result.CodeInvalidProposer, res.Code, res.String())
+
+	// A proof for another epoch
+	epoch := et.executor.consensus.GetEpoch()
+	tx = makeCoinbaseTx(va1, et.executor.valMgr.GetProposerProofForEpoch(epoch+1))
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.Equal(result.CodeInvalidProposer, res.Code, res.String())
+
+	// A missing proof
+	tx = makeCoinbaseTx(va1, core.ProposerProof{Epoch: epoch})
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.Equal(result.CodeInvalidProposer, res.Code, res.String())
+
+	// A block of a past epoch, e.g. during catch-up, is checked against the epoch of the block
+	view := et.state().Delivered()
+	view.SetEpoch(epoch - 1)
+	defer view.ClearEpoch()
+	tx = makeCoinbaseTx(va1, et.executor.valMgr.GetProposerProofForEpoch(epoch-1))
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, view, tx)
+	assert.True(res.IsOK(), res.String())
+	tx = makeCoinbaseTx(va1, et.proposerProof())
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, view, tx)
+	assert.Equal(result.CodeInvalidProposer, res.Code, res.String())
+}
+
+func TestCoinbaseRewardMaturity(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+	params := et.state().Delivered().GetChainParams()
+	params.CoinbaseMaturity = 10
+	et.state().Delivered().SetChainParams(params)
+	et.acc2State(et.accIn, et.accOut)
+
+	// The coinbase transaction of block 100 rewards accIn, which matures at block 110
+	et.fastforwardTo(100)
+	accInAddr := et.accIn.Account.PubKey.Address()
+	reward := types.NewCoins(5000, 0)
+	coinbaseTx := &types.CoinbaseTx{
+		Proposer: types.TxInput{
+			Address: et.accProposer.PubKey.Address(), PubKey: et.accProposer.PubKey},
+		Outputs:     []types.TxOutput{{accInAddr, reward}},
+		BlockHeight: 100,
+	}
+	_, res := et.executor.getTxExecutor(coinbaseTx).process(et.chainID, et.state().Delivered(), coinbaseTx)
+	assert.True(res.IsOK(), res.String())
+	assert.Equal(reward, et.state().Delivered().GetLockedRewards(accInAddr))
+
+	// Spending the reward requires it to be mature
+	tx := types.MakeSendTx(1, et.accOut, et.accIn)
+	tx.Inputs[0].Coins = types.NewCoins(701000, getMinimumTxFee())
+	tx.Outputs[0].Coins = types.NewCoins(701000, 0)
+	et.signSendTx(tx, et.accIn)
+
+	et.fastforwardTo(109)
+	_, res = et.executor.ExecuteTx(tx)
+	assert.Equal(result.CodeImmatureCoinbaseReward, res.Code, res.String())
+
+	et.fastforwardTo(110)
+	assert.True(et.state().Delivered().GetLockedRewards(accInAddr).IsZero())
+	_, res = et.executor.ExecuteTx(tx)
+	assert.True(res.IsOK(), res.String())
+}
+
+func TestCoinbaseRewardHalving(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+	params := et.state().Delivered().GetChainParams()
+	params.InitialBlockRewardGammaWei = 1000
+	params.RewardHalvingInterval = 100
+	et.state().Delivered().SetChainParams(params)
+
+	policy := NewRewardPolicyFromChainParams(params)
+	assert.Equal(int64(1000), policy.BaseReward(0).Int64())
+	assert.Equal(int64(1000), policy.BaseReward(99).Int64())
+	assert.Equal(int64(500), policy.BaseReward(100).Int64())
+	assert.Equal(int64(500), policy.BaseReward(199).Int64())
+	assert.Equal(int64(250), policy.BaseReward(200).Int64())
+	assert.Equal(int64(0), policy.BaseReward(100*maxHalvings).Int64())
+
+	va1 := et.accProposer
+	va2 := et.accVal2
+	et.acc2State(va1, va2)
+	makeCoinbaseTx := func(height uint64, gamma int64) *types.CoinbaseTx {
+		tx := &types.CoinbaseTx{
+			Proposer: types.TxInput{
+				Address: va1.PubKey.Address(), PubKey: va1.PubKey},
+			Outputs: []types.TxOutput{{
+				va1.Account.PubKey.Address(), types.NewCoins(0, gamma),
+			}, {
+				va2.Account.PubKey.Address(), types.NewCoins(0, gamma),
+			}},
+			BlockHeight:   height,
+			ProposerProof: et.proposerProof(),
+		}
+		tx.Proposer.Signature = va1.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	// Just before the halving boundary the base reward is split between the two validators
+	et.fastforwardTo(99)
+	rewards := et.executor.CalculateReward(et.state().Delivered(), []common.Address{va1.PubKey.Address(), va2.PubKey.Address()})
+	assert.Equal(types.NewCoins(0, 500), rewards[string(va1.PubKey.Address().Bytes())])
+	tx := makeCoinbaseTx(99, 500)
+	res := et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.True(res.IsOK(), res.String())
+
+	// Just after the halving boundary the pre-halving reward is rejected
+	et.fastforwardTo(100)
+	tx = makeCoinbaseTx(100, 500)
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.True(res.IsError(), res.String())
+	tx = makeCoinbaseTx(100, 250)
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, et.state().Delivered(), tx)
+	assert.True(res.IsOK(), res.String())
+}
+
+func TestCoinbaseRewardCap(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	stakes := []uint64{500, 100, 500, 300, 50, 300}
+	validators := []core.Validator{}
+	for idx, stake := range stakes {
+		acc := types.MakeAcc(fmt.Sprintf("capped_val%v", idx))
+		validators = append(validators, core.NewValidator(acc.PubKey.ToBytes(), stake))
+	}
+	reversed := make([]core.Validator, len(validators))
+	for idx, validator := range validators {
+		reversed[len(validators)-1-idx] = validator
+	}
+
+	// The validators with the highest stakes are rewarded, with the tie broken by the address
+	tieWinner, tieLoser := validators[3].Address(), validators[5].Address()
+	if bytes.Compare(tieWinner[:], tieLoser[:]) > 0 {
+		tieWinner, tieLoser = tieLoser, tieWinner
+	}
+	rewarded := SelectRewardedValidators(validators, 3)
+	require.Equal(3, len(rewarded))
+	assert.Contains(rewarded, validators[0].Address())
+	assert.Contains(rewarded, validators[2].Address())
+	assert.Contains(rewarded, tieWinner)
+	assert.NotContains(rewarded, tieLoser)
+
+	// The selection does not depend on the order of the validator set
+	assert.Equal(rewarded, SelectRewardedValidators(reversed, 3))
+
+	// No cap, or a cap above the size of the validator set, rewards all the validators
+	assert.Equal(len(validators), len(SelectRewardedValidators(validators, 0)))
+	assert.Equal(len(validators), len(SelectRewardedValidators(validators, 10)))
+
+	// The coinbase transaction is verified against the capped rewards
+	et := NewExecTest()
+	va1 := et.accProposer
+	va2 := et.accVal2
+	et.acc2State(va1, va2)
+	view := et.state().Delivered()
+	params := view.GetChainParams()
+	params.InitialBlockRewardGammaWei = 1000
+	params.MaxRewardedValidators = 1
+	view.SetChainParams(params)
+
+	makeCoinbaseTx := func(outputs []types.TxOutput) *types.CoinbaseTx {
+		tx := &types.CoinbaseTx{
+			Proposer: types.TxInput{
+				Address: va1.PubKey.Address(), PubKey: va1.PubKey},
+			Outputs:       outputs,
+			BlockHeight:   et.state().Height(),
+			ProposerProof: et.proposerProof(),
+		}
+		tx.Proposer.Signature = va1.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	// Only the proposer, with the higher stake, is rewarded, and receives the whole base reward
+	tx := makeCoinbaseTx([]types.TxOutput{{va1.PubKey.Address(), types.NewCoins(0, 1000)}})
+	res := et.executor.getTxExecutor(tx).sanityCheck(et.chainID, view, tx)
+	assert.True(res.IsOK(), res.String())
+
+	tx = makeCoinbaseTx([]types.TxOutput{
+		{va1.PubKey.Address(), types.NewCoins(0, 500)},
+		{va2.PubKey.Address(), types.NewCoins(0, 500)},
+	})
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, view, tx)
+	assert.True(res.IsError(), res.String())
+
+	tx = makeCoinbaseTx([]types.TxOutput{{va2.PubKey.Address(), types.NewCoins(0, 1000)}})
+	res = et.executor.getTxExecutor(tx).sanityCheck(et.chainID, view, tx)
+	assert.True(res.IsError(), res.String())
+}
+
+func TestCheckTxReadOnly(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, tc := range []struct {
+		name   string
+		makeTx func(et *execTest) *types.SendTx
+		valid  bool
+	}{
+		{"valid", func(et *execTest) *types.SendTx {
+			tx := types.MakeSendTx(1, et.accOut, et.accIn)
+			et.signSendTx(tx, et.accIn)
+			return tx
+		}, true},
+		{"invalid sequence", func(et *execTest) *types.SendTx {
+			tx := types.MakeSendTx(2, et.accOut, et.accIn)
+			et.signSendTx(tx, et.accIn)
+			return tx
+		}, false},
+		{"invalid signature", func(et *execTest) *types.SendTx {
+			tx := types.MakeSendTx(1, et.accOut, et.accIn)
+			et.signSendTx(tx, et.accOut)
+			return tx
+		}, false},
+		{"insufficient fee", func(et *execTest) *types.SendTx {
+			tx := types.MakeSendTx(1, et.accOut, et.accIn)
+			tx.Fee = types.NewCoins(0, 0)
+			et.signSendTx(tx, et.accIn)
+			return tx
+		}, false},
+	} {
+		et := NewExecTest()
+		et.acc2State(et.accIn, et.accOut)
+		et.fastforwardBy(1)
+		tx := tc.makeTx(et)
+
+		checkedRoot := et.state().Checked().Hash()
+		_, res := et.executor.CheckTxReadOnly(tx)
+		assert.Equal(tc.valid, res.IsOK(), "%v: %v", tc.name, res.Message)
+
+		// The checked view is unchanged, so the check can be repeated with the same result
+		assert.Equal(checkedRoot, et.state().Checked().Hash(), tc.name)
+		_, res = et.executor.CheckTxReadOnly(tx)
+		assert.Equal(tc.valid, res.IsOK(), "%v: %v", tc.name, res.Message)
+
+		// A transaction passing the check executes successfully on the unchanged state
+		require.Equal(checkedRoot, et.state().Delivered().Hash(), tc.name)
+		_, res = et.executor.ExecuteTx(tx)
+		if tc.valid {
+			assert.True(res.IsOK(), "%v: %v", tc.name, res.Message)
+		}
+	}
+}
+
+func TestCalculateRewardSorted(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+
+	validatorAddresses := []common.Address{}
+	for i := 0; i < 8; i++ {
+		va := types.MakeAcc(fmt.Sprintf("validator%v", i))
+		validatorAddresses = append(validatorAddresses, va.PubKey.Address())
+	}
+	rewardMap := et.executor.CalculateReward(et.state().Delivered(), validatorAddresses)
+	rewards := et.executor.CalculateRewardSorted(et.state().Delivered(), validatorAddresses)
+
+	assert.Equal(len(validatorAddresses), len(rewards))
+	for idx, reward := range rewards {
+		assert.Equal(rewardMap[string(reward.Address[:])], reward.Reward)
+		if idx > 0 {
+			assert.True(bytes.Compare(rewards[idx-1].Address[:], reward.Address[:]) < 0)
+		}
+	}
+}
+
 func TestReserveFundTx(t *testing.T) {
 	assert := assert.New(t)
 	et := NewExecTest()
@@ -636,6 +1450,50 @@ func TestReserveFundTx(t *testing.T) {
 	assert.Equal(uint64(1), retrievedUserAcc.ReservedFunds[0].ReserveSequence)
 }
 
+func TestReserveFundTxDataSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	et := NewExecTest()
+
+	txFee := getMinimumTxFee()
+
+	user1 := types.MakeAcc("user 1")
+	user1.Balance = types.Coins{
+		GammaWei: big.NewInt(6200 * txFee),
+		ThetaWei: big.NewInt(10000 * 1e6),
+	}
+	et.acc2State(user1)
+
+	et.fastforwardTo(1e7)
+
+	// The size of the resource IDs adds up
+	newReserveFundTx := func(dataSize int) *types.ReserveFundTx {
+		tx := &types.ReserveFundTx{
+			Fee: types.NewCoins(0, txFee),
+			Source: types.TxInput{
+				Address:  user1.PubKey.Address(),
+				PubKey:   user1.PubKey,
+				Coins:    types.Coins{GammaWei: big.NewInt(1000 * txFee), ThetaWei: big.NewInt(0)},
+				Sequence: 1,
+			},
+			Collateral:  types.Coins{GammaWei: big.NewInt(1001 * txFee), ThetaWei: big.NewInt(0)},
+			ResourceIDs: []string{strings.Repeat("r", dataSize/2), strings.Repeat("s", dataSize-dataSize/2)},
+			Duration:    1000,
+		}
+		tx.Source.Signature = user1.Sign(tx.SignBytes(et.chainID))
+		return tx
+	}
+
+	_, res := et.executor.CheckTx(newReserveFundTx(core.MaxTxDataBytes))
+	assert.True(res.IsOK(), res.Message)
+
+	_, res = et.executor.ScreenTx(newReserveFundTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.CheckTx(newReserveFundTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.ExecuteTx(newReserveFundTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+}
+
 func TestReleaseFundTx(t *testing.T) {
 	assert := assert.New(t)
 	et := NewExecTest()
@@ -877,6 +1735,27 @@ func TestServicePaymentTxExpiration(t *testing.T) {
 	log.Infof("Service payment check message: %v", res.Message)
 }
 
+func TestServicePaymentTxDataSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	et, _, alice, bob, _, _, _, _ := setupForServicePayment(assert)
+	et.state().Commit()
+
+	txFee := getMinimumTxFee()
+
+	// No fund is reserved for the resource ID, but the size is checked first
+	servicePaymentTx := createServicePaymentTx(et.chainID, &alice, &bob, 10*txFee, 1, 1, 1, 1, strings.Repeat("r", core.MaxTxDataBytes))
+	_, res := et.executor.CheckTx(servicePaymentTx)
+	assert.NotEqual(result.CodeTxDataTooLarge, res.Code)
+
+	servicePaymentTx = createServicePaymentTx(et.chainID, &alice, &bob, 10*txFee, 1, 1, 1, 1, strings.Repeat("r", core.MaxTxDataBytes+1))
+	_, res = et.executor.ScreenTx(servicePaymentTx)
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.CheckTx(servicePaymentTx)
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.ExecuteTx(servicePaymentTx)
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+}
+
 func TestSlashTx(t *testing.T) {
 	assert := assert.New(t)
 	et, resourceID, alice, bob, _, _, _, _ := setupForServicePayment(assert)
@@ -946,6 +1825,41 @@ func TestSlashTx(t *testing.T) {
 	log.Infof("Proposer final balance: %v", retrievedProposerAccount.Balance)
 }
 
+func TestSlashTxDataSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	et, _, alice, _, _, _, _, _ := setupForServicePayment(assert)
+
+	proposer := et.accProposer
+	et.acc2State(proposer)
+	et.state().Commit()
+
+	// No slash intent matches the proof, but the size is checked first
+	newSlashTx := func(dataSize int) *types.SlashTx {
+		slashTx := &types.SlashTx{
+			Proposer: types.TxInput{
+				Address:  proposer.PubKey.Address(),
+				Sequence: 1,
+				PubKey:   proposer.PubKey,
+			},
+			SlashedAddress:  alice.PubKey.Address(),
+			ReserveSequence: 1,
+			SlashProof:      make(common.Bytes, dataSize),
+		}
+		slashTx.Proposer.Signature = proposer.Sign(slashTx.SignBytes(et.chainID))
+		return slashTx
+	}
+
+	_, res := et.executor.CheckTx(newSlashTx(core.MaxTxDataBytes))
+	assert.NotEqual(result.CodeTxDataTooLarge, res.Code)
+
+	_, res = et.executor.ScreenTx(newSlashTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.CheckTx(newSlashTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.ExecuteTx(newSlashTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+}
+
 func TestSplitRuleTxNormalExecution(t *testing.T) {
 	assert := assert.New(t)
 	et, resourceID, alice, bob, carol, _, bobInitBalance, carolInitBalance := setupForServicePayment(assert)
@@ -1172,3 +2086,290 @@ func TestSplitRuleTxUpdate(t *testing.T) {
 	log.Infof("currHeight = %v", currHeight)
 	log.Infof("endHeight2 = %v", endHeight2)
 }
+
+func TestSplitRuleTxDataSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	et, _, _, _, carol, _, _, _ := setupForServicePayment(assert)
+	et.fastforwardBy(1000)
+
+	txFee := getMinimumTxFee()
+
+	initiator := types.MakeAcc("User David")
+	initiator.Balance = types.Coins{GammaWei: big.NewInt(10000 * txFee), ThetaWei: big.NewInt(0)}
+	et.acc2State(initiator)
+
+	newSplitRuleTx := func(dataSize int) *types.SplitRuleTx {
+		splitRuleTx := &types.SplitRuleTx{
+			Fee:        types.NewCoins(0, txFee),
+			ResourceID: strings.Repeat("r", dataSize),
+			Initiator: types.TxInput{
+				Address:  initiator.PubKey.Address(),
+				PubKey:   initiator.PubKey,
+				Sequence: 1,
+			},
+			Splits:   []types.Split{{Address: carol.PubKey.Address(), Percentage: 30}},
+			Duration: uint64(100),
+		}
+		splitRuleTx.Initiator.Signature = initiator.Sign(splitRuleTx.SignBytes(et.chainID))
+		return splitRuleTx
+	}
+
+	_, res := et.executor.CheckTx(newSplitRuleTx(core.MaxTxDataBytes))
+	assert.True(res.IsOK(), res.Message)
+
+	_, res = et.executor.ScreenTx(newSplitRuleTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.CheckTx(newSplitRuleTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.ExecuteTx(newSplitRuleTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+}
+
+func TestParamUpdateTx(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	et := NewExecTest()
+
+	et.acc2State(et.accIn, et.accOut)
+	et.state().Delivered().SetGovernanceAddress(et.accIn.PubKey.Address())
+	et.fastforwardBy(1)
+
+	newParamUpdateTx := func(proposer types.PrivAccount, sequence int, updates []types.ParamUpdate) *types.ParamUpdateTx {
+		tx := &types.ParamUpdateTx{
+			Fee:      types.NewCoins(0, getMinimumTxFee()),
+			Proposer: types.NewTxInput(proposer.PubKey, types.NewCoins(0, 0), sequence),
+			Updates:  updates,
+		}
+		if sequence > 1 {
+			tx.Proposer.PubKey = nil // only included in the first transaction of the account
+		}
+		tx.SetSignature(proposer.PubKey.Address(), proposer.Sign(tx.SignBytes(et.chainID)))
+		return tx
+	}
+	updates := []types.ParamUpdate{{Name: types.ParamMaxBlockGas, Value: 1000}}
+
+	// Unauthorized attempt
+	unauthorizedTx := newParamUpdateTx(et.accOut, 1, updates)
+	_, res := et.executor.ScreenTx(unauthorizedTx)
+	assert.Equal(result.CodeUnauthorizedTx, res.Code, res.Message)
+	_, res = et.executor.ExecuteTx(unauthorizedTx)
+	assert.Equal(result.CodeUnauthorizedTx, res.Code, res.Message)
+	assert.Equal(types.DefaultChainParams(), et.state().Delivered().GetChainParams())
+
+	// Unknown parameter
+	invalidTx := newParamUpdateTx(et.accIn, 1, []types.ParamUpdate{{Name: "Unknown", Value: 1}})
+	_, res = et.executor.ExecuteTx(invalidTx)
+	assert.True(res.IsError())
+
+	// Authorized updates, each bumps the sequence of the proposer
+	tx := newParamUpdateTx(et.accIn, 1, updates)
+	_, res = et.executor.ScreenTx(tx)
+	assert.True(res.IsOK(), res.Message)
+	_, res = et.executor.ExecuteTx(tx)
+	require.True(res.IsOK(), res.Message)
+	params := et.state().Delivered().GetChainParams()
+	assert.Equal(uint64(1000), params.MaxBlockGas)
+	assert.Equal(types.MinimumGasPrice, params.MinimumGasPrice)
+	accIn := et.state().Delivered().GetAccount(et.accIn.PubKey.Address())
+	assert.Equal(uint64(1), accIn.Sequence)
+
+	_, res = et.executor.ExecuteTx(newParamUpdateTx(et.accIn, 2, []types.ParamUpdate{
+		{Name: types.ParamMaxBlockGas, Value: 2000},
+		{Name: types.ParamInitialBlockRewardGammaWei, Value: 1000},
+		{Name: types.ParamRewardHalvingInterval, Value: 100},
+		{Name: types.ParamCoinbaseMaturity, Value: 10},
+	}))
+	require.True(res.IsOK(), res.Message)
+	params = et.state().Delivered().GetChainParams()
+	assert.Equal(uint64(2000), params.MaxBlockGas)
+	assert.Equal(uint64(1000), params.InitialBlockRewardGammaWei)
+	assert.Equal(uint64(100), params.RewardHalvingInterval)
+	assert.Equal(uint64(10), params.CoinbaseMaturity)
+	accIn = et.state().Delivered().GetAccount(et.accIn.PubKey.Address())
+	assert.Equal(uint64(2), accIn.Sequence)
+
+	// Updates are disabled without a governance account
+	et.state().Delivered().SetGovernanceAddress(common.Address{})
+	_, res = et.executor.ExecuteTx(newParamUpdateTx(et.accIn, 3, updates))
+	assert.Equal(result.CodeUnauthorizedTx, res.Code, res.Message)
+}
+
+func TestFreezeAccountTx(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	et := NewExecTest()
+
+	governance := types.MakeAccWithInitBalance("governance", types.NewCoins(0, 50*getMinimumTxFee()))
+	et.acc2State(et.accIn, et.accOut, governance)
+	et.state().Delivered().SetGovernanceAddress(governance.PubKey.Address())
+	et.fastforwardBy(1)
+
+	newFreezeAccountTx := func(proposer types.PrivAccount, seq int, addr common.Address, frozen bool) *types.FreezeAccountTx {
+		tx := &types.FreezeAccountTx{
+			Fee:      types.NewCoins(0, getMinimumTxFee()),
+			Proposer: types.NewTxInput(proposer.PubKey, types.NewCoins(0, 0), seq),
+			Address:  addr,
+			Frozen:   frozen,
+		}
+		tx.SetSignature(proposer.PubKey.Address(), proposer.Sign(tx.SignBytes(et.chainID)))
+		return tx
+	}
+	accOutAddr := et.accOut.PubKey.Address()
+
+	// Unauthorized attempt
+	_, res := et.executor.ExecuteTx(newFreezeAccountTx(et.accIn, 1, accOutAddr, true))
+	assert.Equal(result.CodeUnauthorizedTx, res.Code, res.Message)
+	assert.False(et.state().Delivered().GetAccount(accOutAddr).Frozen)
+
+	// Authorized freeze
+	_, res = et.executor.ExecuteTx(newFreezeAccountTx(governance, 1, accOutAddr, true))
+	require.True(res.IsOK(), res.Message)
+	assert.True(et.state().Delivered().GetAccount(accOutAddr).Frozen)
+
+	// Spending from the frozen account is rejected
+	spendTx := types.MakeSendTx(1, et.accIn, et.accOut)
+	et.signSendTx(spendTx, et.accOut)
+	_, res = et.executor.ExecuteTx(spendTx)
+	assert.Equal(result.CodeAccountFrozen, res.Code, res.Message)
+
+	// Receiving by the frozen account is allowed, and so is reading it
+	receiveTx := types.MakeSendTx(1, et.accOut, et.accIn)
+	et.signSendTx(receiveTx, et.accIn)
+	balanceBefore := et.state().Delivered().GetAccount(accOutAddr).Balance
+	_, res = et.executor.ExecuteTx(receiveTx)
+	require.True(res.IsOK(), res.Message)
+	balanceAfter := et.state().Delivered().GetAccount(accOutAddr).Balance
+	assert.True(balanceAfter.IsEqual(balanceBefore.Plus(receiveTx.Outputs[0].Coins)))
+
+	// The account can spend again once unfrozen
+	_, res = et.executor.ExecuteTx(newFreezeAccountTx(governance, 2, accOutAddr, false))
+	require.True(res.IsOK(), res.Message)
+	_, res = et.executor.ExecuteTx(spendTx)
+	assert.True(res.IsOK(), res.Message)
+
+	// The governance account cannot be frozen
+	_, res = et.executor.ExecuteTx(newFreezeAccountTx(governance, 3, governance.PubKey.Address(), true))
+	assert.True(res.IsError())
+}
+
+func TestUpdateValidatorsTx(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	et := NewExecTest()
+
+	et.acc2State(et.accIn, et.accOut)
+	et.state().Delivered().SetGovernanceAddress(et.accIn.PubKey.Address())
+	et.fastforwardBy(1)
+
+	newUpdateValidatorsTx := func(proposer types.PrivAccount, sequence int, validators []types.ValidatorStake) *types.UpdateValidatorsTx {
+		tx := &types.UpdateValidatorsTx{
+			Fee:        types.NewCoins(0, getMinimumTxFee()),
+			Validators: validators,
+			Proposer:   types.NewTxInput(proposer.PubKey, types.NewCoins(0, 0), sequence),
+		}
+		if sequence > 1 {
+			tx.Proposer.PubKey = nil // only included in the first transaction of the account
+		}
+		tx.SetSignature(proposer.PubKey.Address(), proposer.Sign(tx.SignBytes(et.chainID)))
+		return tx
+	}
+	val3PubKey := types.MakeAcc("val3").PubKey.ToBytes()
+	validators := []types.ValidatorStake{{PubKey: val3PubKey, Stake: 300}}
+
+	// Unauthorized attempt
+	_, res := et.executor.ExecuteTx(newUpdateValidatorsTx(et.accOut, 1, validators))
+	assert.Equal(result.CodeUnauthorizedTx, res.Code, res.Message)
+
+	// Invalid validator public key
+	_, res = et.executor.ExecuteTx(newUpdateValidatorsTx(et.accIn, 1, []types.ValidatorStake{{PubKey: []byte("val3"), Stake: 300}}))
+	assert.True(res.IsError())
+
+	// The authorized update is recorded as pending in the current epoch
+	_, res = et.executor.ExecuteTx(newUpdateValidatorsTx(et.accIn, 1, validators))
+	require.True(res.IsOK(), res.Message)
+	view := et.state().Delivered()
+	assert.Equal(validators, view.GetPendingValidatorChanges())
+	epoch := et.executor.consensus.GetEpoch()
+	assert.Equal(epoch, view.GetPendingValidatorChangesEpoch())
+	assert.Equal(uint64(1), view.GetAccount(et.accIn.PubKey.Address()).Sequence)
+
+	// It is not applied within the same epoch
+	assert.False(et.executor.ApplyEpochBoundary(view))
+	assert.Equal(0, len(view.GetValidatorStakes()))
+
+	// The first block of the next epoch applies it on top of the current validators
+	view.SetEpoch(epoch + 1)
+	assert.True(et.executor.ApplyEpochBoundary(view))
+	view.ClearEpoch()
+	assert.Equal(0, len(view.GetPendingValidatorChanges()))
+	assert.Equal(epoch+2, view.GetValidatorStakesEpoch())
+	numValidators := et.executor.valMgr.GetValidatorSetForEpoch(epoch).Size()
+	assert.Equal(numValidators+1, len(view.GetValidatorStakes()))
+}
+
+func TestApplyValidatorChanges(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	et := NewExecTest()
+	proposerPubKey := et.accProposer.PubKey.ToBytes()
+	val2PubKey := et.accVal2.PubKey.ToBytes()
+	val3PubKey := types.MakeAcc("val3").PubKey.ToBytes()
+	proposer := core.NewValidator(proposerPubKey, uint64(999))
+	val2 := core.NewValidator(val2PubKey, uint64(100))
+	valSet := core.NewValidatorSet()
+	valSet.AddValidator(proposer)
+	valSet.AddValidator(val2)
+	valMgr := consensus.NewRotatingValidatorManager(valSet)
+	executor := NewExecutor(et.state(), et.executor.consensus, valMgr)
+
+	view := et.state().Delivered()
+	view.SetValidatorStakes(0, []types.ValidatorStake{
+		{PubKey: proposerPubKey, Stake: proposer.Stake()},
+		{PubKey: val2PubKey, Stake: val2.Stake()},
+	})
+
+	// In epoch N, a new validator stakes and val2 unstakes
+	epoch := uint64(5)
+	val3 := core.NewValidator(val3PubKey, uint64(300))
+	view.AddPendingValidatorChange(epoch, types.ValidatorStake{PubKey: val3PubKey, Stake: val3.Stake()})
+	view.AddPendingValidatorChange(epoch, types.ValidatorStake{PubKey: val2PubKey, Stake: 0})
+
+	// Recording the changes does not affect the validator set
+	_, err := valMgr.GetValidatorSetForEpoch(epoch).GetValidator(val3.ID())
+	assert.Equal(core.ErrValidatorNotFound, err)
+
+	validatorSet, res := executor.ApplyValidatorChanges(view, epoch)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(2, validatorSet.Size())
+	executor.LoadValidatorSet(view)
+
+	// The changes take effect in epoch N+1 only
+	_, err = valMgr.GetValidatorSetForEpoch(epoch).GetValidator(val3.ID())
+	assert.Equal(core.ErrValidatorNotFound, err)
+	_, err = valMgr.GetValidatorSetForEpoch(epoch).GetValidator(val2.ID())
+	assert.Nil(err)
+	for _, e := range []uint64{epoch + 1, epoch + 10} {
+		v, err := valMgr.GetValidatorSetForEpoch(e).GetValidator(val3.ID())
+		assert.Nil(err)
+		assert.Equal(val3.Stake(), v.Stake())
+		_, err = valMgr.GetValidatorSetForEpoch(e).GetValidator(val2.ID())
+		assert.Equal(core.ErrValidatorNotFound, err)
+	}
+
+	// The stakes in the state are updated
+	assert.Equal(0, len(view.GetPendingValidatorChanges()))
+	assert.Equal(epoch+1, view.GetValidatorStakesEpoch())
+	stakes := view.GetValidatorStakes()
+	require.Equal(2, len(stakes))
+	assert.True(bytes.Compare(stakes[0].PubKey, stakes[1].PubKey) < 0)
+
+	// The changes that would leave no validators are rejected
+	view.AddPendingValidatorChange(epoch+1, types.ValidatorStake{PubKey: proposerPubKey, Stake: 0})
+	view.AddPendingValidatorChange(epoch+1, types.ValidatorStake{PubKey: val3PubKey, Stake: 0})
+	_, res = executor.ApplyValidatorChanges(view, epoch+1)
+	assert.True(res.IsError())
+	assert.Equal(2, len(view.GetPendingValidatorChanges()))
+	_, err = valMgr.GetValidatorSetForEpoch(epoch + 2).GetValidator(val3.ID())
+	assert.Nil(err)
+}
@@ -0,0 +1,125 @@
+package execution
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+// maxHalvings is the number of halvings after which the base reward is zero for any
+// initial reward that fits in 256 bits
+const maxHalvings = 256
+
+//
+// RewardPolicy determines the coinbase reward of each block. The base reward starts at
+// InitialReward and halves every HalvingInterval blocks.
+//
+type RewardPolicy struct {
+	InitialReward   *big.Int // base reward in GammaWei at height 0
+	HalvingInterval uint64   // number of blocks between two halvings (0 means never)
+}
+
+// NewRewardPolicy creates a new instance of RewardPolicy
+func NewRewardPolicy(initialReward *big.Int, halvingInterval uint64) *RewardPolicy {
+	if initialReward == nil {
+		initialReward = big.NewInt(0)
+	}
+	return &RewardPolicy{
+		InitialReward:   new(big.Int).Set(initialReward),
+		HalvingInterval: halvingInterval,
+	}
+}
+
+// NewRewardPolicyFromChainParams creates the reward policy in effect under the given chain params
+func NewRewardPolicyFromChainParams(params *types.ChainParams) *RewardPolicy {
+	return NewRewardPolicy(new(big.Int).SetUint64(params.InitialBlockRewardGammaWei), params.RewardHalvingInterval)
+}
+
+// BaseReward returns the total reward in GammaWei of the block at the given height
+func (rp *RewardPolicy) BaseReward(height uint64) *big.Int {
+	if rp.HalvingInterval == 0 {
+		return new(big.Int).Set(rp.InitialReward)
+	}
+	halvings := height / rp.HalvingInterval
+	if halvings >= maxHalvings {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Rsh(rp.InitialReward, uint(halvings))
+}
+
+// CalculateReward splits the base reward of the block at the given height evenly among the
+// validators. The remainder of the division is not minted.
+func (rp *RewardPolicy) CalculateReward(view *st.StoreView, height uint64, validatorAddresses []common.Address) map[string]types.Coins {
+	accountReward := map[string]types.Coins{}
+	if len(validatorAddresses) == 0 {
+		return accountReward
+	}
+
+	share := new(big.Int).Div(rp.BaseReward(height), big.NewInt(int64(len(validatorAddresses))))
+	for _, validatorAddress := range validatorAddresses {
+		reward, err := types.NewCoins(0, 0).CheckedPlus(types.Coins{GammaWei: share})
+		if err != nil {
+			log.Errorf("Invalid reward share %v at height %v: %v", share, height, err)
+			return map[string]types.Coins{}
+		}
+		accountReward[string(validatorAddress[:])] = reward
+	}
+
+	return accountReward
+}
+
+// SelectRewardedValidators returns the addresses of the validators rewarded in a block. If there
+// are more validators than the cap, only the cap number of validators with the highest stakes are
+// rewarded, with the ties broken by the address bytes, so that the proposer and the validators
+// select the same ones. The rewarded validators split the whole base reward, i.e. the shares of
+// the other validators are carried to them. A zero cap means all the validators are rewarded.
+func SelectRewardedValidators(validators []core.Validator, maxRewarded uint64) []common.Address {
+	selected := make([]core.Validator, len(validators))
+	copy(selected, validators)
+	if maxRewarded > 0 && uint64(len(selected)) > maxRewarded {
+		sort.Slice(selected, func(i, j int) bool {
+			if selected[i].Stake() != selected[j].Stake() {
+				return selected[i].Stake() > selected[j].Stake()
+			}
+			addri, addrj := selected[i].Address(), selected[j].Address()
+			return bytes.Compare(addri[:], addrj[:]) < 0
+		})
+		selected = selected[:maxRewarded]
+	}
+
+	addresses := make([]common.Address, len(selected))
+	for idx, validator := range selected {
+		addresses[idx] = validator.Address()
+	}
+	return addresses
+}
+
+// AccountReward is the reward of an account in a block
+type AccountReward struct {
+	Address common.Address
+	Reward  types.Coins
+}
+
+// SortRewards converts the account reward map returned by CalculateReward into a slice sorted by
+// the address bytes, so that the rewards are iterated in the same order on all the nodes.
+func SortRewards(accountRewardMap map[string]types.Coins) []AccountReward {
+	accountRewards := make([]AccountReward, 0, len(accountRewardMap))
+	for accountAddressStr, accountReward := range accountRewardMap {
+		var accountAddress common.Address
+		copy(accountAddress[:], accountAddressStr)
+		accountRewards = append(accountRewards, AccountReward{
+			Address: accountAddress,
+			Reward:  accountReward,
+		})
+	}
+	sort.Slice(accountRewards, func(i, j int) bool {
+		return bytes.Compare(accountRewards[i].Address[:], accountRewards[j].Address[:]) < 0
+	})
+	return accountRewards
+}
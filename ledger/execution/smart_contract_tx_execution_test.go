@@ -10,6 +10,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/core"
 	"github.com/thetatoken/ukulele/ledger/types"
 	"github.com/thetatoken/ukulele/ledger/vm"
 )
@@ -61,6 +63,66 @@ func TestSimpleSmartContractDeploymentAndExecution(t *testing.T) {
 	executeSmartContract(et, contractAddr, callerPrivAcc, gasLimit, data, 1, assert)
 }
 
+func TestDeriveContractAddress(t *testing.T) {
+	assert := assert.New(t)
+	et, privAccounts := setupForSmartContract(assert, 1)
+	et.fastforwardBy(1000)
+
+	deployerPrivAcc := &privAccounts[0]
+	deployerAddr := deployerPrivAcc.PubKey.Address()
+	deploymentCode, _ := hex.DecodeString("600a600c600039600a6000f3600360135360016013f3")
+	smartContractCode, _ := hex.DecodeString("600360135360016013f3")
+
+	// The addresses are predicted before the deployments
+	predictedAddr1 := DeriveContractAddress(deployerAddr, 1)
+	predictedAddr2 := DeriveContractAddress(deployerAddr, 2)
+	assert.NotEqual(predictedAddr1, predictedAddr2)
+	assert.Nil(et.state().Delivered().GetAccount(predictedAddr1))
+	assert.Nil(et.state().Delivered().GetAccount(predictedAddr2))
+
+	contractAddr1 := deploySmartContract(et, deployerPrivAcc, 0, uint64(90000), deploymentCode, smartContractCode, 1, assert)
+	assert.Equal(predictedAddr1, contractAddr1)
+
+	// Refresh the deployer account, whose balance has changed
+	deployerPrivAcc.Account = *et.state().Delivered().GetAccount(deployerAddr)
+	contractAddr2 := deploySmartContract(et, deployerPrivAcc, 0, uint64(90000), deploymentCode, smartContractCode, 2, assert)
+	assert.Equal(predictedAddr2, contractAddr2)
+	assert.True(bytes.Equal(smartContractCode, et.state().Delivered().GetCode(predictedAddr2)))
+}
+
+func TestSmartContractTxDataSizeLimit(t *testing.T) {
+	assert := assert.New(t)
+	et, privAccounts := setupForSmartContract(assert, 1)
+	deployerPrivAcc := &privAccounts[0]
+	deployerAddr := deployerPrivAcc.PubKey.Address()
+
+	newSmartContractTx := func(dataSize int) *types.SmartContractTx {
+		// The init code consists of STOP opcodes only
+		scTx := &types.SmartContractTx{
+			From: types.TxInput{
+				Address:  deployerAddr,
+				PubKey:   deployerPrivAcc.PubKey,
+				Sequence: 1,
+			},
+			GasLimit: uint64(1000000),
+			GasPrice: new(big.Int).SetUint64(types.MinimumGasPrice),
+			Data:     make(common.Bytes, dataSize),
+		}
+		scTx.From.Signature = deployerPrivAcc.Sign(scTx.SignBytes(et.chainID))
+		return scTx
+	}
+
+	_, res := et.executor.CheckTx(newSmartContractTx(core.MaxTxDataBytes))
+	assert.True(res.IsOK(), res.Message)
+
+	_, res = et.executor.ScreenTx(newSmartContractTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.CheckTx(newSmartContractTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+	_, res = et.executor.ExecuteTx(newSmartContractTx(core.MaxTxDataBytes + 1))
+	assert.Equal(result.CodeTxDataTooLarge, res.Code)
+}
+
 // ------------ Solidity Source Code of the Contract under Test ------------ //
 //
 // pragma solidity ^0.4.18;
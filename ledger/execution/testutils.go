@@ -42,7 +42,18 @@ type TestValidatorManager struct {
 	valSet   *core.ValidatorSet
 }
 
-func (tvm *TestValidatorManager) GetProposerForEpoch(epoch uint64) core.Validator { return tvm.proposer }
+func (tvm *TestValidatorManager) GetProposerForEpoch(epoch uint64) core.Validator {
+	return tvm.proposer
+}
+func (tvm *TestValidatorManager) GetProposerProofForEpoch(epoch uint64) core.ProposerProof {
+	return core.ProposerProof{Epoch: epoch, Seed: core.ProposerSeed(epoch)}
+}
+func (tvm *TestValidatorManager) VerifyProposerProof(proposer core.Validator, proof core.ProposerProof) error {
+	if proof != tvm.GetProposerProofForEpoch(proof.Epoch) || proposer.ID() != tvm.proposer.ID() {
+		return fmt.Errorf("Invalid proposer proof for %v", proposer.ID())
+	}
+	return nil
+}
 func (tvm *TestValidatorManager) GetValidatorSetForEpoch(epoch uint64) *core.ValidatorSet {
 	return tvm.valSet
 }
@@ -66,7 +77,11 @@ type execTest struct {
 }
 
 func NewExecTest() *execTest {
-	et := &execTest{}
+	return newExecTestWithChainID("test_chain_id")
+}
+
+func newExecTestWithChainID(chainID string) *execTest {
+	et := &execTest{chainID: chainID}
 	et.reset()
 
 	return et
@@ -79,7 +94,7 @@ func (et *execTest) reset() {
 	et.accProposer = types.MakeAcc("proposer")
 	et.accVal2 = types.MakeAcc("val2")
 
-	chainID := "test_chain_id"
+	chainID := et.chainID
 	initHeight := uint64(1)
 	initRootHash := common.Hash{}
 	db := backend.NewMemDatabase()
@@ -122,6 +137,10 @@ func (et *execTest) signSendTx(tx *types.SendTx, accsIn ...types.PrivAccount) {
 	types.SignSendTx(et.chainID, tx, accsIn...)
 }
 
+func (et *execTest) proposerProof() core.ProposerProof {
+	return et.executor.valMgr.GetProposerProofForEpoch(et.executor.consensus.GetEpoch())
+}
+
 func (et *execTest) state() *st.LedgerState {
 	return et.executor.state
 }
@@ -30,7 +30,7 @@ func NewCoinbaseTxExecutor(state *st.LedgerState, consensus core.ConsensusEngine
 
 func (exec *CoinbaseTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
 	tx := transaction.(*types.CoinbaseTx)
-	validatorAddresses := getValidatorAddresses(exec.consensus, exec.valMgr)
+	validatorAddresses := getValidatorAddresses(view, exec.consensus, exec.valMgr)
 
 	// Validate proposer, basic
 	res := tx.Proposer.ValidateBasic()
@@ -49,6 +49,12 @@ func (exec *CoinbaseTxExecutor) sanityCheck(chainID string, view *st.StoreView,
 		return res
 	}
 
+	// verify the proposer was selected for the epoch of the block
+	res = exec.verifyProposerProof(view, tx)
+	if res.IsError() {
+		return res
+	}
+
 	proposerAccount, res := getInput(view, tx.Proposer)
 	if res.IsError() {
 		return res
@@ -56,7 +62,7 @@ func (exec *CoinbaseTxExecutor) sanityCheck(chainID string, view *st.StoreView,
 
 	// verify the proposer's signature
 	signBytes := tx.SignBytes(chainID)
-	if !proposerAccount.PubKey.VerifySignature(signBytes, tx.Proposer.Signature) {
+	if !verifySignature(view, proposerAccount.PubKey, signBytes, tx.Proposer.Signature) {
 		return result.Error("SignBytes: %X", signBytes)
 	}
 
@@ -65,14 +71,21 @@ func (exec *CoinbaseTxExecutor) sanityCheck(chainID string, view *st.StoreView,
 	if res.IsError() {
 		return res
 	}
+	res = validateOutputsAdvanced(outputAccounts, tx.Outputs)
+	if res.IsError() {
+		return res
+	}
 
 	if tx.BlockHeight != exec.state.Height() {
 		return result.Error("invalid block height for the coinbase transaction, tx_block_height = %v, state_height = %v",
 			tx.BlockHeight, exec.state.Height())
 	}
 
-	// check the reward amount
-	expectedRewards := CalculateReward(view, validatorAddresses)
+	// check the reward amount, only the validators selected under the cap are rewarded
+	params := view.GetChainParams()
+	rewardedAddresses := SelectRewardedValidators(getValidators(view, exec.consensus, exec.valMgr),
+		params.MaxRewardedValidators)
+	expectedRewards := NewRewardPolicyFromChainParams(params).CalculateReward(view, tx.BlockHeight, rewardedAddresses)
 	if len(expectedRewards) != len(tx.Outputs) {
 		return result.Error("Number of rewarded account is incorrect")
 	}
@@ -86,6 +99,22 @@ func (exec *CoinbaseTxExecutor) sanityCheck(chainID string, view *st.StoreView,
 	return result.OK
 }
 
+func (exec *CoinbaseTxExecutor) verifyProposerProof(view *st.StoreView, tx *types.CoinbaseTx) result.Result {
+	epoch := getEpoch(view, exec.consensus)
+	if tx.ProposerProof.Epoch != epoch {
+		return result.Error("Invalid epoch for the proposer proof, proof_epoch = %v, epoch = %v",
+			tx.ProposerProof.Epoch, epoch).WithErrorCode(result.CodeInvalidProposer)
+	}
+	proposer, err := exec.valMgr.GetValidatorSetForEpoch(epoch).GetValidator(tx.Proposer.PubKey.Address().Hex())
+	if err != nil {
+		return result.Error("The coinbaseTx proposer is not a validator").WithErrorCode(result.CodeInvalidProposer)
+	}
+	if err := exec.valMgr.VerifyProposerProof(proposer, tx.ProposerProof); err != nil {
+		return result.Error("Invalid proposer proof: %v", err).WithErrorCode(result.CodeInvalidProposer)
+	}
+	return result.OK
+}
+
 func (exec *CoinbaseTxExecutor) process(chainID string, view *st.StoreView, transaction types.Tx) (common.Hash, result.Result) {
 	tx := transaction.(*types.CoinbaseTx)
 
@@ -99,11 +128,23 @@ func (exec *CoinbaseTxExecutor) process(chainID string, view *st.StoreView, tran
 		return common.Hash{}, res
 	}
 
+	maturity := view.GetChainParams().CoinbaseMaturity
 	for _, output := range tx.Outputs {
 		addr := string(output.Address[:])
 		if account, exists := accounts[addr]; exists {
-			account.Balance = account.Balance.Plus(output.Coins)
+			balance, err := account.Balance.CheckedPlus(output.Coins)
+			if err != nil {
+				return common.Hash{}, result.Error("Invalid balance of output %v: %v", output.Address.Hex(), err).
+					WithErrorCode(result.CodeCoinOverflow)
+			}
+			account.Balance = balance
 			view.SetAccount(output.Address, account)
+			if maturity > 0 && !output.Coins.IsZero() {
+				view.AddImmatureReward(output.Address, types.ImmatureReward{
+					MatureHeight: tx.BlockHeight + maturity,
+					Coins:        output.Coins,
+				})
+			}
 		}
 	}
 
@@ -112,16 +153,3 @@ func (exec *CoinbaseTxExecutor) process(chainID string, view *st.StoreView, tran
 	txHash := types.TxID(chainID, tx)
 	return txHash, result.OK
 }
-
-// CalculateReward calculates the block reward for each account
-func CalculateReward(view *st.StoreView, validatorAddresses []common.Address) map[string]types.Coins {
-	accountReward := map[string]types.Coins{}
-
-	for _, validatorAddress := range validatorAddresses {
-		// Initial Mainnet release should not reward the validators until the guardians ready to deploy
-		zeroReward := types.Coins{}.NoNil()
-		accountReward[string(validatorAddress[:])] = zeroReward
-	}
-
-	return accountReward
-}
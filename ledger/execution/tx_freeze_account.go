@@ -0,0 +1,95 @@
+package execution
+
+import (
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+var _ TxExecutor = (*FreezeAccountTxExecutor)(nil)
+
+// ------------------------------- FreezeAccount Transaction -----------------------------------
+
+// FreezeAccountTxExecutor implements the TxExecutor interface
+type FreezeAccountTxExecutor struct {
+}
+
+// NewFreezeAccountTxExecutor creates a new instance of FreezeAccountTxExecutor
+func NewFreezeAccountTxExecutor() *FreezeAccountTxExecutor {
+	return &FreezeAccountTxExecutor{}
+}
+
+func (exec *FreezeAccountTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
+	tx := transaction.(*types.FreezeAccountTx)
+
+	res := tx.Proposer.ValidateBasic()
+	if res.IsError() {
+		return res
+	}
+
+	governanceAddress := view.GetGovernanceAddress()
+	if governanceAddress == (common.Address{}) || tx.Proposer.Address != governanceAddress {
+		return result.Error("Only the governance account can freeze accounts").
+			WithErrorCode(result.CodeUnauthorizedTx)
+	}
+
+	// Otherwise the governance account could lock itself out
+	if tx.Address == governanceAddress {
+		return result.Error("The governance account cannot be frozen")
+	}
+
+	proposerAccount, res := getInput(view, tx.Proposer)
+	if res.IsError() {
+		return res
+	}
+
+	signBytes := tx.SignBytes(chainID)
+	res = validateInputAdvanced(view, proposerAccount, signBytes, tx.Proposer)
+	if res.IsError() {
+		return res
+	}
+
+	if !sanityCheckForFee(view, tx.Fee) {
+		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+	}
+
+	if !proposerAccount.Balance.IsGTE(tx.Fee) {
+		return result.Error("Insufficient fund: balance is %v, fee is %v", proposerAccount.Balance, tx.Fee).
+			WithErrorCode(result.CodeInsufficientFund)
+	}
+
+	if view.GetAccount(tx.Address) == nil {
+		return result.Error("Account to freeze does not exist: %v", tx.Address.Hex())
+	}
+
+	return result.OK
+}
+
+func (exec *FreezeAccountTxExecutor) process(chainID string, view *st.StoreView, transaction types.Tx) (common.Hash, result.Result) {
+	tx := transaction.(*types.FreezeAccountTx)
+
+	proposerAccount, res := getInput(view, tx.Proposer)
+	if res.IsError() {
+		return common.Hash{}, res
+	}
+
+	account := view.GetAccount(tx.Address)
+	if account == nil {
+		return common.Hash{}, result.Error("Account to freeze does not exist: %v", tx.Address.Hex())
+	}
+
+	if !chargeFee(proposerAccount, tx.Fee) {
+		return common.Hash{}, result.Error("failed to charge transaction fee")
+	}
+
+	incrementSequence(proposerAccount)
+	view.SetAccount(tx.Proposer.Address, proposerAccount)
+
+	account.Frozen = tx.Frozen
+	view.SetAccount(tx.Address, account)
+
+	txHash := types.TxID(chainID, tx)
+	return txHash, result.OK
+}
@@ -0,0 +1,103 @@
+package execution
+
+import (
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+var _ TxExecutor = (*MultiSendTxExecutor)(nil)
+
+// ------------------------------- MultiSend Transaction -----------------------------------
+
+// MultiSendTxExecutor implements the TxExecutor interface
+type MultiSendTxExecutor struct {
+}
+
+// NewMultiSendTxExecutor creates a new instance of MultiSendTxExecutor
+func NewMultiSendTxExecutor() *MultiSendTxExecutor {
+	return &MultiSendTxExecutor{}
+}
+
+func (exec *MultiSendTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
+	tx := transaction.(*types.MultiSendTx)
+	inputs := []types.TxInput{tx.Input}
+
+	// Validate input and outputs, basic
+	res := validateInputsBasic(inputs)
+	if res.IsError() {
+		return res
+	}
+	if len(tx.Outputs) == 0 {
+		return result.Error("MultiSendTx should have at least one output")
+	}
+	res = validateOutputsBasic(tx.Outputs)
+	if res.IsError() {
+		return res
+	}
+
+	// Get input
+	accounts, res := getInputs(view, inputs)
+	if res.IsError() {
+		return res
+	}
+
+	// Get or make outputs, which also rejects the duplicated output addresses
+	accounts, res = getOrMakeOutputs(view, accounts, tx.Outputs)
+	if res.IsError() {
+		return res
+	}
+
+	// Validate input, advanced. The input balance needs to cover all the outputs and the fee
+	signBytes := tx.SignBytes(chainID)
+	inTotal, res := validateInputsAdvanced(view, accounts, signBytes, inputs)
+	if res.IsError() {
+		return res
+	}
+
+	if !sanityCheckForFee(view, tx.Fee) {
+		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+	}
+
+	res = validateOutputsAdvanced(accounts, tx.Outputs)
+	if res.IsError() {
+		return res
+	}
+
+	outTotal, res := sumOutputs(tx.Outputs)
+	if res.IsError() {
+		return res
+	}
+	outPlusFee, err := outTotal.CheckedPlus(tx.Fee)
+	if err != nil {
+		return result.Error("Invalid output total + fee: %v", err).WithErrorCode(result.CodeCoinOverflow)
+	}
+	if !inTotal.IsEqual(outPlusFee) {
+		return result.Error("Input total (%v) != output total + fee (%v)", inTotal, outPlusFee)
+	}
+
+	return result.OK
+}
+
+func (exec *MultiSendTxExecutor) process(chainID string, view *st.StoreView, transaction types.Tx) (common.Hash, result.Result) {
+	tx := transaction.(*types.MultiSendTx)
+	inputs := []types.TxInput{tx.Input}
+
+	accounts, res := getInputs(view, inputs)
+	if res.IsError() {
+		return common.Hash{}, res
+	}
+
+	accounts, res = getOrMakeOutputs(view, accounts, tx.Outputs)
+	if res.IsError() {
+		return common.Hash{}, res
+	}
+
+	adjustByInputs(view, accounts, inputs)
+	adjustByOutputs(view, accounts, tx.Outputs)
+
+	txHash := types.TxID(chainID, tx)
+	return txHash, result.OK
+}
@@ -0,0 +1,92 @@
+package execution
+
+import (
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+var _ TxExecutor = (*ParamUpdateTxExecutor)(nil)
+
+// ------------------------------- ParamUpdate Transaction -----------------------------------
+
+// ParamUpdateTxExecutor implements the TxExecutor interface
+type ParamUpdateTxExecutor struct {
+}
+
+// NewParamUpdateTxExecutor creates a new instance of ParamUpdateTxExecutor
+func NewParamUpdateTxExecutor() *ParamUpdateTxExecutor {
+	return &ParamUpdateTxExecutor{}
+}
+
+func (exec *ParamUpdateTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
+	tx := transaction.(*types.ParamUpdateTx)
+
+	res := tx.Proposer.ValidateBasic()
+	if res.IsError() {
+		return res
+	}
+
+	governanceAddress := view.GetGovernanceAddress()
+	if governanceAddress == (common.Address{}) || tx.Proposer.Address != governanceAddress {
+		return result.Error("Only the governance account can update the chain params").
+			WithErrorCode(result.CodeUnauthorizedTx)
+	}
+
+	proposerAccount, res := getInput(view, tx.Proposer)
+	if res.IsError() {
+		return res
+	}
+
+	signBytes := tx.SignBytes(chainID)
+	res = validateInputAdvanced(view, proposerAccount, signBytes, tx.Proposer)
+	if res.IsError() {
+		return res
+	}
+
+	if !sanityCheckForFee(view, tx.Fee) {
+		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+	}
+
+	if !proposerAccount.Balance.IsGTE(tx.Fee) {
+		return result.Error("Insufficient fund: balance is %v, fee is %v", proposerAccount.Balance, tx.Fee).
+			WithErrorCode(result.CodeInsufficientFund)
+	}
+
+	if len(tx.Updates) == 0 {
+		return result.Error("No chain params to update")
+	}
+	if _, res := view.GetChainParams().Update(tx.Updates); res.IsError() {
+		return res
+	}
+
+	return result.OK
+}
+
+func (exec *ParamUpdateTxExecutor) process(chainID string, view *st.StoreView, transaction types.Tx) (common.Hash, result.Result) {
+	tx := transaction.(*types.ParamUpdateTx)
+
+	proposerAccount, res := getInput(view, tx.Proposer)
+	if res.IsError() {
+		return common.Hash{}, res
+	}
+
+	params, res := view.GetChainParams().Update(tx.Updates)
+	if res.IsError() {
+		return common.Hash{}, res
+	}
+
+	if !chargeFee(proposerAccount, tx.Fee) {
+		return common.Hash{}, result.Error("failed to charge transaction fee")
+	}
+
+	view.SetChainParams(params)
+
+	incrementSequence(proposerAccount)
+	view.SetAccount(tx.Proposer.Address, proposerAccount)
+
+	txHash := types.TxID(chainID, tx)
+	return txHash, result.OK
+}
@@ -44,15 +44,15 @@ func (exec *ReleaseFundTxExecutor) sanityCheck(chainID string, view *st.StoreVie
 
 	// Validate input, advanced
 	signBytes := tx.SignBytes(chainID)
-	res = validateInputAdvanced(sourceAccount, signBytes, tx.Source)
+	res = validateInputAdvanced(view, sourceAccount, signBytes, tx.Source)
 	if res.IsError() {
 		log.Infof(fmt.Sprintf("validateSourceAdvanced failed on %v: %v", tx.Source.Address.Hex(), res))
 		return res
 	}
 
-	if !sanityCheckForFee(tx.Fee) {
+	if !sanityCheckForFee(view, tx.Fee) {
 		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
-			types.MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
 	}
 
 	minimalBalance := tx.Fee
@@ -92,7 +92,7 @@ func (exec *ReleaseFundTxExecutor) process(chainID string, view *st.StoreView, t
 		return common.Hash{}, result.Error("failed to charge transaction fee")
 	}
 
-	sourceAccount.Sequence++
+	incrementSequence(sourceAccount)
 	view.SetAccount(sourceAddress, sourceAccount)
 
 	txHash := types.TxID(chainID, tx)
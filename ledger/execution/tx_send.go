@@ -23,8 +23,15 @@ func NewSendTxExecutor() *SendTxExecutor {
 func (exec *SendTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
 	tx := transaction.(*types.SendTx)
 
+	// The fee payer, if any, is validated as an additional input which covers exactly the fee
+	inputs := tx.AllInputs()
+	if tx.FeePayer != nil && !tx.FeePayer.Coins.IsEqual(tx.Fee) {
+		return result.Error("Fee payer coins (%v) != fee (%v)", tx.FeePayer.Coins, tx.Fee).
+			WithErrorCode(result.CodeInvalidFee)
+	}
+
 	// Validate inputs and outputs, basic
-	res := validateInputsBasic(tx.Inputs)
+	res := validateInputsBasic(inputs)
 	if res.IsError() {
 		return res
 	}
@@ -34,7 +41,7 @@ func (exec *SendTxExecutor) sanityCheck(chainID string, view *st.StoreView, tran
 	}
 
 	// Get inputs
-	accounts, res := getInputs(view, tx.Inputs)
+	accounts, res := getInputs(view, inputs)
 	if res.IsError() {
 		return res
 	}
@@ -47,19 +54,29 @@ func (exec *SendTxExecutor) sanityCheck(chainID string, view *st.StoreView, tran
 
 	// Validate inputs and outputs, advanced
 	signBytes := tx.SignBytes(chainID)
-	inTotal, res := validateInputsAdvanced(accounts, signBytes, tx.Inputs)
+	inTotal, res := validateInputsAdvanced(view, accounts, signBytes, inputs)
 	if res.IsError() {
 		return res
 	}
 
-	if !sanityCheckForFee(tx.Fee) {
+	if !sanityCheckForFee(view, tx.Fee) {
 		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
-			types.MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+	}
+
+	res = validateOutputsAdvanced(accounts, tx.Outputs)
+	if res.IsError() {
+		return res
 	}
 
-	outTotal := sumOutputs(tx.Outputs)
-	outPlusFees := outTotal
-	outPlusFees = outTotal.Plus(tx.Fee)
+	outTotal, res := sumOutputs(tx.Outputs)
+	if res.IsError() {
+		return res
+	}
+	outPlusFees, err := outTotal.CheckedPlus(tx.Fee)
+	if err != nil {
+		return result.Error("Invalid output total + fees: %v", err).WithErrorCode(result.CodeCoinOverflow)
+	}
 	if !inTotal.IsEqual(outPlusFees) {
 		return result.Error("Input total (%v) != output total + fees (%v)", inTotal, outPlusFees)
 	}
@@ -70,7 +87,8 @@ func (exec *SendTxExecutor) sanityCheck(chainID string, view *st.StoreView, tran
 func (exec *SendTxExecutor) process(chainID string, view *st.StoreView, transaction types.Tx) (common.Hash, result.Result) {
 	tx := transaction.(*types.SendTx)
 
-	accounts, res := getInputs(view, tx.Inputs)
+	inputs := tx.AllInputs()
+	accounts, res := getInputs(view, inputs)
 	if res.IsError() {
 		return common.Hash{}, res
 	}
@@ -80,7 +98,7 @@ func (exec *SendTxExecutor) process(chainID string, view *st.StoreView, transact
 		return common.Hash{}, res
 	}
 
-	adjustByInputs(view, accounts, tx.Inputs)
+	adjustByInputs(view, accounts, inputs)
 	adjustByOutputs(view, accounts, tx.Outputs)
 
 	txHash := types.TxID(chainID, tx)
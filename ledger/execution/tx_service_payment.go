@@ -47,6 +47,9 @@ func (exec *ServicePaymentTxExecutor) sanityCheck(chainID string, view *st.Store
 	if res.IsError() {
 		return res
 	}
+	if res := checkAccountNotFrozen(sourceAccount, sourceAddress); res.IsError() {
+		return res
+	}
 
 	// Get the target account (that signed and broadcasted this transaction)
 	targetAccount, res := getOrMakeInput(view, tx.Target)
@@ -60,28 +63,27 @@ func (exec *ServicePaymentTxExecutor) sanityCheck(chainID string, view *st.Store
 
 	// Verify source
 	sourceSignBytes := tx.SourceSignBytes(chainID)
-	if !sourceAccount.PubKey.VerifySignature(sourceSignBytes, tx.Source.Signature) {
+	if !verifySignature(view, sourceAccount.PubKey, sourceSignBytes, tx.Source.Signature) {
 		errMsg := fmt.Sprintf("sanityCheckForServicePaymentTx failed on source signature, addr: %v", sourceAddress.Hex())
 		log.Infof(errMsg)
 		return result.Error(errMsg)
 	}
 
 	// Verify target
-	if targetAccount.Sequence+1 != tx.Target.Sequence {
-		return result.Error("Got %v, expected %v. (acc.seq=%v)",
-			tx.Target.Sequence, targetAccount.Sequence+1, targetAccount.Sequence)
+	if res := checkSequence(targetAccount, tx.Target); res.IsError() {
+		return res
 	}
 
 	targetSignBytes := tx.TargetSignBytes(chainID)
-	if !targetAccount.PubKey.VerifySignature(targetSignBytes, tx.Target.Signature) {
+	if !verifySignature(view, targetAccount.PubKey, targetSignBytes, tx.Target.Signature) {
 		errMsg := fmt.Sprintf("sanityCheckForServicePaymentTx failed on target signature, addr: %v", targetAddress.Hex())
 		log.Infof(errMsg)
 		return result.Error(errMsg)
 	}
 
-	if !sanityCheckForFee(tx.Fee) {
+	if !sanityCheckForFee(view, tx.Fee) {
 		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
-			types.MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
 	}
 
 	transferAmount := tx.Source.Coins
@@ -134,7 +136,7 @@ func (exec *ServicePaymentTxExecutor) process(chainID string, view *st.StoreView
 	if !chargeFee(targetAccount, tx.Fee) {
 		return common.Hash{}, result.Error("failed to charge transaction fee")
 	}
-	targetAccount.Sequence++ // targetAccount broadcasted the transaction
+	incrementSequence(targetAccount) // targetAccount broadcasted the transaction
 
 	view.SetAccount(sourceAddress, sourceAccount)
 	for account := range coinsMap {
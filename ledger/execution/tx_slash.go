@@ -30,7 +30,7 @@ func NewSlashTxExecutor(consensus core.ConsensusEngine, valMgr core.ValidatorMan
 func (exec *SlashTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
 	tx := transaction.(*types.SlashTx)
 
-	validatorAddresses := getValidatorAddresses(exec.consensus, exec.valMgr)
+	validatorAddresses := getValidatorAddresses(view, exec.consensus, exec.valMgr)
 
 	// Validate proposer, basic
 	res := tx.Proposer.ValidateBasic()
@@ -51,7 +51,7 @@ func (exec *SlashTxExecutor) sanityCheck(chainID string, view *st.StoreView, tra
 
 	// verify the proposer's signature
 	signBytes := tx.SignBytes(chainID)
-	if !proposerAccount.PubKey.VerifySignature(signBytes, tx.Proposer.Signature) {
+	if !verifySignature(view, proposerAccount.PubKey, signBytes, tx.Proposer.Signature) {
 		return result.Error("SignBytes: %X", signBytes)
 	}
 
@@ -127,9 +127,16 @@ func (exec *SlashTxExecutor) process(chainID string, view *st.StoreView, transac
 	if !remainingFund.IsNonnegative() {
 		remainingFund = types.NewCoins(0, 0) // Should NOT happen, just to be on the safe side
 	}
-	slashedAmount := reservedFund.Collateral.Plus(remainingFund)
+	slashedAmount, err := reservedFund.Collateral.CheckedPlus(remainingFund)
+	if err != nil {
+		return common.Hash{}, result.Error("Invalid slashed amount: %v", err).WithErrorCode(result.CodeCoinOverflow)
+	}
 
-	proposerAccount.Balance = proposerAccount.Balance.Plus(slashedAmount)
+	proposerBalance, err := proposerAccount.Balance.CheckedPlus(slashedAmount)
+	if err != nil {
+		return common.Hash{}, result.Error("Invalid proposer balance: %v", err).WithErrorCode(result.CodeCoinOverflow)
+	}
+	proposerAccount.Balance = proposerBalance
 	slashedAccount.ReservedFunds = append(slashedAccount.ReservedFunds[:reservedFundIdx],
 		slashedAccount.ReservedFunds[reservedFundIdx+1:]...)
 
@@ -17,6 +17,14 @@ var _ TxExecutor = (*SmartContractTxExecutor)(nil)
 
 // ------------------------------- SmartContractTx Transaction -----------------------------------
 
+// DeriveContractAddress returns the address of the contract deployed by the SmartContractTx with
+// the given deployer and sequence, i.e. tx.From.Address and tx.From.Sequence. The address only
+// depends on these two fields, so it can be predicted before the deployment.
+func DeriveContractAddress(deployer common.Address, sequence uint64) common.Address {
+	// The sequence of the deployer account is incremented by the deployment
+	return vm.ContractAddress(deployer, sequence-1)
+}
+
 // SmartContractTxExecutor implements the TxExecutor interface
 type SmartContractTxExecutor struct {
 }
@@ -43,7 +51,7 @@ func (exec *SmartContractTxExecutor) sanityCheck(chainID string, view *st.StoreV
 
 	// Validate input, advanced
 	signBytes := tx.SignBytes(chainID)
-	res = validateInputAdvanced(fromAccount, signBytes, tx.From)
+	res = validateInputAdvanced(view, fromAccount, signBytes, tx.From)
 	if res.IsError() {
 		log.Infof(fmt.Sprintf("validateSourceAdvanced failed on %v: %v", tx.From.Address.Hex(), res))
 		return res
@@ -55,8 +63,8 @@ func (exec *SmartContractTxExecutor) sanityCheck(chainID string, view *st.StoreV
 			WithErrorCode(result.CodeInvalidValueToTransfer)
 	}
 
-	if !sanityCheckForGasPrice(tx.GasPrice) {
-		return result.Error("Insufficient gas price. Gas price needs to be at least %v GammaWei", types.MinimumGasPrice).
+	if !sanityCheckForGasPrice(view, tx.GasPrice) {
+		return result.Error("Insufficient gas price. Gas price needs to be at least %v GammaWei", view.GetChainParams().MinimumGasPrice).
 			WithErrorCode(result.CodeInvalidGasPrice)
 	}
 
@@ -90,6 +98,7 @@ func (exec *SmartContractTxExecutor) process(chainID string, view *st.StoreView,
 	//       deployed smart contract. Thus, we should call vm.Execute() before calling getInput().
 	//       Otherwise, the fromAccount returned by getInput() will have incorrect balance.
 	_, _, gasUsed, _ := vm.Execute(tx, view)
+	view.AddGasUsed(gasUsed)
 
 	fromAddress := tx.From.Address
 	fromAccount, success := getInput(view, tx.From)
@@ -108,7 +117,7 @@ func (exec *SmartContractTxExecutor) process(chainID string, view *st.StoreView,
 
 	createContract := (tx.To.Address == common.Address{})
 	if !createContract { // vm.create() increments the sequence of the from account
-		fromAccount.Sequence++
+		incrementSequence(fromAccount)
 	}
 	view.SetAccount(fromAddress, fromAccount)
 
@@ -43,14 +43,14 @@ func (exec *SplitRuleTxExecutor) sanityCheck(chainID string, view *st.StoreView,
 
 	// Validate inputs and outputs, advanced
 	signBytes := tx.SignBytes(chainID)
-	res = validateInputAdvanced(initiatorAccount, signBytes, tx.Initiator)
+	res = validateInputAdvanced(view, initiatorAccount, signBytes, tx.Initiator)
 	if res.IsError() {
 		return res
 	}
 
-	if !sanityCheckForFee(tx.Fee) {
+	if !sanityCheckForFee(view, tx.Fee) {
 		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
-			types.MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
 	}
 
 	minimalBalance := tx.Fee
@@ -129,7 +129,7 @@ func (exec *SplitRuleTxExecutor) process(chainID string, view *st.StoreView, tra
 		return common.Hash{}, result.Error("failed to charge transaction fee")
 	}
 
-	initiatorAccount.Sequence++
+	incrementSequence(initiatorAccount)
 	view.SetAccount(tx.Initiator.Address, initiatorAccount)
 
 	txHash := types.TxID(chainID, tx)
@@ -3,6 +3,8 @@ package execution
 import (
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
 	st "github.com/thetatoken/ukulele/ledger/state"
 	"github.com/thetatoken/ukulele/ledger/types"
 )
@@ -13,78 +15,86 @@ var _ TxExecutor = (*UpdateValidatorsTxExecutor)(nil)
 
 // UpdateValidatorsTxExecutor implements the TxExecutor interface
 type UpdateValidatorsTxExecutor struct {
-	state *st.LedgerState
+	state     *st.LedgerState
+	consensus core.ConsensusEngine
 }
 
 // NewUpdateValidatorsTxExecutor creates a new instance of UpdateValidatorsTxExecutor
-func NewUpdateValidatorsTxExecutor(state *st.LedgerState) *UpdateValidatorsTxExecutor {
+func NewUpdateValidatorsTxExecutor(state *st.LedgerState, consensus core.ConsensusEngine) *UpdateValidatorsTxExecutor {
 	return &UpdateValidatorsTxExecutor{
-		state: state,
+		state:     state,
+		consensus: consensus,
 	}
 }
 
 func (exec *UpdateValidatorsTxExecutor) sanityCheck(chainID string, view *st.StoreView, transaction types.Tx) result.Result {
-	// tx := transaction.(*types.UpdateValidatorsTx)
-
-	// res := tx.Proposer.ValidateBasic()
-	// if res.IsError() {
-	// 	return res
-	// }
-
-	// // Get input account
-	// proposerAccount, success := getInput(view, tx.Proposer)
-	// if success.IsError() {
-	// 	return result.Error("Proposer account does not exist: %v", tx.Proposer.Address)
-	// }
-
-	// // Validate input, advanced
-	// signBytes := tx.SignBytes(chainID)
-	// res = validateInputAdvanced(proposerAccount, signBytes, tx.Proposer)
-	// if res.IsError() {
-	// 	log.Infof(fmt.Sprintf("validateInputAdvanced failed on %X: %v", tx.Proposer.Address, res))
-	// 	return res
-	// }
-
-	// if !sanityCheckForFee(tx.Fee) {
-	//	return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
-	//		types.MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
-	// }
-
-	// // Verify that validator set matches with local config.
-	// genDoc, err := ReadGenesisFile()
-	// if err != nil {
-	// 	return result.Error(err.Error())
-	// }
-	// configedValidators := make(map[string]ttypes.GenesisValidator)
-	// for _, v := range genDoc.Validators {
-	// 	configedValidators[hex.EncodeToString(v.PubKey.Bytes())] = v
-	// }
-	// for _, pv := range tx.Validators {
-	// 	cv, ok := configedValidators[hex.EncodeToString(pv.PubKey)]
-	// 	if !ok || cv.Amount != int64(pv.Power) {
-	// 		return result.Error("Proposed validator set doesn't match with local configuration")
-	// 	}
-	// }
+	tx := transaction.(*types.UpdateValidatorsTx)
+
+	res := tx.Proposer.ValidateBasic()
+	if res.IsError() {
+		return res
+	}
+
+	governanceAddress := view.GetGovernanceAddress()
+	if governanceAddress == (common.Address{}) || tx.Proposer.Address != governanceAddress {
+		return result.Error("Only the governance account can update the validators").
+			WithErrorCode(result.CodeUnauthorizedTx)
+	}
+
+	proposerAccount, res := getInput(view, tx.Proposer)
+	if res.IsError() {
+		return res
+	}
+
+	signBytes := tx.SignBytes(chainID)
+	res = validateInputAdvanced(view, proposerAccount, signBytes, tx.Proposer)
+	if res.IsError() {
+		return res
+	}
+
+	if !sanityCheckForFee(view, tx.Fee) {
+		return result.Error("Insufficient fee. Transaction fee needs to be at least %v GammaWei",
+			view.GetChainParams().MinimumTransactionFeeGammaWei).WithErrorCode(result.CodeInvalidFee)
+	}
+
+	if !proposerAccount.Balance.IsGTE(tx.Fee) {
+		return result.Error("Insufficient fund: balance is %v, fee is %v", proposerAccount.Balance, tx.Fee).
+			WithErrorCode(result.CodeInsufficientFund)
+	}
+
+	if len(tx.Validators) == 0 {
+		return result.Error("No validators to update")
+	}
+	for _, validator := range tx.Validators {
+		if _, err := crypto.PublicKeyFromBytes(validator.PubKey); err != nil {
+			return result.Error("Invalid validator public key: %v", err)
+		}
+	}
 
 	return result.OK
 }
 
+// process records the validator changes as pending. They take effect after the next epoch
+// boundary, see Executor.ApplyEpochBoundary.
 func (exec *UpdateValidatorsTxExecutor) process(chainID string, view *st.StoreView, transaction types.Tx) (common.Hash, result.Result) {
 	tx := transaction.(*types.UpdateValidatorsTx)
 
-	// account, res := getInput(view, tx.Proposer)
-	// if res.IsError() {
-	// 	return nil, res
-	// }
+	proposerAccount, res := getInput(view, tx.Proposer)
+	if res.IsError() {
+		return common.Hash{}, res
+	}
 
-	// if !chargeFee(account, tx.Fee) {
-	// 	return nil, result.Error("failed to charge transaction fee")
-	// }
+	if !chargeFee(proposerAccount, tx.Fee) {
+		return common.Hash{}, result.Error("failed to charge transaction fee")
+	}
 
-	// account.Sequence++
-	// view.SetAccount(tx.Proposer.Address, account)
+	epoch := getEpoch(view, exec.consensus)
+	for _, validator := range tx.Validators {
+		view.AddPendingValidatorChange(epoch, validator)
+	}
 
-	// exec.state.SetValidatorDiff(tx.Validators)
+	incrementSequence(proposerAccount)
+	view.SetAccount(tx.Proposer.Address, proposerAccount)
 
 	txHash := types.TxID(chainID, tx)
 	return txHash, result.OK
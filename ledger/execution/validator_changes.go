@@ -0,0 +1,95 @@
+package execution
+
+import (
+	"bytes"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+// ApplyEpochBoundary is the executor step run before the transactions of each block. At the first
+// block of an epoch, it applies the validator stake changes recorded in the earlier epochs, which
+// become effective in the next epoch. It returns whether the validator stakes changed. The
+// changes that cannot be applied are discarded, so they never block the chain.
+func (exec *Executor) ApplyEpochBoundary(view *st.StoreView) bool {
+	epoch := getEpoch(view, exec.consensus)
+	if len(view.GetPendingValidatorChanges()) == 0 || view.GetPendingValidatorChangesEpoch() >= epoch {
+		return false
+	}
+	if _, res := exec.ApplyValidatorChanges(view, epoch); res.IsError() {
+		log.Errorf("Discarding the pending validator changes: %v", res.Message)
+		view.ClearPendingValidatorChanges()
+		return false
+	}
+	return true
+}
+
+// ApplyValidatorChanges applies the validator stake changes pending in the view to the stakes of
+// the active validators at the boundary into the given epoch, and returns the resulting validator
+// set, which becomes effective in the next epoch. Recording a stake change and activating it are
+// separate steps, so the blocks of the given epoch are still validated by the validator set
+// their proposers were selected from. Before the first changes, the stakes are those of the
+// validator set of the validator manager.
+func (exec *Executor) ApplyValidatorChanges(view *st.StoreView, epoch uint64) (*core.ValidatorSet, result.Result) {
+	stakes := make(map[string]uint64)
+	if len(view.GetValidatorStakes()) == 0 {
+		for _, v := range exec.valMgr.GetValidatorSetForEpoch(epoch).Validators() {
+			pubKey := v.PublicKey()
+			stakes[string(pubKey.ToBytes())] = v.Stake()
+		}
+	}
+	for _, stake := range view.GetValidatorStakes() {
+		stakes[string(stake.PubKey)] = stake.Stake
+	}
+	for _, change := range view.GetPendingValidatorChanges() {
+		if _, err := crypto.PublicKeyFromBytes(change.PubKey); err != nil {
+			return nil, result.Error("Invalid validator public key: %v", err)
+		}
+		if change.Stake == 0 {
+			delete(stakes, string(change.PubKey))
+		} else {
+			stakes[string(change.PubKey)] = change.Stake
+		}
+	}
+	if len(stakes) == 0 {
+		return nil, result.Error("The validator changes of epoch %v would leave no validators", epoch)
+	}
+
+	resolved := make([]types.ValidatorStake, 0, len(stakes))
+	for pubKey, stake := range stakes {
+		resolved = append(resolved, types.ValidatorStake{PubKey: []byte(pubKey), Stake: stake})
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return bytes.Compare(resolved[i].PubKey, resolved[j].PubKey) < 0
+	})
+	view.SetValidatorStakes(epoch+1, resolved)
+	view.ClearPendingValidatorChanges()
+
+	return newValidatorSet(resolved), result.OK
+}
+
+// LoadValidatorSet registers the validator set persisted in the view with the validator manager,
+// if it implements core.ValidatorSetUpdater. It is called once the state with the applied changes
+// is committed, or the ledger is reset to another state.
+func (exec *Executor) LoadValidatorSet(view *st.StoreView) {
+	stakes := view.GetValidatorStakes()
+	if len(stakes) == 0 {
+		return
+	}
+	if updater, ok := exec.valMgr.(core.ValidatorSetUpdater); ok {
+		updater.SetValidatorSetForEpoch(view.GetValidatorStakesEpoch(), newValidatorSet(stakes))
+	}
+}
+
+func newValidatorSet(stakes []types.ValidatorStake) *core.ValidatorSet {
+	validatorSet := core.NewValidatorSet()
+	for _, stake := range stakes {
+		validatorSet.AddValidator(core.NewValidator(stake.PubKey, stake.Stake))
+	}
+	return validatorSet
+}
@@ -0,0 +1,40 @@
+package ledger
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/thetatoken/ukulele/common"
+)
+
+// FinalizationCallback is invoked with the height and the state root of a finalized block
+type FinalizationCallback func(height uint64, root common.Hash)
+
+// RegisterFinalizationCallback registers a callback to be invoked whenever a block is finalized.
+// The callbacks are invoked outside of the ledger state lock, so they may query the ledger.
+func (ledger *Ledger) RegisterFinalizationCallback(fn FinalizationCallback) {
+	ledger.callbackMu.Lock()
+	defer ledger.callbackMu.Unlock()
+
+	ledger.finalizationCallbacks = append(ledger.finalizationCallbacks, fn)
+}
+
+// notifyFinalization invokes the finalization callbacks in the order of registration
+func (ledger *Ledger) notifyFinalization(height uint64, root common.Hash) {
+	ledger.callbackMu.RLock()
+	callbacks := ledger.finalizationCallbacks
+	ledger.callbackMu.RUnlock()
+
+	for _, fn := range callbacks {
+		invokeFinalizationCallback(fn, height, root)
+	}
+}
+
+// invokeFinalizationCallback invokes the callback, recovering from its panic so that a faulty
+// callback does not crash the node
+func invokeFinalizationCallback(fn FinalizationCallback, height uint64, root common.Hash) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Finalization callback panicked: height = %v, root = %v, error = %v", height, root.Hex(), r)
+		}
+	}()
+	fn(height, root)
+}
@@ -0,0 +1,54 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+)
+
+func TestLedgerFinalizationCallback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, ledger, _ := newTestLedger()
+	prepareInitLedgerState(ledger, 1)
+	res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash())
+	require.True(res.IsOK(), res.Message)
+
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+	height := ledger.state.Height()
+
+	// A panicking callback does not prevent the other callbacks from running
+	ledger.RegisterFinalizationCallback(func(height uint64, root common.Hash) {
+		panic("faulty callback")
+	})
+
+	var finalizedHeight uint64
+	var finalizedRoot, snapshotRoot common.Hash
+	ledger.RegisterFinalizationCallback(func(height uint64, root common.Hash) {
+		finalizedHeight = height
+		finalizedRoot = root
+
+		// The callback may query the ledger
+		snapshot, err := ledger.GetFinalizedSnapshot()
+		require.Nil(err)
+		snapshotRoot = snapshot.Hash()
+	})
+
+	res = ledger.FinalizeState(height, stateRoot)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(height, finalizedHeight)
+	assert.Equal(stateRoot, finalizedRoot)
+	assert.Equal(stateRoot, snapshotRoot)
+
+	// The callbacks are not invoked if the finalization fails
+	finalizedHeight = 0
+	res = ledger.FinalizeState(height+1, common.BytesToHash([]byte("unknown root")))
+	assert.True(res.IsError())
+	assert.Equal(uint64(0), finalizedHeight)
+}
@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/ledger/vm"
+)
+
+// EstimateGas returns the minimum gas limit the given smart contract transaction needs to
+// execute successfully against the current delivered state. It binary-searches the gas limit
+// up to the block gas limit, and returns an error if the transaction fails even with the
+// block gas limit. The ledger state is not modified.
+func (ledger *Ledger) EstimateGas(rawTx common.Bytes) (uint64, result.Result) {
+	tx, err := types.TxFromBytes(rawTx)
+	if err != nil {
+		return 0, result.Error("Error decoding tx: %v", err).
+			WithErrorCode(result.CodeTxParseError)
+	}
+	sctx, ok := tx.(*types.SmartContractTx)
+	if !ok {
+		return 0, result.Error("Gas estimation is only supported for smart contract transactions")
+	}
+
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	hi := ledger.state.Delivered().GetChainParams().MaxBlockGas
+	res := ledger.simulateSmartContractTx(sctx, hi)
+	if res.IsError() {
+		return 0, result.Error("Transaction fails with the block gas limit %v: %v", hi, res.Message).
+			WithErrorCode(result.CodeEVMError)
+	}
+
+	// Invariant: the transaction fails with gas limit lo, and succeeds with gas limit hi
+	lo := uint64(0)
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		if ledger.simulateSmartContractTx(sctx, mid).IsOK() {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return hi, result.OK
+}
+
+// simulateSmartContractTx executes the smart contract transaction with the given gas limit
+// against a copy of the delivered view.
+func (ledger *Ledger) simulateSmartContractTx(sctx *types.SmartContractTx, gasLimit uint64) result.Result {
+	view, err := ledger.state.Delivered().Copy()
+	if err != nil {
+		return result.Error("Failed to copy the delivered view: %v", err)
+	}
+
+	simTx := *sctx
+	simTx.GasLimit = gasLimit
+	_, _, _, vmErr := vm.Execute(&simTx, view)
+	if vmErr != nil {
+		return result.Error("%v", vmErr).WithErrorCode(result.CodeEVMError)
+	}
+	return result.OK
+}
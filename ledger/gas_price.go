@@ -0,0 +1,80 @@
+package ledger
+
+import (
+	"math/big"
+
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+// regularTxGas is the gas a regular transaction is deemed to consume when its flat fee is
+// compared against the gas price floor, such that the minimum transaction fee corresponds to
+// the minimum gas price
+var regularTxGas = new(big.Int).SetUint64(types.MinimumTransactionFeeGammaWei / types.MinimumGasPrice)
+
+// SetMinGasPrice sets the gas price floor in GammaWei. The transactions priced below the floor
+// are rejected by ScreenTx, and are not included in the blocks proposed by the node. The floor is
+// a local policy of the node, e.g. to keep spam out of the mempool, and is not enforced on the
+// blocks proposed by the other nodes.
+func (ledger *Ledger) SetMinGasPrice(minGasPrice *big.Int) {
+	ledger.policyMu.Lock()
+	defer ledger.policyMu.Unlock()
+
+	ledger.minGasPrice = new(big.Int).Set(minGasPrice)
+}
+
+// GetMinGasPrice returns the gas price floor in GammaWei
+func (ledger *Ledger) GetMinGasPrice() *big.Int {
+	ledger.policyMu.RLock()
+	defer ledger.policyMu.RUnlock()
+
+	return new(big.Int).Set(ledger.minGasPrice)
+}
+
+// checkGasPrice checks the transaction is priced at or above the gas price floor. The special
+// transactions, which pay no fee, are exempt.
+func (ledger *Ledger) checkGasPrice(tx types.Tx) result.Result {
+	gasPrice, ok := getGasPrice(tx)
+	if !ok {
+		return result.OK
+	}
+
+	minGasPrice := ledger.GetMinGasPrice()
+	if gasPrice.Cmp(minGasPrice) < 0 {
+		return result.Error("Gas price %v GammaWei is below the minimum gas price %v GammaWei",
+			gasPrice, minGasPrice).WithErrorCode(result.CodeFeeTooLow)
+	}
+	return result.OK
+}
+
+// getGasPrice returns the gas price in GammaWei of the transaction. For a regular transaction,
+// it is the fee divided by regularTxGas.
+func getGasPrice(tx types.Tx) (*big.Int, bool) {
+	var fee types.Coins
+	switch tx := tx.(type) {
+	case *types.SendTx:
+		fee = tx.Fee
+	case *types.MultiSendTx:
+		fee = tx.Fee
+	case *types.ReserveFundTx:
+		fee = tx.Fee
+	case *types.ReleaseFundTx:
+		fee = tx.Fee
+	case *types.ServicePaymentTx:
+		fee = tx.Fee
+	case *types.SplitRuleTx:
+		fee = tx.Fee
+	case *types.ParamUpdateTx:
+		fee = tx.Fee
+	case *types.FreezeAccountTx:
+		fee = tx.Fee
+	case *types.SmartContractTx:
+		if tx.GasPrice == nil {
+			return big.NewInt(0), true
+		}
+		return tx.GasPrice, true
+	default:
+		return nil, false
+	}
+	return new(big.Int).Div(fee.NoNil().GammaWei, regularTxGas), true
+}
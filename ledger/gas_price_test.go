@@ -0,0 +1,48 @@
+package ledger
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+func TestLedgerMinGasPrice(t *testing.T) {
+	assert := assert.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+	assert.Equal(new(big.Int).SetUint64(types.MinimumGasPrice), ledger.GetMinGasPrice())
+
+	// The minimum transaction fee and gas price are at the default floor
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	res := ledger.ScreenTx(sendTxBytes)
+	assert.True(res.IsOK(), res.Message)
+	scTxBytes := newRawSmartContractTx(chainID, 2, 50000, types.MinimumGasPrice, accIns[0])
+	res = ledger.ScreenTx(scTxBytes)
+	assert.True(res.IsOK(), res.Message)
+
+	// Raising the floor rejects the transactions priced below it, in both ScreenTx and CheckTx
+	ledger.SetMinGasPrice(new(big.Int).SetUint64(2 * types.MinimumGasPrice))
+	res = ledger.ScreenTx(sendTxBytes)
+	assert.Equal(result.CodeFeeTooLow, res.Code, res.Message)
+	res = ledger.ScreenTx(scTxBytes)
+	assert.Equal(result.CodeFeeTooLow, res.Code, res.Message)
+	tx, err := types.TxFromBytes(scTxBytes)
+	assert.Nil(err)
+	res = ledger.checkTx(scTxBytes, tx)
+	assert.Equal(result.CodeFeeTooLow, res.Code, res.Message)
+
+	// A smart contract transaction priced at the raised floor is accepted
+	scTxBytes = newRawSmartContractTx(chainID, 2, 50000, 2*types.MinimumGasPrice, accIns[0])
+	res = ledger.ScreenTx(scTxBytes)
+	assert.True(res.IsOK(), res.Message)
+
+	// The special transactions pay no fee and are not subject to the floor
+	coinbaseTxBytes := newRawCoinbaseTx(chainID, ledger, 1)
+	tx, err = types.TxFromBytes(coinbaseTxBytes)
+	assert.Nil(err)
+	assert.True(ledger.checkGasPrice(tx).IsOK())
+}
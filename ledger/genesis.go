@@ -0,0 +1,202 @@
+package ledger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/hexutil"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/rlp"
+)
+
+// GenesisAccount is an account funded in the genesis state
+type GenesisAccount struct {
+	Address  common.Address         `json:"address"`
+	Balance  types.Coins            `json:"balance"`
+	Multisig *GenesisMultisigPolicy `json:"multisig,omitempty" rlp:"optional"` // nil if the account has no multisig policy
+}
+
+// GenesisMultisigPolicy is the multisig policy of a genesis account, which requires the spends
+// of the account to be signed by at least Threshold of the public keys
+type GenesisMultisigPolicy struct {
+	Threshold uint64          `json:"threshold"`
+	PubKeys   []hexutil.Bytes `json:"pub_keys"`
+}
+
+// policy returns the multisig policy, after validating the public keys and the threshold
+func (gmp *GenesisMultisigPolicy) policy() (*types.MultisigPolicy, result.Result) {
+	policy := &types.MultisigPolicy{Threshold: gmp.Threshold}
+	for _, pubKeyBytes := range gmp.PubKeys {
+		pubKey, err := crypto.PublicKeyFromBytes(common.Bytes(pubKeyBytes))
+		if err != nil {
+			return nil, result.Error("Invalid multisig public key: %v", err)
+		}
+		policy.PubKeys = append(policy.PubKeys, pubKey)
+	}
+	if res := policy.ValidateBasic(); res.IsError() {
+		return nil, res
+	}
+	return policy, result.OK
+}
+
+// GenesisValidator is a validator and its stake in the genesis state
+type GenesisValidator struct {
+	PubKey hexutil.Bytes `json:"pub_key"`
+	Stake  uint64        `json:"stake"`
+}
+
+//
+// GenesisState describes the initial state of the chain. It can be loaded from JSON (see
+// ReadGenesisState) or RLP. The chain params and the governance account are optional, the chain
+// starts with the default chain params and without governance account if they are not set.
+//
+type GenesisState struct {
+	ChainID           string             `json:"chain_id"`
+	Accounts          []GenesisAccount   `json:"accounts"`
+	Validators        []GenesisValidator `json:"validators"`
+	ChainParams       *types.ChainParams `json:"chain_params,omitempty" rlp:"optional"`
+	GovernanceAddress common.Address     `json:"governance_address" rlp:"optional"`
+}
+
+// ReadGenesisState reads the genesis state from the given JSON file
+func ReadGenesisState(filePath string) (*GenesisState, error) {
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	genesis := &GenesisState{}
+	if err := json.Unmarshal(raw, genesis); err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// ValidatorSet returns the genesis validator set
+func (genesis *GenesisState) ValidatorSet() *core.ValidatorSet {
+	validatorSet := core.NewValidatorSet()
+	for _, validator := range genesis.Validators {
+		validatorSet.AddValidator(core.NewValidator(common.Bytes(validator.PubKey), validator.Stake))
+	}
+	return validatorSet
+}
+
+// LoadGenesis populates the ledger state with the given genesis state, and commits it as the
+// state of the genesis block at height 0. The resulting state root only depends on the content
+// of the genesis state, not on the order of its accounts and validators. If the expected genesis
+// root is configured, the resulting state root is verified against it.
+func (ledger *Ledger) LoadGenesis(genesis *GenesisState) result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	return ledger.loadGenesis(genesis, ledger.genesisRoot)
+}
+
+// LoadGenesisWithExpectedRoot is the same as LoadGenesis, except that it fails without committing
+// the genesis state if the resulting state root differs from the given one. This catches the
+// genesis configurations drifting between the operators of the nodes bootstrapping the chain.
+func (ledger *Ledger) LoadGenesisWithExpectedRoot(genesis *GenesisState, expectedRoot common.Hash) result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	return ledger.loadGenesis(genesis, expectedRoot)
+}
+
+// loadGenesis is the non-locking version of LoadGenesisWithExpectedRoot, which skips the
+// verification if the expected root is empty
+func (ledger *Ledger) loadGenesis(genesis *GenesisState, expectedRoot common.Hash) result.Result {
+	if genesis.ChainID != ledger.state.GetChainID() {
+		return result.Error("Genesis chain ID %v does not match the ledger chain ID %v",
+			genesis.ChainID, ledger.state.GetChainID())
+	}
+
+	view := st.NewStoreView(0, common.Hash{}, ledger.db)
+	view.Set(st.ChainIDKey(), common.Bytes(genesis.ChainID))
+
+	for _, genesisAcc := range genesis.Accounts {
+		if view.GetAccount(genesisAcc.Address) != nil {
+			return result.Error("Duplicated genesis account: %v", genesisAcc.Address.Hex())
+		}
+		balance := genesisAcc.Balance.NoNil()
+		if !balance.IsNonnegative() {
+			return result.Error("Negative balance of genesis account: %v", genesisAcc.Address.Hex())
+		}
+		view.SetAccount(genesisAcc.Address, &types.Account{
+			Balance: balance,
+		})
+		if genesisAcc.Multisig != nil {
+			policy, res := genesisAcc.Multisig.policy()
+			if res.IsError() {
+				return result.Error("Invalid multisig policy of genesis account %v: %v", genesisAcc.Address.Hex(), res.Message)
+			}
+			view.SetMultisigPolicy(genesisAcc.Address, policy)
+		}
+	}
+
+	validators := make([]GenesisValidator, len(genesis.Validators))
+	copy(validators, genesis.Validators)
+	addresses := make(map[common.Address]bool)
+	for _, validator := range validators {
+		pubKey, err := crypto.PublicKeyFromBytes(common.Bytes(validator.PubKey))
+		if err != nil {
+			return result.Error("Invalid genesis validator public key: %v", err)
+		}
+		if validator.Stake == 0 {
+			return result.Error("Genesis validator %v has no stake", pubKey.Address().Hex())
+		}
+		if addresses[pubKey.Address()] {
+			return result.Error("Duplicated genesis validator: %v", pubKey.Address().Hex())
+		}
+		addresses[pubKey.Address()] = true
+
+		// The validators need accounts with known public keys to sign the coinbase transactions
+		acc := view.GetAccount(pubKey.Address())
+		if acc == nil {
+			acc = &types.Account{
+				Balance: types.NewCoins(0, 0),
+			}
+		}
+		acc.PubKey = pubKey
+		view.SetAccount(pubKey.Address(), acc)
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return string(validators[i].PubKey) < string(validators[j].PubKey)
+	})
+	validatorsBytes, err := rlp.EncodeToBytes(validators)
+	if err != nil {
+		return result.Error("Failed to encode the genesis validators: %v", err)
+	}
+	view.Set(st.ValidatorStakesKey(), validatorsBytes)
+
+	if genesis.ChainParams != nil {
+		if res := genesis.ChainParams.ValidateBasic(); res.IsError() {
+			return result.Error("Invalid genesis chain params: %v", res.Message)
+		}
+		view.SetChainParams(genesis.ChainParams)
+	}
+	if genesis.GovernanceAddress != (common.Address{}) {
+		view.SetGovernanceAddress(genesis.GovernanceAddress)
+	}
+
+	if stateRoot := view.Hash(); expectedRoot != (common.Hash{}) && stateRoot != expectedRoot {
+		log.Errorf("Genesis state root mismatch: root = %v, expected = %v", stateRoot.Hex(), expectedRoot.Hex())
+		return result.Error("Genesis state root mismatch! root: %v, expected: %v", stateRoot.Hex(), expectedRoot.Hex()).
+			WithErrorCode(result.CodeGenesisRootMismatch)
+	}
+
+	stateRoot := view.Save()
+	if res := ledger.resetState(0, stateRoot); res.IsError() {
+		return res
+	}
+	if res := ledger.state.Finalize(0, stateRoot); res.IsError() {
+		return res
+	}
+	ledger.updateStatus(false)
+	return result.OK
+}
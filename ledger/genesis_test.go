@@ -0,0 +1,259 @@
+package ledger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/hexutil"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/crypto"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/rlp"
+)
+
+func newTestGenesisState(chainID string, val1PubKey, val2PubKey *crypto.PublicKey) *GenesisState {
+	return &GenesisState{
+		ChainID: chainID,
+		Accounts: []GenesisAccount{
+			{Address: common.HexToAddress("0x1111111111111111111111111111111111111111"), Balance: types.NewCoins(1000, 2000)},
+			{Address: common.HexToAddress("0x2222222222222222222222222222222222222222"), Balance: types.NewCoins(3000, 4000)},
+			{Address: val1PubKey.Address(), Balance: types.NewCoins(5000, 6000)},
+		},
+		Validators: []GenesisValidator{
+			{PubKey: hexutil.Bytes(val1PubKey.ToBytes()), Stake: 100},
+			{PubKey: hexutil.Bytes(val2PubKey.ToBytes()), Stake: 200},
+		},
+	}
+}
+
+func TestLedgerLoadGenesis(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, val1PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val1")
+	require.Nil(err)
+	_, val2PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val2")
+	require.Nil(err)
+
+	chainID, ledger, _ := newTestLedger()
+	genesis := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	res := ledger.LoadGenesis(genesis)
+	require.True(res.IsOK(), res.Message)
+
+	view := ledger.state.Delivered()
+	stateRoot := view.Hash()
+	assert.Equal(uint64(0), view.Height())
+	assert.Equal(stateRoot, ledger.state.Finalized().Hash())
+	assert.Equal(types.NewCoins(3000, 4000), view.GetAccount(genesis.Accounts[1].Address).Balance)
+
+	// The validator accounts carry the public keys of the validators
+	val1Acc := view.GetAccount(genesis.Accounts[2].Address)
+	assert.Equal(types.NewCoins(5000, 6000), val1Acc.Balance)
+	assert.Equal(common.Bytes(genesis.Validators[0].PubKey), val1Acc.PubKey.ToBytes())
+	assert.Equal(uint64(300), genesis.ValidatorSet().TotalStake())
+
+	// The state root is deterministic
+	for i := 0; i < 3; i++ {
+		_, anotherLedger, _ := newTestLedger()
+		res := anotherLedger.LoadGenesis(newTestGenesisState(chainID, val1PubKey, val2PubKey))
+		require.True(res.IsOK(), res.Message)
+		assert.Equal(stateRoot, anotherLedger.state.Delivered().Hash())
+	}
+
+	// The state root does not depend on the order of the accounts and validators
+	reordered := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	reordered.Accounts[0], reordered.Accounts[2] = reordered.Accounts[2], reordered.Accounts[0]
+	reordered.Validators[0], reordered.Validators[1] = reordered.Validators[1], reordered.Validators[0]
+	_, anotherLedger, _ := newTestLedger()
+	res = anotherLedger.LoadGenesis(reordered)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(stateRoot, anotherLedger.state.Delivered().Hash())
+
+	// Invalid genesis states
+	invalid := newTestGenesisState("another_chain_id", val1PubKey, val2PubKey)
+	assert.True(ledger.LoadGenesis(invalid).IsError())
+
+	invalid = newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	invalid.Accounts = append(invalid.Accounts, invalid.Accounts[0])
+	assert.True(ledger.LoadGenesis(invalid).IsError())
+
+	invalid = newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	invalid.Validators[0].Stake = 0
+	assert.True(ledger.LoadGenesis(invalid).IsError())
+}
+
+func TestLedgerLoadGenesisMultisig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, val1PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val1")
+	require.Nil(err)
+	_, val2PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val2")
+	require.Nil(err)
+	pubKeys := []hexutil.Bytes{}
+	for _, seed := range []string{"signer1", "signer2", "signer3"} {
+		_, pubKey, err := crypto.TEST_GenerateKeyPairWithSeed(seed)
+		require.Nil(err)
+		pubKeys = append(pubKeys, hexutil.Bytes(pubKey.ToBytes()))
+	}
+
+	chainID, ledger, _ := newTestLedger()
+	genesis := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	genesis.Accounts[1].Multisig = &GenesisMultisigPolicy{Threshold: 2, PubKeys: pubKeys}
+	res := ledger.LoadGenesis(genesis)
+	require.True(res.IsOK(), res.Message)
+
+	view := ledger.state.Delivered()
+	policy := view.GetMultisigPolicy(genesis.Accounts[1].Address)
+	require.NotNil(policy)
+	assert.Equal(uint64(2), policy.Threshold)
+	require.Equal(3, len(policy.PubKeys))
+	assert.Equal(common.Bytes(pubKeys[0]), policy.PubKeys[0].ToBytes())
+	assert.Nil(view.GetMultisigPolicy(genesis.Accounts[0].Address))
+
+	// The policy is part of the genesis state root
+	_, anotherLedger, _ := newTestLedger()
+	res = anotherLedger.LoadGenesis(newTestGenesisState(chainID, val1PubKey, val2PubKey))
+	require.True(res.IsOK(), res.Message)
+	assert.NotEqual(view.Hash(), anotherLedger.state.Delivered().Hash())
+
+	// Invalid policies
+	for _, invalidPolicy := range []*GenesisMultisigPolicy{
+		{Threshold: 0, PubKeys: pubKeys},
+		{Threshold: 4, PubKeys: pubKeys},
+		{Threshold: 2, PubKeys: []hexutil.Bytes{pubKeys[0], pubKeys[0]}},
+		{Threshold: 1, PubKeys: []hexutil.Bytes{hexutil.Bytes("invalid")}},
+	} {
+		invalid := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+		invalid.Accounts[1].Multisig = invalidPolicy
+		_, anotherLedger, _ := newTestLedger()
+		assert.True(anotherLedger.LoadGenesis(invalid).IsError(), "%v", invalidPolicy)
+	}
+}
+
+func TestLedgerLoadGenesisChainParams(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, val1PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val1")
+	require.Nil(err)
+	_, val2PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val2")
+	require.Nil(err)
+
+	// Without chain params and governance account, the chain starts with the defaults
+	chainID, ledger, _ := newTestLedger()
+	res := ledger.LoadGenesis(newTestGenesisState(chainID, val1PubKey, val2PubKey))
+	require.True(res.IsOK(), res.Message)
+	view := ledger.state.Delivered()
+	assert.Equal(types.DefaultChainParams(), view.GetChainParams())
+	assert.Equal(common.Address{}, view.GetGovernanceAddress())
+
+	params := types.DefaultChainParams()
+	params.InitialBlockRewardGammaWei = 1000
+	params.RewardHalvingInterval = 100
+	params.CoinbaseMaturity = 10
+	governanceAddress := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	genesis := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	genesis.ChainParams = params
+	genesis.GovernanceAddress = governanceAddress
+	_, anotherLedger, _ := newTestLedger()
+	res = anotherLedger.LoadGenesis(genesis)
+	require.True(res.IsOK(), res.Message)
+	anotherView := anotherLedger.state.Delivered()
+	assert.Equal(params, anotherView.GetChainParams())
+	assert.Equal(governanceAddress, anotherView.GetGovernanceAddress())
+	assert.NotEqual(view.Hash(), anotherView.Hash())
+
+	// Invalid chain params
+	invalid := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	invalid.ChainParams = &types.ChainParams{}
+	_, anotherLedger, _ = newTestLedger()
+	assert.True(anotherLedger.LoadGenesis(invalid).IsError())
+}
+
+func TestLedgerLoadGenesisExpectedRoot(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, val1PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val1")
+	require.Nil(err)
+	_, val2PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val2")
+	require.Nil(err)
+
+	chainID, ledger, _ := newTestLedger()
+	res := ledger.LoadGenesis(newTestGenesisState(chainID, val1PubKey, val2PubKey))
+	require.True(res.IsOK(), res.Message)
+	genesisRoot := ledger.state.Delivered().Hash()
+
+	// Matching expected root
+	_, anotherLedger, _ := newTestLedger()
+	res = anotherLedger.LoadGenesisWithExpectedRoot(newTestGenesisState(chainID, val1PubKey, val2PubKey), genesisRoot)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(genesisRoot, anotherLedger.state.Delivered().Hash())
+	assert.Equal(genesisRoot, anotherLedger.state.Finalized().Hash())
+
+	// Mismatching expected root, e.g. an operator configured a different balance
+	drifted := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	drifted.Accounts[0].Balance = types.NewCoins(1, 1)
+	_, anotherLedger, _ = newTestLedger()
+	rootBefore := anotherLedger.state.Delivered().Hash()
+	res = anotherLedger.LoadGenesisWithExpectedRoot(drifted, genesisRoot)
+	assert.Equal(result.CodeGenesisRootMismatch, res.Code, res.Message)
+	assert.Equal(rootBefore, anotherLedger.state.Delivered().Hash())
+
+	// The expected root can be configured for LoadGenesis
+	_, anotherLedger, _ = newTestLedger()
+	anotherLedger.genesisRoot = genesisRoot
+	res = anotherLedger.LoadGenesis(drifted)
+	assert.Equal(result.CodeGenesisRootMismatch, res.Code, res.Message)
+	res = anotherLedger.LoadGenesis(newTestGenesisState(chainID, val1PubKey, val2PubKey))
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(genesisRoot, anotherLedger.state.Delivered().Hash())
+}
+
+func TestReadGenesisState(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirname, err := ioutil.TempDir(os.TempDir(), "genesis_test_")
+	require.Nil(err)
+	defer os.RemoveAll(dirname)
+
+	_, val1PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val1")
+	require.Nil(err)
+	_, val2PubKey, err := crypto.TEST_GenerateKeyPairWithSeed("genesis_val2")
+	require.Nil(err)
+
+	_, signerPubKey, err := crypto.TEST_GenerateKeyPairWithSeed("signer1")
+	require.Nil(err)
+
+	chainID, ledger, _ := newTestLedger()
+	genesis := newTestGenesisState(chainID, val1PubKey, val2PubKey)
+	genesis.Accounts[1].Multisig = &GenesisMultisigPolicy{Threshold: 1, PubKeys: []hexutil.Bytes{hexutil.Bytes(signerPubKey.ToBytes())}}
+	genesis.ChainParams = types.DefaultChainParams()
+	genesis.ChainParams.CoinbaseMaturity = 10
+	genesis.GovernanceAddress = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	raw, err := json.Marshal(genesis)
+	require.Nil(err)
+	filePath := path.Join(dirname, "genesis.json")
+	require.Nil(ioutil.WriteFile(filePath, raw, 0600))
+
+	loaded, err := ReadGenesisState(filePath)
+	require.Nil(err)
+	assert.Equal(genesis, loaded)
+
+	raw, err = rlp.EncodeToBytes(genesis)
+	require.Nil(err)
+	decoded := &GenesisState{}
+	require.Nil(rlp.DecodeBytes(raw, decoded))
+	assert.Equal(genesis, decoded)
+
+	res := ledger.LoadGenesis(loaded)
+	assert.True(res.IsOK(), res.Message)
+}
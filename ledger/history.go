@@ -0,0 +1,41 @@
+package ledger
+
+import (
+	"errors"
+
+	"github.com/thetatoken/ukulele/common"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/store"
+)
+
+var (
+	// ErrBlockNotFound for no block is committed at the given height.
+	ErrBlockNotFound = errors.New("BlockNotFound")
+
+	// ErrStatePruned for the state of the given height is no longer available in the database.
+	ErrStatePruned = errors.New("StatePruned")
+)
+
+// GetAccountAtHeight returns the account as of the block committed at the given height. It
+// returns nil if the account did not exist at that height, ErrBlockNotFound if no block has been
+// committed at the height, and ErrStatePruned if the state of the height has been pruned.
+func (ledger *Ledger) GetAccountAtHeight(addr common.Address, height uint64) (*types.Account, error) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	blockIndexEntry := &BlockIndexEntry{}
+	err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, ErrBlockNotFound
+		}
+		return nil, err
+	}
+
+	view := st.NewStoreView(height, blockIndexEntry.StateRoot, ledger.db)
+	if view == nil {
+		return nil, ErrStatePruned
+	}
+	return view.GetAccount(addr), nil
+}
@@ -0,0 +1,59 @@
+package ledger
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+)
+
+func TestLedgerGetAccountAtHeight(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numBlocks := 3
+	accOut, accIns := prepareInitLedgerState(ledger, numBlocks)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	accOutAddr := accOut.PubKey.Address()
+
+	// Each block transfers 15 ThetaWei to the output account
+	for idx, block := range blocks[1:] {
+		acc, err := ledger.GetAccountAtHeight(accOutAddr, block.Height)
+		require.Nil(err)
+		require.NotNil(acc)
+		expectedThetaWei := big.NewInt(700000 + 15*int64(idx+1))
+		assert.Equal(expectedThetaWei, acc.Balance.ThetaWei)
+
+		// The input account of the block has sent its coins at this height, but not before
+		accIn, err := ledger.GetAccountAtHeight(accIns[idx].PubKey.Address(), block.Height)
+		require.Nil(err)
+		assert.Equal(uint64(1), accIn.Sequence)
+		if idx+1 < numBlocks {
+			accNext, err := ledger.GetAccountAtHeight(accIns[idx+1].PubKey.Address(), block.Height)
+			require.Nil(err)
+			assert.Equal(uint64(0), accNext.Sequence)
+		}
+	}
+
+	// Nonexistent account
+	acc, err := ledger.GetAccountAtHeight(common.HexToAddress("0x1234"), blocks[1].Height)
+	assert.Nil(err)
+	assert.Nil(acc)
+
+	// No block committed at the height
+	liveHeight := ledger.state.Height()
+	_, err = ledger.GetAccountAtHeight(accOutAddr, liveHeight+1)
+	assert.Equal(ErrBlockNotFound, err)
+
+	// The state of the height has been pruned
+	err = ledger.store.Put(blockIndexKey(liveHeight+1), BlockIndexEntry{
+		ParentStateRoot: ledger.state.Delivered().Hash(),
+		StateRoot:       common.BytesToHash([]byte("pruned")),
+	})
+	require.Nil(err)
+	_, err = ledger.GetAccountAtHeight(accOutAddr, liveHeight+1)
+	assert.Equal(ErrStatePruned, err)
+}
@@ -0,0 +1,59 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/crypto"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/store"
+)
+
+// VerifyStateIntegrity checks that the delivered state is consistent with the block committed
+// at the current height, e.g. after a crash. It checks that the state root of the delivered view
+// matches the stored state root, that the stored state root is available in the database, and
+// that the tx index entries of the block point to the block. It returns the first inconsistency
+// found. If no block has been committed at the current height, there is nothing to check.
+func (ledger *Ledger) VerifyStateIntegrity() result.Result {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	height := ledger.state.Height()
+	blockIndexEntry := &BlockIndexEntry{}
+	err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+	if err == store.ErrKeyNotFound {
+		return result.OK
+	}
+	if err != nil {
+		return result.Error("Failed to read the block index at height %v: %v", height, err)
+	}
+
+	stateRoot := ledger.state.Delivered().Hash()
+	if stateRoot != blockIndexEntry.StateRoot {
+		return result.Error("State root mismatch at height %v: delivered %v, stored %v", height,
+			hex.EncodeToString(stateRoot[:]), hex.EncodeToString(blockIndexEntry.StateRoot[:])).
+			WithErrorCode(result.CodeStateRootMismatch)
+	}
+	if st.NewStoreView(height, blockIndexEntry.StateRoot, ledger.db) == nil {
+		return result.Error("State root at height %v is not available: %v",
+			height, hex.EncodeToString(blockIndexEntry.StateRoot[:])).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+
+	for idx, rawTx := range blockIndexEntry.RawTxs {
+		txHash := crypto.Keccak256Hash(rawTx)
+		txIndexEntry := &TxIndexEntry{}
+		err := ledger.store.Get(txIndexKey(txHash), txIndexEntry)
+		if err != nil {
+			return result.Error("Tx %v of the block at height %v is not indexed: %v", txHash.Hex(), height, err)
+		}
+		if txIndexEntry.BlockHeight != height || txIndexEntry.Index != uint64(idx) ||
+			!bytes.Equal(txIndexEntry.RawTx, rawTx) {
+			return result.Error("Tx index entry of %v does not point to the block at height %v: height %v, index %v",
+				txHash.Hex(), height, txIndexEntry.BlockHeight, txIndexEntry.Index)
+		}
+	}
+
+	return result.OK
+}
@@ -0,0 +1,61 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
+	mp "github.com/thetatoken/ukulele/mempool"
+)
+
+func TestLedgerVerifyStateIntegrity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+
+	// Nothing has been committed at the current height
+	res := ledger.VerifyStateIntegrity()
+	assert.True(res.IsOK(), res.Message)
+
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes))
+	require.Nil(err)
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+
+	res = ledger.VerifyStateIntegrity()
+	assert.True(res.IsOK(), res.Message)
+
+	height := ledger.state.Height()
+	blockIndexEntry := &BlockIndexEntry{}
+	require.Nil(ledger.store.Get(blockIndexKey(height), blockIndexEntry))
+
+	// Corrupted tx index
+	txHash := crypto.Keccak256Hash(sendTxBytes)
+	txIndexEntry := &TxIndexEntry{}
+	require.Nil(ledger.store.Get(txIndexKey(txHash), txIndexEntry))
+	corruptedTxIndexEntry := *txIndexEntry
+	corruptedTxIndexEntry.BlockHeight = height + 1
+	require.Nil(ledger.store.Put(txIndexKey(txHash), corruptedTxIndexEntry))
+	res = ledger.VerifyStateIntegrity()
+	assert.True(res.IsError())
+	require.Nil(ledger.store.Put(txIndexKey(txHash), *txIndexEntry))
+
+	// Corrupted stored state root
+	corruptedBlockIndexEntry := *blockIndexEntry
+	corruptedBlockIndexEntry.StateRoot = common.BytesToHash([]byte("corrupted"))
+	require.Nil(ledger.store.Put(blockIndexKey(height), corruptedBlockIndexEntry))
+	res = ledger.VerifyStateIntegrity()
+	assert.True(res.IsError())
+
+	// Restored
+	require.Nil(ledger.store.Put(blockIndexKey(height), *blockIndexEntry))
+	res = ledger.VerifyStateIntegrity()
+	assert.True(res.IsOK(), res.Message)
+}
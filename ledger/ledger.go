@@ -1,10 +1,16 @@
 package ledger
 
 import (
+	"context"
 	"encoding/hex"
+	"fmt"
+	"math/big"
+	"runtime"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/common/result"
 	"github.com/thetatoken/ukulele/core"
@@ -13,7 +19,10 @@ import (
 	st "github.com/thetatoken/ukulele/ledger/state"
 	"github.com/thetatoken/ukulele/ledger/types"
 	mp "github.com/thetatoken/ukulele/mempool"
+	"github.com/thetatoken/ukulele/store"
 	"github.com/thetatoken/ukulele/store/database"
+	"github.com/thetatoken/ukulele/store/kvstore"
+	"github.com/thetatoken/ukulele/store/treestore"
 )
 
 var _ core.Ledger = (*Ledger)(nil)
@@ -25,24 +34,80 @@ type Ledger struct {
 	consensus core.ConsensusEngine
 	valMgr    core.ValidatorManager
 	mempool   *mp.Mempool
+	signer    crypto.Signer // Signs the coinbase and slash transactions proposed by the node
 
 	mu       *sync.RWMutex // Lock for accessing ledger state.
 	state    *st.LedgerState
 	executor *exec.Executor
+	store    store.Store       // For the tx index
+	db       database.Database // For replaying the committed blocks
+	batch    *blockBatch       // Blocks applied since BeginBatch, nil if no batch is in progress
+
+	checkTxCache *checkTxCache
+	stateVersion uint64 // Version of the checked view, advances whenever the checked view changes
+
+	statusMu *sync.RWMutex // Lock for accessing the status, which does not wait for the ledger state lock
+	status   Status
+
+	canonicalTxOrdering bool          // Whether to sort the regular transactions of the proposed blocks by (sender, sequence)
+	proposalDeadline    time.Duration // Time after which the proposer stops adding regular transactions, 0 means no deadline
+	maxReorgDepth       uint64        // Max number of blocks a reset of the ledger state can roll back or replay
+	genesisRoot         common.Hash   // Expected state root of the genesis state, not verified if empty
+	stateRetention      uint64        // Number of blocks below the finalized one whose states FinalizeAndMaybePrune keeps, 0 means no pruning
+
+	callbackMu            *sync.RWMutex // Lock for accessing the callbacks, which run outside of the ledger state lock
+	finalizationCallbacks []FinalizationCallback
+
+	subscriptionMu   *sync.Mutex // Lock for accessing the subscribers of the committed blocks
+	blockSubscribers map[chan BlockInfo]struct{}
+
+	policyMu    *sync.RWMutex // Lock for accessing the transaction admission policy, which can be adjusted at runtime
+	minGasPrice *big.Int      // Gas price floor in GammaWei
 }
 
-// NewLedger creates an instance of Ledger
-func NewLedger(chainID string, db database.Database, consensus core.ConsensusEngine, valMgr core.ValidatorManager, mempool *mp.Mempool) *Ledger {
+// NewLedger creates an instance of Ledger. If signer is nil, the transactions proposed by the
+// node are signed with the private key of the consensus engine.
+func NewLedger(chainID string, db database.Database, consensus core.ConsensusEngine, valMgr core.ValidatorManager, mempool *mp.Mempool, signer crypto.Signer) *Ledger {
+	if signer == nil {
+		signer = consensus.PrivateKey()
+	}
 	state := st.NewLedgerState(chainID, db)
 	executor := exec.NewExecutor(state, consensus, valMgr)
 	ledger := &Ledger{
 		consensus: consensus,
 		valMgr:    valMgr,
 		mempool:   mempool,
+		signer:    signer,
 		mu:        &sync.RWMutex{},
 		state:     state,
 		executor:  executor,
+		store:     kvstore.NewKVStore(db),
+		db:        db,
+
+		checkTxCache: newCheckTxCache(checkTxCacheSize),
+
+		statusMu: &sync.RWMutex{},
+
+		canonicalTxOrdering: viper.GetBool(common.CfgLedgerCanonicalTxOrdering),
+		proposalDeadline:    time.Duration(viper.GetInt64(common.CfgLedgerProposalDeadline)) * time.Millisecond,
+		maxReorgDepth:       core.MaxReorgDepth,
+		stateRetention:      uint64(viper.GetInt64(common.CfgLedgerStateRetention)),
+
+		callbackMu: &sync.RWMutex{},
+
+		subscriptionMu:   &sync.Mutex{},
+		blockSubscribers: make(map[chan BlockInfo]struct{}),
+
+		policyMu:    &sync.RWMutex{},
+		minGasPrice: new(big.Int).SetInt64(viper.GetInt64(common.CfgLedgerMinGasPrice)),
+	}
+	if maxReorgDepth := viper.GetInt64(common.CfgLedgerMaxReorgDepth); maxReorgDepth > 0 {
+		ledger.maxReorgDepth = uint64(maxReorgDepth)
 	}
+	if genesisRoot := viper.GetString(common.CfgLedgerGenesisRoot); genesisRoot != "" {
+		ledger.genesisRoot = common.HexToHash(genesisRoot)
+	}
+	ledger.updateStatus(false)
 	return ledger
 }
 
@@ -70,12 +135,72 @@ func (ledger *Ledger) GetFinalizedSnapshot() (*st.StoreView, error) {
 	return ledger.state.Finalized().Copy()
 }
 
-// ScreenTx screens the given transaction
+// GetBalance returns a copy of the balance of the given account in the selected view. The balance
+// of a nonexistent account is zero.
+func (ledger *Ledger) GetBalance(addr common.Address, viewSel core.ViewSelector) (types.Coins, error) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	var view *st.StoreView
+	switch viewSel {
+	case core.DeliveredView:
+		view = ledger.state.Delivered()
+	case core.CheckedView:
+		view = ledger.state.Checked()
+	case core.ScreenedView:
+		view = ledger.state.Screened()
+	default:
+		return types.Coins{}, fmt.Errorf("Invalid view selector: %v", viewSel)
+	}
+
+	acc := view.GetAccount(addr)
+	if acc == nil {
+		return types.NewCoins(0, 0), nil
+	}
+	balance := acc.Balance.NoNil()
+	return types.Coins{
+		ThetaWei: new(big.Int).Set(balance.ThetaWei),
+		GammaWei: new(big.Int).Set(balance.GammaWei),
+	}, nil
+}
+
+// GetPendingSlashIntents returns copies of the slash intents in the delivered ledger state, i.e.
+// the slashes the next proposed block would include
+func (ledger *Ledger) GetPendingSlashIntents() ([]types.SlashIntent, error) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	slashIntents := ledger.state.Delivered().GetSlashIntents()
+	pendingSlashIntents := make([]types.SlashIntent, len(slashIntents))
+	for i, slashIntent := range slashIntents {
+		pendingSlashIntents[i] = types.SlashIntent{
+			Address:         slashIntent.Address,
+			ReserveSequence: slashIntent.ReserveSequence,
+			Proof:           append(common.Bytes{}, slashIntent.Proof...),
+		}
+	}
+	return pendingSlashIntents, nil
+}
+
+// ScreenTx screens the given transaction. The screening starts the lifecycle of the transaction
+// in the logs, whose lines carry the hash of the transaction as the txHash field.
 func (ledger *Ledger) ScreenTx(rawTx common.Bytes) result.Result {
+	res := ledger.screenTx(rawTx)
+	txLog := txLogger(crypto.Keccak256Hash(rawTx))
+	if res.IsError() {
+		txLog.Debugf("Transaction screening failed: %v", res.Message)
+	} else {
+		txLog.Debug("Screened transaction")
+	}
+	return res
+}
+
+func (ledger *Ledger) screenTx(rawTx common.Bytes) result.Result {
 	var tx types.Tx
 	tx, err := types.TxFromBytes(rawTx)
 	if err != nil {
-		return result.Error("Error decoding tx: %v", err)
+		return result.Error("Error decoding tx: %v", err).
+			WithErrorCode(result.CodeTxParseError)
 	}
 
 	if ledger.shouldSkipCheckTx(tx) {
@@ -83,6 +208,10 @@ func (ledger *Ledger) ScreenTx(rawTx common.Bytes) result.Result {
 			WithErrorCode(result.CodeUnauthorizedTx)
 	}
 
+	if res := ledger.checkGasPrice(tx); res.IsError() {
+		return res
+	}
+
 	ledger.mu.RLock()
 	defer ledger.mu.RUnlock()
 
@@ -90,113 +219,490 @@ func (ledger *Ledger) ScreenTx(rawTx common.Bytes) result.Result {
 	return res
 }
 
+// SubmitTx screens the given transaction, and adds it to the mempool if it passes the screening,
+// from where it is gossiped to the peers on the transaction channel. It is the single entry point
+// for the transactions submitted by the clients. On success, the info of the result is the hash
+// of the transaction.
+func (ledger *Ledger) SubmitTx(rawTx common.Bytes) result.Result {
+	// The mempool screens the transaction through ScreenTx before adding it
+	err := ledger.mempool.InsertTransaction(mp.CreateMempoolTransaction(rawTx))
+	if err != nil {
+		res := result.Error("Failed to submit the transaction: %v", err)
+		if codedErr, ok := err.(interface{ Code() result.ErrorCode }); ok {
+			res = res.WithErrorCode(codedErr.Code())
+		}
+		return res
+	}
+	return result.OK.WithInfo(crypto.Keccak256Hash(rawTx))
+}
+
 // ProposeBlockTxs collects and executes a list of transactions, which will be used to assemble the next blockl
-// It also clears these transactions from the mempool.
+// It also clears these transactions from the mempool. If a proposal deadline is configured, it stops
+// adding regular transactions once the deadline has passed.
 func (ledger *Ledger) ProposeBlockTxs() (stateRootHash common.Hash, blockRawTxs []common.Bytes, res result.Result) {
+	ctx, cancel := ledger.proposalContext()
+	defer cancel()
+	return ledger.ProposeBlockTxsCtx(ctx)
+}
+
+// ProposeBlockTxsWithTrace is the same as ProposeBlockTxs, except that it also returns the trace of
+// the block assembly, which records whether each candidate transaction was included, and if not,
+// why. Meant for debugging rejected proposals, since the tracing slows down the assembly.
+func (ledger *Ledger) ProposeBlockTxsWithTrace() (stateRootHash common.Hash, blockRawTxs []common.Bytes, trace *ProposalTrace, res result.Result) {
+	ctx, cancel := ledger.proposalContext()
+	defer cancel()
+
+	trace = &ProposalTrace{}
+	stateRootHash, blockRawTxs, res = ledger.proposeBlockTxs(ctx, trace)
+	return stateRootHash, blockRawTxs, trace, res
+}
+
+// proposalContext returns the context bounded by the proposal deadline if configured
+func (ledger *Ledger) proposalContext() (context.Context, context.CancelFunc) {
+	if ledger.proposalDeadline > 0 {
+		return context.WithTimeout(context.Background(), ledger.proposalDeadline)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// ProposeBlockTxsCtx is the same as ProposeBlockTxs, except that it stops adding regular transactions
+// once the context is done. The special transactions are always included, and the regular transactions
+// not yet checked are left in the mempool.
+func (ledger *Ledger) ProposeBlockTxsCtx(ctx context.Context) (stateRootHash common.Hash, blockRawTxs []common.Bytes, res result.Result) {
+	return ledger.proposeBlockTxs(ctx, nil)
+}
+
+// proposeBlockTxs assembles the block transactions, and records the decisions in the trace if not nil
+func (ledger *Ledger) proposeBlockTxs(ctx context.Context, trace *ProposalTrace) (stateRootHash common.Hash, blockRawTxs []common.Bytes, res result.Result) {
 	ledger.mu.Lock()
 	defer ledger.mu.Unlock()
 
 	view := ledger.state.Checked()
+	regularRawTxs, blockRawTxs := ledger.assembleBlockTxs(ctx, view, ledger.checkTx, trace)
+
+	stateRootHash = view.Hash()
+	ledger.mempool.Update(regularRawTxs) // clear txs from the mempool
+
+	return stateRootHash, blockRawTxs, result.OK
+}
+
+// PreviewNextBlock returns the transactions and the state root hash of the block that would be
+// proposed next. The transactions are checked against a copy of the checked view, so neither
+// the ledger state nor the mempool is modified.
+func (ledger *Ledger) PreviewNextBlock() (blockRawTxs []common.Bytes, stateRootHash common.Hash, res result.Result) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	checkedView := ledger.state.Checked()
+	view, err := checkedView.Copy()
+	if err != nil {
+		return nil, common.Hash{}, result.Error("Failed to copy the checked view: %v", err)
+	}
+	for _, slashIntent := range checkedView.GetSlashIntents() {
+		view.AddSlashIntent(slashIntent)
+	}
+	view.AddGasUsed(checkedView.GasUsed())
+
+	_, blockRawTxs = ledger.assembleBlockTxs(context.Background(), view, func(rawTx common.Bytes, tx types.Tx) result.Result {
+		if res := ledger.checkGasPrice(tx); res.IsError() {
+			return res
+		}
+		_, res := ledger.executor.CheckTxOnView(view, tx)
+		return res
+	}, nil)
+
+	return blockRawTxs, view.Hash(), result.OK
+}
+
+// assembleBlockTxs collects the special transactions and the regular transactions reaped from the
+// mempool, and returns the reaped transactions along with the transactions that pass checkTx.
+// checkTx is expected to apply the passing transactions to the given view. Once the context is
+// done, the remaining regular transactions are skipped, and are excluded from the returned
+// reaped transactions. The decisions on the candidates are recorded in the trace if not nil.
+func (ledger *Ledger) assembleBlockTxs(ctx context.Context, view *st.StoreView, checkTx func(rawTx common.Bytes, tx types.Tx) result.Result, trace *ProposalTrace) (regularRawTxs []common.Bytes, blockRawTxs []common.Bytes) {
+	// The validators apply the epoch boundary before the block transactions, so does the proposer
+	ledger.executor.ApplyEpochBoundary(view)
 
 	// Add special transactions
 	rawTxCandidates := []common.Bytes{}
 	ledger.addSpecialTransactions(view, &rawTxCandidates)
+	numSpecialTxs := len(rawTxCandidates)
 
 	// Add regular transactions submitted by the clients
-	regularRawTxs := ledger.mempool.Reap(core.MaxNumRegularTxsPerBlock)
+	regularRawTxs = ledger.mempool.Reap(core.MaxNumRegularTxsPerBlock)
+	if ledger.canonicalTxOrdering {
+		regularRawTxs = sortTxsCanonically(regularRawTxs)
+	}
 	for _, regularRawTx := range regularRawTxs {
 		rawTxCandidates = append(rawTxCandidates, regularRawTx)
 	}
 
 	blockRawTxs = []common.Bytes{}
-	for _, rawTxCandidate := range rawTxCandidates {
+	includedSenders := make(map[common.Address]bool) // only tracked for the trace
+	for idx, rawTxCandidate := range rawTxCandidates {
+		if idx >= numSpecialTxs && isDone(ctx) {
+			numChecked := idx - numSpecialTxs
+			log.Infof("Proposal deadline reached, skipping %v regular transactions", len(regularRawTxs)-numChecked)
+			for _, skippedRawTx := range regularRawTxs[numChecked:] {
+				trace.skipped(skippedRawTx, TxSkipReasonDeadlineReached, "")
+			}
+			regularRawTxs = regularRawTxs[:numChecked]
+			break
+		}
 		tx, err := types.TxFromBytes(rawTxCandidate)
 		if err != nil {
+			trace.skipped(rawTxCandidate, TxSkipReasonParseError, err.Error())
+			continue
+		}
+		if !ledger.hasSufficientBlockGas(view, tx) {
+			log.Debugf("Skipping transaction due to insufficient block gas: tx = %v", tx)
+			trace.skipped(rawTxCandidate, TxSkipReasonBlockGas, "")
 			continue
 		}
-		_, res := ledger.executor.CheckTx(tx)
+		// The view accumulates the effects of the accepted candidates, so a candidate
+		// conflicting with an accepted one, e.g. a double spend, fails the check
+		res := checkTx(rawTxCandidate, tx)
+		sender, hasSender := types.GetSenderInput(tx)
 		if res.IsError() {
-			log.Errorf("Transaction check failed: errMsg = %v, tx = %v", res.Message, tx)
+			txLogger(crypto.Keccak256Hash(rawTxCandidate)).Errorf("Transaction check failed: errMsg = %v, tx = %v", res.Message, tx)
+			if hasSender && includedSenders[sender.Address] {
+				trace.skipped(rawTxCandidate, TxSkipReasonDoubleSpend, res.Message)
+			} else {
+				trace.skipped(rawTxCandidate, TxSkipReasonCheckFailed, res.Message)
+			}
 			continue
 		}
+		if trace != nil && hasSender {
+			includedSenders[sender.Address] = true
+		}
+		txLogger(crypto.Keccak256Hash(rawTxCandidate)).Debug("Checked transaction")
+		trace.included(rawTxCandidate)
 		blockRawTxs = append(blockRawTxs, rawTxCandidate)
 	}
 
-	stateRootHash = view.Hash()
-	ledger.mempool.Update(regularRawTxs) // clear txs from the mempool
+	return regularRawTxs, blockRawTxs
+}
 
-	return stateRootHash, blockRawTxs, result.OK
+// ApplyBlock applies the transactions of the given block like ApplyBlockTxs. The special
+// transactions are checked against the validator set of the block's epoch, so the blocks of
+// past epochs can be applied, e.g. during catch-up.
+func (ledger *Ledger) ApplyBlock(block *core.Block) result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	return ledger.applyBlockTxs(context.Background(), block.Epoch, block.Txs, block.StateHash)
 }
 
 // ApplyBlockTxs applies the given block transactions. If any of the transactions failed, it returns
 // an error immediately. If all the transactions execute successfully, it then validates the state
-// root hash. If the states root hash matches the expected value, it clears the transactions from the mempool
+// root hash. If the states root hash matches the expected value, it clears the transactions from the mempool.
+// The block is assumed to be of the current epoch of the consensus engine.
 func (ledger *Ledger) ApplyBlockTxs(blockRawTxs []common.Bytes, expectedStateRoot common.Hash) result.Result {
+	return ledger.ApplyBlockTxsCtx(context.Background(), blockRawTxs, expectedStateRoot)
+}
+
+// ApplyBlockTxsCtx is the same as ApplyBlockTxs, except that it checks the context between the
+// transactions. If the context is cancelled, it rolls back to the state before the block and
+// returns an error with CodeCancelled.
+func (ledger *Ledger) ApplyBlockTxsCtx(ctx context.Context, blockRawTxs []common.Bytes, expectedStateRoot common.Hash) result.Result {
 	ledger.mu.Lock()
 	defer ledger.mu.Unlock()
 
+	return ledger.applyBlockTxs(ctx, ledger.consensus.GetEpoch(), blockRawTxs, expectedStateRoot)
+}
+
+// applyBlockTxs is the non-locking version of ApplyBlockTxsCtx, which applies the transactions of
+// a block of the given epoch
+func (ledger *Ledger) applyBlockTxs(ctx context.Context, epoch uint64, blockRawTxs []common.Bytes, expectedStateRoot common.Hash) result.Result {
 	view := ledger.state.Delivered()
+	view.SetEpoch(epoch)
+	defer view.ClearEpoch()
 
 	currHeight := view.Height()
 	currStateRoot := view.Hash()
 
-	for _, rawTx := range blockRawTxs {
+	txs := make([]types.Tx, len(blockRawTxs))
+	for i, rawTx := range blockRawTxs {
 		tx, err := types.TxFromBytes(rawTx)
 		if err != nil {
-			ledger.resetState(currHeight, currStateRoot)
-			return result.Error("Failed to parse transaction: %v", hex.EncodeToString(rawTx))
+			return result.Error("Failed to parse transaction: %v", hex.EncodeToString(rawTx)).
+				WithErrorCode(result.CodeTxParseError)
 		}
+		txs[i] = tx
+	}
+
+	// The signatures do not depend on the state changes of the block, so they are verified in
+	// parallel before the sequential execution, which skips the signatures already verified
+	defer view.ClearVerifiedSignatures()
+	sigs := collectTxSignatures(view, ledger.state.GetChainID(), txs)
+	if idx, ok := verifyTxSignatures(view, sigs, runtime.NumCPU()); !ok {
+		return result.Error("Signature verification failed for transaction: %v", hex.EncodeToString(blockRawTxs[idx])).
+			WithErrorCode(result.CodeInvalidSignature)
+	}
+
+	validatorsChanged := ledger.executor.ApplyEpochBoundary(view)
+
+	for idx, tx := range txs {
+		select {
+		case <-ctx.Done():
+			ledger.revertBlock(currHeight, currStateRoot)
+			return result.Error("Block application cancelled: %v", ctx.Err()).
+				WithErrorCode(result.CodeCancelled)
+		default:
+		}
+
 		_, res := ledger.executor.ExecuteTx(tx)
 		if res.IsError() {
-			ledger.resetState(currHeight, currStateRoot)
+			txLogger(crypto.Keccak256Hash(blockRawTxs[idx])).Errorf("Transaction execution failed: %v", res.Message)
+			ledger.revertBlock(currHeight, currStateRoot)
+			if res.Code == result.CodeGenericError {
+				res = res.WithErrorCode(result.CodeTxExecutionFailed)
+			}
 			return res
 		}
+		txLogger(crypto.Keccak256Hash(blockRawTxs[idx])).Debug("Executed transaction")
+		if maxBlockGas := view.GetChainParams().MaxBlockGas; view.GasUsed() > maxBlockGas {
+			ledger.revertBlock(currHeight, currStateRoot)
+			return result.Error("Block gas limit exceeded! gas used: %v, limit: %v", view.GasUsed(), maxBlockGas).
+				WithErrorCode(result.CodeBlockGasLimitExceeded)
+		}
 	}
 
 	newStateRoot := view.Hash()
 	if newStateRoot != expectedStateRoot {
-		ledger.resetState(currHeight, currStateRoot)
+		ledger.revertBlock(currHeight, currStateRoot)
 		return result.Error("State root mismatch! root: %v, exptected: %v",
 			hex.EncodeToString(newStateRoot[:]),
-			hex.EncodeToString(expectedStateRoot[:]))
+			hex.EncodeToString(expectedStateRoot[:])).
+			WithErrorCode(result.CodeStateRootMismatch)
 	}
 
-	ledger.state.Commit() // commit to persistent storage
+	ledger.updateStatus(true)
+	height := currHeight + 1
+	// Commit to persistent storage, so that the block is never indexed without its state, or vice versa
+	ledger.state.CommitWith(func(batch database.Putter) error {
+		if err := ledger.indexTxs(batch, height, blockRawTxs); err != nil {
+			return err
+		}
+		return ledger.indexBlock(batch, height, epoch, currStateRoot, newStateRoot, blockRawTxs)
+	})
+	ledger.stateVersion++
+	if validatorsChanged {
+		ledger.executor.LoadValidatorSet(ledger.state.Delivered())
+	}
+
+	if ledger.batch != nil {
+		// Announced by CommitBatch once the state is persisted
+		ledger.batch.blocks = append(ledger.batch.blocks, batchedBlock{
+			height:    height,
+			stateRoot: newStateRoot,
+			rawTxs:    blockRawTxs,
+		})
+		ledger.updateStatus(false)
+		return result.OK
+	}
+
+	ledger.updateStatus(false)
+	ledger.notifyNewBlock(ledger.state.Height(), newStateRoot, len(blockRawTxs))
 
 	ledger.mempool.Update(blockRawTxs) // clear txs from the mempool
+	ledger.mempool.EvictExpiredTransactions(ledger.state.Height())
 
 	return result.OK
 }
 
-// ResetState sets the ledger state with the designated root
-func (ledger *Ledger) ResetState(height uint64, rootHash common.Hash) result.Result {
+// revertBlock reverts the state changes of a block that failed to apply. Within a batch, the whole
+// batch is rolled back.
+func (ledger *Ledger) revertBlock(height uint64, rootHash common.Hash) {
+	if ledger.batch != nil {
+		ledger.rollbackBatch()
+		return
+	}
+	ledger.resetState(height, rootHash)
+}
+
+// isDone returns whether the context is done, or its deadline has passed
+func isDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// ResetState sets the ledger state with the designated root. If the designated root is not
+// available in the database (e.g. after switching to a different branch), the target state is
+// rebuilt by replaying the given blocks from the nearest ancestor whose state root is available.
+// The blocks should be ordered by height, with the last one being the block whose state root is
+// the designated root, and are indexed in place of the blocks of the abandoned branch. It refuses
+// to revert a finalized block, or to roll back or replay more blocks than the max reorg depth.
+func (ledger *Ledger) ResetState(height uint64, rootHash common.Hash, blocks ...*core.Block) result.Result {
 	ledger.mu.Lock()
 	defer ledger.mu.Unlock()
 
-	return ledger.resetState(height, rootHash)
+	if res := ledger.checkReorgDepth(height, rootHash); res.IsError() {
+		return res
+	}
+	res := ledger.resetState(height, rootHash)
+	if len(blocks) == 0 {
+		return res
+	}
+	if res.IsError() {
+		res = ledger.replayBranch(height, rootHash, blocks)
+		if res.IsError() {
+			return res
+		}
+	}
+	if target := blocks[len(blocks)-1]; target.Height == height && target.StateHash == rootHash {
+		ledger.reindexBranch(blocks)
+	}
+	return result.OK
 }
 
-// FinalizeState sets the ledger state with the finalized root
+// replayBranch rebuilds the designated state by replaying the given blocks from the nearest
+// ancestor whose state root is available. The blocks are replayed in a batch, so if the replay
+// fails, the ledger state is restored to the state before the replay. The caller must hold the
+// ledger state lock.
+func (ledger *Ledger) replayBranch(height uint64, rootHash common.Hash, blocks []*core.Block) result.Result {
+	numBlocks := len(blocks)
+	if uint64(numBlocks-1) > ledger.maxReorgDepth {
+		return result.Error("Cannot replay %v blocks, exceeding the max reorg depth %v", numBlocks-1, ledger.maxReorgDepth).
+			WithErrorCode(result.CodeReorgTooDeep)
+	}
+	targetBlock := blocks[numBlocks-1]
+	if targetBlock.Height != height || targetBlock.StateHash != rootHash {
+		return result.Error("The last block to replay does not match the designated state, height: %v, root: %v",
+			height, hex.EncodeToString(rootHash[:])).
+			WithErrorCode(result.CodeBlockReplayFailed)
+	}
+
+	ancestorIdx := -1
+	for idx := numBlocks - 2; idx >= 0; idx-- {
+		if treestore.NewTreeStore(blocks[idx].StateHash, ledger.db) != nil {
+			ancestorIdx = idx
+			break
+		}
+	}
+	if ancestorIdx < 0 {
+		return result.Error("Failed to set state root: %v, no ancestor with a known state root", hex.EncodeToString(rootHash[:])).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+
+	// The state is rolled back to the ancestor before the replay
+	ancestor := blocks[ancestorIdx]
+	if res := ledger.checkReorgDepth(ancestor.Height, ancestor.StateHash); res.IsError() {
+		return res
+	}
+
+	if res := ledger.beginBatch(); res.IsError() {
+		return result.Error("Cannot replay blocks while a batch is in progress").
+			WithErrorCode(res.Code)
+	}
+	if res := ledger.state.ResetState(ancestor.Height, ancestor.StateHash); res.IsError() {
+		ledger.rollbackBatch()
+		return result.Error("Failed to set state root: %v", hex.EncodeToString(ancestor.StateHash[:])).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+	for idx := ancestorIdx + 1; idx < numBlocks; idx++ {
+		block := blocks[idx]
+		res := ledger.applyBlockTxs(context.Background(), block.Epoch, block.Txs, block.StateHash)
+		if res.IsError() {
+			if ledger.batch != nil { // not rolled back by applyBlockTxs, e.g. on a parse error
+				ledger.rollbackBatch()
+			}
+			return result.Error("Failed to replay block at height %v: %v", block.Height, res.Message).
+				WithErrorCode(result.CodeBlockReplayFailed)
+		}
+	}
+
+	// The blocks of the abandoned branch are replaced by the replayed blocks once committed
+	ledger.unindexBlocksAbove(ancestor.Height)
+	return ledger.commitBatch()
+}
+
+// FinalizeState sets the ledger state with the finalized root, and then invokes the finalization
+// callbacks outside of the ledger state lock
 func (ledger *Ledger) FinalizeState(height uint64, rootHash common.Hash) result.Result {
 	ledger.mu.Lock()
-	defer ledger.mu.Unlock()
+	res := ledger.finalizeState(height, rootHash)
+	ledger.mu.Unlock()
+	if res.IsError() {
+		return res
+	}
+	ledger.notifyFinalization(height, rootHash)
+	return result.OK
+}
 
+// finalizeState sets the ledger state with the finalized root. The caller must hold the ledger
+// state lock.
+func (ledger *Ledger) finalizeState(height uint64, rootHash common.Hash) result.Result {
 	res := ledger.state.Finalize(height, rootHash)
 	if res.IsError() {
-		return result.Error("Failed to finalize state root: %v", hex.EncodeToString(rootHash[:]))
+		return result.Error("Failed to finalize state root: %v", hex.EncodeToString(rootHash[:])).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+	ledger.updateStatus(false)
+	return result.OK
+}
+
+// checkReorgDepth checks whether the ledger state can be reset to the designated root. Since the
+// finalized blocks are irreversible, the root cannot be below the finalized height, or a different
+// root at the finalized height. Also, to bound the re-execution a malicious branch could force, the
+// reset cannot roll back more blocks than the max reorg depth.
+func (ledger *Ledger) checkReorgDepth(height uint64, rootHash common.Hash) result.Result {
+	finalized := ledger.state.Finalized()
+	finalizedHeight := finalized.Height()
+	if height < finalizedHeight || (finalizedHeight > 0 && height == finalizedHeight && rootHash != finalized.Hash()) {
+		return result.Error("Cannot reset the state to height %v, which would revert the block finalized at height %v",
+			height, finalizedHeight).WithErrorCode(result.CodeReorgBelowFinalized)
+	}
+
+	currentHeight := ledger.state.Height()
+	if height < currentHeight && currentHeight-height > ledger.maxReorgDepth {
+		return result.Error("Cannot roll back the state from height %v to %v, exceeding the max reorg depth %v",
+			currentHeight, height, ledger.maxReorgDepth).WithErrorCode(result.CodeReorgTooDeep)
 	}
 	return result.OK
 }
 
-// resetState sets the ledger state with the designated root
+// resetState sets the ledger state with the designated root. The blocks committed above the
+// height are abandoned, and hence removed from the tx and block indexes.
 func (ledger *Ledger) resetState(height uint64, rootHash common.Hash) result.Result {
 	res := ledger.state.ResetState(height, rootHash)
 	if res.IsError() {
-		return result.Error("Failed to set state root: %v", hex.EncodeToString(rootHash[:]))
+		return result.Error("Failed to set state root: %v", hex.EncodeToString(rootHash[:])).
+			WithErrorCode(result.CodeStateNotAvailable)
 	}
+	ledger.unindexBlocksAbove(height)
+	ledger.executor.LoadValidatorSet(ledger.state.Delivered())
+	ledger.stateVersion++
+	ledger.updateStatus(false)
 	return result.OK
 }
 
+// checkTx checks the given transaction against the checked view. A failed check leaves the checked
+// view unchanged, so its result is cached and reused until the checked view changes.
+func (ledger *Ledger) checkTx(rawTx common.Bytes, tx types.Tx) result.Result {
+	if res := ledger.checkGasPrice(tx); res.IsError() {
+		return res
+	}
+
+	txHash := crypto.Keccak256Hash(rawTx)
+	if res, ok := ledger.checkTxCache.get(txHash, ledger.stateVersion); ok {
+		return res
+	}
+
+	_, res := ledger.executor.CheckTx(tx)
+	if res.IsOK() {
+		ledger.stateVersion++ // the transaction has been applied to the checked view
+		return res
+	}
+	ledger.checkTxCache.put(txHash, ledger.stateVersion, res)
+	return res
+}
+
 // CheckTx() should skip all the transactions that can only be initiated by the validators
 // i.e., if a regular user submits a coinbaseTx or slashTx, it should be skipped so it will not
 // get into the mempool
@@ -211,18 +717,33 @@ func (ledger *Ledger) shouldSkipCheckTx(tx types.Tx) bool {
 	}
 }
 
+// hasSufficientBlockGas checks whether the remaining gas of the block is sufficient for the given transaction.
+// Since a smart contract transaction never consumes more than its gas limit, the block proposed is
+// guaranteed to stay within the block gas limit
+func (ledger *Ledger) hasSufficientBlockGas(view *st.StoreView, tx types.Tx) bool {
+	scTx, ok := tx.(*types.SmartContractTx)
+	if !ok {
+		return true
+	}
+	gasUsed := view.GasUsed()
+	maxBlockGas := view.GetChainParams().MaxBlockGas
+	return gasUsed <= maxBlockGas && scTx.GasLimit <= maxBlockGas-gasUsed
+}
+
 // addSpecialTransactions adds special transactions (e.g. coinbase transaction, slash transaction) to the block
 func (ledger *Ledger) addSpecialTransactions(view *st.StoreView, rawTxs *[]common.Bytes) {
 	epoch := ledger.consensus.GetEpoch()
 	proposer := ledger.valMgr.GetProposerForEpoch(epoch)
+	proposerProof := ledger.valMgr.GetProposerProofForEpoch(epoch)
 	validators := ledger.valMgr.GetValidatorSetForEpoch(epoch).Validators()
 
-	ledger.addCoinbaseTx(view, &proposer, &validators, rawTxs)
+	ledger.addCoinbaseTx(view, &proposer, proposerProof, &validators, rawTxs)
 	ledger.addSlashTxs(view, &proposer, &validators, rawTxs)
 }
 
 // addCoinbaseTx adds a Coinbase transaction
-func (ledger *Ledger) addCoinbaseTx(view *st.StoreView, proposer *core.Validator, validators *[]core.Validator, rawTxs *[]common.Bytes) {
+func (ledger *Ledger) addCoinbaseTx(view *st.StoreView, proposer *core.Validator, proposerProof core.ProposerProof,
+	validators *[]core.Validator, rawTxs *[]common.Bytes) {
 	proposerAddress := proposer.Address()
 	proposerPubKey := proposer.PublicKey()
 	proposerTxIn := types.TxInput{
@@ -230,27 +751,23 @@ func (ledger *Ledger) addCoinbaseTx(view *st.StoreView, proposer *core.Validator
 		PubKey:  &proposerPubKey,
 	}
 
-	validatorAddresses := make([]common.Address, len(*validators))
-	for idx, validator := range *validators {
-		validatorAddress := validator.Address()
-		validatorAddresses[idx] = validatorAddress
-	}
-	accountRewardMap := exec.CalculateReward(view, validatorAddresses)
+	// Only the validators selected under the cap are rewarded
+	validatorAddresses := exec.SelectRewardedValidators(*validators, view.GetChainParams().MaxRewardedValidators)
+	accountRewards := ledger.executor.CalculateRewardSorted(view, validatorAddresses)
 
 	coinbaseTxOutputs := []types.TxOutput{}
-	for accountAddressStr, accountReward := range accountRewardMap {
-		var accountAddress common.Address
-		copy(accountAddress[:], accountAddressStr)
+	for _, accountReward := range accountRewards {
 		coinbaseTxOutputs = append(coinbaseTxOutputs, types.TxOutput{
-			Address: accountAddress,
-			Coins:   accountReward,
+			Address: accountReward.Address,
+			Coins:   accountReward.Reward,
 		})
 	}
 
 	coinbaseTx := &types.CoinbaseTx{
-		Proposer:    proposerTxIn,
-		Outputs:     coinbaseTxOutputs,
-		BlockHeight: ledger.state.Height(),
+		Proposer:      proposerTxIn,
+		Outputs:       coinbaseTxOutputs,
+		BlockHeight:   ledger.state.Height(),
+		ProposerProof: proposerProof,
 	}
 
 	signature, err := ledger.signTransaction(coinbaseTx)
@@ -309,7 +826,7 @@ func (ledger *Ledger) addSlashTxs(view *st.StoreView, proposer *core.Validator,
 func (ledger *Ledger) signTransaction(tx types.Tx) (*crypto.Signature, error) {
 	chainID := ledger.state.GetChainID()
 	signBytes := tx.SignBytes(chainID)
-	signature, err := ledger.consensus.PrivateKey().Sign(signBytes)
+	signature, err := ledger.signer.Sign(signBytes)
 	if err != nil {
 		return nil, err
 	}
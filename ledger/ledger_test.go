@@ -1,10 +1,12 @@
 package ledger
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -45,6 +47,95 @@ func TestLedgerScreenTx(t *testing.T) {
 	assert.Equal(result.CodeUnauthorizedTx, res.Code, res.Message)
 }
 
+func TestLedgerGetBalance(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+	accIn := accIns[0]
+	accInAddr := accIn.PubKey.Address()
+
+	for _, viewSel := range []core.ViewSelector{core.DeliveredView, core.CheckedView, core.ScreenedView} {
+		balance, err := ledger.GetBalance(accInAddr, viewSel)
+		require.Nil(err)
+		assert.Equal(accIn.Balance, balance)
+	}
+
+	// The balances of the views diverge after screening a transaction
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIn)
+	res := ledger.ScreenTx(sendTxBytes)
+	require.True(res.IsOK(), res.Message)
+	txFee := getMinimumTxFee()
+	screenedBalance, err := ledger.GetBalance(accInAddr, core.ScreenedView)
+	require.Nil(err)
+	assert.Equal(accIn.Balance.Minus(types.NewCoins(15, txFee)), screenedBalance)
+	deliveredBalance, err := ledger.GetBalance(accInAddr, core.DeliveredView)
+	require.Nil(err)
+	assert.Equal(accIn.Balance, deliveredBalance)
+
+	// The returned balance is a copy
+	deliveredBalance.ThetaWei.SetInt64(0)
+	deliveredBalance, err = ledger.GetBalance(accInAddr, core.DeliveredView)
+	require.Nil(err)
+	assert.Equal(accIn.Balance, deliveredBalance)
+
+	// Nonexistent account
+	balance, err := ledger.GetBalance(common.HexToAddress("0x1234"), core.DeliveredView)
+	require.Nil(err)
+	assert.True(balance.IsZero())
+
+	_, err = ledger.GetBalance(accInAddr, core.ViewSelector(0))
+	assert.NotNil(err)
+}
+
+func TestLedgerScreenTxSequenceGap(t *testing.T) {
+	assert := assert.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	numInAccs := 1
+	accOut, accIns := prepareInitLedgerState(ledger, numInAccs)
+
+	// The account expects sequence 1
+	sendTxBytes := newRawSendTx(chainID, 3, false, accOut, accIns[0])
+	res := ledger.ScreenTx(sendTxBytes)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+
+	sequenceGap, ok := res.Info.(*types.SequenceGap)
+	assert.True(ok)
+	assert.Equal(accIns[0].Account.PubKey.Address(), sequenceGap.Address)
+	assert.Equal(uint64(3), sequenceGap.Sequence)
+	assert.Equal(uint64(1), sequenceGap.ExpectedSequence)
+}
+
+func TestLedgerSubmitTx(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 2)
+
+	// A valid transaction is added to the mempool, which gossips it to the peers
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	res := ledger.SubmitTx(sendTxBytes)
+	require.True(res.IsOK(), res.Message)
+	txHash := crypto.Keccak256Hash(sendTxBytes)
+	assert.Equal(txHash, res.Info)
+	assert.True(mempool.Has(txHash))
+
+	// An invalid transaction is neither added to the mempool nor gossiped
+	invalidTxBytes := newRawSendTx(chainID, 3, false, accOut, accIns[1])
+	res = ledger.SubmitTx(invalidTxBytes)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+	assert.False(mempool.Has(crypto.Keccak256Hash(invalidTxBytes)))
+	assert.Equal(1, mempool.Size())
+
+	// A transaction cannot be submitted twice
+	res = ledger.SubmitTx(sendTxBytes)
+	assert.True(res.IsError())
+	assert.Equal(1, mempool.Size())
+}
+
 func TestLedgerProposerBlockTxs(t *testing.T) {
 	assert := assert.New(t)
 
@@ -90,6 +181,195 @@ func TestLedgerProposerBlockTxs(t *testing.T) {
 	}
 }
 
+func TestLedgerProposeBlockTxsDoubleSpend(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	_, accIns := prepareInitLedgerState(ledger, 3)
+
+	// Two transactions spending the same input, each of them is valid on its own
+	sendTxBytes1 := newRawSendTx(chainID, 1, true, accIns[1], accIns[0])
+	sendTxBytes2 := newRawSendTx(chainID, 1, true, accIns[2], accIns[0])
+	err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes1))
+	require.Nil(err)
+	res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash()) // reset the screened view
+	require.True(res.IsOK(), res.Message)
+	err = mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes2))
+	require.Nil(err)
+	require.Equal(2, mempool.Size())
+
+	_, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(2, len(blockTxs)) // coinbase and the first send transaction
+	assert.Equal(sendTxBytes1, blockTxs[1])
+}
+
+func TestLedgerProposeBlockTxsWithTrace(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 3)
+
+	// Two transactions spending the same input
+	sendTxBytes1 := newRawSendTx(chainID, 1, true, accIns[1], accIns[0])
+	sendTxBytes2 := newRawSendTx(chainID, 1, true, accIns[2], accIns[0])
+	require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes1)))
+	res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash()) // reset the screened view
+	require.True(res.IsOK(), res.Message)
+	require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes2)))
+
+	// A transaction which no longer passes the check
+	sendTxBytes3 := newRawSendTx(chainID, 1, true, accOut, accIns[1])
+	require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes3)))
+	accIn1Addr := accIns[1].PubKey.Address()
+	accIn1 := ledger.state.Checked().GetAccount(accIn1Addr)
+	accIn1.Balance = types.NewCoins(0, 0)
+	ledger.state.Checked().SetAccount(accIn1Addr, accIn1)
+
+	_, blockTxs, trace, res := ledger.ProposeBlockTxsWithTrace()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(2, len(blockTxs)) // coinbase and the first send transaction
+	require.Equal(4, len(trace.Entries))
+
+	entries := make(map[common.Hash]ProposalTraceEntry)
+	for _, entry := range trace.Entries {
+		entries[entry.TxHash] = entry
+	}
+	for _, blockTx := range blockTxs {
+		entry := entries[crypto.Keccak256Hash(blockTx)]
+		assert.True(entry.Included)
+		assert.Equal(TxSkipReason(""), entry.Reason)
+	}
+	entry := entries[crypto.Keccak256Hash(sendTxBytes2)]
+	assert.False(entry.Included)
+	assert.Equal(TxSkipReasonDoubleSpend, entry.Reason)
+	entry = entries[crypto.Keccak256Hash(sendTxBytes3)]
+	assert.False(entry.Included)
+	assert.Equal(TxSkipReasonCheckFailed, entry.Reason)
+	assert.NotEqual("", entry.Message)
+	assert.Equal(2, len(trace.Skipped()))
+
+	// The regular transactions not checked before the deadline are traced as well
+	sendTxBytes4 := newRawSendTx(chainID, 1, true, accOut, accIns[2])
+	require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes4)))
+	res = ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash()) // the checked view has a coinbase transaction already
+	require.True(res.IsOK(), res.Message)
+	ledger.proposalDeadline = time.Nanosecond
+	_, blockTxs, trace, res = ledger.ProposeBlockTxsWithTrace()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(1, len(blockTxs))
+	skipped := trace.Skipped()
+	require.Equal(1, len(skipped))
+	assert.Equal(crypto.Keccak256Hash(sendTxBytes4), skipped[0].TxHash)
+	assert.Equal(TxSkipReasonDeadlineReached, skipped[0].Reason)
+}
+
+func TestLedgerProposeBlockTxsDeadline(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numInAccs := 3
+	accOut, accIns := prepareInitLedgerState(ledger, numInAccs)
+	rawSendTxs := []common.Bytes{}
+	for _, accIn := range accIns {
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIn)
+		require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes)))
+		rawSendTxs = append(rawSendTxs, sendTxBytes)
+	}
+
+	// The deadline has passed before any regular transaction is checked
+	ledger.proposalDeadline = time.Nanosecond
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(1, len(blockTxs))
+	tx, err := types.TxFromBytes(blockTxs[0])
+	require.Nil(err)
+	assert.IsType(&types.CoinbaseTx{}, tx)
+
+	// The skipped transactions stay in the mempool
+	assert.Equal(numInAccs, mempool.Size())
+
+	// The context is done after the first regular transaction has been checked
+	res = ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash())
+	require.True(res.IsOK(), res.Message)
+	stateRoot, blockTxs, res = ledger.ProposeBlockTxsCtx(newCountdownContext(1))
+	require.True(res.IsOK(), res.Message)
+	require.Equal(2, len(blockTxs))
+	assert.Equal(rawSendTxs[0], blockTxs[1])
+	assert.Equal(numInAccs-1, mempool.Size())
+
+	// The partial block is consistent
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestLedgerSigner(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID := "test_chain_id"
+	consensus := exec.NewTestConsensusEngine("proposer")
+	valMgr := newTesetValidatorManager(consensus)
+	signer := &mockSigner{privKey: consensus.PrivateKey()}
+	ledger := newTestLedgerWithSigner(chainID, "peer0", consensus, valMgr, signer)
+	prepareInitLedgerState(ledger, 1)
+
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(1, len(blockTxs))
+
+	// The coinbase transaction is signed by the signer
+	tx, err := types.TxFromBytes(blockTxs[0])
+	require.Nil(err)
+	coinbaseTx, ok := tx.(*types.CoinbaseTx)
+	require.True(ok)
+	require.Equal(1, len(signer.signed))
+	assert.Equal(common.Bytes(coinbaseTx.SignBytes(chainID)), signer.signed[0])
+
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestLedgerPreviewNextBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numInAccs := 3
+	accOut, accIns := prepareInitLedgerState(ledger, numInAccs)
+
+	for idx := 0; idx < numInAccs; idx++ {
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[idx])
+		err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes))
+		assert.Nil(err)
+	}
+	checkedStateRoot := ledger.state.Checked().Hash()
+
+	// The preview leaves the mempool and the checked view untouched
+	previewTxs, previewStateRoot, res := ledger.PreviewNextBlock()
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(numInAccs+1, len(previewTxs)) // coinbase and the send transactions
+	assert.Equal(numInAccs, mempool.Size())
+	assert.Equal(checkedStateRoot, ledger.state.Checked().Hash())
+	assert.NotEqual(checkedStateRoot, previewStateRoot)
+
+	// Previewing again gives the same result
+	previewTxs2, previewStateRoot2, res := ledger.PreviewNextBlock()
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(len(previewTxs), len(previewTxs2))
+	assert.Equal(previewStateRoot, previewStateRoot2)
+
+	// The proposed block matches the preview
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(previewStateRoot, stateRoot)
+	assert.Equal(len(previewTxs), len(blockTxs))
+	assert.Equal(previewTxs[1:], blockTxs[1:])
+	assert.Equal(0, mempool.Size())
+}
+
 func TestLedgerApplyBlockTxs(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -148,20 +428,643 @@ func TestLedgerApplyBlockTxs(t *testing.T) {
 	}
 }
 
+func TestLedgerErrorCodes(t *testing.T) {
+	assert := assert.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+	height := ledger.state.Height()
+	root := ledger.state.Delivered().Hash()
+	malformedTx := common.Bytes("not a transaction")
+
+	res := ledger.ScreenTx(malformedTx)
+	assert.Equal(result.CodeTxParseError, res.Code, res.Message)
+
+	res = ledger.ApplyBlockTxs([]common.Bytes{malformedTx}, root)
+	assert.Equal(result.CodeTxParseError, res.Code, res.Message)
+
+	// The specific code of a failed transaction is preserved
+	sendTxBytes := newRawSendTxWithoutPubKey(chainID, 2, accOut, accIns[0])
+	res = ledger.ApplyBlockTxs([]common.Bytes{sendTxBytes}, root)
+	assert.Equal(result.CodeInvalidSequence, res.Code, res.Message)
+
+	sendTxBytes = newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	res = ledger.ApplyBlockTxs([]common.Bytes{sendTxBytes}, common.Hash{})
+	assert.Equal(result.CodeStateRootMismatch, res.Code, res.Message)
+	assert.Equal(root, ledger.state.Delivered().Hash())
+
+	res = ledger.ResetState(height, common.HexToHash("0x1234"))
+	assert.Equal(result.CodeStateNotAvailable, res.Code, res.Message)
+
+	res = ledger.CommitBatch()
+	assert.Equal(result.CodeNoBatchInProgress, res.Code, res.Message)
+	res = ledger.BeginBatch()
+	assert.True(res.IsOK(), res.Message)
+	res = ledger.BeginBatch()
+	assert.Equal(result.CodeBatchAlreadyStarted, res.Code, res.Message)
+}
+
+func TestLedgerGetTransaction(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numInAccs := 1
+	accOut, accIns := prepareInitLedgerState(ledger, numInAccs)
+
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	sendTxHash := crypto.Keccak256Hash(sendTxBytes)
+	err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes))
+	require.Nil(err)
+
+	_, _, err = ledger.GetTransaction(sendTxHash)
+	assert.Equal(ErrTxNotFound, err)
+
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+
+	// A block that fails to apply should not be indexed
+	res = ledger.ApplyBlockTxs(blockTxs, common.Hash{})
+	require.True(res.IsError())
+	_, _, err = ledger.GetTransaction(sendTxHash)
+	assert.Equal(ErrTxNotFound, err)
+
+	height := ledger.state.Height()
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+
+	tx, txHeight, err := ledger.GetTransaction(sendTxHash)
+	require.Nil(err)
+	assert.Equal(height+1, txHeight)
+	sendTx, ok := tx.(*types.SendTx)
+	require.True(ok)
+	assert.Equal(accIns[0].PubKey.Address(), sendTx.Inputs[0].Address)
+}
+
+func TestLedgerResetStateUnindexesAbandonedBlocks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 3)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	txHashes := []common.Hash{}
+	for _, block := range blocks[1:] {
+		sendTxHash := crypto.Keccak256Hash(block.Txs[len(block.Txs)-1])
+		_, txHeight, err := ledger.GetTransaction(sendTxHash)
+		require.Nil(err)
+		assert.Equal(block.Height, txHeight)
+		txHashes = append(txHashes, sendTxHash)
+	}
+
+	// Reset below the blocks of the last two txs
+	res := ledger.ResetState(blocks[1].Height, blocks[1].StateHash)
+	require.True(res.IsOK(), res.Message)
+
+	_, txHeight, err := ledger.GetTransaction(txHashes[0])
+	require.Nil(err)
+	assert.Equal(blocks[1].Height, txHeight)
+	for _, txHash := range txHashes[1:] {
+		_, _, err = ledger.GetTransaction(txHash)
+		assert.Equal(ErrTxNotFound, err)
+	}
+	_, err = ledger.GetAccountAtHeight(accOut.PubKey.Address(), blocks[2].Height)
+	assert.Equal(ErrBlockNotFound, err)
+	_, err = ledger.GetAccountAtHeight(accOut.PubKey.Address(), blocks[1].Height)
+	assert.Nil(err)
+
+	// The blocks are indexed again once re-applied
+	applyTestBlocks(t, ledger, blocks[1:])
+	_, txHeight, err = ledger.GetTransaction(txHashes[2])
+	require.Nil(err)
+	assert.Equal(blocks[3].Height, txHeight)
+}
+
+func TestLedgerResetStateReindexesBranch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 2)
+
+	initBlock := core.NewBlock()
+	initBlock.Height = ledger.state.Height()
+	initBlock.StateHash = ledger.state.Delivered().Hash()
+
+	// Two sibling blocks A and B on top of the initial block, with a different tx each
+	newSiblingBlock := func(accIn types.PrivAccount) (*core.Block, common.Hash) {
+		res := ledger.ResetState(initBlock.Height, initBlock.StateHash)
+		require.True(res.IsOK(), res.Message)
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIn)
+		require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes)))
+		stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+		require.True(res.IsOK(), res.Message)
+		res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+		require.True(res.IsOK(), res.Message)
+
+		block := core.NewBlock()
+		block.Epoch = ledger.consensus.GetEpoch()
+		block.Height = ledger.state.Height()
+		block.StateHash = stateRoot
+		block.Txs = blockTxs
+		return block, crypto.Keccak256Hash(sendTxBytes)
+	}
+	blockA, txHashA := newSiblingBlock(accIns[0])
+	blockB, txHashB := newSiblingBlock(accIns[1])
+	_, _, err := ledger.GetTransaction(txHashA)
+	assert.Equal(ErrTxNotFound, err)
+
+	// Switch back to A, whose state is still available, so it is not replayed
+	res := ledger.ResetState(blockA.Height, blockA.StateHash, initBlock, blockA)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(blockA.StateHash, ledger.state.Delivered().Hash())
+
+	_, txHeight, err := ledger.GetTransaction(txHashA)
+	require.Nil(err)
+	assert.Equal(blockA.Height, txHeight)
+	_, _, err = ledger.GetTransaction(txHashB)
+	assert.Equal(ErrTxNotFound, err)
+	blockIndexEntry := &BlockIndexEntry{}
+	require.Nil(ledger.store.Get(blockIndexKey(blockA.Height), blockIndexEntry))
+	assert.Equal(blockA.StateHash, blockIndexEntry.StateRoot)
+	assert.Equal(initBlock.StateHash, blockIndexEntry.ParentStateRoot)
+
+	// And back to B again
+	res = ledger.ResetState(blockB.Height, blockB.StateHash, initBlock, blockB)
+	require.True(res.IsOK(), res.Message)
+	_, _, err = ledger.GetTransaction(txHashA)
+	assert.Equal(ErrTxNotFound, err)
+	_, txHeight, err = ledger.GetTransaction(txHashB)
+	require.Nil(err)
+	assert.Equal(blockB.Height, txHeight)
+}
+
+// epochConsensusEngine is a consensus engine at the given epoch
+type epochConsensusEngine struct {
+	core.ConsensusEngine
+	epoch uint64
+}
+
+func (e *epochConsensusEngine) GetEpoch() uint64 { return e.epoch }
+
+func TestLedgerApplyBlockOfPastEpoch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+
+	epoch := ledger.consensus.GetEpoch()
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+
+	// The catching up ledger has moved on to a later epoch
+	consensus := &epochConsensusEngine{ConsensusEngine: ledger.consensus, epoch: epoch + 5}
+	catchUpLedger := newTestLedgerWithConsensus(chainID, "peer1", consensus, ledger.valMgr)
+	setInitLedgerState(catchUpLedger, accOut, accIns)
+
+	// The proposer proof is not valid for the current epoch
+	res = catchUpLedger.ApplyBlockTxs(blockTxs, stateRoot)
+	assert.Equal(result.CodeInvalidProposer, res.Code, res.Message)
+
+	block := core.NewBlock()
+	block.Epoch = epoch
+	block.Height = catchUpLedger.state.Height() + 1
+	block.StateHash = stateRoot
+	block.Txs = blockTxs
+	res = catchUpLedger.ApplyBlock(block)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(stateRoot, catchUpLedger.state.Delivered().Hash())
+}
+
+// updatableValidatorManager records the validator sets registered for the later epochs
+type updatableValidatorManager struct {
+	core.ValidatorManager
+	updates map[uint64]*core.ValidatorSet
+}
+
+func (m *updatableValidatorManager) SetValidatorSetForEpoch(epoch uint64, validators *core.ValidatorSet) {
+	m.updates[epoch] = validators
+}
+
+func TestLedgerApplyValidatorChangesAtEpochBoundary(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID := "test_chain_id"
+	epoch := uint64(5)
+	consensus := &epochConsensusEngine{ConsensusEngine: exec.NewTestConsensusEngine("proposer"), epoch: epoch}
+	valMgr := &updatableValidatorManager{
+		ValidatorManager: newTesetValidatorManager(consensus),
+		updates:          make(map[uint64]*core.ValidatorSet),
+	}
+	ledger := newTestLedgerWithConsensus(chainID, "peer0", consensus, valMgr)
+	accOut := types.MakeAccWithInitBalance("accOut", types.NewCoins(700000, 3))
+	governance := types.MakeAccWithInitBalance("governance", types.NewCoins(900000, 50000*getMinimumTxFee()))
+	ledger.state.Delivered().SetGovernanceAddress(governance.PubKey.Address())
+	setInitLedgerState(ledger, accOut, []types.PrivAccount{governance})
+
+	// In epoch N, a new validator stakes
+	val3PubKey := types.MakeAcc("val3").PubKey.ToBytes()
+	updateValidatorsTx := &types.UpdateValidatorsTx{
+		Fee:        types.NewCoins(0, getMinimumTxFee()),
+		Validators: []types.ValidatorStake{{PubKey: val3PubKey, Stake: 300}},
+		Proposer:   types.NewTxInput(governance.PubKey, types.NewCoins(0, 0), 1),
+	}
+	updateValidatorsTx.SetSignature(governance.PubKey.Address(), governance.Sign(updateValidatorsTx.SignBytes(chainID)))
+	rawTx, err := types.TxToBytes(updateValidatorsTx)
+	require.Nil(err)
+	require.Nil(ledger.mempool.InsertTransaction(mp.CreateMempoolTransaction(rawTx)))
+
+	applyNextBlock := func() {
+		stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+		require.True(res.IsOK(), res.Message)
+		res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+		require.True(res.IsOK(), res.Message)
+	}
+	applyNextBlock()
+	view := ledger.state.Delivered()
+	assert.Equal(1, len(view.GetPendingValidatorChanges()))
+	assert.Equal(0, len(view.GetValidatorStakes()))
+
+	// The other blocks of epoch N do not apply the changes
+	applyNextBlock()
+	assert.Equal(1, len(ledger.state.Delivered().GetPendingValidatorChanges()))
+	assert.Equal(0, len(valMgr.updates))
+
+	// The first block of epoch N+1 applies the changes, which are persisted in the state, and
+	// become active in the epoch after, since its proposer was selected from the former validators
+	consensus.epoch = epoch + 1
+	applyNextBlock()
+	view = ledger.state.Delivered()
+	assert.Equal(0, len(view.GetPendingValidatorChanges()))
+	assert.Equal(3, len(view.GetValidatorStakes()))
+	assert.Equal(epoch+2, view.GetValidatorStakesEpoch())
+	require.Equal(1, len(valMgr.updates))
+	validatorSet := valMgr.updates[epoch+2]
+	require.NotNil(validatorSet)
+	_, err = validatorSet.GetValidator(core.NewValidator(val3PubKey, 300).ID())
+	assert.Nil(err)
+
+	// The persisted validator set is registered again after a reset
+	valMgr.updates = make(map[uint64]*core.ValidatorSet)
+	res := ledger.ResetState(ledger.state.Height(), view.Hash())
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(3, valMgr.updates[epoch+2].Size())
+}
+
+func TestLedgerResetStateReplay(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numInAccs := 2
+	accOut, accIns := prepareInitLedgerState(ledger, numInAccs)
+
+	// The replay ledger shares the same initial state, but does not have the states of
+	// the subsequent blocks in its database
+	replayLedger := newTestLedgerWithConsensus(chainID, "peer1", ledger.consensus, ledger.valMgr)
+	setInitLedgerState(replayLedger, accOut, accIns)
+
+	initBlock := core.NewBlock()
+	initBlock.Height = ledger.state.Height()
+	initBlock.StateHash = ledger.state.Delivered().Hash()
+	require.Equal(initBlock.StateHash, replayLedger.state.Delivered().Hash())
+
+	blocks := []*core.Block{initBlock}
+	for idx := 0; idx < numInAccs; idx++ {
+		parent := blocks[len(blocks)-1]
+		res := ledger.ResetState(parent.Height, parent.StateHash)
+		require.True(res.IsOK(), res.Message)
+
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[idx])
+		err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes))
+		require.Nil(err)
+
+		stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+		require.True(res.IsOK(), res.Message)
+		res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+		require.True(res.IsOK(), res.Message)
+
+		block := core.NewBlock()
+		block.Epoch = ledger.consensus.GetEpoch()
+		block.Height = ledger.state.Height()
+		block.StateHash = stateRoot
+		block.Txs = blockTxs
+		blocks = append(blocks, block)
+	}
+	targetBlock := blocks[len(blocks)-1]
+
+	res := replayLedger.ResetState(targetBlock.Height, targetBlock.StateHash)
+	assert.True(res.IsError())
+
+	// Cannot replay without an ancestor with a known state root
+	res = replayLedger.ResetState(targetBlock.Height, targetBlock.StateHash, blocks[1:]...)
+	assert.True(res.IsError())
+
+	// A replay failing midway restores the state before the replay
+	badBlock := core.NewBlock()
+	badBlock.Epoch = targetBlock.Epoch
+	badBlock.Height = targetBlock.Height
+	badBlock.StateHash = common.BytesToHash([]byte("bad root"))
+	badBlock.Txs = targetBlock.Txs
+	badBlocks := append(append([]*core.Block{}, blocks[:len(blocks)-1]...), badBlock)
+	res = replayLedger.ResetState(badBlock.Height, badBlock.StateHash, badBlocks...)
+	assert.Equal(result.CodeBlockReplayFailed, res.Code, res.Message)
+	assert.Equal(initBlock.StateHash, replayLedger.state.Delivered().Hash())
+	assert.Equal(initBlock.Height, replayLedger.state.Height())
+	assert.Nil(replayLedger.batch)
+
+	res = replayLedger.ResetState(targetBlock.Height, targetBlock.StateHash, blocks...)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(targetBlock.StateHash, replayLedger.state.Delivered().Hash())
+	assert.Equal(targetBlock.Height, replayLedger.state.Height())
+
+	// The replayed state is now materialized
+	res = replayLedger.ResetState(targetBlock.Height, targetBlock.StateHash)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestLedgerResetStateReorgDepth(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 4)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	ledger.maxReorgDepth = 2
+
+	// Rolling back more blocks than the max reorg depth is refused
+	tip := blocks[4]
+	res := ledger.ResetState(blocks[1].Height, blocks[1].StateHash)
+	assert.Equal(result.CodeReorgTooDeep, res.Code, res.Message)
+	assert.Equal(tip.Height, ledger.state.Height())
+	assert.Equal(tip.StateHash, ledger.state.Delivered().Hash())
+
+	res = ledger.ResetState(blocks[2].Height, blocks[2].StateHash)
+	require.True(res.IsOK(), res.Message)
+
+	// Replaying more blocks than the max reorg depth is refused as well
+	res = ledger.ResetState(tip.Height, common.BytesToHash([]byte("unknown root")), blocks...)
+	assert.Equal(result.CodeReorgTooDeep, res.Code, res.Message)
+
+	// The finalized blocks are irreversible
+	res = ledger.FinalizeState(blocks[2].Height, blocks[2].StateHash)
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ResetState(blocks[1].Height, blocks[1].StateHash)
+	assert.Equal(result.CodeReorgBelowFinalized, res.Code, res.Message)
+	res = ledger.ResetState(blocks[2].Height, blocks[3].StateHash)
+	assert.Equal(result.CodeReorgBelowFinalized, res.Code, res.Message)
+	res = ledger.ResetState(blocks[2].Height, blocks[2].StateHash)
+	assert.True(res.IsOK(), res.Message)
+	res = ledger.ResetState(blocks[3].Height, blocks[3].StateHash)
+	assert.True(res.IsOK(), res.Message)
+}
+
+func TestLedgerResetStateReplayAncestorDepth(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 4)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	tip := blocks[4]
+
+	// A branch whose blocks above the given base have unknown states
+	newBranch := func(base int) []*core.Block {
+		branch := append([]*core.Block{}, blocks[:base+1]...)
+		for idx := base + 1; idx < len(blocks)-1; idx++ {
+			block := core.NewBlock()
+			block.Height = blocks[idx].Height
+			block.StateHash = common.BytesToHash([]byte(fmt.Sprintf("unknown root %v", idx)))
+			block.Txs = blocks[idx].Txs
+			branch = append(branch, block)
+		}
+		return branch
+	}
+
+	// The only ancestor with a known state is more blocks behind than the max reorg depth
+	ledger.maxReorgDepth = 3
+	branch := newBranch(0)
+	target := branch[len(branch)-1]
+	res := ledger.ResetState(target.Height, target.StateHash, branch...)
+	assert.Equal(result.CodeReorgTooDeep, res.Code, res.Message)
+	assert.Equal(tip.Height, ledger.state.Height())
+	assert.Equal(tip.StateHash, ledger.state.Delivered().Hash())
+
+	// The only ancestor with a known state is below the finalized block
+	ledger.maxReorgDepth = 10
+	res = ledger.FinalizeState(blocks[2].Height, blocks[2].StateHash)
+	require.True(res.IsOK(), res.Message)
+	branch = newBranch(1)
+	target = branch[len(branch)-1]
+	res = ledger.ResetState(target.Height, target.StateHash, branch...)
+	assert.Equal(result.CodeReorgBelowFinalized, res.Code, res.Message)
+	assert.Equal(tip.Height, ledger.state.Height())
+	assert.Equal(tip.StateHash, ledger.state.Delivered().Hash())
+}
+
+func TestLedgerApplyBlockTxsCtxCancelled(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 2)
+	height := ledger.state.Height()
+	root := ledger.state.Delivered().Hash()
+
+	for _, accIn := range accIns {
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIn)
+		require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes)))
+	}
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(3, len(blockTxs)) // the coinbase tx and the two send txs
+
+	// The context is cancelled after the first two transactions have been executed
+	ctx := newCountdownContext(2)
+	res = ledger.ApplyBlockTxsCtx(ctx, blockTxs, stateRoot)
+	assert.Equal(result.CodeCancelled, res.Code, res.Message)
+
+	// The state is rolled back to the state before the block
+	assert.Equal(height, ledger.state.Height())
+	assert.Equal(root, ledger.state.Delivered().Hash())
+	accInAddr := accIns[0].Account.PubKey.Address()
+	assert.Equal(uint64(0), ledger.state.Delivered().GetAccount(accInAddr).Sequence)
+	assert.False(ledger.state.Delivered().CoinbaseTransactinProcessed())
+
+	// The block can be applied afterwards
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(height+1, ledger.state.Height())
+}
+
+// countdownContext is a context that is cancelled after its Done method has been called the
+// given number of times
+type countdownContext struct {
+	context.Context
+	remaining int
+	done      chan struct{}
+}
+
+func newCountdownContext(remaining int) *countdownContext {
+	return &countdownContext{
+		Context:   context.Background(),
+		remaining: remaining,
+		done:      make(chan struct{}),
+	}
+}
+
+func (ctx *countdownContext) Done() <-chan struct{} {
+	if ctx.remaining == 0 {
+		close(ctx.done)
+	}
+	ctx.remaining--
+	return ctx.done
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.remaining < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestLedgerBlockGasLimit(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	prepareInitLedgerState(ledger, 0)
+
+	gasLimit := core.MaxBlockGas
+	gasPrice := types.MinimumGasPrice
+	feeLimit := int64(gasLimit * gasPrice)
+	caller := types.MakeAccWithInitBalance("caller", types.NewCoins(0, 10*feeLimit))
+	ledger.state.Delivered().SetAccount(caller.Account.PubKey.Address(), &caller.Account)
+	ledger.state.Commit()
+	height := ledger.state.Height()
+	root := ledger.state.Delivered().Hash()
+
+	// Each of the transactions consumes almost all the gas of a block
+	scTxBytes1 := newRawSmartContractTx(chainID, 1, gasLimit, gasPrice, caller)
+	scTxBytes2 := newRawSmartContractTx(chainID, 2, gasLimit, gasPrice, caller)
+
+	// The validators should reject a block exceeding the block gas limit
+	res := ledger.ResetState(height, root)
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs([]common.Bytes{scTxBytes1, scTxBytes2}, common.Hash{})
+	assert.Equal(result.CodeBlockGasLimitExceeded, res.Code, res.Message)
+	assert.Equal(root, ledger.state.Delivered().Hash())
+
+	// The proposer should skip the transaction that could overflow the block gas limit
+	require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(scTxBytes1)))
+	require.Nil(mempool.InsertTransaction(mp.CreateMempoolTransaction(scTxBytes2)))
+
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	require.Equal(2, len(blockTxs)) // the coinbase tx and the first smart contract tx
+	assert.Equal(scTxBytes1, blockTxs[1])
+
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	assert.True(res.IsOK(), res.Message)
+	assert.Equal(uint64(0), ledger.state.Delivered().GasUsed())
+}
+
+func TestLedgerEstimateGas(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, _ := prepareInitLedgerState(ledger, 0)
+	root := ledger.state.Delivered().Hash()
+
+	// The init code stores 1 at slot 0: PUSH1 0x01 PUSH1 0x00 SSTORE
+	scTx := &types.SmartContractTx{
+		From: types.TxInput{
+			Address:  accOut.PubKey.Address(),
+			Sequence: 1,
+		},
+		GasLimit: 0,
+		GasPrice: new(big.Int).SetUint64(types.MinimumGasPrice),
+		Data:     common.Bytes{0x60, 0x01, 0x60, 0x00, 0x55},
+	}
+	scTxBytes, err := types.TxToBytes(scTx)
+	require.Nil(err)
+
+	gas, res := ledger.EstimateGas(scTxBytes)
+	require.True(res.IsOK(), res.Message)
+	assert.True(ledger.simulateSmartContractTx(scTx, gas).IsOK())
+	assert.True(ledger.simulateSmartContractTx(scTx, gas-1).IsError())
+	assert.Equal(root, ledger.state.Delivered().Hash())
+
+	// The transaction fails even with the block gas limit
+	_, res = ledger.EstimateGas(newRawSmartContractTx(chainID, 1, 0, types.MinimumGasPrice, accOut))
+	assert.Equal(result.CodeEVMError, res.Code)
+
+	// Only smart contract transactions are supported
+	_, res = ledger.EstimateGas(newRawCoinbaseTx(chainID, ledger, 1))
+	assert.True(res.IsError())
+}
+
 // ----------- Utilities ----------- //
 
+func TestLedgerGetPendingSlashIntents(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ledger, _ := newTestLedger()
+	prepareInitLedgerState(ledger, 1)
+
+	slashIntents, err := ledger.GetPendingSlashIntents()
+	assert.Nil(err)
+	assert.Equal(0, len(slashIntents))
+
+	slashIntent := types.SlashIntent{
+		Address:         common.HexToAddress("0x2E833968E5bB786Ae419c4d13189fB081Cc43bab"),
+		ReserveSequence: 3,
+		Proof:           common.Bytes("proof"),
+	}
+	ledger.state.Delivered().AddSlashIntent(slashIntent)
+
+	slashIntents, err = ledger.GetPendingSlashIntents()
+	assert.Nil(err)
+	assert.Equal([]types.SlashIntent{slashIntent}, slashIntents)
+
+	// The returned slash intents are copies
+	slashIntents[0].ReserveSequence = 4
+	slashIntents[0].Proof[0] = 'x'
+	delivered := ledger.state.Delivered().GetSlashIntents()
+	assert.Equal(uint64(3), delivered[0].ReserveSequence)
+	assert.Equal(common.Bytes("proof"), delivered[0].Proof)
+
+	ledger.state.Delivered().ClearSlashIntents()
+	slashIntents, err = ledger.GetPendingSlashIntents()
+	assert.Nil(err)
+	assert.Equal(0, len(slashIntents))
+}
+
 func newTestLedger() (chainID string, ledger *Ledger, mempool *mp.Mempool) {
 	chainID = "test_chain_id"
 	peerID := "peer0"
 	proposerSeed := "proposer"
 
-	db := backend.NewMemDatabase()
 	consensus := exec.NewTestConsensusEngine(proposerSeed)
 	valMgr := newTesetValidatorManager(consensus)
+	ledger = newTestLedgerWithConsensus(chainID, peerID, consensus, valMgr)
+
+	return chainID, ledger, ledger.mempool
+}
+
+func newTestLedgerWithConsensus(chainID string, peerID string, consensus core.ConsensusEngine, valMgr core.ValidatorManager) *Ledger {
+	return newTestLedgerWithSigner(chainID, peerID, consensus, valMgr, nil)
+}
+
+func newTestLedgerWithSigner(chainID string, peerID string, consensus core.ConsensusEngine, valMgr core.ValidatorManager, signer crypto.Signer) *Ledger {
+	db := backend.NewMemDatabase()
 	p2psimnet := p2psim.NewSimnetWithHandler(nil)
 	messenger := p2psimnet.AddEndpoint(peerID)
-	mempool = newTestMempool(peerID, messenger)
-	ledger = NewLedger(chainID, db, consensus, valMgr, mempool)
+	mempool := newTestMempool(peerID, messenger)
+	ledger := NewLedger(chainID, db, consensus, valMgr, mempool, signer)
 	mempool.SetLedger(ledger)
 
 	messenger.Start()
@@ -171,7 +1074,7 @@ func newTestLedger() (chainID string, ledger *Ledger, mempool *mp.Mempool) {
 	initRootHash := common.Hash{}
 	ledger.ResetState(initHeight, initRootHash)
 
-	return chainID, ledger, mempool
+	return ledger
 }
 
 func newTesetValidatorManager(consensus core.ConsensusEngine) core.ValidatorManager {
@@ -202,6 +1105,19 @@ func newTestMempool(peerID string, messenger p2p.Network) *mp.Mempool {
 
 func prepareInitLedgerState(ledger *Ledger, numInAccs int) (accOut types.PrivAccount, accIns []types.PrivAccount) {
 	txFee := getMinimumTxFee()
+	accOut = types.MakeAccWithInitBalance("accOut", types.NewCoins(700000, 3))
+	for i := 0; i < numInAccs; i++ {
+		secret := "in_secret_" + strconv.FormatInt(int64(i), 16)
+		accIn := types.MakeAccWithInitBalance(secret, types.NewCoins(900000, 50000*txFee))
+		accIns = append(accIns, accIn)
+	}
+
+	setInitLedgerState(ledger, accOut, accIns)
+
+	return accOut, accIns
+}
+
+func setInitLedgerState(ledger *Ledger, accOut types.PrivAccount, accIns []types.PrivAccount) {
 	validators := ledger.valMgr.GetValidatorSetForEpoch(0).Validators()
 	for _, val := range validators {
 		valPubKey := val.PublicKey()
@@ -213,19 +1129,12 @@ func prepareInitLedgerState(ledger *Ledger, numInAccs int) (accOut types.PrivAcc
 		ledger.state.Delivered().SetAccount(valPubKey.Address(), valAccount)
 	}
 
-	accOut = types.MakeAccWithInitBalance("accOut", types.NewCoins(700000, 3))
 	ledger.state.Delivered().SetAccount(accOut.Account.PubKey.Address(), &accOut.Account)
-
-	for i := 0; i < numInAccs; i++ {
-		secret := "in_secret_" + strconv.FormatInt(int64(i), 16)
-		accIn := types.MakeAccWithInitBalance(secret, types.NewCoins(900000, 50000*txFee))
-		accIns = append(accIns, accIn)
+	for _, accIn := range accIns {
 		ledger.state.Delivered().SetAccount(accIn.Account.PubKey.Address(), &accIn.Account)
 	}
 
 	ledger.state.Commit()
-
-	return accOut, accIns
 }
 
 func newRawCoinbaseTx(chainID string, ledger *Ledger, sequence int) common.Bytes {
@@ -243,9 +1152,10 @@ func newRawCoinbaseTx(chainID string, ledger *Ledger, sequence int) common.Bytes
 	proposerSk := ledger.consensus.PrivateKey()
 	proposerPk := proposerSk.PublicKey()
 	coinbaseTx := &types.CoinbaseTx{
-		Proposer:    types.TxInput{Address: proposerPk.Address(), PubKey: proposerPk, Sequence: uint64(sequence)},
-		Outputs:     outputs,
-		BlockHeight: 2,
+		Proposer:      types.TxInput{Address: proposerPk.Address(), PubKey: proposerPk, Sequence: uint64(sequence)},
+		Outputs:       outputs,
+		BlockHeight:   2,
+		ProposerProof: ledger.valMgr.GetProposerProofForEpoch(ledger.consensus.GetEpoch()),
 	}
 
 	signBytes := coinbaseTx.SignBytes(chainID)
@@ -306,6 +1216,45 @@ func newRawSendTx(chainID string, sequence int, addPubKey bool, accOut, accIn ty
 	return sendTxBytes
 }
 
+func newRawSmartContractTx(chainID string, sequence int, gasLimit uint64, gasPrice uint64, caller types.PrivAccount) common.Bytes {
+	// Deploying the INVALID opcode consumes all the gas available
+	scTx := &types.SmartContractTx{
+		From: types.TxInput{
+			Address:  caller.PubKey.Address(),
+			Sequence: uint64(sequence),
+		},
+		GasLimit: gasLimit,
+		GasPrice: new(big.Int).SetUint64(gasPrice),
+		Data:     common.Bytes{0xfe},
+	}
+	if sequence == 1 {
+		scTx.From.PubKey = caller.PubKey
+	}
+	signBytes := scTx.SignBytes(chainID)
+	scTx.From.Signature = caller.Sign(signBytes)
+
+	scTxBytes, err := types.TxToBytes(scTx)
+	if err != nil {
+		panic(err)
+	}
+	return scTxBytes
+}
+
 func getMinimumTxFee() int64 {
 	return int64(types.MinimumTransactionFeeGammaWei)
 }
+
+// mockSigner signs with the given private key, and records the signed messages
+type mockSigner struct {
+	privKey *crypto.PrivateKey
+	signed  []common.Bytes
+}
+
+func (ms *mockSigner) Sign(msg common.Bytes) (*crypto.Signature, error) {
+	ms.signed = append(ms.signed, msg)
+	return ms.privKey.Sign(msg)
+}
+
+func (ms *mockSigner) PublicKey() *crypto.PublicKey {
+	return ms.privKey.PublicKey()
+}
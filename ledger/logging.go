@@ -0,0 +1,15 @@
+package ledger
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/thetatoken/ukulele/common"
+)
+
+// txHashLogField is the log field holding the hash of the transaction a log line is about. It
+// correlates the log lines of the lifecycle of a transaction, from the screening to the commit.
+const txHashLogField = "txHash"
+
+// txLogger returns the log entry for the log lines about the transaction with the given hash
+func txLogger(txHash common.Hash) *log.Entry {
+	return log.WithField(txHashLogField, txHash.Hex())
+}
@@ -0,0 +1,56 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+func TestLedgerTxLifecycleLogs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	logger := log.StandardLogger()
+	out, formatter, level := logger.Out, logger.Formatter, logger.Level
+	defer func() {
+		log.SetOutput(out)
+		log.SetFormatter(formatter)
+		log.SetLevel(level)
+	}()
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	log.SetLevel(log.DebugLevel)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+	res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash())
+	require.True(res.IsOK(), res.Message)
+
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	res = ledger.SubmitTx(sendTxBytes)
+	require.True(res.IsOK(), res.Message)
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+
+	// Each step of the lifecycle is logged with the hash of the transaction
+	txHash := crypto.Keccak256Hash(sendTxBytes).Hex()
+	txMessages := []string{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		require.Nil(json.Unmarshal(scanner.Bytes(), &entry), scanner.Text())
+		if entry[txHashLogField] == txHash {
+			txMessages = append(txMessages, entry["msg"].(string))
+		}
+	}
+	assert.Equal([]string{"Screened transaction", "Checked transaction", "Executed transaction", "Committed transaction"}, txMessages)
+}
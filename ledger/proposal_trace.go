@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+// TxSkipReason describes why a candidate transaction was left out of a proposed block
+type TxSkipReason string
+
+const (
+	TxSkipReasonParseError      TxSkipReason = "parse_error"        // the transaction cannot be decoded
+	TxSkipReasonBlockGas        TxSkipReason = "block_gas_exceeded" // the remaining block gas is insufficient
+	TxSkipReasonCheckFailed     TxSkipReason = "check_failed"       // the transaction fails the check
+	TxSkipReasonDoubleSpend     TxSkipReason = "double_spend"       // the transaction conflicts with an included one of the same sender
+	TxSkipReasonDeadlineReached TxSkipReason = "deadline_reached"   // the proposal deadline passed before the transaction was checked
+)
+
+// ProposalTraceEntry records whether a candidate transaction was included in the proposed block
+type ProposalTraceEntry struct {
+	TxHash   common.Hash
+	Included bool
+	Reason   TxSkipReason // empty if included
+	Message  string       // details of the reason, e.g. the check error
+}
+
+// ProposalTrace records the decisions made on the candidate transactions while assembling a
+// block proposal, in the order the candidates were considered
+type ProposalTrace struct {
+	Entries []ProposalTraceEntry
+}
+
+// Skipped returns the entries of the candidate transactions left out of the block
+func (trace *ProposalTrace) Skipped() []ProposalTraceEntry {
+	skipped := []ProposalTraceEntry{}
+	for _, entry := range trace.Entries {
+		if !entry.Included {
+			skipped = append(skipped, entry)
+		}
+	}
+	return skipped
+}
+
+func (trace *ProposalTrace) included(rawTx common.Bytes) {
+	if trace == nil {
+		return
+	}
+	trace.Entries = append(trace.Entries, ProposalTraceEntry{
+		TxHash:   crypto.Keccak256Hash(rawTx),
+		Included: true,
+	})
+}
+
+func (trace *ProposalTrace) skipped(rawTx common.Bytes, reason TxSkipReason, message string) {
+	if trace == nil {
+		return
+	}
+	trace.Entries = append(trace.Entries, ProposalTraceEntry{
+		TxHash:  crypto.Keccak256Hash(rawTx),
+		Reason:  reason,
+		Message: message,
+	})
+}
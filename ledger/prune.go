@@ -0,0 +1,123 @@
+package ledger
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/store"
+	"github.com/thetatoken/ukulele/store/treestore"
+)
+
+// prunedHeightKey is the DB key of the height up to which the states have been pruned
+var prunedHeightKey = common.Bytes("ls/ph")
+
+// FinalizeAndMaybePrune sets the ledger state with the finalized root, and if a state retention
+// is configured, prunes the states of the blocks more than the retention below the finalized
+// block. Both happen under the ledger state lock, so no query observes the finalized state with
+// the old states not yet pruned, and the states a query may still reach are never pruned. The
+// finalization callbacks are invoked afterwards, outside of the lock.
+func (ledger *Ledger) FinalizeAndMaybePrune(height uint64, rootHash common.Hash) result.Result {
+	ledger.mu.Lock()
+	res := ledger.finalizeState(height, rootHash)
+	if res.IsOK() {
+		res = ledger.pruneStates(height)
+	}
+	ledger.mu.Unlock()
+	if res.IsError() {
+		return res
+	}
+	ledger.notifyFinalization(height, rootHash)
+	return result.OK
+}
+
+// pruneStates prunes the states of the blocks committed below the retention window of the
+// finalized height, which cannot be reset to anymore. The state trie nodes are reference
+// counted, so the nodes shared with the retained states are kept. The pruned height is
+// persisted so that the state of each block is pruned only once. The caller must hold the
+// ledger state lock.
+func (ledger *Ledger) pruneStates(finalizedHeight uint64) result.Result {
+	if ledger.stateRetention == 0 || finalizedHeight <= ledger.stateRetention {
+		return result.OK
+	}
+	pruneBelow := finalizedHeight - ledger.stateRetention
+
+	var prunedHeight uint64
+	err := ledger.store.Get(prunedHeightKey, &prunedHeight)
+	if err != nil && err != store.ErrKeyNotFound {
+		return result.Error("Failed to read the pruned height: %v", err)
+	}
+
+	retainedRoots, err := ledger.retainedStateRoots(pruneBelow)
+	if err != nil {
+		return result.Error("Failed to read the retained state roots: %v", err)
+	}
+
+	for height := prunedHeight + 1; height < pruneBelow; height++ {
+		blockIndexEntry := &BlockIndexEntry{}
+		err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+		if err == store.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return result.Error("Failed to read the block index at height %v: %v", height, err)
+		}
+
+		if !retainedRoots[blockIndexEntry.StateRoot] {
+			if err := ledger.pruneStateTree(blockIndexEntry.StateRoot); err != nil {
+				return result.Error("Failed to prune the state at height %v: %v", height, err)
+			}
+		}
+		if err := ledger.store.Put(prunedHeightKey, height); err != nil {
+			return result.Error("Failed to write the pruned height: %v", err)
+		}
+		log.Debugf("Pruned the state at height %v, root: %v", height, blockIndexEntry.StateRoot.Hex())
+	}
+
+	return result.OK
+}
+
+// retainedStateRoots returns the state roots of the blocks indexed at or above the given height
+func (ledger *Ledger) retainedStateRoots(fromHeight uint64) (map[common.Hash]bool, error) {
+	roots := make(map[common.Hash]bool)
+	for height := fromHeight; height <= ledger.state.Height(); height++ {
+		blockIndexEntry := &BlockIndexEntry{}
+		err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+		if err == store.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		roots[blockIndexEntry.StateRoot] = true
+	}
+	return roots, nil
+}
+
+// pruneStateTree prunes the state tree with the given root. The root node is referenced once per
+// commit of the state, e.g. by both the proposal and the application of the block, whereas the
+// other nodes are referenced once per parent. Hence the extra references of the root are dropped
+// first, so that the tree is no longer reachable afterwards.
+func (ledger *Ledger) pruneStateTree(root common.Hash) error {
+	for {
+		ref, err := ledger.db.CountReference(root[:])
+		if err == store.ErrKeyNotFound {
+			return nil // already pruned
+		}
+		if err != nil {
+			return err
+		}
+		if ref <= 1 {
+			break
+		}
+		if err := ledger.db.Dereference(root[:]); err != nil {
+			return err
+		}
+	}
+
+	tree := treestore.NewTreeStore(root, ledger.db)
+	if tree == nil {
+		return nil
+	}
+	return tree.Prune()
+}
@@ -0,0 +1,61 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+)
+
+func TestLedgerFinalizeAndMaybePrune(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 4)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	ledger.stateRetention = 1
+
+	var notified []uint64
+	ledger.RegisterFinalizationCallback(func(height uint64, root common.Hash) {
+		notified = append(notified, height)
+	})
+
+	// Without a block old enough, nothing is pruned
+	res := ledger.FinalizeAndMaybePrune(blocks[2].Height, blocks[2].StateHash)
+	require.True(res.IsOK(), res.Message)
+	for _, block := range blocks[1:3] {
+		_, err := ledger.GetAccountAtHeight(accOut.PubKey.Address(), block.Height)
+		assert.Nil(err)
+	}
+
+	target := blocks[4]
+	res = ledger.FinalizeAndMaybePrune(target.Height, target.StateHash)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(target.Height, ledger.state.Finalized().Height())
+	assert.Equal(target.StateHash, ledger.state.Finalized().Hash())
+	assert.Equal([]uint64{blocks[2].Height, target.Height}, notified)
+
+	// The states older than the retention window are pruned
+	for _, block := range blocks[1:3] {
+		_, err := ledger.GetAccountAtHeight(accOut.PubKey.Address(), block.Height)
+		assert.Equal(ErrStatePruned, err, "height %v", block.Height)
+	}
+
+	// The recent states survive intact
+	for _, block := range blocks[3:] {
+		for _, accIn := range accIns {
+			account, err := ledger.GetAccountAtHeight(accIn.PubKey.Address(), block.Height)
+			require.Nil(err, "height %v", block.Height)
+			assert.NotNil(account)
+		}
+	}
+	assert.NotNil(ledger.state.Delivered().GetAccount(accOut.PubKey.Address()))
+
+	// Finalizing again does not prune the same states twice
+	res = ledger.FinalizeAndMaybePrune(target.Height, target.StateHash)
+	require.True(res.IsOK(), res.Message)
+	_, err := ledger.GetAccountAtHeight(accOut.PubKey.Address(), blocks[3].Height)
+	assert.Nil(err)
+}
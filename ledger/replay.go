@@ -0,0 +1,60 @@
+package ledger
+
+import (
+	"encoding/hex"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	exec "github.com/thetatoken/ukulele/ledger/execution"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+// ReplayBlock re-executes the transactions of the block committed at the given height on top of
+// its parent state, and returns the computed state root. The replay runs on a throwaway ledger
+// state, so the live ledger state is not modified. It returns an error if the block or its
+// parent state is not available, e.g. after the state has been pruned.
+func (ledger *Ledger) ReplayBlock(height uint64) (common.Hash, result.Result) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	blockIndexEntry := &BlockIndexEntry{}
+	err := ledger.store.Get(blockIndexKey(height), blockIndexEntry)
+	if err != nil {
+		return common.Hash{}, result.Error("Block at height %v is not available: %v", height, err).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+
+	return ledger.replayBlock(height, blockIndexEntry)
+}
+
+// replayBlock re-executes the transactions of the indexed block committed at the given height
+// on a throwaway ledger state. The caller must hold the ledger state lock.
+func (ledger *Ledger) replayBlock(height uint64, blockIndexEntry *BlockIndexEntry) (common.Hash, result.Result) {
+	state := st.NewLedgerState(ledger.state.GetChainID(), ledger.db)
+	res := state.ResetState(height-1, blockIndexEntry.ParentStateRoot)
+	if res.IsError() {
+		return common.Hash{}, result.Error("Parent state of block at height %v is not available, root: %v",
+			height, hex.EncodeToString(blockIndexEntry.ParentStateRoot[:])).
+			WithErrorCode(result.CodeStateNotAvailable)
+	}
+
+	// The transactions have been checked when the block was committed
+	executor := exec.NewExecutor(state, ledger.consensus, ledger.valMgr)
+	executor.SetSkipSanityCheck(true)
+
+	for _, rawTx := range blockIndexEntry.RawTxs {
+		tx, err := types.TxFromBytes(rawTx)
+		if err != nil {
+			return common.Hash{}, result.Error("Failed to parse transaction: %v", hex.EncodeToString(rawTx)).
+				WithErrorCode(result.CodeTxParseError)
+		}
+		_, res := executor.ExecuteTx(tx)
+		if res.IsError() {
+			return common.Hash{}, result.Error("Failed to replay block at height %v: %v", height, res.Message).
+				WithErrorCode(result.CodeBlockReplayFailed)
+		}
+	}
+
+	return state.Delivered().Hash(), result.OK
+}
@@ -0,0 +1,114 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
+	mp "github.com/thetatoken/ukulele/mempool"
+)
+
+func TestLedgerReplayBlock(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	numInAccs := 2
+	accOut, accIns := prepareInitLedgerState(ledger, numInAccs)
+
+	initBlock := core.NewBlock()
+	initBlock.Height = ledger.state.Height()
+	initBlock.StateHash = ledger.state.Delivered().Hash()
+
+	blocks := []*core.Block{initBlock}
+	for idx := 0; idx < numInAccs; idx++ {
+		// Each block is applied on a fresh view of its parent state, same as the consensus engine does
+		parent := blocks[len(blocks)-1]
+		res := ledger.ResetState(parent.Height, parent.StateHash)
+		require.True(res.IsOK(), res.Message)
+
+		sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[idx])
+		err := mempool.InsertTransaction(mp.CreateMempoolTransaction(sendTxBytes))
+		require.Nil(err)
+
+		stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+		require.True(res.IsOK(), res.Message)
+		res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+		require.True(res.IsOK(), res.Message)
+
+		block := core.NewBlock()
+		block.Height = ledger.state.Height()
+		block.StateHash = stateRoot
+		blocks = append(blocks, block)
+	}
+
+	liveHeight := ledger.state.Height()
+	liveStateRoot := ledger.state.Delivered().Hash()
+
+	// The stored state roots are reproducible
+	for _, block := range blocks[1:] {
+		stateRoot, res := ledger.ReplayBlock(block.Height)
+		require.True(res.IsOK(), res.Message)
+		assert.Equal(block.StateHash, stateRoot)
+	}
+
+	// The live state is not modified by the replays
+	assert.Equal(liveHeight, ledger.state.Height())
+	assert.Equal(liveStateRoot, ledger.state.Delivered().Hash())
+
+	// Error if the block is not available
+	_, res := ledger.ReplayBlock(liveHeight + 1)
+	assert.True(res.IsError())
+
+	// Error if the parent state is not available
+	err := ledger.store.Put(blockIndexKey(liveHeight+1), BlockIndexEntry{
+		ParentStateRoot: common.BytesToHash([]byte("pruned")),
+		StateRoot:       liveStateRoot,
+	})
+	require.Nil(err)
+	_, res = ledger.ReplayBlock(liveHeight + 1)
+	assert.True(res.IsError())
+}
+
+func TestLedgerAuditChain(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, mempool := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 3)
+	blocks := newTestBlocks(chainID, ledger, mempool, accOut, accIns)
+	from, to := blocks[1].Height, blocks[3].Height
+
+	mismatches, err := ledger.AuditChain(from, to)
+	require.Nil(err)
+	assert.Equal(0, len(mismatches))
+
+	// Inject a wrong stored root at one height
+	corrupted := blocks[2]
+	blockIndexEntry := &BlockIndexEntry{}
+	require.Nil(ledger.store.Get(blockIndexKey(corrupted.Height), blockIndexEntry))
+	wrongRoot := common.BytesToHash([]byte("wrong root"))
+	blockIndexEntry.StateRoot = wrongRoot
+	require.Nil(ledger.store.Put(blockIndexKey(corrupted.Height), blockIndexEntry))
+
+	liveRoot := ledger.state.Delivered().Hash()
+	liveHeight := ledger.state.Height()
+	mismatches, err = ledger.AuditChain(from, to)
+	require.Nil(err)
+	require.Equal(1, len(mismatches))
+	assert.Equal(corrupted.Height, mismatches[0].Height)
+	assert.Equal(wrongRoot, mismatches[0].StoredRoot)
+	assert.Equal(corrupted.StateHash, mismatches[0].ComputedRoot)
+
+	// The live state is not modified
+	assert.Equal(liveRoot, ledger.state.Delivered().Hash())
+	assert.Equal(liveHeight, ledger.state.Height())
+
+	// The range needs to be committed
+	_, err = ledger.AuditChain(from, to+1)
+	assert.NotNil(err)
+	_, err = ledger.AuditChain(to, from)
+	assert.NotNil(err)
+}
@@ -0,0 +1,196 @@
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/rlp"
+	"github.com/thetatoken/ukulele/store/treestore"
+)
+
+type snapshotRecordType uint8
+
+const (
+	// snapshotRecordState is a key/value pair of the state trie
+	snapshotRecordState snapshotRecordType = iota
+
+	// snapshotRecordStorage is a key/value pair of the storage trie of the account in the
+	// preceding state record
+	snapshotRecordStorage
+)
+
+// snapshotHeader is the first item of a state snapshot
+type snapshotHeader struct {
+	Height    uint64
+	StateRoot common.Hash
+}
+
+// snapshotRecord is a key/value pair of a state snapshot
+type snapshotRecord struct {
+	Type  snapshotRecordType
+	Key   common.Bytes
+	Value common.Bytes
+}
+
+// ExportStateSnapshot streams the finalized state at the given height to w. The snapshot
+// consists of a header followed by the key/value pairs of the state trie in key order. Each
+// account is followed by the key/value pairs of its storage trie, so the snapshot is
+// deterministic for a given state root.
+func (ledger *Ledger) ExportStateSnapshot(height uint64, w io.Writer) result.Result {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	view := ledger.state.Finalized()
+	if view.Height() != height {
+		return result.Error("Only the state at the latest finalized height %v can be exported, requested height: %v",
+			view.Height(), height)
+	}
+	db := view.GetStore().GetDB()
+
+	header := &snapshotHeader{
+		Height:    height,
+		StateRoot: view.Hash(),
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return result.Error("Failed to write the snapshot header: %v", err)
+	}
+
+	var err error
+	view.GetStore().Traverse(nil, func(key, value common.Bytes) bool {
+		if err != nil {
+			return false
+		}
+		if err = rlp.Encode(w, &snapshotRecord{snapshotRecordState, key, value}); err != nil {
+			return false
+		}
+
+		account, ok := decodeSnapshotAccount(key, value)
+		if !ok || (account.Root == common.Hash{}) {
+			return true
+		}
+		storage := treestore.NewTreeStore(account.Root, db)
+		if storage == nil {
+			err = fmt.Errorf("failed to load the storage trie %v", account.Root.Hex())
+			return false
+		}
+		storage.Traverse(nil, func(k, v common.Bytes) bool {
+			if err != nil {
+				return false
+			}
+			err = rlp.Encode(w, &snapshotRecord{snapshotRecordStorage, k, v})
+			return err == nil
+		})
+		return err == nil
+	})
+	if err != nil {
+		return result.Error("Failed to write the snapshot: %v", err)
+	}
+
+	return result.OK
+}
+
+// ImportStateSnapshot reconstructs the state from the snapshot read from r, and verifies the
+// resulting state root matches the expected root. On success, the ledger state is reset to,
+// and finalized at, the imported state.
+func (ledger *Ledger) ImportStateSnapshot(r io.Reader, expectedRoot common.Hash) result.Result {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	stream := rlp.NewStream(r, 0)
+	header := &snapshotHeader{}
+	if err := stream.Decode(header); err != nil {
+		return result.Error("Failed to read the snapshot header: %v", err)
+	}
+	if header.StateRoot != expectedRoot {
+		return result.Error("Snapshot state root %v does not match the expected root %v",
+			header.StateRoot.Hex(), expectedRoot.Hex())
+	}
+
+	db := ledger.state.Finalized().GetStore().GetDB()
+	view := st.NewStoreView(header.Height, common.Hash{}, db)
+
+	var account *types.Account // the account whose storage records are being read
+	var storage *treestore.TreeStore
+	for {
+		record := &snapshotRecord{}
+		err := stream.Decode(record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result.Error("Failed to read the snapshot: %v", err)
+		}
+
+		switch record.Type {
+		case snapshotRecordState:
+			if res := commitSnapshotStorage(account, storage); res.IsError() {
+				return res
+			}
+			account, storage = nil, nil
+
+			view.Set(record.Key, record.Value)
+			if acc, ok := decodeSnapshotAccount(record.Key, record.Value); ok && (acc.Root != common.Hash{}) {
+				account = acc
+				storage = treestore.NewTreeStore(common.Hash{}, db)
+			}
+		case snapshotRecordStorage:
+			if storage == nil {
+				return result.Error("Storage record %v does not follow an account with storage", record.Key)
+			}
+			storage.Set(record.Key, record.Value)
+		default:
+			return result.Error("Unknown snapshot record type: %v", record.Type)
+		}
+	}
+	if res := commitSnapshotStorage(account, storage); res.IsError() {
+		return res
+	}
+
+	root := view.Save()
+	if root != expectedRoot {
+		return result.Error("Imported state root %v does not match the expected root %v", root.Hex(), expectedRoot.Hex())
+	}
+
+	if res := ledger.resetState(header.Height, root); res.IsError() {
+		return res
+	}
+	if res := ledger.state.Finalize(header.Height, root); res.IsError() {
+		return res
+	}
+	ledger.updateStatus(false)
+	return result.OK
+}
+
+// decodeSnapshotAccount decodes the account if the key/value pair is an account
+func decodeSnapshotAccount(key, value common.Bytes) (*types.Account, bool) {
+	if !bytes.HasPrefix(key, st.AccountKeyPrefix()) {
+		return nil, false
+	}
+	account := &types.Account{}
+	if err := types.FromBytes(value, account); err != nil {
+		return nil, false
+	}
+	return account, true
+}
+
+// commitSnapshotStorage persists the imported storage trie of the account, and verifies its
+// root matches the storage root of the account
+func commitSnapshotStorage(account *types.Account, storage *treestore.TreeStore) result.Result {
+	if account == nil {
+		return result.OK
+	}
+	root, err := storage.Commit()
+	if err != nil {
+		return result.Error("Failed to commit the storage trie: %v", err)
+	}
+	if root != account.Root {
+		return result.Error("Imported storage root %v does not match the account storage root %v",
+			root.Hex(), account.Root.Hex())
+	}
+	return result.OK
+}
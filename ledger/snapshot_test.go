@@ -0,0 +1,70 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+)
+
+func TestLedgerStateSnapshotRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 3)
+
+	// A contract with code and storage
+	contractAddr := common.BytesToAddress([]byte("contract"))
+	slot1, value1 := common.BytesToHash([]byte("slot1")), common.BytesToHash([]byte("value1"))
+	slot2, value2 := common.BytesToHash([]byte("slot2")), common.BytesToHash([]byte("value2"))
+	view := ledger.state.Delivered()
+	view.SetCode(contractAddr, common.Bytes{0x60, 0x01, 0x60, 0x00, 0x55})
+	view.SetState(contractAddr, slot1, value1)
+	view.SetState(contractAddr, slot2, value2)
+	height := ledger.state.Height()
+	root := ledger.state.Commit()
+	res := ledger.FinalizeState(height, root)
+	require.True(res.IsOK(), res.Message)
+
+	var snapshot bytes.Buffer
+	res = ledger.ExportStateSnapshot(height, &snapshot)
+	require.True(res.IsOK(), res.Message)
+
+	// The snapshot is deterministic
+	var snapshot2 bytes.Buffer
+	res = ledger.ExportStateSnapshot(height, &snapshot2)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(snapshot.Bytes(), snapshot2.Bytes())
+
+	// Only the finalized height can be exported
+	res = ledger.ExportStateSnapshot(height+1, &bytes.Buffer{})
+	assert.True(res.IsError())
+
+	// Import into a ledger with a fresh db
+	_, ledger2, _ := newTestLedger()
+	res = ledger2.ImportStateSnapshot(bytes.NewReader(snapshot.Bytes()), root)
+	require.True(res.IsOK(), res.Message)
+
+	finalized := ledger2.state.Finalized()
+	assert.Equal(root, finalized.Hash())
+	assert.Equal(height, finalized.Height())
+	assert.Equal(root, ledger2.state.Delivered().Hash())
+	assert.Equal(ledger.state.Finalized().GetAccount(accOut.PubKey.Address()),
+		finalized.GetAccount(accOut.PubKey.Address()))
+	for _, accIn := range accIns {
+		assert.Equal(ledger.state.Finalized().GetAccount(accIn.PubKey.Address()),
+			finalized.GetAccount(accIn.PubKey.Address()))
+	}
+	assert.Equal(common.Bytes{0x60, 0x01, 0x60, 0x00, 0x55}, common.Bytes(finalized.GetCode(contractAddr)))
+	assert.Equal(value1, finalized.GetState(contractAddr, slot1))
+	assert.Equal(value2, finalized.GetState(contractAddr, slot2))
+
+	// The snapshot is rejected if the root does not match
+	_, ledger3, _ := newTestLedger()
+	res = ledger3.ImportStateSnapshot(bytes.NewReader(snapshot.Bytes()), common.BytesToHash([]byte("wrong")))
+	assert.True(res.IsError())
+	assert.NotEqual(root, ledger3.state.Finalized().Hash())
+}
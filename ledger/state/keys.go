@@ -11,9 +11,24 @@ func ChainIDKey() common.Bytes {
 	return common.Bytes("chainid")
 }
 
+// AccountKeyPrefix returns the prefix for the account key
+func AccountKeyPrefix() common.Bytes {
+	return common.Bytes("ls/a/")
+}
+
 // AccountKey construct the state key for the given address
 func AccountKey(addr common.Address) common.Bytes {
-	return append(common.Bytes("ls/a/"), addr[:]...)
+	return append(AccountKeyPrefix(), addr[:]...)
+}
+
+// ImmatureRewardsKey construct the state key for the immature coinbase rewards of the given address
+func ImmatureRewardsKey(addr common.Address) common.Bytes {
+	return append(common.Bytes("ls/ir/"), addr[:]...)
+}
+
+// MultisigPolicyKey construct the state key for the multisig policy of the given address
+func MultisigPolicyKey(addr common.Address) common.Bytes {
+	return append(common.Bytes("ls/msp/"), addr[:]...)
 }
 
 // SplitRuleKeyPrefix returns the prefix for the split rule key
@@ -31,3 +46,34 @@ func SplitRuleKey(resourceID string) common.Bytes {
 func CodeKey(codeHash common.Bytes) common.Bytes {
 	return append(common.Bytes("ls/ch/"), codeHash...)
 }
+
+// ValidatorStakesKey returns the key for the stakes of the validators
+func ValidatorStakesKey() common.Bytes {
+	return common.Bytes("ls/vs")
+}
+
+// ValidatorStakesEpochKey returns the key for the epoch from which the validator stakes are effective
+func ValidatorStakesEpochKey() common.Bytes {
+	return common.Bytes("ls/vse")
+}
+
+// PendingValidatorChangesKey returns the key for the validator stake changes not applied yet
+func PendingValidatorChangesKey() common.Bytes {
+	return common.Bytes("ls/pvc")
+}
+
+// PendingValidatorChangesEpochKey returns the key for the epoch the pending validator stake changes
+// were recorded in
+func PendingValidatorChangesEpochKey() common.Bytes {
+	return common.Bytes("ls/pvce")
+}
+
+// ChainParamsKey returns the key for the chain parameters
+func ChainParamsKey() common.Bytes {
+	return common.Bytes("ls/cp")
+}
+
+// GovernanceAddressKey returns the key for the address of the governance account
+func GovernanceAddressKey() common.Bytes {
+	return common.Bytes("ls/ga")
+}
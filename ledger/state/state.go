@@ -20,6 +20,10 @@ type LedgerState struct {
 	delivered *StoreView // for actually applying the transactions
 	checked   *StoreView // for block proposal check
 	screened  *StoreView // for mempool screening
+
+	batching    bool                                // whether the commits are deferred to CommitBatch
+	batchRoots  []common.Hash                       // roots committed in memory since BeginBatch
+	batchWrites []func(batch database.Putter) error // writes of the commits since BeginBatch
 }
 
 // NewLedgerState creates a new Leger State with given store.
@@ -37,7 +41,7 @@ func NewLedgerState(chainID string, db database.Database) *LedgerState {
 
 // ResetState resets the height and state root of its storeviews, and clear the in-memory states
 func (s *LedgerState) ResetState(height uint64, stateRootHash common.Hash) result.Result {
-	storeview := NewStoreView(height, stateRootHash, s.db)
+	storeview := s.newStoreView(height, stateRootHash)
 	if storeview == nil {
 		return result.Error(fmt.Sprintf("Failed to set ledger state with state root hash: %v", stateRootHash))
 	}
@@ -58,7 +62,7 @@ func (s *LedgerState) ResetState(height uint64, stateRootHash common.Hash) resul
 
 // Finalize updates the finalized view.
 func (s *LedgerState) Finalize(height uint64, stateRootHash common.Hash) result.Result {
-	storeview := NewStoreView(height, stateRootHash, s.db)
+	storeview := s.newStoreView(height, stateRootHash)
 	if storeview == nil {
 		return result.Error(fmt.Sprintf("Failed to finalize ledger state with state root hash: %v", stateRootHash))
 	}
@@ -66,6 +70,20 @@ func (s *LedgerState) Finalize(height uint64, stateRootHash common.Hash) result.
 	return result.OK
 }
 
+// newStoreView creates a StoreView with the given root. If a batch is in progress, the StoreView
+// shares the in-memory trie database of the delivered view, so that the roots committed within
+// the batch are available before they are written to the persistent storage.
+func (s *LedgerState) newStoreView(height uint64, stateRootHash common.Hash) *StoreView {
+	if !s.batching {
+		return NewStoreView(height, stateRootHash, s.db)
+	}
+	storeview, err := s.delivered.CopyAt(height, stateRootHash)
+	if err != nil {
+		return nil
+	}
+	return storeview
+}
+
 // GetChainID gets chain ID.
 func (s *LedgerState) GetChainID() string {
 	if s.chainID != "" {
@@ -102,10 +120,29 @@ func (s *LedgerState) Finalized() *StoreView {
 }
 
 // Commit stores the current delivered view as committed, starts new delivered/checked state and
-// returns the hash for the commit.
+// returns the hash for the commit. If a batch is in progress, the delivered view is only saved in
+// memory, and written to the persistent storage by CommitBatch.
 func (s *LedgerState) Commit() common.Hash {
-	hash := s.delivered.Save()
+	return s.CommitWith(nil)
+}
+
+// CommitWith is the same as Commit, except that the writes of the given function, if not nil, are
+// persisted atomically with the delivered view, e.g. to index the committed block. If a batch is
+// in progress, the writes are deferred to CommitBatch as well.
+func (s *LedgerState) CommitWith(writes func(batch database.Putter) error) common.Hash {
+	var hash common.Hash
+	if s.batching {
+		hash = s.delivered.SaveInMemory()
+		s.batchRoots = append(s.batchRoots, hash)
+		if writes != nil {
+			s.batchWrites = append(s.batchWrites, writes)
+		}
+	} else {
+		hash = s.delivered.SaveWith(writes)
+	}
 	s.delivered.IncrementHeight()
+	s.delivered.ResetGasUsed()
+	s.delivered.SetCoinbaseTransactionProcessed(false) // the next block has its own coinbase transaction
 
 	var err error
 	s.checked, err = s.delivered.Copy()
@@ -118,3 +155,43 @@ func (s *LedgerState) Commit() common.Hash {
 	}
 	return hash
 }
+
+// BeginBatch starts deferring the commits to the persistent storage until CommitBatch is called
+func (s *LedgerState) BeginBatch() {
+	s.batching = true
+	s.batchRoots = []common.Hash{}
+	s.batchWrites = nil
+}
+
+// InBatch returns whether a batch is in progress
+func (s *LedgerState) InBatch() bool {
+	return s.batching
+}
+
+// CommitBatch writes the states committed since BeginBatch to the persistent storage in a single
+// batch, together with the writes passed to CommitWith, and ends the batch.
+func (s *LedgerState) CommitBatch() error {
+	roots, batchWrites := s.batchRoots, s.batchWrites
+	s.batching = false
+	s.batchRoots = nil
+	s.batchWrites = nil
+	if len(roots) == 0 {
+		return nil
+	}
+	return s.delivered.FlushWith(roots, func(batch database.Putter) error {
+		for _, writes := range batchWrites {
+			if err := writes(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DiscardBatch ends the batch without writing the states committed since BeginBatch to the
+// persistent storage. The caller is expected to reset the state to the root before the batch.
+func (s *LedgerState) DiscardBatch() {
+	s.batching = false
+	s.batchRoots = nil
+	s.batchWrites = nil
+}
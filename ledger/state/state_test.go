@@ -1,6 +1,7 @@
 package state
 
 import (
+	"errors"
 	"math/big"
 	"testing"
 
@@ -10,7 +11,9 @@ import (
 	"github.com/thetatoken/ukulele/core"
 	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/store/database"
 	"github.com/thetatoken/ukulele/store/database/backend"
+	"github.com/thetatoken/ukulele/store/treestore"
 )
 
 func TestLedgerStateBasics(t *testing.T) {
@@ -227,3 +230,57 @@ func TestLedgerStateSplitRuleCommit(t *testing.T) {
 	log.Infof("After commit #2, rootHashChecked    : %v\n", rootHashChecked4.Hex())
 	log.Infof("After commit #2, rootHashDelivered  : %v\n", rootHashDelivered4.Hex())
 }
+
+func TestLedgerStateCommitWith(t *testing.T) {
+	assert := assert.New(t)
+
+	db := backend.NewMemDatabase()
+	ls := NewLedgerState("testchain", db)
+	ls.ResetState(uint64(1), common.Hash{})
+
+	setAccount := func(seed string) common.Hash {
+		_, pubKey, _ := crypto.TEST_GenerateKeyPairWithSeed(seed)
+		ls.Delivered().SetAccount(pubKey.Address(), &types.Account{PubKey: pubKey})
+		return ls.Delivered().Hash()
+	}
+	writeKey := func(key string) func(batch database.Putter) error {
+		return func(batch database.Putter) error {
+			return batch.Put([]byte(key), []byte("value"))
+		}
+	}
+	hasKey := func(key string) bool {
+		has, _ := db.Has([]byte(key))
+		return has
+	}
+
+	// The writes are persisted with the state
+	setAccount("account0")
+	root1 := ls.CommitWith(writeKey("key1"))
+	assert.True(hasKey("key1"))
+	assert.NotNil(treestore.NewTreeStore(root1, db))
+
+	// Neither is persisted if the writes fail
+	root := setAccount("account1")
+	assert.Panics(func() {
+		ls.CommitWith(func(batch database.Putter) error {
+			return errors.New("write failed")
+		})
+	})
+	assert.Nil(treestore.NewTreeStore(root, db))
+	ls.ResetState(uint64(2), root1)
+
+	// Within a batch, the writes are deferred to CommitBatch
+	ls.BeginBatch()
+	setAccount("account2")
+	ls.CommitWith(writeKey("key2"))
+	assert.False(hasKey("key2"))
+	assert.Nil(ls.CommitBatch())
+	assert.True(hasKey("key2"))
+
+	// and dropped with the batch
+	ls.BeginBatch()
+	setAccount("account3")
+	ls.CommitWith(writeKey("key3"))
+	ls.DiscardBatch()
+	assert.False(hasKey("key3"))
+}
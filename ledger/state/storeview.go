@@ -26,7 +26,10 @@ type StoreView struct {
 	coinbaseTransactinProcessed bool
 	slashIntents                []types.SlashIntent
 	validatorsDiff              []*core.Validator
-	refund                      uint64 // Gas refund during smart contract execution
+	refund                      uint64               // Gas refund during smart contract execution
+	gasUsed                     uint64               // Gas consumed by the smart contract transactions of the current block
+	verifiedSignatures          map[common.Hash]bool // Signatures verified ahead of the execution of the current block
+	epoch                       *uint64              // Epoch of the current block, nil if the view is not bound to a block
 }
 
 // NewStoreView creates an instance of the StoreView
@@ -61,6 +64,23 @@ func (sv *StoreView) Copy() (*StoreView, error) {
 	return copiedStoreView, nil
 }
 
+// CopyAt returns a fresh StoreView with the given height and root, which shares the in-memory
+// trie database with the StoreView. The root can be one of the roots saved by SaveInMemory.
+func (sv *StoreView) CopyAt(height uint64, root common.Hash) (*StoreView, error) {
+	revertedStore, err := sv.store.Revert(root)
+	if err != nil {
+		return nil, err
+	}
+	copiedStoreView := &StoreView{
+		height:         height,
+		store:          revertedStore,
+		slashIntents:   []types.SlashIntent{},
+		validatorsDiff: []*core.Validator{},
+		refund:         0,
+	}
+	return copiedStoreView, nil
+}
+
 // Hash returns the root hash of the tree store
 func (sv *StoreView) Hash() common.Hash {
 	return sv.store.Hash()
@@ -76,6 +96,21 @@ func (sv *StoreView) IncrementHeight() {
 	sv.height++
 }
 
+// GasUsed returns the gas consumed by the smart contract transactions of the current block
+func (sv *StoreView) GasUsed() uint64 {
+	return sv.gasUsed
+}
+
+// AddGasUsed adds the gas consumed by a smart contract transaction to the current block
+func (sv *StoreView) AddGasUsed(gas uint64) {
+	sv.gasUsed += gas
+}
+
+// ResetGasUsed resets the gas consumed for the current block
+func (sv *StoreView) ResetGasUsed() {
+	sv.gasUsed = 0
+}
+
 // Save saves the StoreView to the persistent storage, and return the root hash
 func (sv *StoreView) Save() common.Hash {
 	rootHash, err := sv.store.Commit()
@@ -86,6 +121,41 @@ func (sv *StoreView) Save() common.Hash {
 	return rootHash
 }
 
+// SaveWith is the same as Save, except that the writes of the given function are persisted
+// atomically with the StoreView
+func (sv *StoreView) SaveWith(writes func(batch database.Putter) error) common.Hash {
+	rootHash, err := sv.store.Trie.Commit(nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to save the StoreView: %v", err))
+	}
+	if err := sv.store.Trie.GetDB().CommitRootsWith([]common.Hash{rootHash}, true, writes); err != nil {
+		panic(fmt.Sprintf("Failed to save the StoreView: %v", err))
+	}
+	return rootHash
+}
+
+// SaveInMemory saves the StoreView to the in-memory trie database without writing it to the
+// persistent storage, and return the root hash
+func (sv *StoreView) SaveInMemory() common.Hash {
+	rootHash, err := sv.store.Trie.Commit(nil)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to save the StoreView: %v", err))
+	}
+	return rootHash
+}
+
+// Flush writes the states of the given roots saved by SaveInMemory to the persistent storage
+// in a single batch
+func (sv *StoreView) Flush(roots []common.Hash) error {
+	return sv.FlushWith(roots, nil)
+}
+
+// FlushWith is the same as Flush, except that the writes of the given function are persisted
+// atomically with the states
+func (sv *StoreView) FlushWith(roots []common.Hash, writes func(batch database.Putter) error) error {
+	return sv.store.Trie.GetDB().CommitRootsWith(roots, true, writes)
+}
+
 // Get returns the value corresponding the key
 func (sv *StoreView) Get(key common.Bytes) common.Bytes {
 	value := sv.store.Get(key)
@@ -102,6 +172,35 @@ func (sv *StoreView) Set(key common.Bytes, value common.Bytes) {
 	sv.store.Set(key, value)
 }
 
+// AddVerifiedSignature records a signature verified ahead of the execution of the current block,
+// e.g. in parallel, so that the executor does not verify it again
+func (sv *StoreView) AddVerifiedSignature(pubKey *crypto.PublicKey, signBytes common.Bytes, sig *crypto.Signature) {
+	if sv.verifiedSignatures == nil {
+		sv.verifiedSignatures = make(map[common.Hash]bool)
+	}
+	sv.verifiedSignatures[verifiedSignatureKey(pubKey, signBytes, sig)] = true
+}
+
+// IsSignatureVerified returns whether the signature has been verified against the public key by
+// AddVerifiedSignature
+func (sv *StoreView) IsSignatureVerified(pubKey *crypto.PublicKey, signBytes common.Bytes, sig *crypto.Signature) bool {
+	if len(sv.verifiedSignatures) == 0 || pubKey == nil || sig == nil {
+		return false
+	}
+	return sv.verifiedSignatures[verifiedSignatureKey(pubKey, signBytes, sig)]
+}
+
+// ClearVerifiedSignatures clears the signatures recorded by AddVerifiedSignature
+func (sv *StoreView) ClearVerifiedSignatures() {
+	sv.verifiedSignatures = nil
+}
+
+// verifiedSignatureKey hashes each part separately, so that the key is unambiguous regardless of
+// the lengths of the parts
+func verifiedSignatureKey(pubKey *crypto.PublicKey, signBytes common.Bytes, sig *crypto.Signature) common.Hash {
+	return crypto.Keccak256Hash(crypto.Keccak256(pubKey.ToBytes()), crypto.Keccak256(signBytes), crypto.Keccak256(sig.ToBytes()))
+}
+
 // AddSlashIntent adds slashIntent
 func (sv *StoreView) AddSlashIntent(slashIntent types.SlashIntent) {
 	sv.slashIntents = append(sv.slashIntents, slashIntent)
@@ -127,6 +226,25 @@ func (sv *StoreView) SetCoinbaseTransactionProcessed(processed bool) {
 	sv.coinbaseTransactinProcessed = processed
 }
 
+// SetEpoch binds the view to the epoch of the block being executed, so that the transactions of a
+// block from a past epoch, e.g. during catch-up or replay, are checked against that epoch
+func (sv *StoreView) SetEpoch(epoch uint64) {
+	sv.epoch = &epoch
+}
+
+// Epoch returns the epoch set by SetEpoch, and false if the view is not bound to a block
+func (sv *StoreView) Epoch() (uint64, bool) {
+	if sv.epoch == nil {
+		return 0, false
+	}
+	return *sv.epoch, true
+}
+
+// ClearEpoch clears the epoch set by SetEpoch
+func (sv *StoreView) ClearEpoch() {
+	sv.epoch = nil
+}
+
 // GetAndClearValidatorDiff retrives and clear validator diff
 func (sv *StoreView) GetAndClearValidatorDiff() []*core.Validator {
 	res := sv.validatorsDiff
@@ -164,11 +282,209 @@ func (sv *StoreView) SetAccount(addr common.Address, acc *types.Account) {
 	sv.Set(AccountKey(addr), accBytes)
 }
 
-// DeleteAccount deletes an account.
+// DeleteAccount deletes an account. The account is removed from the state trie rather than
+// marked as deleted, so the resulting root hash is the same as if the account never existed.
 func (sv *StoreView) DeleteAccount(addr common.Address) {
 	sv.Delete(AccountKey(addr))
 }
 
+// GetImmatureRewards returns the coinbase rewards of an account recorded by AddImmatureReward
+// which may not have matured yet.
+func (sv *StoreView) GetImmatureRewards(addr common.Address) []types.ImmatureReward {
+	data := sv.Get(ImmatureRewardsKey(addr))
+	if data == nil || len(data) == 0 {
+		return []types.ImmatureReward{}
+	}
+	rewards := []types.ImmatureReward{}
+	err := types.FromBytes(data, &rewards)
+	if err != nil {
+		panic(fmt.Sprintf("Error reading immature rewards %X error: %v",
+			data, err.Error()))
+	}
+	return rewards
+}
+
+// AddImmatureReward records a coinbase reward of an account that cannot be spent before the
+// given mature height. The rewards that have matured by the current height are pruned.
+func (sv *StoreView) AddImmatureReward(addr common.Address, reward types.ImmatureReward) {
+	rewards := []types.ImmatureReward{}
+	for _, r := range sv.GetImmatureRewards(addr) {
+		if r.MatureHeight > sv.height {
+			rewards = append(rewards, r)
+		}
+	}
+	rewards = append(rewards, reward)
+
+	rewardsBytes, err := types.ToBytes(rewards)
+	if err != nil {
+		panic(fmt.Sprintf("Error writing immature rewards %v error: %v",
+			rewards, err.Error()))
+	}
+	sv.Set(ImmatureRewardsKey(addr), rewardsBytes)
+}
+
+// GetLockedRewards returns the total coinbase rewards of an account that have not matured at
+// the current height, which are part of the account balance but cannot be spent yet.
+func (sv *StoreView) GetLockedRewards(addr common.Address) types.Coins {
+	locked := types.NewCoins(0, 0)
+	for _, reward := range sv.GetImmatureRewards(addr) {
+		if reward.MatureHeight > sv.height {
+			locked = locked.Plus(reward.Coins)
+		}
+	}
+	return locked
+}
+
+// GetMultisigPolicy returns the multisig policy of an account, or nil if the spends of the account
+// are authorized by the single public key of the account.
+func (sv *StoreView) GetMultisigPolicy(addr common.Address) *types.MultisigPolicy {
+	data := sv.Get(MultisigPolicyKey(addr))
+	if data == nil || len(data) == 0 {
+		return nil
+	}
+	policy := &types.MultisigPolicy{}
+	err := types.FromBytes(data, policy)
+	if err != nil {
+		panic(fmt.Sprintf("Error reading multisig policy %X error: %v",
+			data, err.Error()))
+	}
+	return policy
+}
+
+// SetMultisigPolicy sets the multisig policy of an account
+func (sv *StoreView) SetMultisigPolicy(addr common.Address, policy *types.MultisigPolicy) {
+	policyBytes, err := types.ToBytes(policy)
+	if err != nil {
+		panic(fmt.Sprintf("Error writing multisig policy %v error: %v",
+			policy, err.Error()))
+	}
+	sv.Set(MultisigPolicyKey(addr), policyBytes)
+}
+
+// GetValidatorStakes returns the stakes of the active validators, sorted by public key
+func (sv *StoreView) GetValidatorStakes() []types.ValidatorStake {
+	return sv.getValidatorStakes(ValidatorStakesKey())
+}
+
+// SetValidatorStakes sets the stakes of the active validators, effective from the given epoch
+func (sv *StoreView) SetValidatorStakes(epoch uint64, stakes []types.ValidatorStake) {
+	sv.setValidatorStakes(ValidatorStakesKey(), stakes)
+	sv.setEpoch(ValidatorStakesEpochKey(), epoch)
+}
+
+// GetValidatorStakesEpoch returns the epoch from which the stakes of the active validators are
+// effective
+func (sv *StoreView) GetValidatorStakesEpoch() uint64 {
+	return sv.getEpoch(ValidatorStakesEpochKey())
+}
+
+// GetPendingValidatorChanges returns the validator stake changes recorded since the last epoch
+// boundary, in the order they were recorded
+func (sv *StoreView) GetPendingValidatorChanges() []types.ValidatorStake {
+	return sv.getValidatorStakes(PendingValidatorChangesKey())
+}
+
+// GetPendingValidatorChangesEpoch returns the epoch the pending validator stake changes were
+// recorded in
+func (sv *StoreView) GetPendingValidatorChangesEpoch() uint64 {
+	return sv.getEpoch(PendingValidatorChangesEpochKey())
+}
+
+// AddPendingValidatorChange records a change of the validator stake in the given epoch, which only
+// takes effect once the pending changes are applied at the next epoch boundary
+func (sv *StoreView) AddPendingValidatorChange(epoch uint64, change types.ValidatorStake) {
+	changes := append(sv.GetPendingValidatorChanges(), change)
+	sv.setValidatorStakes(PendingValidatorChangesKey(), changes)
+	sv.setEpoch(PendingValidatorChangesEpochKey(), epoch)
+}
+
+// ClearPendingValidatorChanges removes the pending validator stake changes
+func (sv *StoreView) ClearPendingValidatorChanges() {
+	sv.Delete(PendingValidatorChangesKey())
+	sv.Delete(PendingValidatorChangesEpochKey())
+}
+
+func (sv *StoreView) getEpoch(key common.Bytes) uint64 {
+	data := sv.Get(key)
+	if data == nil || len(data) == 0 {
+		return 0
+	}
+	var epoch uint64
+	err := types.FromBytes(data, &epoch)
+	if err != nil {
+		panic(fmt.Sprintf("Error reading epoch %X error: %v", data, err.Error()))
+	}
+	return epoch
+}
+
+func (sv *StoreView) setEpoch(key common.Bytes, epoch uint64) {
+	epochBytes, err := types.ToBytes(epoch)
+	if err != nil {
+		panic(fmt.Sprintf("Error writing epoch %v error: %v", epoch, err.Error()))
+	}
+	sv.Set(key, epochBytes)
+}
+
+func (sv *StoreView) getValidatorStakes(key common.Bytes) []types.ValidatorStake {
+	stakes := []types.ValidatorStake{}
+	data := sv.Get(key)
+	if data == nil || len(data) == 0 {
+		return stakes
+	}
+	err := types.FromBytes(data, &stakes)
+	if err != nil {
+		panic(fmt.Sprintf("Error reading validator stakes %X error: %v",
+			data, err.Error()))
+	}
+	return stakes
+}
+
+func (sv *StoreView) setValidatorStakes(key common.Bytes, stakes []types.ValidatorStake) {
+	stakesBytes, err := types.ToBytes(stakes)
+	if err != nil {
+		panic(fmt.Sprintf("Error writing validator stakes %v error: %v",
+			stakes, err.Error()))
+	}
+	sv.Set(key, stakesBytes)
+}
+
+// GetChainParams returns the chain parameters, or the default chain parameters if they have not
+// been set in the state
+func (sv *StoreView) GetChainParams() *types.ChainParams {
+	data := sv.Get(ChainParamsKey())
+	if data == nil || len(data) == 0 {
+		return types.DefaultChainParams()
+	}
+	params := &types.ChainParams{}
+	err := types.FromBytes(data, params)
+	if err != nil {
+		panic(fmt.Sprintf("Error reading chain params %X error: %v",
+			data, err.Error()))
+	}
+	return params
+}
+
+// SetChainParams sets the chain parameters
+func (sv *StoreView) SetChainParams(params *types.ChainParams) {
+	paramsBytes, err := types.ToBytes(params)
+	if err != nil {
+		panic(fmt.Sprintf("Error writing chain params %v error: %v",
+			params, err.Error()))
+	}
+	sv.Set(ChainParamsKey(), paramsBytes)
+}
+
+// GetGovernanceAddress returns the address of the governance account, or the empty address if
+// the chain has no governance account
+func (sv *StoreView) GetGovernanceAddress() common.Address {
+	return common.BytesToAddress(sv.Get(GovernanceAddressKey()))
+}
+
+// SetGovernanceAddress sets the address of the governance account
+func (sv *StoreView) SetGovernanceAddress(addr common.Address) {
+	sv.Set(GovernanceAddressKey(), addr[:])
+}
+
 // SplitRuleExists checks if a split rule associated with the given resourceID already exists
 func (sv *StoreView) SplitRuleExists(resourceID string) bool {
 	return sv.GetSplitRule(resourceID) != nil
@@ -416,6 +732,8 @@ func (sv *StoreView) SetState(addr common.Address, key, val common.Hash) {
 	sv.SetAccount(addr, account)
 }
 
+// Suicide implements the StateDB interface. It deletes the account of the self-destructed
+// contract right away, after the EVM has transferred the balance to the beneficiary.
 func (sv *StoreView) Suicide(addr common.Address) bool {
 	if sv.GetAccount(addr) == nil {
 		return false
@@ -424,6 +742,7 @@ func (sv *StoreView) Suicide(addr common.Address) bool {
 	return true
 }
 
+// HasSuicided implements the StateDB interface.
 func (sv *StoreView) HasSuicided(addr common.Address) bool {
 	account := sv.GetAccount(addr)
 	return account == nil
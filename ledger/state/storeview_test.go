@@ -2,6 +2,7 @@ package state
 
 import (
 	"encoding/hex"
+	"fmt"
 	"math/big"
 	"testing"
 
@@ -9,9 +10,12 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
 	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/ledger/types"
 	"github.com/thetatoken/ukulele/store/database/backend"
+	"github.com/thetatoken/ukulele/store/treestore"
+	"github.com/thetatoken/ukulele/store/trie"
 )
 
 func TestStoreViewBasics(t *testing.T) {
@@ -111,6 +115,51 @@ func TestStoreViewAccountAccess(t *testing.T) {
 	log.Infof("Balance: %v\n", accRetrieved.Balance)
 }
 
+func TestStoreViewDeleteAccount(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pubKey1, err := crypto.TEST_GenerateKeyPairWithSeed("account1")
+	assert.Nil(err)
+	_, pubKey2, err := crypto.TEST_GenerateKeyPairWithSeed("account2")
+	assert.Nil(err)
+	acc1 := &types.Account{
+		PubKey:  pubKey1,
+		Balance: types.Coins{ThetaWei: big.NewInt(786), GammaWei: big.NewInt(0)},
+	}
+	acc2 := &types.Account{
+		PubKey:  pubKey2,
+		Balance: types.Coins{ThetaWei: big.NewInt(0), GammaWei: big.NewInt(0)},
+	}
+	acc1Addr := acc1.PubKey.Address()
+	acc2Addr := acc2.PubKey.Address()
+
+	db := backend.NewMemDatabase()
+	sv := NewStoreView(uint64(1), common.Hash{}, db)
+	sv.SetAccount(acc1Addr, acc1)
+	rootHashWithAcc1 := sv.Hash()
+
+	sv.SetAccount(acc2Addr, acc2)
+	rootHashWithAcc1And2 := sv.Hash()
+	assert.NotEqual(rootHashWithAcc1, rootHashWithAcc1And2)
+
+	// Deleting the account restores the root hash of the state without the account
+	sv.DeleteAccount(acc2Addr)
+	assert.Nil(sv.GetAccount(acc2Addr))
+	assert.False(sv.Exist(acc2Addr))
+	assert.NotNil(sv.GetAccount(acc1Addr))
+	assert.Equal(rootHashWithAcc1, sv.Hash())
+
+	// Deleting an account that does not exist is a no-op
+	sv.DeleteAccount(acc2Addr)
+	assert.Equal(rootHashWithAcc1, sv.Hash())
+
+	// A self-destructed account is deleted as well
+	assert.True(sv.Suicide(acc1Addr))
+	assert.True(sv.HasSuicided(acc1Addr))
+	assert.False(sv.Suicide(acc1Addr))
+	assert.Equal(NewStoreView(uint64(1), common.Hash{}, backend.NewMemDatabase()).Hash(), sv.Hash())
+}
+
 func TestStoreViewSplitRuleAccess(t *testing.T) {
 	assert := assert.New(t)
 
@@ -180,3 +229,89 @@ func TestStoreViewSplitRuleAccess(t *testing.T) {
 	assert.Nil(sv.GetSplitRule(rid2))
 	assert.NotNil(sv.GetSplitRule(rid3))
 }
+
+func TestStoreViewCopyOnWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	db := backend.NewMemDatabase()
+	sv1 := NewStoreView(uint64(1), common.Hash{}, db)
+	for i := 0; i < 100; i++ {
+		sv1.Set(common.Bytes(fmt.Sprintf("key%v", i)), common.Bytes(fmt.Sprintf("value%v", i)))
+	}
+	sv1.Save()
+
+	// Uncommitted changes are carried over to the copy
+	sv1.Set(common.Bytes("key100"), common.Bytes("value100"))
+	sv1RootHash := sv1.Hash()
+
+	sv2, err := sv1.Copy()
+	assert.Nil(err)
+	sv3 := newFullCopy(sv1)
+	assert.Equal(sv1RootHash, sv2.Hash())
+	assert.Equal(common.Bytes("value100"), sv2.Get(common.Bytes("key100")))
+
+	// The copy-on-write copy and the full copy agree after identical mutations
+	for _, sv := range []*StoreView{sv2, sv3} {
+		sv.Set(common.Bytes("key0"), common.Bytes("updated"))
+		sv.Set(common.Bytes("key101"), common.Bytes("value101"))
+		sv.Delete(common.Bytes("key50"))
+	}
+	assert.Equal(sv3.Hash(), sv2.Hash())
+	assert.NotEqual(sv1RootHash, sv2.Hash())
+
+	// The original view is not affected by the mutations of the copy
+	assert.Equal(sv1RootHash, sv1.Hash())
+	assert.Equal(common.Bytes("value0"), sv1.Get(common.Bytes("key0")))
+	assert.Equal(common.Bytes("value50"), sv1.Get(common.Bytes("key50")))
+	assert.Equal(common.Bytes(nil), sv1.Get(common.Bytes("key101")))
+
+	// Nor is the copy affected by the mutations of the original view
+	sv1.Set(common.Bytes("key1"), common.Bytes("updated"))
+	assert.Equal(common.Bytes("value1"), sv2.Get(common.Bytes("key1")))
+	assert.Equal(sv3.Hash(), sv2.Hash())
+}
+
+// newFullCopy copies the StoreView by committing it to the in-memory trie DB and
+// loading the trie again from its root, i.e. the way StoreView.Copy used to work.
+func newFullCopy(sv *StoreView) *StoreView {
+	sv.store.Trie.Commit(nil)
+	tr, err := trie.New(sv.store.Hash(), sv.store.Trie.GetDB())
+	if err != nil {
+		panic(err)
+	}
+	return &StoreView{
+		height:         sv.height,
+		store:          &treestore.TreeStore{Trie: tr},
+		slashIntents:   []types.SlashIntent{},
+		validatorsDiff: []*core.Validator{},
+	}
+}
+
+func newLargeStoreView(numKeys int) *StoreView {
+	sv := NewStoreView(uint64(1), common.Hash{}, backend.NewMemDatabase())
+	for i := 0; i < numKeys; i++ {
+		sv.Set(common.Bytes(fmt.Sprintf("key%v", i)), common.Bytes(fmt.Sprintf("value%v", i)))
+	}
+	sv.Save()
+	return sv
+}
+
+// Each iteration applies a single update before taking the snapshot, as the
+// screened view does between two snapshots.
+func BenchmarkStoreViewFullCopy(b *testing.B) {
+	sv := newLargeStoreView(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sv.Set(common.Bytes(fmt.Sprintf("key%v", i%10000)), common.Bytes(fmt.Sprintf("updated%v", i)))
+		newFullCopy(sv)
+	}
+}
+
+func BenchmarkStoreViewCopy(b *testing.B) {
+	sv := newLargeStoreView(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sv.Set(common.Bytes(fmt.Sprintf("key%v", i%10000)), common.Bytes(fmt.Sprintf("updated%v", i)))
+		sv.Copy()
+	}
+}
@@ -0,0 +1,105 @@
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/thetatoken/ukulele/common"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/store/treestore"
+	"github.com/thetatoken/ukulele/store/trie"
+)
+
+// AccountChangeType is the type of the change of an account between two states
+type AccountChangeType uint8
+
+const (
+	// AccountAdded indicates the account only exists in the later state
+	AccountAdded AccountChangeType = iota
+
+	// AccountModified indicates the account exists in both states, but differs
+	AccountModified
+
+	// AccountRemoved indicates the account only exists in the earlier state
+	AccountRemoved
+)
+
+// AccountChange is an account whose state differs between two states
+type AccountChange struct {
+	Address common.Address
+	Type    AccountChangeType
+	From    *types.Account // nil if the account is added
+	To      *types.Account // nil if the account is removed
+}
+
+// StateDiff returns the accounts whose states differ between the states with the given roots,
+// sorted by address. The state tries are walked together, and the subtries identical in both
+// states are skipped, so the cost is proportional to the size of the difference. Both states
+// need to be available in the database, i.e. not pruned.
+func (ledger *Ledger) StateDiff(fromRoot, toRoot common.Hash) ([]AccountChange, error) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	fromStore := treestore.NewTreeStore(fromRoot, ledger.db)
+	if fromStore == nil {
+		return nil, fmt.Errorf("State %v is not available", fromRoot.Hex())
+	}
+	toStore := treestore.NewTreeStore(toRoot, ledger.db)
+	if toStore == nil {
+		return nil, fmt.Errorf("State %v is not available", toRoot.Hex())
+	}
+
+	// Accounts only in the later state, or modified
+	added, err := diffAccounts(fromStore, toStore)
+	if err != nil {
+		return nil, err
+	}
+	// Accounts only in the earlier state, or modified
+	removed, err := diffAccounts(toStore, fromStore)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []AccountChange{}
+	for addr, to := range added {
+		if from, ok := removed[addr]; ok {
+			changes = append(changes, AccountChange{Address: addr, Type: AccountModified, From: from, To: to})
+		} else {
+			changes = append(changes, AccountChange{Address: addr, Type: AccountAdded, To: to})
+		}
+	}
+	for addr, from := range removed {
+		if _, ok := added[addr]; !ok {
+			changes = append(changes, AccountChange{Address: addr, Type: AccountRemoved, From: from})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return bytes.Compare(changes[i].Address[:], changes[j].Address[:]) < 0
+	})
+	return changes, nil
+}
+
+// diffAccounts returns the accounts of store b which are not in store a with the same value
+func diffAccounts(a, b *treestore.TreeStore) (map[common.Address]*types.Account, error) {
+	diffIt, _ := trie.NewDifferenceIterator(a.NodeIterator(nil), b.NodeIterator(nil))
+	it := trie.NewIterator(diffIt)
+
+	prefix := st.AccountKeyPrefix()
+	accounts := make(map[common.Address]*types.Account)
+	for it.Next() {
+		if !bytes.HasPrefix(it.Key, prefix) {
+			continue
+		}
+		account := &types.Account{}
+		if err := types.FromBytes(it.Value, account); err != nil {
+			return nil, fmt.Errorf("Failed to decode account %X: %v", it.Key, err)
+		}
+		accounts[common.BytesToAddress(it.Key[len(prefix):])] = account
+	}
+	if it.Err != nil {
+		return nil, fmt.Errorf("Failed to walk the state trie: %v", it.Err)
+	}
+	return accounts, nil
+}
@@ -0,0 +1,80 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+func TestLedgerStateDiff(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 3)
+	fromRoot := ledger.state.Commit()
+
+	// Modify an existing account, and add a new one
+	view := ledger.state.Delivered()
+	modifiedAddr := accIns[1].PubKey.Address()
+	modified := view.GetAccount(modifiedAddr)
+	original := *modified
+	modified.Balance = modified.Balance.Minus(types.NewCoins(100, 0))
+	view.SetAccount(modifiedAddr, modified)
+
+	newAcc := types.MakeAccWithInitBalance("new_account", types.NewCoins(100, 0))
+	newAddr := newAcc.PubKey.Address()
+	view.SetAccount(newAddr, &newAcc.Account)
+	toRoot := ledger.state.Commit()
+	require.NotEqual(fromRoot, toRoot)
+
+	changes, err := ledger.StateDiff(fromRoot, toRoot)
+	require.Nil(err)
+	require.Equal(2, len(changes))
+	byAddr := make(map[common.Address]AccountChange)
+	for _, change := range changes {
+		byAddr[change.Address] = change
+	}
+
+	change, ok := byAddr[modifiedAddr]
+	require.True(ok)
+	assert.Equal(AccountModified, change.Type)
+	assert.Equal(original.Balance, change.From.Balance)
+	assert.Equal(modified.Balance, change.To.Balance)
+
+	change, ok = byAddr[newAddr]
+	require.True(ok)
+	assert.Equal(AccountAdded, change.Type)
+	assert.Nil(change.From)
+	assert.Equal(newAcc.Account.Balance, change.To.Balance)
+
+	_, ok = byAddr[accOut.PubKey.Address()]
+	assert.False(ok)
+
+	// The reverse diff reports the new account as removed
+	changes, err = ledger.StateDiff(toRoot, fromRoot)
+	require.Nil(err)
+	require.Equal(2, len(changes))
+	for _, change := range changes {
+		if change.Address == newAddr {
+			assert.Equal(AccountRemoved, change.Type)
+			assert.Nil(change.To)
+		} else {
+			assert.Equal(modifiedAddr, change.Address)
+			assert.Equal(AccountModified, change.Type)
+			assert.Equal(modified.Balance, change.From.Balance)
+		}
+	}
+
+	// No difference between the same states
+	changes, err = ledger.StateDiff(toRoot, toRoot)
+	require.Nil(err)
+	assert.Equal(0, len(changes))
+
+	// The states need to be available
+	_, err = ledger.StateDiff(fromRoot, common.BytesToHash([]byte("unknown")))
+	assert.NotNil(err)
+}
@@ -0,0 +1,41 @@
+package ledger
+
+import (
+	"github.com/thetatoken/ukulele/common"
+)
+
+// Status is a summary of the ledger state for health and readiness checks
+type Status struct {
+	DeliveredHeight uint64      `json:"delivered_height"`
+	FinalizedHeight uint64      `json:"finalized_height"`
+	StateRoot       common.Hash `json:"state_root"`
+	Committing      bool        `json:"committing"` // whether a block is being committed
+	CaughtUp        bool        `json:"caught_up"`  // whether the ledger has caught up with the consensus tip
+}
+
+// Status returns the status of the ledger. It does not wait for the ledger state lock,
+// so it is safe to poll frequently, including while a block is being committed.
+func (ledger *Ledger) Status() Status {
+	ledger.statusMu.RLock()
+	status := ledger.status
+	ledger.statusMu.RUnlock()
+
+	tip := ledger.consensus.GetTip()
+	status.CaughtUp = tip != nil && !status.Committing && status.DeliveredHeight >= tip.Height
+	return status
+}
+
+// updateStatus updates the status with the current ledger state. The caller must hold the
+// ledger state lock.
+func (ledger *Ledger) updateStatus(committing bool) {
+	ledger.statusMu.Lock()
+	defer ledger.statusMu.Unlock()
+
+	ledger.status.Committing = committing
+	if committing {
+		return
+	}
+	ledger.status.DeliveredHeight = ledger.state.Height()
+	ledger.status.FinalizedHeight = ledger.state.Finalized().Height()
+	ledger.status.StateRoot = ledger.state.Delivered().Hash()
+}
@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/core"
+	exec "github.com/thetatoken/ukulele/ledger/execution"
+)
+
+// testTipConsensusEngine is a test consensus engine with a configurable tip
+type testTipConsensusEngine struct {
+	*exec.TestConsensusEngine
+	tip *core.ExtendedBlock
+}
+
+func (tce *testTipConsensusEngine) GetTip() *core.ExtendedBlock { return tce.tip }
+
+func TestLedgerStatus(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID := "test_chain_id"
+	consensus := &testTipConsensusEngine{TestConsensusEngine: exec.NewTestConsensusEngine("proposer")}
+	valMgr := newTesetValidatorManager(consensus)
+	ledger := newTestLedgerWithConsensus(chainID, "peer0", consensus, valMgr)
+	prepareInitLedgerState(ledger, 1)
+	res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash())
+	require.True(res.IsOK(), res.Message)
+
+	// The consensus tip is unknown
+	status := ledger.Status()
+	assert.Equal(ledger.state.Height(), status.DeliveredHeight)
+	assert.Equal(ledger.state.Delivered().Hash(), status.StateRoot)
+	assert.False(status.Committing)
+	assert.False(status.CaughtUp)
+
+	height := ledger.state.Height()
+	consensus.tip = &core.ExtendedBlock{Block: &core.Block{BlockHeader: &core.BlockHeader{Height: height + 1}}}
+	assert.False(ledger.Status().CaughtUp)
+
+	// The status reflects the committed block
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+
+	status = ledger.Status()
+	assert.Equal(height+1, status.DeliveredHeight)
+	assert.Equal(ledger.state.Delivered().Hash(), status.StateRoot)
+	assert.True(status.CaughtUp)
+
+	res = ledger.FinalizeState(height+1, status.StateRoot)
+	require.True(res.IsOK(), res.Message)
+	assert.Equal(height+1, ledger.Status().FinalizedHeight)
+
+	// The ledger is not caught up while a block is being committed
+	ledger.updateStatus(true)
+	status = ledger.Status()
+	assert.True(status.Committing)
+	assert.False(status.CaughtUp)
+}
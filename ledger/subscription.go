@@ -0,0 +1,70 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/thetatoken/ukulele/common"
+)
+
+// newBlockSubscriptionBufferSize is the number of notifications buffered for each subscriber
+const newBlockSubscriptionBufferSize = 64
+
+// BlockInfo describes a block committed by the ledger
+type BlockInfo struct {
+	Height    uint64
+	StateRoot common.Hash
+	NumTxs    int
+	Timestamp time.Time // Time at which the ledger committed the block
+}
+
+// SubscribeNewBlocks returns a channel that receives a BlockInfo for each block committed after
+// the subscription, and a function to cancel the subscription, which closes the channel. Each
+// subscriber has its own buffered channel. The commits never wait for the subscribers: if the
+// buffer of a subscriber is full, its oldest notification is dropped to make room for the new one.
+func (ledger *Ledger) SubscribeNewBlocks() (<-chan BlockInfo, func()) {
+	ch := make(chan BlockInfo, newBlockSubscriptionBufferSize)
+
+	ledger.subscriptionMu.Lock()
+	ledger.blockSubscribers[ch] = struct{}{}
+	ledger.subscriptionMu.Unlock()
+
+	unsubscribe := func() {
+		ledger.subscriptionMu.Lock()
+		defer ledger.subscriptionMu.Unlock()
+
+		if _, ok := ledger.blockSubscribers[ch]; ok {
+			delete(ledger.blockSubscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// notifyNewBlock sends the info of a committed block to the subscribers without blocking
+func (ledger *Ledger) notifyNewBlock(height uint64, stateRoot common.Hash, numTxs int) {
+	info := BlockInfo{
+		Height:    height,
+		StateRoot: stateRoot,
+		NumTxs:    numTxs,
+		Timestamp: time.Now(),
+	}
+
+	ledger.subscriptionMu.Lock()
+	defer ledger.subscriptionMu.Unlock()
+
+	for ch := range ledger.blockSubscribers {
+		select {
+		case ch <- info:
+			continue
+		default:
+		}
+
+		// The buffer is full, drop the oldest notification to make room. Only the ledger sends
+		// to the channel while holding the lock, so the send below cannot block.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- info
+	}
+}
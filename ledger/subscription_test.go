@@ -0,0 +1,78 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+)
+
+func TestLedgerSubscribeNewBlocks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	_, ledger, _ := newTestLedger()
+	prepareInitLedgerState(ledger, 1)
+	res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash())
+	require.True(res.IsOK(), res.Message)
+
+	ch1, unsubscribe1 := ledger.SubscribeNewBlocks()
+	ch2, unsubscribe2 := ledger.SubscribeNewBlocks()
+	defer unsubscribe2()
+
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+	height := ledger.state.Height()
+
+	for _, ch := range []<-chan BlockInfo{ch1, ch2} {
+		select {
+		case info := <-ch:
+			assert.Equal(height, info.Height)
+			assert.Equal(stateRoot, info.StateRoot)
+			assert.Equal(len(blockTxs), info.NumTxs)
+			assert.False(info.Timestamp.IsZero())
+		default:
+			assert.Fail("No notification of the committed block")
+		}
+	}
+
+	// No notification for a block that failed to apply
+	res = ledger.ApplyBlockTxs(blockTxs, common.BytesToHash([]byte("wrong root")))
+	require.True(res.IsError())
+	assert.Equal(0, len(ch1))
+	assert.Equal(0, len(ch2))
+
+	// The cancelled subscription is closed and no longer notified
+	unsubscribe1()
+	unsubscribe1()
+	_, ok := <-ch1
+	assert.False(ok)
+
+	stateRoot, blockTxs, res = ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+	info := <-ch2
+	assert.Equal(height+1, info.Height)
+	assert.Equal(stateRoot, info.StateRoot)
+}
+
+func TestLedgerSubscribeNewBlocksSlowSubscriber(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ledger, _ := newTestLedger()
+	ch, unsubscribe := ledger.SubscribeNewBlocks()
+	defer unsubscribe()
+
+	// A subscriber that does not keep up loses the oldest notifications instead of blocking
+	numBlocks := uint64(newBlockSubscriptionBufferSize + 10)
+	for height := uint64(1); height <= numBlocks; height++ {
+		ledger.notifyNewBlock(height, common.Hash{}, 0)
+	}
+
+	assert.Equal(newBlockSubscriptionBufferSize, len(ch))
+	assert.Equal(uint64(11), (<-ch).Height)
+}
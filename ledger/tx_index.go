@@ -0,0 +1,180 @@
+package ledger
+
+import (
+	"encoding/binary"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/rlp"
+	"github.com/thetatoken/ukulele/store"
+	"github.com/thetatoken/ukulele/store/database"
+)
+
+var (
+	// ErrTxNotFound for transaction hash is not found in the tx index.
+	ErrTxNotFound = errors.New("TxNotFound")
+)
+
+// txIndexKey constructs the DB key for the given transaction hash.
+func txIndexKey(hash common.Hash) common.Bytes {
+	return append(common.Bytes("ls/txi/"), hash[:]...)
+}
+
+// blockIndexKey constructs the DB key for the block committed at the given height.
+func blockIndexKey(height uint64) common.Bytes {
+	heightBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(heightBytes, height)
+	return append(common.Bytes("ls/bi/"), heightBytes...)
+}
+
+// TxIndexEntry records where a committed transaction is located.
+type TxIndexEntry struct {
+	BlockHeight uint64
+	Index       uint64
+	RawTx       common.Bytes
+}
+
+// indexTxs adds the transactions committed at the given height to the tx index with the given
+// batch, e.g. the batch persisting the state of the block.
+func (ledger *Ledger) indexTxs(batch database.Putter, height uint64, blockRawTxs []common.Bytes) error {
+	for idx, rawTx := range blockRawTxs {
+		txIndexEntry := TxIndexEntry{
+			BlockHeight: height,
+			Index:       uint64(idx),
+			RawTx:       rawTx,
+		}
+		txHash := crypto.Keccak256Hash(rawTx)
+		if err := putIndexEntry(batch, txIndexKey(txHash), txIndexEntry); err != nil {
+			return err
+		}
+		txLogger(txHash).WithFields(log.Fields{"height": height, "index": idx}).Debug("Committed transaction")
+	}
+	return nil
+}
+
+// putIndexEntry writes the RLP encoded index entry with the given batch, which is read back by
+// ledger.store
+func putIndexEntry(batch database.Putter, key common.Bytes, entry interface{}) error {
+	encodedEntry, err := rlp.EncodeToBytes(entry)
+	if err != nil {
+		return err
+	}
+	return batch.Put(key, encodedEntry)
+}
+
+// BlockIndexEntry records the transactions of a committed block together with the state roots
+// before and after applying them. The epoch is missing from the entries indexed by older versions.
+type BlockIndexEntry struct {
+	ParentStateRoot common.Hash
+	StateRoot       common.Hash
+	RawTxs          []common.Bytes
+	Epoch           uint64 `rlp:"optional"`
+}
+
+// indexBlock records the block committed at the given height with the given batch. If blocks of
+// different branches are committed at the same height, the last one is kept.
+func (ledger *Ledger) indexBlock(batch database.Putter, height uint64, epoch uint64, parentStateRoot common.Hash, stateRoot common.Hash, blockRawTxs []common.Bytes) error {
+	blockIndexEntry := BlockIndexEntry{
+		ParentStateRoot: parentStateRoot,
+		StateRoot:       stateRoot,
+		RawTxs:          blockRawTxs,
+		Epoch:           epoch,
+	}
+	return putIndexEntry(batch, blockIndexKey(height), blockIndexEntry)
+}
+
+// unindexBlocksAbove removes the blocks committed above the given height from the block index,
+// together with their transactions from the tx index, so the blocks abandoned by a reset are no
+// longer found. A transaction indexed again at or below the height, e.g. when it was included by
+// the other branch, is left in the tx index.
+func (ledger *Ledger) unindexBlocksAbove(height uint64) {
+	for h := height + 1; ; h++ {
+		blockIndexEntry := &BlockIndexEntry{}
+		err := ledger.store.Get(blockIndexKey(h), blockIndexEntry)
+		if err == store.ErrKeyNotFound {
+			return
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+
+		ledger.unindexTxs(height, blockIndexEntry.RawTxs)
+		if err := ledger.store.Delete(blockIndexKey(h)); err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// unindexTxs removes the given transactions from the tx index, unless they are indexed at or
+// below the given height.
+func (ledger *Ledger) unindexTxs(height uint64, rawTxs []common.Bytes) {
+	for _, rawTx := range rawTxs {
+		txHash := crypto.Keccak256Hash(rawTx)
+		txIndexEntry := &TxIndexEntry{}
+		err := ledger.store.Get(txIndexKey(txHash), txIndexEntry)
+		if err == store.ErrKeyNotFound || (err == nil && txIndexEntry.BlockHeight <= height) {
+			continue
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+		if err := ledger.store.Delete(txIndexKey(txHash)); err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// reindexBranch indexes the blocks of the branch the ledger state has been reset to, in place of
+// the blocks of the abandoned branch indexed at the same heights. This is needed even if the
+// state is not replayed, e.g. when switching back to a branch whose states are still available.
+// The first block is the base of the branch, e.g. the last finalized block, which is kept as is.
+func (ledger *Ledger) reindexBranch(blocks []*core.Block) {
+	for idx := 1; idx < len(blocks); idx++ {
+		block := blocks[idx]
+		blockIndexEntry := &BlockIndexEntry{}
+		err := ledger.store.Get(blockIndexKey(block.Height), blockIndexEntry)
+		if err == nil && blockIndexEntry.StateRoot == block.StateHash {
+			continue // already indexed
+		}
+		if err != nil && err != store.ErrKeyNotFound {
+			log.Panic(err)
+		}
+		if err == nil {
+			ledger.unindexTxs(block.Height-1, blockIndexEntry.RawTxs)
+		}
+		if err := ledger.indexTxs(ledger.db, block.Height, block.Txs); err != nil {
+			log.Panic(err)
+		}
+		err = ledger.indexBlock(ledger.db, block.Height, block.Epoch, blocks[idx-1].StateHash, block.StateHash, block.Txs)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// GetTransaction looks up a committed transaction by hash, and returns the decoded
+// transaction together with the height of the block it was committed in.
+func (ledger *Ledger) GetTransaction(txHash common.Hash) (types.Tx, uint64, error) {
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+
+	txIndexEntry := &TxIndexEntry{}
+	err := ledger.store.Get(txIndexKey(txHash), txIndexEntry)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, 0, ErrTxNotFound
+		}
+		return nil, 0, err
+	}
+
+	tx, err := types.TxFromBytes(txIndexEntry.RawTx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tx, txIndexEntry.BlockHeight, nil
+}
@@ -0,0 +1,54 @@
+package ledger
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+// canonicalTxKey is the sort key of a regular transaction in the canonical order
+type canonicalTxKey struct {
+	hasSender bool
+	sender    common.Address
+	sequence  uint64
+	hash      common.Hash
+}
+
+// sortTxsCanonically sorts the regular transactions by (sender, sequence), so that the order does not
+// depend on the order the transactions were reaped from the mempool. The transactions without a sender
+// go last. The remaining ties, e.g. two transactions with the same sender and sequence, are broken by
+// the transaction hash.
+func sortTxsCanonically(rawTxs []common.Bytes) []common.Bytes {
+	keys := make(map[string]canonicalTxKey, len(rawTxs))
+	for _, rawTx := range rawTxs {
+		key := canonicalTxKey{hash: crypto.Keccak256Hash(rawTx)}
+		if tx, err := types.TxFromBytes(rawTx); err == nil {
+			if input, ok := types.GetSenderInput(tx); ok {
+				key.hasSender = true
+				key.sender = input.Address
+				key.sequence = input.Sequence
+			}
+		}
+		keys[string(rawTx)] = key
+	}
+
+	sorted := make([]common.Bytes, len(rawTxs))
+	copy(sorted, rawTxs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ki, kj := keys[string(sorted[i])], keys[string(sorted[j])]
+		if ki.hasSender != kj.hasSender {
+			return ki.hasSender
+		}
+		if cmp := bytes.Compare(ki.sender[:], kj.sender[:]); cmp != 0 {
+			return cmp < 0
+		}
+		if ki.sequence != kj.sequence {
+			return ki.sequence < kj.sequence
+		}
+		return bytes.Compare(ki.hash[:], kj.hash[:]) < 0
+	})
+	return sorted
+}
@@ -0,0 +1,91 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	exec "github.com/thetatoken/ukulele/ledger/execution"
+	"github.com/thetatoken/ukulele/ledger/types"
+	mp "github.com/thetatoken/ukulele/mempool"
+)
+
+func TestSortTxsCanonically(t *testing.T) {
+	assert := assert.New(t)
+
+	chainID := "test_chain_id"
+	accOut, accIns := prepareTestOrderingAccounts(3)
+	a1 := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	a2 := newRawSendTxWithoutPubKey(chainID, 2, accOut, accIns[0])
+	b1 := newRawSendTx(chainID, 1, true, accOut, accIns[1])
+	c1 := newRawSendTx(chainID, 1, true, accOut, accIns[2])
+	c2 := newRawSendTxWithoutPubKey(chainID, 2, accOut, accIns[2])
+	undecodable := common.Bytes("undecodable")
+
+	expected := sortTxsCanonically([]common.Bytes{a1, a2, b1, c1, c2, undecodable})
+	assert.Equal(expected, sortTxsCanonically([]common.Bytes{undecodable, c2, c1, b1, a2, a1}))
+	assert.Equal(expected, sortTxsCanonically([]common.Bytes{b1, c1, a2, undecodable, a1, c2}))
+
+	// The transactions of the same sender are in the order of their sequences,
+	// and the transactions without a sender go last
+	position := make(map[string]int)
+	for idx, rawTx := range expected {
+		position[string(rawTx)] = idx
+	}
+	assert.True(position[string(a1)] < position[string(a2)])
+	assert.True(position[string(c1)] < position[string(c2)])
+	assert.Equal(len(expected)-1, position[string(undecodable)])
+}
+
+func TestLedgerProposeBlockTxsCanonicalOrdering(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID := "test_chain_id"
+	consensus := exec.NewTestConsensusEngine("proposer")
+	valMgr := newTesetValidatorManager(consensus)
+	accOut, accIns := prepareTestOrderingAccounts(3)
+	a1 := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	a2 := newRawSendTxWithoutPubKey(chainID, 2, accOut, accIns[0])
+	b1 := newRawSendTx(chainID, 1, true, accOut, accIns[1])
+	c1 := newRawSendTx(chainID, 1, true, accOut, accIns[2])
+
+	// Two proposers reaping the same transactions in different orders propose the same block
+	proposeBlockTxs := func(rawTxs ...common.Bytes) (common.Hash, []common.Bytes) {
+		ledger := newTestLedgerWithConsensus(chainID, "peer0", consensus, valMgr)
+		ledger.canonicalTxOrdering = true
+		setInitLedgerState(ledger, accOut, accIns)
+		res := ledger.ResetState(ledger.state.Height(), ledger.state.Delivered().Hash())
+		require.True(res.IsOK(), res.Message)
+
+		for _, rawTx := range rawTxs {
+			require.Nil(ledger.mempool.InsertTransaction(mp.CreateMempoolTransaction(rawTx)))
+		}
+		stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+		require.True(res.IsOK(), res.Message)
+		return stateRoot, blockTxs
+	}
+	stateRoot1, blockTxs1 := proposeBlockTxs(a1, a2, b1, c1)
+	stateRoot2, blockTxs2 := proposeBlockTxs(c1, b1, a1, a2)
+
+	// The special transactions go first
+	require.Equal(5, len(blockTxs1))
+	coinbaseTx, err := types.TxFromBytes(blockTxs1[0])
+	require.Nil(err)
+	assert.IsType(&types.CoinbaseTx{}, coinbaseTx)
+
+	assert.Equal(blockTxs1[1:], blockTxs2[1:])
+	assert.Equal(sortTxsCanonically([]common.Bytes{a1, a2, b1, c1}), blockTxs1[1:])
+	assert.Equal(stateRoot1, stateRoot2)
+}
+
+func prepareTestOrderingAccounts(numInAccs int) (accOut types.PrivAccount, accIns []types.PrivAccount) {
+	txFee := getMinimumTxFee()
+	accOut = types.MakeAccWithInitBalance("accOut", types.NewCoins(700000, 3))
+	for i := 0; i < numInAccs; i++ {
+		accIn := types.MakeAccWithInitBalance("in_secret_"+string(rune('a'+i)), types.NewCoins(900000, 50000*txFee))
+		accIns = append(accIns, accIn)
+	}
+	return accOut, accIns
+}
@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"sync"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+// txSignature is a signature of a block transaction, together with the public key the
+// executor verifies it against
+type txSignature struct {
+	txIndex   int
+	pubKey    *crypto.PublicKey
+	signBytes common.Bytes
+	signature *crypto.Signature
+}
+
+// collectTxSignatures collects the signatures of the signed inputs of the block transactions.
+// Same as the executor, a signature is verified against the public key of the account, or the
+// public key of the input if the account does not have one yet. Since the public key of an
+// account is never changed once set, the state of the view before the block is applied can be
+// used. The signatures without a public key and the multisig signatures are left to the
+// sequential execution.
+func collectTxSignatures(view *st.StoreView, chainID string, txs []types.Tx) []txSignature {
+	sigs := []txSignature{}
+	for idx, tx := range txs {
+		for _, si := range types.GetSignedInputs(chainID, tx) {
+			if si.Input.IsMultisig() {
+				continue
+			}
+			pubKey := si.Input.PubKey
+			if account := view.GetAccount(si.Input.Address); account != nil && isValidPubKey(account.PubKey) {
+				pubKey = account.PubKey
+			}
+			if !isValidPubKey(pubKey) {
+				continue
+			}
+			sigs = append(sigs, txSignature{
+				txIndex:   idx,
+				pubKey:    pubKey,
+				signBytes: si.SignBytes,
+				signature: si.Input.Signature,
+			})
+		}
+	}
+	return sigs
+}
+
+// verifyTxSignatures verifies the signatures with the given number of workers, and records the
+// valid ones in the view, so that the executor does not verify them again. It returns the index
+// of the first transaction with an invalid signature, and false if there is any.
+func verifyTxSignatures(view *st.StoreView, sigs []txSignature, numWorkers int) (int, bool) {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	valid := make([]bool, len(sigs))
+	wg := &sync.WaitGroup{}
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(sigs); i += numWorkers {
+				valid[i] = sigs[i].pubKey.VerifySignature(sigs[i].signBytes, sigs[i].signature)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i, ok := range valid {
+		if !ok {
+			return sigs[i].txIndex, false
+		}
+		view.AddVerifiedSignature(sigs[i].pubKey, sigs[i].signBytes, sigs[i].signature)
+	}
+	return 0, true
+}
+
+func isValidPubKey(pubKey *crypto.PublicKey) bool {
+	return pubKey != nil && !pubKey.IsEmpty()
+}
@@ -0,0 +1,129 @@
+package ledger
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	st "github.com/thetatoken/ukulele/ledger/state"
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+func TestLedgerApplyBlockTxsInvalidSignature(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 2)
+	height := ledger.state.Height()
+	root := ledger.state.Delivered().Hash()
+
+	coinbaseTxBytes := newRawCoinbaseTx(chainID, ledger, 1)
+	sendTxBytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	invalidTxBytes := newRawSendTxWithInvalidSignature(chainID, accOut, accIns[1])
+	blockRawTxs := []common.Bytes{coinbaseTxBytes, sendTxBytes, invalidTxBytes}
+
+	txs := []types.Tx{}
+	for _, rawTx := range blockRawTxs {
+		tx, err := types.TxFromBytes(rawTx)
+		require.Nil(err)
+		txs = append(txs, tx)
+	}
+	sigs := collectTxSignatures(ledger.state.Delivered(), chainID, txs)
+	assert.Equal(3, len(sigs))
+	view, err := ledger.state.Delivered().Copy()
+	require.Nil(err)
+	idx, ok := verifyTxSignatures(view, sigs, 2)
+	assert.False(ok)
+	assert.Equal(2, idx)
+	assert.False(view.IsSignatureVerified(sigs[2].pubKey, sigs[2].signBytes, sigs[2].signature))
+
+	// The block is rejected before any state change
+	res := ledger.ApplyBlockTxs(blockRawTxs, common.Hash{})
+	assert.Equal(result.CodeInvalidSignature, res.Code, res.Message)
+	assert.Equal(height, ledger.state.Height())
+	assert.Equal(root, ledger.state.Delivered().Hash())
+	assert.Equal(accIns[0].Balance, ledger.state.Delivered().GetAccount(accIns[0].PubKey.Address()).Balance)
+}
+
+func TestCollectTxSignatures(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	chainID, ledger, _ := newTestLedger()
+	accOut, accIns := prepareInitLedgerState(ledger, 1)
+
+	// The signatures are verified against the public key of the account, which does not
+	// change across the transactions of the block
+	sendTx1Bytes := newRawSendTx(chainID, 1, true, accOut, accIns[0])
+	sendTx2Bytes := newRawSendTxWithoutPubKey(chainID, 2, accOut, accIns[0])
+	txs := []types.Tx{}
+	for _, rawTx := range []common.Bytes{sendTx1Bytes, sendTx2Bytes} {
+		tx, err := types.TxFromBytes(rawTx)
+		require.Nil(err)
+		txs = append(txs, tx)
+	}
+
+	sigs := collectTxSignatures(ledger.state.Delivered(), chainID, txs)
+	require.Equal(2, len(sigs))
+	for idx, sig := range sigs {
+		assert.Equal(idx, sig.txIndex)
+		assert.Equal(accIns[0].PubKey.ToBytes(), sig.pubKey.ToBytes())
+	}
+
+	// The verified signatures are recorded in the view, so that the executor skips them
+	view := ledger.state.Delivered()
+	_, ok := verifyTxSignatures(view, sigs, 2)
+	require.True(ok)
+	for _, sig := range sigs {
+		assert.True(view.IsSignatureVerified(sig.pubKey, sig.signBytes, sig.signature))
+	}
+	assert.False(view.IsSignatureVerified(sigs[0].pubKey, sigs[1].signBytes, sigs[0].signature))
+
+	// The records are cleared once the block is applied
+	stateRoot, blockTxs, res := ledger.ProposeBlockTxs()
+	require.True(res.IsOK(), res.Message)
+	res = ledger.ApplyBlockTxs(blockTxs, stateRoot)
+	require.True(res.IsOK(), res.Message)
+	assert.False(ledger.state.Delivered().IsSignatureVerified(sigs[0].pubKey, sigs[0].signBytes, sigs[0].signature))
+}
+
+func BenchmarkVerifyTxSignaturesSerial(b *testing.B) {
+	view, sigs := newTestBlockTxSignatures(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		verifyTxSignatures(view, sigs, 1)
+	}
+}
+
+func BenchmarkVerifyTxSignaturesParallel(b *testing.B) {
+	view, sigs := newTestBlockTxSignatures(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		verifyTxSignatures(view, sigs, runtime.NumCPU())
+	}
+}
+
+// newTestBlockTxSignatures collects the signatures of a block of send transactions from
+// distinct senders
+func newTestBlockTxSignatures(numTxs int) (*st.StoreView, []txSignature) {
+	chainID, ledger, _ := newTestLedger()
+	accOut := types.MakeAccWithInitBalance("accOut", types.NewCoins(0, 0))
+	txs := []types.Tx{}
+	for i := 0; i < numTxs; i++ {
+		accIn := types.MakeAccWithInitBalance("in_secret_"+strconv.Itoa(i), types.NewCoins(0, 0))
+		tx, err := types.TxFromBytes(newRawSendTx(chainID, 1, true, accOut, accIn))
+		if err != nil {
+			panic(err)
+		}
+		txs = append(txs, tx)
+	}
+	view := ledger.state.Delivered()
+	return view, collectTxSignatures(view, chainID, txs)
+}
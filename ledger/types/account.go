@@ -20,6 +20,8 @@ type Account struct {
 	// Smart contract
 	Root     common.Hash `json:"root"`      // merkle root of the storage trie
 	CodeHash common.Hash `json:"code_hash"` // hash of the smart contract code
+
+	Frozen bool `json:"frozen,omitempty" rlp:"optional"` // whether spending from the account is disabled
 }
 
 func NewAccount() *Account {
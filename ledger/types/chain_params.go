@@ -0,0 +1,85 @@
+package types
+
+import (
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/core"
+)
+
+// ChainParams are the chain parameters adjustable at runtime. They are stored in the ledger state,
+// so that all the nodes read the same values
+type ChainParams struct {
+	MinimumGasPrice               uint64 // minimum gas price for a smart contract transaction
+	MinimumTransactionFeeGammaWei uint64 // minimum fee for a regular transaction
+	MaxBlockGas                   uint64 // max amount of gas the smart contract transactions in one block can consume
+	MaxRewardedValidators         uint64 `rlp:"optional"` // max number of validators rewarded in one block (0 means no cap)
+	InitialBlockRewardGammaWei    uint64 `rlp:"optional"` // total coinbase reward of a block before the first halving
+	RewardHalvingInterval         uint64 `rlp:"optional"` // number of blocks after which the block reward halves (0 means never)
+	CoinbaseMaturity              uint64 `rlp:"optional"` // number of blocks before the coinbase rewards can be spent (0 means immediately)
+}
+
+// Names of the chain parameters, as referred to by ParamUpdate
+const (
+	ParamMinimumGasPrice               = "MinimumGasPrice"
+	ParamMinimumTransactionFeeGammaWei = "MinimumTransactionFeeGammaWei"
+	ParamMaxBlockGas                   = "MaxBlockGas"
+	ParamMaxRewardedValidators         = "MaxRewardedValidators"
+	ParamInitialBlockRewardGammaWei    = "InitialBlockRewardGammaWei"
+	ParamRewardHalvingInterval         = "RewardHalvingInterval"
+	ParamCoinbaseMaturity              = "CoinbaseMaturity"
+)
+
+// ParamUpdate sets the chain parameter of the given name to the value
+type ParamUpdate struct {
+	Name  string
+	Value uint64
+}
+
+// DefaultChainParams returns the chain parameters in effect until they are set in the ledger state
+func DefaultChainParams() *ChainParams {
+	return &ChainParams{
+		MinimumGasPrice:               MinimumGasPrice,
+		MinimumTransactionFeeGammaWei: MinimumTransactionFeeGammaWei,
+		MaxBlockGas:                   core.MaxBlockGas,
+	}
+}
+
+// ValidateBasic checks that the chain parameters are usable
+func (cp *ChainParams) ValidateBasic() result.Result {
+	if cp.MinimumGasPrice == 0 {
+		return result.Error("Minimum gas price cannot be zero")
+	}
+	if cp.MaxBlockGas == 0 {
+		return result.Error("Max block gas cannot be zero")
+	}
+	return result.OK
+}
+
+// Update returns a copy of the chain parameters with the updates applied, or an error if any of
+// the updates refers to an unknown parameter, or the resulting parameters are invalid
+func (cp *ChainParams) Update(updates []ParamUpdate) (*ChainParams, result.Result) {
+	updated := *cp
+	for _, update := range updates {
+		switch update.Name {
+		case ParamMinimumGasPrice:
+			updated.MinimumGasPrice = update.Value
+		case ParamMinimumTransactionFeeGammaWei:
+			updated.MinimumTransactionFeeGammaWei = update.Value
+		case ParamMaxBlockGas:
+			updated.MaxBlockGas = update.Value
+		case ParamMaxRewardedValidators:
+			updated.MaxRewardedValidators = update.Value
+		case ParamInitialBlockRewardGammaWei:
+			updated.InitialBlockRewardGammaWei = update.Value
+		case ParamRewardHalvingInterval:
+			updated.RewardHalvingInterval = update.Value
+		case ParamCoinbaseMaturity:
+			updated.CoinbaseMaturity = update.Value
+		default:
+			return nil, result.Error("Unknown chain parameter: %v", update.Name)
+		}
+	}
+	if res := updated.ValidateBasic(); res.IsError() {
+		return nil, res
+	}
+	return &updated, result.OK
+}
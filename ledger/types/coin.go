@@ -1,6 +1,7 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -9,11 +10,24 @@ import (
 var (
 	Zero    *big.Int
 	Hundred *big.Int
+
+	// MaxCoinAmount is the maximum amount of each type of coin, which keeps the amounts within
+	// the 256-bit range of the EVM
+	MaxCoinAmount *big.Int
+)
+
+var (
+	// ErrCoinOverflow for a coin amount exceeding MaxCoinAmount.
+	ErrCoinOverflow = errors.New("CoinOverflow")
+
+	// ErrCoinUnderflow for a negative coin amount.
+	ErrCoinUnderflow = errors.New("CoinUnderflow")
 )
 
 func init() {
 	Zero = big.NewInt(0)
 	Hundred = big.NewInt(100)
+	MaxCoinAmount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
 }
 
 type Coins struct {
@@ -90,6 +104,38 @@ func (coinsA Coins) Plus(coinsB Coins) Coins {
 	}
 }
 
+// CheckedPlus is the same as Plus, except that it returns ErrCoinOverflow if any amount of the
+// sum exceeds MaxCoinAmount, and ErrCoinUnderflow if any amount of the sum is negative
+func (coinsA Coins) CheckedPlus(coinsB Coins) (Coins, error) {
+	sum := coinsA.Plus(coinsB)
+	if err := sum.checkRange(); err != nil {
+		return Coins{}, err
+	}
+	return sum, nil
+}
+
+// CheckedMinus is the same as Minus, except that it returns ErrCoinUnderflow if any amount of the
+// difference is negative, and ErrCoinOverflow if any amount of the difference exceeds MaxCoinAmount
+func (coinsA Coins) CheckedMinus(coinsB Coins) (Coins, error) {
+	diff := coinsA.Minus(coinsB)
+	if err := diff.checkRange(); err != nil {
+		return Coins{}, err
+	}
+	return diff, nil
+}
+
+// checkRange checks that the amounts are within [0, MaxCoinAmount]
+func (coins Coins) checkRange() error {
+	c := coins.NoNil()
+	if c.ThetaWei.Sign() < 0 || c.GammaWei.Sign() < 0 {
+		return ErrCoinUnderflow
+	}
+	if c.ThetaWei.Cmp(MaxCoinAmount) > 0 || c.GammaWei.Cmp(MaxCoinAmount) > 0 {
+		return ErrCoinOverflow
+	}
+	return nil
+}
+
 func (coins Coins) Negative() Coins {
 	c := coins.NoNil()
 
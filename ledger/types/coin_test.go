@@ -26,6 +26,45 @@ func TestCoins(t *testing.T) {
 	assert.True(NewCoins(8, 25).IsEqual(a.Plus(b)))
 }
 
+func TestCoinsCheckedArithmetic(t *testing.T) {
+	assert := assert.New(t)
+
+	max := Coins{
+		ThetaWei: new(big.Int).Set(MaxCoinAmount),
+		GammaWei: new(big.Int).Set(MaxCoinAmount),
+	}
+	one := NewCoins(1, 1)
+
+	// CheckedPlus at the maximum amount
+	sum, err := max.Minus(one).CheckedPlus(one)
+	assert.Nil(err)
+	assert.True(sum.IsEqual(max))
+	_, err = max.CheckedPlus(one)
+	assert.Equal(ErrCoinOverflow, err)
+	_, err = max.CheckedPlus(NewCoins(0, 1))
+	assert.Equal(ErrCoinOverflow, err)
+	_, err = NewCoins(0, 0).CheckedPlus(NewCoins(-1, 0))
+	assert.Equal(ErrCoinUnderflow, err)
+
+	// CheckedMinus at zero
+	diff, err := one.CheckedMinus(one)
+	assert.Nil(err)
+	assert.True(diff.IsZero())
+	_, err = one.CheckedMinus(NewCoins(2, 1))
+	assert.Equal(ErrCoinUnderflow, err)
+	_, err = one.CheckedMinus(NewCoins(1, 2))
+	assert.Equal(ErrCoinUnderflow, err)
+	diff, err = max.CheckedMinus(max)
+	assert.Nil(err)
+	assert.True(diff.IsZero())
+	_, err = NewCoins(0, 0).CheckedMinus(max.Negative().Minus(one))
+	assert.Equal(ErrCoinOverflow, err)
+
+	// The operands are not modified
+	assert.Equal(MaxCoinAmount, max.ThetaWei)
+	assert.True(one.IsEqual(NewCoins(1, 1)))
+}
+
 //Test operations on invalid coins
 func TestInvalidCoin(t *testing.T) {
 	assert := assert.New(t)
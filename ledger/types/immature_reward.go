@@ -0,0 +1,17 @@
+package types
+
+import "fmt"
+
+// ImmatureReward is a coinbase reward of an account that cannot be spent until the ledger
+// reaches the maturity height
+type ImmatureReward struct {
+	MatureHeight uint64
+	Coins        Coins
+}
+
+func (ir *ImmatureReward) String() string {
+	if ir == nil {
+		return "nil-ImmatureReward"
+	}
+	return fmt.Sprintf("ImmatureReward{%v %v}", ir.MatureHeight, ir.Coins)
+}
@@ -0,0 +1,74 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/crypto"
+)
+
+// MultisigPolicy requires the spends of an account to be signed by at least Threshold of the
+// authorized public keys
+type MultisigPolicy struct {
+	Threshold uint64
+	PubKeys   []*crypto.PublicKey
+}
+
+// ValidateBasic checks that the threshold can be satisfied by distinct authorized keys
+func (mp *MultisigPolicy) ValidateBasic() result.Result {
+	if mp.Threshold == 0 || mp.Threshold > uint64(len(mp.PubKeys)) {
+		return result.Error("Invalid multisig threshold %v for %v public keys", mp.Threshold, len(mp.PubKeys))
+	}
+	authorized := make(map[common.Address]bool)
+	for _, pubKey := range mp.PubKeys {
+		if pubKey == nil || pubKey.IsEmpty() {
+			return result.Error("Multisig public key cannot be nil or empty")
+		}
+		if authorized[pubKey.Address()] {
+			return result.Error("Duplicated multisig public key: %v", pubKey.Address())
+		}
+		authorized[pubKey.Address()] = true
+	}
+	return result.OK
+}
+
+// VerifySignatures checks that the signatures are made by at least Threshold distinct authorized
+// keys. The order of the signatures does not matter, but each key can only sign once.
+func (mp *MultisigPolicy) VerifySignatures(signBytes common.Bytes, sigs []*crypto.Signature) result.Result {
+	authorized := make(map[common.Address]bool)
+	for _, pubKey := range mp.PubKeys {
+		authorized[pubKey.Address()] = true
+	}
+
+	signed := make(map[common.Address]bool)
+	for _, sig := range sigs {
+		if sig == nil || sig.IsEmpty() {
+			return result.Error("Multisig signature cannot be nil or empty").
+				WithErrorCode(result.CodeInvalidSignature)
+		}
+		signer, err := sig.RecoverSignerAddress(signBytes)
+		if err != nil || !authorized[signer] {
+			return result.Error("Multisig signature is not made by an authorized key").
+				WithErrorCode(result.CodeInvalidSignature)
+		}
+		if signed[signer] {
+			return result.Error("Duplicated multisig signer: %v", signer).
+				WithErrorCode(result.CodeInvalidSignature)
+		}
+		signed[signer] = true
+	}
+
+	if uint64(len(signed)) < mp.Threshold {
+		return result.Error("Got %v multisig signatures, expected at least %v", len(signed), mp.Threshold).
+			WithErrorCode(result.CodeInvalidSignature)
+	}
+	return result.OK
+}
+
+func (mp *MultisigPolicy) String() string {
+	if mp == nil {
+		return "nil-MultisigPolicy"
+	}
+	return fmt.Sprintf("MultisigPolicy{%v-of-%v}", mp.Threshold, len(mp.PubKeys))
+}
@@ -32,6 +32,9 @@ const (
 	TxSplitRule
 	TxUpdateValidators
 	TxSmartContract
+	TxMultiSend
+	TxParamUpdate
+	TxFreezeAccount
 )
 
 func TxFromBytes(raw []byte) (Tx, error) {
@@ -77,6 +80,18 @@ func TxFromBytes(raw []byte) (Tx, error) {
 		data := &SmartContractTx{}
 		err = rlp.Decode(buff, data)
 		return data, err
+	} else if txType == TxMultiSend {
+		data := &MultiSendTx{}
+		err = rlp.Decode(buff, data)
+		return data, err
+	} else if txType == TxParamUpdate {
+		data := &ParamUpdateTx{}
+		err = rlp.Decode(buff, data)
+		return data, err
+	} else if txType == TxFreezeAccount {
+		data := &FreezeAccountTx{}
+		err = rlp.Decode(buff, data)
+		return data, err
 	} else {
 		return nil, fmt.Errorf("Unknown TX type: %v", txType)
 	}
@@ -104,6 +119,12 @@ func TxToBytes(t Tx) ([]byte, error) {
 		txType = TxUpdateValidators
 	case *SmartContractTx:
 		txType = TxSmartContract
+	case *MultiSendTx:
+		txType = TxMultiSend
+	case *ParamUpdateTx:
+		txType = TxParamUpdate
+	case *FreezeAccountTx:
+		txType = TxFreezeAccount
 	default:
 		return nil, errors.New("Unsupported message type")
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/core"
 	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/rlp"
 )
@@ -165,3 +166,43 @@ func getTestAddress(addr string) common.Address {
 	copy(address[:], addr)
 	return address
 }
+
+func TestCoinbaseTxWithoutProposerProof(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// The coinbase transactions encoded before the proposer proof was introduced
+	type oldCoinbaseTx struct {
+		Proposer    TxInput
+		Outputs     []TxOutput
+		BlockHeight uint64
+	}
+	_, pubKey, _ := crypto.GenerateKeyPair()
+	sig, _ := crypto.SignatureFromBytes([]byte("i am signature"))
+	oldTx := &oldCoinbaseTx{
+		Proposer: TxInput{
+			Address:   getTestAddress("123"),
+			PubKey:    pubKey,
+			Signature: sig,
+		},
+		Outputs:     []TxOutput{{Address: getTestAddress("456")}},
+		BlockHeight: uint64(999),
+	}
+	b, err := rlp.EncodeToBytes(TxCoinbase)
+	require.Nil(err)
+	txBytes, err := rlp.EncodeToBytes(oldTx)
+	require.Nil(err)
+	b = append(b, txBytes...)
+
+	tx, err := TxFromBytes(b)
+	require.Nil(err)
+	coinbaseTx, ok := tx.(*CoinbaseTx)
+	require.True(ok)
+	assert.Equal(uint64(999), coinbaseTx.BlockHeight)
+	assert.Equal(core.ProposerProof{}, coinbaseTx.ProposerProof)
+
+	// Re-encoded to the same bytes, so the signatures of the older transactions still verify
+	b2, err := TxToBytes(coinbaseTx)
+	require.Nil(err)
+	assert.Equal(b, b2)
+}
@@ -3,7 +3,9 @@ package types
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/thetatoken/ukulele/common"
@@ -46,6 +48,110 @@ func TxID(chainID string, tx Tx) common.Hash {
 	return crypto.Keccak256Hash(signBytes)
 }
 
+// GetSenderInput returns the input of the account whose sequence is consumed by the transaction
+func GetSenderInput(tx Tx) (TxInput, bool) {
+	switch tx := tx.(type) {
+	case *SendTx:
+		if len(tx.Inputs) == 0 {
+			return TxInput{}, false
+		}
+		return tx.Inputs[0], true
+	case *MultiSendTx:
+		return tx.Input, true
+	case *ReserveFundTx:
+		return tx.Source, true
+	case *ReleaseFundTx:
+		return tx.Source, true
+	case *ServicePaymentTx:
+		return tx.Target, true
+	case *SplitRuleTx:
+		return tx.Initiator, true
+	case *SmartContractTx:
+		return tx.From, true
+	case *ParamUpdateTx:
+		return tx.Proposer, true
+	case *FreezeAccountTx:
+		return tx.Proposer, true
+	default:
+		return TxInput{}, false
+	}
+}
+
+// SignedInput is an input of a transaction, together with the bytes signed by the input
+type SignedInput struct {
+	Input     TxInput
+	SignBytes []byte
+}
+
+// GetSignedInputs returns the signed inputs of the transaction, which is the single definition of
+// the bytes signed by the inputs of each transaction type
+func GetSignedInputs(chainID string, tx Tx) []SignedInput {
+	switch tx := tx.(type) {
+	case *CoinbaseTx:
+		return []SignedInput{{tx.Proposer, tx.SignBytes(chainID)}}
+	case *SlashTx:
+		return []SignedInput{{tx.Proposer, tx.SignBytes(chainID)}}
+	case *SendTx:
+		signBytes := tx.SignBytes(chainID)
+		inputs := tx.AllInputs()
+		signedInputs := make([]SignedInput, len(inputs))
+		for i, input := range inputs {
+			signedInputs[i] = SignedInput{input, signBytes}
+		}
+		return signedInputs
+	case *MultiSendTx:
+		return []SignedInput{{tx.Input, tx.SignBytes(chainID)}}
+	case *ReserveFundTx:
+		return []SignedInput{{tx.Source, tx.SignBytes(chainID)}}
+	case *ReleaseFundTx:
+		return []SignedInput{{tx.Source, tx.SignBytes(chainID)}}
+	case *ServicePaymentTx:
+		return []SignedInput{
+			{tx.Source, tx.SourceSignBytes(chainID)},
+			{tx.Target, tx.TargetSignBytes(chainID)},
+		}
+	case *SplitRuleTx:
+		return []SignedInput{{tx.Initiator, tx.SignBytes(chainID)}}
+	case *UpdateValidatorsTx:
+		return []SignedInput{{tx.Proposer, tx.SignBytes(chainID)}}
+	case *SmartContractTx:
+		return []SignedInput{{tx.From, tx.SignBytes(chainID)}}
+	case *ParamUpdateTx:
+		return []SignedInput{{tx.Proposer, tx.SignBytes(chainID)}}
+	case *FreezeAccountTx:
+		return []SignedInput{{tx.Proposer, tx.SignBytes(chainID)}}
+	default:
+		return []SignedInput{}
+	}
+}
+
+// GetValidUntilHeight returns the block height after which the transaction expires, or 0 if
+// the transaction does not expire
+func GetValidUntilHeight(tx Tx) uint64 {
+	switch tx := tx.(type) {
+	case *SendTx:
+		return tx.ValidUntilHeight
+	case *MultiSendTx:
+		return tx.ValidUntilHeight
+	case *ReserveFundTx:
+		return tx.ValidUntilHeight
+	case *ReleaseFundTx:
+		return tx.ValidUntilHeight
+	case *ServicePaymentTx:
+		return tx.ValidUntilHeight
+	case *SplitRuleTx:
+		return tx.ValidUntilHeight
+	case *SmartContractTx:
+		return tx.ValidUntilHeight
+	case *ParamUpdateTx:
+		return tx.ValidUntilHeight
+	case *FreezeAccountTx:
+		return tx.ValidUntilHeight
+	default:
+		return 0
+	}
+}
+
 //--------------------------------------------------------------------------------
 
 // Contract: This function is deterministic and completely reversible.
@@ -73,6 +179,28 @@ type TxInput struct {
 	Sequence  uint64            `json:"sequence"`  // Must be 1 greater than the last committed TxInput
 	Signature *crypto.Signature `json:"signature"` // Depends on the PubKey type and the whole Tx
 	PubKey    *crypto.PublicKey `json:"pub_key"`   // Is present iff Sequence == 0
+
+	// Signatures of the authorized keys of a multisig account, in any order. The field is
+	// omitted from the RLP encoding when empty, so single-signature inputs encode as before.
+	Signatures []*crypto.Signature `json:"signatures,omitempty" rlp:"tail"`
+}
+
+// DecodeRLP implements RLP Decoder interface. An input without multisig signatures decodes
+// with nil Signatures, same as before it was encoded.
+func (txIn *TxInput) DecodeRLP(stream *rlp.Stream) error {
+	type rawTxInput TxInput // prevents the recursion into DecodeRLP
+	if err := stream.Decode((*rawTxInput)(txIn)); err != nil {
+		return err
+	}
+	if len(txIn.Signatures) == 0 {
+		txIn.Signatures = nil
+	}
+	return nil
+}
+
+// IsMultisig indicates whether the input is authorized by multiple signatures
+func (txIn TxInput) IsMultisig() bool {
+	return len(txIn.Signatures) > 0
 }
 
 func (txIn TxInput) ValidateBasic() result.Result {
@@ -93,12 +221,26 @@ func (txIn TxInput) ValidateBasic() result.Result {
 	// if txIn.Sequence <= 0 {
 	// 	return result.Error("Sequence must be greater than 0")
 	// }
+	if txIn.IsMultisig() {
+		// The authorized keys are stored in the multisig policy of the account
+		if !(txIn.PubKey == nil || txIn.PubKey.IsEmpty()) {
+			return result.Error("PubKey must be nil for a multisig input")
+		}
+		if !(txIn.Signature == nil || txIn.Signature.IsEmpty()) {
+			return result.Error("Signature must be nil for a multisig input")
+		}
+		return result.OK
+	}
 	if txIn.Sequence == 1 && (txIn.PubKey == nil || txIn.PubKey.IsEmpty()) {
 		return result.Error("PubKey must be present when Sequence == 1")
 	}
 	if txIn.Sequence > 1 && !(txIn.PubKey == nil || txIn.PubKey.IsEmpty()) {
 		return result.Error("PubKey must be nil when Sequence > 1")
 	}
+	if txIn.Signature != nil && !crypto.IsSupportedSignatureScheme(txIn.Signature.Scheme()) {
+		return result.Error("Unsupported signature scheme: %v", txIn.Signature.Scheme()).
+			WithErrorCode(result.CodeInvalidSignature)
+	}
 	return result.OK
 }
 
@@ -106,6 +248,14 @@ func (txIn TxInput) String() string {
 	return fmt.Sprintf("TxInput{%v,%v,%v,%v,%v}", txIn.Address.Hex(), txIn.Coins, txIn.Sequence, txIn.Signature, txIn.PubKey)
 }
 
+// SequenceGap is attached to the results with CodeInvalidSequence. It reports the sequence the
+// account expects, so clients can correct the sequence of their transactions.
+type SequenceGap struct {
+	Address          common.Address `json:"address"`
+	Sequence         uint64         `json:"sequence"`          // sequence of the transaction input
+	ExpectedSequence uint64         `json:"expected_sequence"` // sequence the account expects next
+}
+
 func NewTxInput(pubKey *crypto.PublicKey, coins Coins, sequence int) TxInput {
 	input := TxInput{
 		Address:  pubKey.Address(),
@@ -146,9 +296,10 @@ func (txOut TxOutput) String() string {
 //-----------------------------------------------------------------------------
 
 type CoinbaseTx struct {
-	Proposer    TxInput    `json:"proposer"`
-	Outputs     []TxOutput `json:"outputs"`
-	BlockHeight uint64     `json:"block_height"`
+	Proposer      TxInput            `json:"proposer"`
+	Outputs       []TxOutput         `json:"outputs"`
+	BlockHeight   uint64             `json:"block_height"`
+	ProposerProof core.ProposerProof `json:"proposer_proof" rlp:"optional"` // proves the proposer was selected for the epoch, missing from the older txs
 }
 
 func (_ *CoinbaseTx) AssertIsTx() {}
@@ -216,22 +367,87 @@ type SendTx struct {
 	Fee     Coins      `json:"fee"` // Fee
 	Inputs  []TxInput  `json:"inputs"`
 	Outputs []TxOutput `json:"outputs"`
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty"` // The transaction expires after this block height (0 means never)
+
+	// FeePayer optionally pays the fee on behalf of the senders, in which case the inputs only
+	// cover the outputs. Its coins must be equal to the fee.
+	FeePayer *TxInput `json:"fee_payer,omitempty"`
+}
+
+// sendTxRLP is the RLP representation of SendTx. The expiry height and the fee payer are
+// omitted from the encoding when absent, so the transactions without them encode as before.
+type sendTxRLP struct {
+	Fee              Coins
+	Inputs           []TxInput
+	Outputs          []TxOutput
+	ValidUntilHeight uint64    `rlp:"optional"`
+	FeePayer         []TxInput `rlp:"tail"`
+}
+
+// EncodeRLP implements RLP Encoder interface.
+func (tx *SendTx) EncodeRLP(w io.Writer) error {
+	raw := sendTxRLP{
+		Fee:              tx.Fee,
+		Inputs:           tx.Inputs,
+		Outputs:          tx.Outputs,
+		ValidUntilHeight: tx.ValidUntilHeight,
+	}
+	if tx.FeePayer != nil {
+		raw.FeePayer = []TxInput{*tx.FeePayer}
+	}
+	return rlp.Encode(w, &raw)
+}
+
+// DecodeRLP implements RLP Decoder interface.
+func (tx *SendTx) DecodeRLP(stream *rlp.Stream) error {
+	raw := sendTxRLP{}
+	if err := stream.Decode(&raw); err != nil {
+		return err
+	}
+	if len(raw.FeePayer) > 1 {
+		return errors.New("SendTx can have at most one fee payer")
+	}
+	tx.Fee, tx.Inputs, tx.Outputs, tx.FeePayer = raw.Fee, raw.Inputs, raw.Outputs, nil
+	tx.ValidUntilHeight = raw.ValidUntilHeight
+	if len(raw.FeePayer) == 1 {
+		tx.FeePayer = &raw.FeePayer[0]
+	}
+	return nil
 }
 
 func (_ *SendTx) AssertIsTx() {}
 
+// AllInputs returns the inputs of the transaction followed by the fee payer, if any
+func (tx *SendTx) AllInputs() []TxInput {
+	if tx.FeePayer == nil {
+		return tx.Inputs
+	}
+	inputs := make([]TxInput, 0, len(tx.Inputs)+1)
+	inputs = append(inputs, tx.Inputs...)
+	return append(inputs, *tx.FeePayer)
+}
+
 func (tx *SendTx) SignBytes(chainID string) []byte {
 	signBytes := encodeToBytes(chainID)
 	sigz := make([]*crypto.Signature, len(tx.Inputs))
+	multisigz := make([][]*crypto.Signature, len(tx.Inputs))
 	for i := range tx.Inputs {
-		sigz[i] = tx.Inputs[i].Signature
-		tx.Inputs[i].Signature = nil
+		sigz[i], multisigz[i] = tx.Inputs[i].Signature, tx.Inputs[i].Signatures
+		tx.Inputs[i].Signature, tx.Inputs[i].Signatures = nil, nil
+	}
+	feePayer := tx.FeePayer
+	if feePayer != nil {
+		unsignedFeePayer := *feePayer
+		unsignedFeePayer.Signature, unsignedFeePayer.Signatures = nil, nil
+		tx.FeePayer = &unsignedFeePayer
 	}
 	txBytes, _ := TxToBytes(tx)
 	signBytes = append(signBytes, txBytes...)
 	for i := range tx.Inputs {
-		tx.Inputs[i].Signature = sigz[i]
+		tx.Inputs[i].Signature, tx.Inputs[i].Signatures = sigz[i], multisigz[i]
 	}
+	tx.FeePayer = feePayer
 	return signBytes
 }
 
@@ -242,32 +458,76 @@ func (tx *SendTx) SetSignature(addr common.Address, sig *crypto.Signature) bool
 			return true
 		}
 	}
+	if tx.FeePayer != nil && tx.FeePayer.Address == addr {
+		tx.FeePayer.Signature = sig
+		return true
+	}
 	return false
 }
 
 func (tx *SendTx) String() string {
+	if tx.FeePayer != nil {
+		return fmt.Sprintf("SendTx{fee: %v, %v->%v, fee_payer: %v}", tx.Inputs, tx.Outputs, tx.Fee, tx.FeePayer)
+	}
 	return fmt.Sprintf("SendTx{fee: %v, %v->%v}", tx.Inputs, tx.Outputs, tx.Fee)
 }
 
 //-----------------------------------------------------------------------------
 
+// MultiSendTx transfers coins from a single input to multiple outputs, e.g. for batch payouts
+type MultiSendTx struct {
+	Fee     Coins      `json:"fee"` // Fee
+	Input   TxInput    `json:"input"`
+	Outputs []TxOutput `json:"outputs"`
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
+}
+
+func (_ *MultiSendTx) AssertIsTx() {}
+
+func (tx *MultiSendTx) SignBytes(chainID string) []byte {
+	signBytes := encodeToBytes(chainID)
+	sig, sigs := tx.Input.Signature, tx.Input.Signatures
+	tx.Input.Signature, tx.Input.Signatures = nil, nil
+	txBytes, _ := TxToBytes(tx)
+	signBytes = append(signBytes, txBytes...)
+	tx.Input.Signature, tx.Input.Signatures = sig, sigs
+	return signBytes
+}
+
+func (tx *MultiSendTx) SetSignature(addr common.Address, sig *crypto.Signature) bool {
+	if tx.Input.Address == addr {
+		tx.Input.Signature = sig
+		return true
+	}
+	return false
+}
+
+func (tx *MultiSendTx) String() string {
+	return fmt.Sprintf("MultiSendTx{fee: %v, %v->%v}", tx.Fee, tx.Input, tx.Outputs)
+}
+
+//-----------------------------------------------------------------------------
+
 type ReserveFundTx struct {
 	Fee         Coins    `json:"fee"`          // Fee
 	Source      TxInput  `json:"source"`       // Source account
 	Collateral  Coins    `json:"collateral"`   // Collateral for the micropayment pool
 	ResourceIDs []string `json:"resource_ids"` // List of resource ID
 	Duration    uint64   `json:"duration"`
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
 }
 
 func (_ *ReserveFundTx) AssertIsTx() {}
 
 func (tx *ReserveFundTx) SignBytes(chainID string) []byte {
 	signBytes := encodeToBytes(chainID)
-	sig := tx.Source.Signature
-	tx.Source.Signature = nil
+	sig, sigs := tx.Source.Signature, tx.Source.Signatures
+	tx.Source.Signature, tx.Source.Signatures = nil, nil
 	txBytes, _ := TxToBytes(tx)
 	signBytes = append(signBytes, txBytes...)
-	tx.Source.Signature = sig
+	tx.Source.Signature, tx.Source.Signatures = sig, sigs
 	return signBytes
 }
 
@@ -290,17 +550,19 @@ type ReleaseFundTx struct {
 	Fee             Coins   `json:"fee"`    // Fee
 	Source          TxInput `json:"source"` // source account
 	ReserveSequence uint64  `json:"reserve_sequence"`
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
 }
 
 func (_ *ReleaseFundTx) AssertIsTx() {}
 
 func (tx *ReleaseFundTx) SignBytes(chainID string) []byte {
 	signBytes := encodeToBytes(chainID)
-	sig := tx.Source.Signature
-	tx.Source.Signature = nil
+	sig, sigs := tx.Source.Signature, tx.Source.Signatures
+	tx.Source.Signature, tx.Source.Signatures = nil, nil
 	txBytes, _ := TxToBytes(tx)
 	signBytes = append(signBytes, txBytes...)
-	tx.Source.Signature = sig
+	tx.Source.Signature, tx.Source.Signatures = sig, sigs
 	return signBytes
 }
 
@@ -325,6 +587,8 @@ type ServicePaymentTx struct {
 	PaymentSequence uint64  `json:"payment_sequence"` // each on-chain settlement needs to increase the payment sequence by 1
 	ReserveSequence uint64  `json:"reserve_sequence"` // ReserveSequence to locate the ReservedFund
 	ResourceID      string  `json:"resource_id"`      // The corresponding resourceID
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
 }
 
 func (_ *ServicePaymentTx) AssertIsTx() {}
@@ -335,10 +599,12 @@ func (tx *ServicePaymentTx) SourceSignBytes(chainID string) []byte {
 	source := tx.Source
 	target := tx.Target
 	fee := tx.Fee
+	validUntilHeight := tx.ValidUntilHeight // chosen by the target when submitting the transaction
 
 	tx.Source = TxInput{Address: source.Address, Coins: source.Coins}
 	tx.Target = TxInput{Address: target.Address}
 	tx.Fee = NewCoins(0, 0)
+	tx.ValidUntilHeight = 0
 
 	txBytes, _ := TxToBytes(tx)
 	signBytes = append(signBytes, txBytes...)
@@ -346,6 +612,7 @@ func (tx *ServicePaymentTx) SourceSignBytes(chainID string) []byte {
 	tx.Source = source
 	tx.Target = target
 	tx.Fee = fee
+	tx.ValidUntilHeight = validUntilHeight
 
 	return signBytes
 }
@@ -355,7 +622,6 @@ func (tx *ServicePaymentTx) SetSourceSignature(sig *crypto.Signature) {
 }
 
 func (tx *ServicePaymentTx) TargetSignBytes(chainID string) []byte {
-	// TODO: remove chainID from all Tx sign bytes.
 	signBytes := encodeToBytes(chainID)
 	targetSig := tx.Target.Signature
 
@@ -399,17 +665,19 @@ type SplitRuleTx struct {
 	Initiator  TxInput `json:"initiator"`   // Initiator of the split rule
 	Splits     []Split `json:"splits"`      // Agreed splits
 	Duration   uint64  `json:"duration"`    // Duration of the payment split in terms of blocks
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
 }
 
 func (_ *SplitRuleTx) AssertIsTx() {}
 
 func (tx *SplitRuleTx) SignBytes(chainID string) []byte {
 	signBytes := encodeToBytes(chainID)
-	sig := tx.Initiator.Signature
-	tx.Initiator.Signature = nil
+	sig, sigs := tx.Initiator.Signature, tx.Initiator.Signatures
+	tx.Initiator.Signature, tx.Initiator.Signatures = nil, nil
 	txBytes, _ := TxToBytes(tx)
 	signBytes = append(signBytes, txBytes...)
-	tx.Initiator.Signature = sig
+	tx.Initiator.Signature, tx.Initiator.Signatures = sig, sigs
 	return signBytes
 }
 
@@ -429,21 +697,20 @@ func (tx *SplitRuleTx) String() string {
 //-----------------------------------------------------------------------------
 
 type UpdateValidatorsTx struct {
-	Fee        Coins             `json:"fee"`        // Fee
-	Validators []*core.Validator `json:"validators"` // validators diff
-	Proposer   TxInput           `json:"source"`     // source account
+	Fee        Coins            `json:"fee"`        // Fee
+	Validators []ValidatorStake `json:"validators"` // validators diff, a zero stake removes the validator
+	Proposer   TxInput          `json:"source"`     // source account
 }
 
 func (_ *UpdateValidatorsTx) AssertIsTx() {}
 
 func (tx *UpdateValidatorsTx) SignBytes(chainID string) []byte {
 	signBytes := encodeToBytes(chainID)
-	for _, v := range tx.Validators {
-		bytes, err := rlp.EncodeToBytes(v)
-		if err != nil {
-			signBytes = append(signBytes, bytes...)
-		}
-	}
+	sig, sigs := tx.Proposer.Signature, tx.Proposer.Signatures
+	tx.Proposer.Signature, tx.Proposer.Signatures = nil, nil
+	txBytes, _ := TxToBytes(tx)
+	signBytes = append(signBytes, txBytes...)
+	tx.Proposer.Signature, tx.Proposer.Signatures = sig, sigs
 	return signBytes
 }
 
@@ -467,17 +734,19 @@ type SmartContractTx struct {
 	GasLimit uint64       `json:"gas_limit"`
 	GasPrice *big.Int     `json:"gas_price"`
 	Data     common.Bytes `json:"data"`
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
 }
 
 func (_ *SmartContractTx) AssertIsTx() {}
 
 func (tx *SmartContractTx) SignBytes(chainID string) []byte {
 	signBytes := encodeToBytes(chainID)
-	sig := tx.From.Signature
-	tx.From.Signature = nil
+	sig, sigs := tx.From.Signature, tx.From.Signatures
+	tx.From.Signature, tx.From.Signatures = nil, nil
 	txBytes, _ := TxToBytes(tx)
 	signBytes = append(signBytes, txBytes...)
-	tx.From.Signature = sig
+	tx.From.Signature, tx.From.Signatures = sig, sigs
 	return signBytes
 }
 
@@ -493,3 +762,76 @@ func (tx *SmartContractTx) String() string {
 	return fmt.Sprintf("SmartContractTx{%v -> %v, value: %v, gas_limit: %v, gas_price: %v, data: %v}",
 		tx.From.Address.Hex(), tx.To.Address.Hex(), tx.From.Coins.GammaWei, tx.GasLimit, tx.GasPrice, tx.Data)
 }
+
+//-----------------------------------------------------------------------------
+
+// ParamUpdateTx updates the chain parameters. It has to be signed by the governance account.
+type ParamUpdateTx struct {
+	Fee      Coins         `json:"fee"`      // Fee
+	Proposer TxInput       `json:"proposer"` // governance account
+	Updates  []ParamUpdate `json:"updates"`  // chain parameters to update
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
+}
+
+func (_ *ParamUpdateTx) AssertIsTx() {}
+
+func (tx *ParamUpdateTx) SignBytes(chainID string) []byte {
+	signBytes := encodeToBytes(chainID)
+	sig, sigs := tx.Proposer.Signature, tx.Proposer.Signatures
+	tx.Proposer.Signature, tx.Proposer.Signatures = nil, nil
+	txBytes, _ := TxToBytes(tx)
+	signBytes = append(signBytes, txBytes...)
+	tx.Proposer.Signature, tx.Proposer.Signatures = sig, sigs
+	return signBytes
+}
+
+func (tx *ParamUpdateTx) SetSignature(addr common.Address, sig *crypto.Signature) bool {
+	if tx.Proposer.Address == addr {
+		tx.Proposer.Signature = sig
+		return true
+	}
+	return false
+}
+
+func (tx *ParamUpdateTx) String() string {
+	return fmt.Sprintf("ParamUpdateTx{fee: %v, proposer: %v, updates: %v}", tx.Fee, tx.Proposer, tx.Updates)
+}
+
+//-----------------------------------------------------------------------------
+
+// FreezeAccountTx freezes or unfreezes an account. No transaction can spend from a frozen account,
+// while it can still receive coins. It has to be signed by the governance account.
+type FreezeAccountTx struct {
+	Fee      Coins          `json:"fee"`      // Fee
+	Proposer TxInput        `json:"proposer"` // governance account
+	Address  common.Address `json:"address"`  // account to freeze or unfreeze
+	Frozen   bool           `json:"frozen"`   // true to freeze, false to unfreeze
+
+	ValidUntilHeight uint64 `json:"valid_until_height,omitempty" rlp:"optional"` // The transaction expires after this block height (0 means never)
+}
+
+func (_ *FreezeAccountTx) AssertIsTx() {}
+
+func (tx *FreezeAccountTx) SignBytes(chainID string) []byte {
+	signBytes := encodeToBytes(chainID)
+	sig, sigs := tx.Proposer.Signature, tx.Proposer.Signatures
+	tx.Proposer.Signature, tx.Proposer.Signatures = nil, nil
+	txBytes, _ := TxToBytes(tx)
+	signBytes = append(signBytes, txBytes...)
+	tx.Proposer.Signature, tx.Proposer.Signatures = sig, sigs
+	return signBytes
+}
+
+func (tx *FreezeAccountTx) SetSignature(addr common.Address, sig *crypto.Signature) bool {
+	if tx.Proposer.Address == addr {
+		tx.Proposer.Signature = sig
+		return true
+	}
+	return false
+}
+
+func (tx *FreezeAccountTx) String() string {
+	return fmt.Sprintf("FreezeAccountTx{fee: %v, proposer: %v, address: %v, frozen: %v}",
+		tx.Fee, tx.Proposer, tx.Address.Hex(), tx.Frozen)
+}
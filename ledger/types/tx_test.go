@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -8,7 +9,6 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/thetatoken/ukulele/core"
 )
 
 var chainID string = "test_chain"
@@ -301,6 +301,46 @@ func TestSendTxProto(t *testing.T) {
 	assert.False(tx2.Inputs[0].Signature.IsEmpty())
 }
 
+func TestSendTxValidUntilHeight(t *testing.T) {
+	assert, require := assert.New(t), require.New(t)
+
+	chainID := "test_chain_id"
+	test1PrivAcc := PrivAccountFromSecret("sendtx1")
+	test2PrivAcc := PrivAccountFromSecret("sendtx2")
+
+	tx := &SendTx{
+		Fee: Coins{GammaWei: big.NewInt(2)},
+		Inputs: []TxInput{
+			NewTxInput(test1PrivAcc.PrivKey.PublicKey(), Coins{ThetaWei: big.NewInt(0), GammaWei: big.NewInt(10)}, 1),
+		},
+		Outputs: []TxOutput{
+			TxOutput{
+				Address: test2PrivAcc.PrivKey.PublicKey().Address(),
+				Coins:   Coins{ThetaWei: big.NewInt(0), GammaWei: big.NewInt(8)},
+			},
+		},
+	}
+	b, err := TxToBytes(tx)
+	require.Nil(err)
+	signBytes := tx.SignBytes(chainID)
+
+	// The field is preserved and covered by the signature
+	tx.ValidUntilHeight = 100
+	b2, err := TxToBytes(tx)
+	require.Nil(err)
+	assert.NotEqual(b, b2)
+	assert.NotEqual(signBytes, tx.SignBytes(chainID))
+
+	txs, err := TxFromBytes(b2)
+	require.Nil(err)
+	assert.Equal(uint64(100), GetValidUntilHeight(txs))
+
+	// Transactions without the field decode as never expiring
+	txs, err = TxFromBytes(b)
+	require.Nil(err)
+	assert.Equal(uint64(0), GetValidUntilHeight(txs))
+}
+
 func TestReserveFundTxSignable(t *testing.T) {
 	reserveFundTx := &ReserveFundTx{
 		Fee: Coins{ThetaWei: Zero, GammaWei: big.NewInt(111)},
@@ -588,7 +628,8 @@ func TestSplitRuleTxProto(t *testing.T) {
 
 func TestUpdateValidatorsTxSignable(t *testing.T) {
 	updateValidatorsTx := &UpdateValidatorsTx{
-		Validators: []*core.Validator{},
+		Fee:        Coins{ThetaWei: Zero, GammaWei: big.NewInt(111)},
+		Validators: []ValidatorStake{{PubKey: []byte("validator2"), Stake: 100}},
 		Proposer: TxInput{
 			Address:  getTestAddress("validator1"),
 			Coins:    Coins{ThetaWei: Zero, GammaWei: big.NewInt(12345)},
@@ -598,7 +639,7 @@ func TestUpdateValidatorsTxSignable(t *testing.T) {
 
 	signBytes := updateValidatorsTx.SignBytes(chainID)
 	signBytesHex := fmt.Sprintf("%X", signBytes)
-	expected := "8A746573745F636861696E"
+	expected := "8A746573745F636861696E07F2C2806FCDCC8A76616C696461746F723264E09476616C696461746F723100000000000000000000C480823039830109328080"
 
 	assert.Equal(t, expected, signBytesHex,
 		"Got unexpected sign string for UpdateValidatorsTx. Expected:\n%v\nGot:\n%v", expected, signBytesHex)
@@ -610,19 +651,9 @@ func TestUpdateValidatorsTxProto(t *testing.T) {
 	chainID := "test_chain_id"
 	test1PrivAcc := PrivAccountFromSecret("updatevalidatorstx")
 
-	// Construct a UpdateValidatorsTx signature
-	// idBytes, err := hex.DecodeString("id123")
-	// if err != nil {
-	// 	panic(fmt.Sprintf("Unable to decode public key: %v", id))
-	// }
-	// va := core.NewValidator(idBytes, uint64(100))
-	// tx := &UpdateValidatorsTx{
-	// 	Validators: []*core.Validator{&va},
-	// 	Proposer:   NewTxInput(test1PrivAcc.PrivKey.PublicKey(), Coins{{"", 10}}, 1),
-	// }
-
 	tx := &UpdateValidatorsTx{
-		Proposer: NewTxInput(test1PrivAcc.PrivKey.PublicKey(), Coins{ThetaWei: Zero, GammaWei: big.NewInt(10)}, 1),
+		Validators: []ValidatorStake{{PubKey: []byte("validator2"), Stake: 100}},
+		Proposer:   NewTxInput(test1PrivAcc.PrivKey.PublicKey(), Coins{ThetaWei: Zero, GammaWei: big.NewInt(10)}, 1),
 	}
 
 	// serialize this and back
@@ -632,8 +663,7 @@ func TestUpdateValidatorsTxProto(t *testing.T) {
 	require.Nil(err)
 	tx2 := txs.(*UpdateValidatorsTx)
 
-	fmt.Printf(">>> tx.Validators:  %v\n", tx.Validators)
-	fmt.Printf(">>> tx2.Validators: %v\n", tx2.Validators)
+	assert.Equal(tx.Validators, tx2.Validators)
 
 	// make sure they are the same!
 	signBytes := tx.SignBytes(chainID)
@@ -657,3 +687,128 @@ func TestUpdateValidatorsTxProto(t *testing.T) {
 	assert.Equal(tx.Proposer.Signature, tx2.Proposer.Signature)
 	assert.False(tx2.Proposer.Signature.IsEmpty())
 }
+
+func TestTxSignBytesIncludeChainID(t *testing.T) {
+	assert := assert.New(t)
+
+	input := TxInput{
+		Address:  getTestAddress("input"),
+		Coins:    NewCoins(1, 2),
+		Sequence: 1,
+	}
+	output := TxOutput{
+		Address: getTestAddress("output"),
+		Coins:   NewCoins(1, 2),
+	}
+	fee := NewCoins(0, 3)
+
+	txs := []Tx{
+		&CoinbaseTx{Proposer: input, Outputs: []TxOutput{output}, BlockHeight: 10},
+		&SlashTx{Proposer: input, SlashedAddress: getTestAddress("slashed"), ReserveSequence: 1},
+		&SendTx{Fee: fee, Inputs: []TxInput{input}, Outputs: []TxOutput{output}},
+		&ReserveFundTx{Fee: fee, Source: input, Collateral: NewCoins(0, 1), ResourceIDs: []string{"rid"}, Duration: 100},
+		&ReleaseFundTx{Fee: fee, Source: input, ReserveSequence: 1},
+		&SplitRuleTx{Fee: fee, ResourceID: "rid", Initiator: input, Duration: 100},
+		&UpdateValidatorsTx{Fee: fee, Validators: []ValidatorStake{{PubKey: []byte("validator"), Stake: 1}}, Proposer: input},
+		&SmartContractTx{From: input, To: output, GasLimit: 100, GasPrice: big.NewInt(1), Data: []byte{0x1}},
+	}
+	for _, tx := range txs {
+		assert.True(bytes.HasPrefix(tx.SignBytes("main"), encodeToBytes("main")), "%v", tx)
+		assert.NotEqual(tx.SignBytes("main"), tx.SignBytes("test"), "%v", tx)
+	}
+
+	servicePaymentTx := &ServicePaymentTx{Fee: fee, Source: input, Target: input, PaymentSequence: 1, ReserveSequence: 1, ResourceID: "rid"}
+	assert.NotEqual(servicePaymentTx.SourceSignBytes("main"), servicePaymentTx.SourceSignBytes("test"))
+	assert.NotEqual(servicePaymentTx.TargetSignBytes("main"), servicePaymentTx.TargetSignBytes("test"))
+}
+
+func TestMultiSendTxProto(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	test1PrivAcc := PrivAccountFromSecret("multisendtx")
+	tx := &MultiSendTx{
+		Fee: NewCoins(0, 10),
+		Input: TxInput{
+			Address:  test1PrivAcc.PrivKey.PublicKey().Address(),
+			Coins:    NewCoins(10, 20),
+			Sequence: 1,
+		},
+		Outputs: []TxOutput{
+			{Address: getTestAddress("output1"), Coins: NewCoins(4, 5)},
+			{Address: getTestAddress("output2"), Coins: NewCoins(6, 5)},
+		},
+	}
+	signBytes := tx.SignBytes(chainID)
+	tx.SetSignature(test1PrivAcc.PrivKey.PublicKey().Address(), test1PrivAcc.Sign(signBytes))
+
+	b, err := TxToBytes(tx)
+	require.Nil(err)
+	txs, err := TxFromBytes(b)
+	require.Nil(err)
+	tx2 := txs.(*MultiSendTx)
+
+	assert.Equal(signBytes, tx2.SignBytes(chainID))
+	assert.Equal(tx.Input.Signature, tx2.Input.Signature)
+	assert.Equal(2, len(tx2.Outputs))
+	assert.Equal(tx.Outputs[1].Address, tx2.Outputs[1].Address)
+}
+
+func TestParamUpdateTxProto(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	test1PrivAcc := PrivAccountFromSecret("paramupdatetx")
+	tx := &ParamUpdateTx{
+		Fee: NewCoins(0, 10),
+		Proposer: TxInput{
+			Address:  test1PrivAcc.PrivKey.PublicKey().Address(),
+			Sequence: 1,
+		},
+		Updates: []ParamUpdate{
+			{Name: ParamMinimumGasPrice, Value: 2000},
+			{Name: ParamMaxBlockGas, Value: 1000},
+		},
+	}
+	signBytes := tx.SignBytes(chainID)
+	tx.SetSignature(test1PrivAcc.PrivKey.PublicKey().Address(), test1PrivAcc.Sign(signBytes))
+
+	b, err := TxToBytes(tx)
+	require.Nil(err)
+	txs, err := TxFromBytes(b)
+	require.Nil(err)
+	tx2 := txs.(*ParamUpdateTx)
+
+	assert.Equal(signBytes, tx2.SignBytes(chainID))
+	assert.Equal(tx.Proposer.Signature, tx2.Proposer.Signature)
+	assert.Equal(tx.Updates, tx2.Updates)
+}
+
+func TestFreezeAccountTxProto(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	test1PrivAcc := PrivAccountFromSecret("freezeaccounttx")
+	tx := &FreezeAccountTx{
+		Fee: NewCoins(0, 10),
+		Proposer: TxInput{
+			Address:  test1PrivAcc.PrivKey.PublicKey().Address(),
+			Sequence: 1,
+		},
+		Address: PrivAccountFromSecret("frozen").PrivKey.PublicKey().Address(),
+		Frozen:  true,
+	}
+	signBytes := tx.SignBytes(chainID)
+	tx.SetSignature(test1PrivAcc.PrivKey.PublicKey().Address(), test1PrivAcc.Sign(signBytes))
+
+	b, err := TxToBytes(tx)
+	require.Nil(err)
+	txs, err := TxFromBytes(b)
+	require.Nil(err)
+	tx2 := txs.(*FreezeAccountTx)
+
+	assert.Equal(signBytes, tx2.SignBytes(chainID))
+	assert.Equal(tx.Proposer.Signature, tx2.Proposer.Signature)
+	assert.Equal(tx.Address, tx2.Address)
+	assert.True(tx2.Frozen)
+}
@@ -0,0 +1,12 @@
+package types
+
+import (
+	"github.com/thetatoken/ukulele/common"
+)
+
+// ValidatorStake is the stake of a validator. As a pending change of the validator set, a zero
+// stake removes the validator from the set.
+type ValidatorStake struct {
+	PubKey common.Bytes
+	Stake  uint64
+}
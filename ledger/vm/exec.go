@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/ledger/state"
 	"github.com/thetatoken/ukulele/ledger/types"
 	"github.com/thetatoken/ukulele/ledger/vm/params"
@@ -93,3 +94,9 @@ func calculateIntrinsicGas(data []byte, createContract bool) (uint64, error) {
 	}
 	return gas, nil
 }
+
+// ContractAddress returns the address of the contract created by the given account when the
+// account has the given nonce, i.e. the sequence of the account before the creation
+func ContractAddress(creator common.Address, nonce uint64) common.Address {
+	return crypto.CreateAddress(creator, nonce)
+}
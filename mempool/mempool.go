@@ -1,16 +1,22 @@
 package mempool
 
 import (
-	"errors"
+	"fmt"
+	"math/big"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/common/clist"
 	"github.com/thetatoken/ukulele/common/math"
+	"github.com/thetatoken/ukulele/common/result"
 	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
 	dp "github.com/thetatoken/ukulele/dispatcher"
+	"github.com/thetatoken/ukulele/ledger/types"
+	"github.com/thetatoken/ukulele/store"
 )
 
 type MempoolError string
@@ -21,8 +27,63 @@ func (m MempoolError) Error() string {
 
 const DuplicateTxError = MempoolError("Transaction already seen")
 
+// SenderQuotaExceededError indicates that the sender already has the maximum number of
+// pending transactions allowed in the mempool
+type SenderQuotaExceededError struct {
+	Sender common.Address
+	Quota  int
+}
+
+func (e SenderQuotaExceededError) Error() string {
+	return fmt.Sprintf("Sender %v has reached the quota of %v pending transactions", e.Sender.Hex(), e.Quota)
+}
+
+// Code returns the error code of the error
+func (e SenderQuotaExceededError) Code() result.ErrorCode {
+	return result.CodeMempoolSenderQuotaExceeded
+}
+
+// MempoolFullError indicates that the transaction was evicted right away, since the pending
+// transactions would exceed the total size limit and it has the lowest priority
+type MempoolFullError struct {
+	MaxTotalBytes int
+}
+
+func (e MempoolFullError) Error() string {
+	return fmt.Sprintf("Mempool is full, the pending transactions are limited to %v bytes", e.MaxTotalBytes)
+}
+
+// Code returns the error code of the error
+func (e MempoolFullError) Code() result.ErrorCode {
+	return result.CodeMempoolFull
+}
+
+// ScreeningError indicates that the transaction failed the screening of the ledger
+type ScreeningError struct {
+	Result result.Result
+}
+
+func (e ScreeningError) Error() string {
+	return e.Result.Message
+}
+
+// Code returns the error code of the screening result
+func (e ScreeningError) Code() result.ErrorCode {
+	return e.Result.Code
+}
+
 type MempoolTransaction struct {
 	rawTransaction common.Bytes
+	hash           common.Hash
+
+	// Decoded once the transaction is inserted
+	sender           common.Address // The account whose sequence the transaction consumes
+	hasSender        bool
+	sequence         uint64   // The sequence of the sender account the transaction consumes
+	fee              *big.Int // The fee in GammaWei the transaction offers to pay
+	validUntilHeight uint64   // The transaction expires after this block height (0 means never)
+
+	persistSeq uint64 // The sequence number under which the transaction is persisted
 }
 
 func CreateMempoolTransaction(rawTransaction common.Bytes) *MempoolTransaction {
@@ -31,27 +92,75 @@ func CreateMempoolTransaction(rawTransaction common.Bytes) *MempoolTransaction {
 	}
 }
 
+//
+// Config contains the configuration of the Mempool
+//
+type Config struct {
+	OrderingStrategy OrderingStrategy
+	MaxTxsPerSender  int // 0 means no limit
+	MaxTotalBytes    int // limit of the total size of the pending transactions, 0 means no limit
+
+	PeerTxRate  float64 // transactions per second a peer may relay, 0 means no limit
+	PeerTxBurst int     // maximum number of transactions a peer may relay in a burst
+
+	Persistent bool // whether the pending transactions are persisted to survive restarts, requires SetStore
+}
+
+// DefaultConfig returns the Mempool configuration specified by the config file
+func DefaultConfig() Config {
+	strategyName := viper.GetString(common.CfgMempoolOrderingStrategy)
+	strategy, ok := NewOrderingStrategy(strategyName)
+	if !ok {
+		log.Warnf("Unknown mempool ordering strategy: %v, using %v", strategyName, OrderingFIFO)
+		strategy = &FIFOOrdering{}
+	}
+	return Config{
+		OrderingStrategy: strategy,
+		MaxTxsPerSender:  viper.GetInt(common.CfgMempoolMaxTxsPerSender),
+		MaxTotalBytes:    viper.GetInt(common.CfgMempoolMaxTotalBytes),
+
+		PeerTxRate:  viper.GetFloat64(common.CfgMempoolPeerTxRate),
+		PeerTxBurst: viper.GetInt(common.CfgMempoolPeerTxBurst),
+
+		Persistent: viper.GetBool(common.CfgMempoolPersistent),
+	}
+}
+
 //
 // Mempool manages the transactions submitted by the clients
 // or relayed from peers
 //
 type Mempool struct {
-	mutex *sync.Mutex
+	mutex  *sync.Mutex
+	config Config
 
 	ledger     core.Ledger
 	dispatcher *dp.Dispatcher
-
-	txCandidates *clist.CList
-	txBookeepper transactionBookkeeper
+	store      store.Store // for persisting the pending transactions
+
+	txCandidates   *clist.CList
+	txBookeepper   transactionBookkeeper
+	senderTxCounts map[common.Address]int              // number of pending transactions of each sender
+	txIndex        map[common.Hash]*MempoolTransaction // pending transactions by hash
+	totalBytes     int                                 // total size of the pending transactions
+	nextPersistSeq uint64                              // sequence number of the next persisted transaction
 }
 
 // CreateMempool creates an instance of Mempool
 func CreateMempool(dispatcher *dp.Dispatcher) *Mempool {
+	return CreateMempoolWithConfig(dispatcher, DefaultConfig())
+}
+
+// CreateMempoolWithConfig creates an instance of Mempool with the given configuration
+func CreateMempoolWithConfig(dispatcher *dp.Dispatcher, config Config) *Mempool {
 	return &Mempool{
-		mutex:        &sync.Mutex{},
-		dispatcher:   dispatcher,
-		txCandidates: clist.New(),
-		txBookeepper: createTransactionBookkeeper(defaultMaxNumTxs),
+		mutex:          &sync.Mutex{},
+		config:         config,
+		dispatcher:     dispatcher,
+		txCandidates:   clist.New(),
+		txBookeepper:   createTransactionBookkeeper(defaultMaxNumTxs),
+		senderTxCounts: make(map[common.Address]int),
+		txIndex:        make(map[common.Hash]*MempoolTransaction),
 	}
 }
 
@@ -60,20 +169,46 @@ func (mp *Mempool) SetLedger(ledger core.Ledger) {
 	mp.ledger = ledger
 }
 
+// SetStore sets the store where the pending transactions are persisted if configured
+func (mp *Mempool) SetStore(store store.Store) {
+	mp.store = store
+}
+
 // InsertTransaction inserts the incoming transaction to mempool (submitted by the clients or relayed from peers)
 func (mp *Mempool) InsertTransaction(mptx *MempoolTransaction) error {
 	mp.mutex.Lock()
 	defer mp.mutex.Unlock()
 
+	err := mp.insertTransaction(mptx)
+	if _, full := err.(MempoolFullError); err == nil || full {
+		mp.persistTxRange()
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// insertTransaction screens the transaction and adds it to the transaction candidate list
+func (mp *Mempool) insertTransaction(mptx *MempoolTransaction) error {
 	if mp.txBookeepper.hasSeen(mptx) {
 		log.Infof("Transaction already seen: %v", mptx)
 		return DuplicateTxError
 	}
 
+	mptx.decode()
+	if mptx.hasSender && mp.config.MaxTxsPerSender > 0 &&
+		mp.senderTxCounts[mptx.sender] >= mp.config.MaxTxsPerSender {
+		return SenderQuotaExceededError{
+			Sender: mptx.sender,
+			Quota:  mp.config.MaxTxsPerSender,
+		}
+	}
+
 	txBytes := mptx.rawTransaction
 	checkTxRes := mp.ledger.ScreenTx(txBytes)
 	if !checkTxRes.IsOK() {
-		return errors.New(checkTxRes.Message)
+		return ScreeningError{Result: checkTxRes}
 	}
 
 	// only record the transactions that passed the screening. This is because that
@@ -83,12 +218,26 @@ func (mp *Mempool) InsertTransaction(mptx *MempoolTransaction) error {
 	// should not be rejected even though it has been submitted earlier.
 	mp.txBookeepper.record(mptx)
 	mp.txCandidates.PushBack(mptx)
+	mp.persistTransaction(mptx)
+	mp.txIndex[mptx.hash] = mptx
+	mp.totalBytes += len(mptx.rawTransaction)
+	if mptx.hasSender {
+		mp.senderTxCounts[mptx.sender]++
+	}
+
+	for _, evicted := range mp.evictTransactionsOverLimit() {
+		if evicted == mptx {
+			return MempoolFullError{MaxTotalBytes: mp.config.MaxTotalBytes}
+		}
+	}
 
 	return nil
 }
 
-// Start needs to be called when the Mempool starts
+// Start needs to be called when the Mempool starts. If the Mempool is persistent, it restores
+// the transactions pending before the restart, which requires the ledger state to be ready.
 func (mp *Mempool) Start() error {
+	mp.restoreTransactions()
 	go mp.broadcastTransactionsRoutine()
 	return nil
 }
@@ -112,8 +261,29 @@ func (mp *Mempool) Size() int {
 	return mp.txCandidates.Len()
 }
 
-// Reap returns a list of valid raw transactions. maxNumTxs == 0 means
-// none, maxNumTxs < 0 means uncapped. Note that Reap does NOT remove
+// Has returns whether the transaction with the given hash is pending in the Mempool
+func (mp *Mempool) Has(txHash common.Hash) bool {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	_, exists := mp.txIndex[txHash]
+	return exists
+}
+
+// Get returns the raw transaction with the given hash if it is pending in the Mempool
+func (mp *Mempool) Get(txHash common.Hash) (common.Bytes, bool) {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	mptx, exists := mp.txIndex[txHash]
+	if !exists {
+		return nil, false
+	}
+	return mptx.rawTransaction, true
+}
+
+// Reap returns a list of valid raw transactions in the order given by the configured
+// ordering strategy. maxNumTxs == 0 means none, maxNumTxs < 0 means uncapped. Note that Reap does NOT remove
 // the transactions from the txCandidates list. Instead, the consensus
 // engine needs to call the Mempool.Update() function to remove the
 // committed transactions
@@ -130,8 +300,10 @@ func (mp *Mempool) Reap(maxNumTxs int) []common.Bytes {
 	}
 
 	txs := make([]common.Bytes, 0, maxNumTxs)
-	for e := mp.txCandidates.Front(); e != nil && len(txs) < maxNumTxs; e = e.Next() {
-		mptx := e.Value.(*MempoolTransaction)
+	for _, mptx := range mp.orderedTransactions() {
+		if len(txs) >= maxNumTxs {
+			break
+		}
 		txs = append(txs, mptx.rawTransaction)
 	}
 
@@ -149,27 +321,134 @@ func (mp *Mempool) Update(committedRawTxs []common.Bytes) bool {
 	}
 
 	for e := mp.txCandidates.Front(); e != nil; e = e.Next() {
-		rawmptx := e.Value.(*MempoolTransaction).rawTransaction
+		mptx := e.Value.(*MempoolTransaction)
+		rawmptx := mptx.rawTransaction
 		if _, exists := committedRawTxMap[string(rawmptx[:])]; exists {
-			mp.txCandidates.Remove(e)
-			e.DetachPrev()
+			mp.removeTransaction(e)
 		}
 	}
+	mp.persistTxRange()
 
 	return true
 }
 
-// Flush removes all transactions from the Mempool and the transactionBookkeeper
+// EvictExpiredTransactions removes the transactions that expire before the block at the given
+// height, and returns the number of removed transactions
+func (mp *Mempool) EvictExpiredTransactions(height uint64) int {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	numEvicted := 0
+	for e := mp.txCandidates.Front(); e != nil; e = e.Next() {
+		mptx := e.Value.(*MempoolTransaction)
+		if mptx.validUntilHeight != 0 && height > mptx.validUntilHeight {
+			mp.removeTransaction(e)
+			numEvicted++
+		}
+	}
+	if numEvicted > 0 {
+		mp.persistTxRange()
+	}
+
+	return numEvicted
+}
+
+// Flush removes all transactions from the Mempool and the transactionBookkeeper, e.g. when the
+// chain halts or restarts
 func (mp *Mempool) Flush() {
 	mp.mutex.Lock()
 	defer mp.mutex.Unlock()
 
 	mp.txBookeepper.reset()
+	mp.senderTxCounts = make(map[common.Address]int)
+	mp.txIndex = make(map[common.Hash]*MempoolTransaction)
+	mp.totalBytes = 0
 
 	for e := mp.txCandidates.Front(); e != nil; e = e.Next() {
+		mp.unpersistTransaction(e.Value.(*MempoolTransaction))
 		mp.txCandidates.Remove(e)
 		e.DetachPrev()
 	}
+	mp.persistTxRange()
+}
+
+// orderedTransactions returns the pending transactions in the order given by the configured
+// ordering strategy
+func (mp *Mempool) orderedTransactions() []*MempoolTransaction {
+	mptxs := make([]*MempoolTransaction, 0, mp.txCandidates.Len())
+	for e := mp.txCandidates.Front(); e != nil; e = e.Next() {
+		mptxs = append(mptxs, e.Value.(*MempoolTransaction))
+	}
+	return mp.config.OrderingStrategy.Order(mptxs)
+}
+
+// evictTransactionsOverLimit evicts the transactions reaped last until the total size of the
+// pending transactions is within the limit, and returns the evicted transactions. Since the
+// transactions of a sender are reaped in the order of their sequences, a sender's transactions
+// are evicted from the highest sequence, and the remaining ones stay free of sequence gaps. The
+// evicted transactions are forgotten by the transactionBookkeeper, so they can be resubmitted.
+func (mp *Mempool) evictTransactionsOverLimit() []*MempoolTransaction {
+	if mp.config.MaxTotalBytes <= 0 || mp.totalBytes <= mp.config.MaxTotalBytes {
+		return nil
+	}
+
+	elements := make(map[*MempoolTransaction]*clist.CElement, mp.txCandidates.Len())
+	for e := mp.txCandidates.Front(); e != nil; e = e.Next() {
+		elements[e.Value.(*MempoolTransaction)] = e
+	}
+
+	evicted := []*MempoolTransaction{}
+	ordered := mp.orderedTransactions()
+	for idx := len(ordered) - 1; idx >= 0 && mp.totalBytes > mp.config.MaxTotalBytes; idx-- {
+		mptx := ordered[idx]
+		mp.removeTransaction(elements[mptx])
+		mp.txBookeepper.remove(mptx)
+		evicted = append(evicted, mptx)
+	}
+	log.Infof("Evicted %v transactions from the full mempool", len(evicted))
+	return evicted
+}
+
+// removeTransaction removes the transaction of the given element from the transaction candidate list
+func (mp *Mempool) removeTransaction(e *clist.CElement) {
+	mptx := e.Value.(*MempoolTransaction)
+	mp.txCandidates.Remove(e)
+	e.DetachPrev()
+	mp.unpersistTransaction(mptx)
+	mp.releaseSenderQuota(mptx)
+	delete(mp.txIndex, mptx.hash)
+	mp.totalBytes -= len(mptx.rawTransaction)
+}
+
+// releaseSenderQuota decrements the pending transaction count of the sender of the removed transaction
+func (mp *Mempool) releaseSenderQuota(mptx *MempoolTransaction) {
+	if !mptx.hasSender {
+		return
+	}
+	mp.senderTxCounts[mptx.sender]--
+	if mp.senderTxCounts[mptx.sender] <= 0 {
+		delete(mp.senderTxCounts, mptx.sender)
+	}
+}
+
+// decode decodes the raw transaction, and records the fields the Mempool orders, limits and
+// evicts the transactions by. An undecodable transaction has no sender and pays no fee, it is
+// rejected by the screening anyway.
+func (mptx *MempoolTransaction) decode() {
+	mptx.hash = crypto.Keccak256Hash(mptx.rawTransaction)
+	mptx.fee = big.NewInt(0)
+
+	tx, err := types.TxFromBytes(mptx.rawTransaction)
+	if err != nil {
+		return
+	}
+	if input, ok := types.GetSenderInput(tx); ok {
+		mptx.sender = input.Address
+		mptx.hasSender = true
+		mptx.sequence = input.Sequence
+	}
+	mptx.fee = getFee(tx)
+	mptx.validUntilHeight = types.GetValidUntilHeight(tx)
 }
 
 // broadcastTransactionRoutine broadcasts transactions to neighoring peers
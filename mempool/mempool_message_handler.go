@@ -6,6 +6,8 @@ import (
 	"github.com/thetatoken/ukulele/rlp"
 
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
+	"github.com/thetatoken/ukulele/p2p"
 	"github.com/thetatoken/ukulele/p2p/types"
 
 	dp "github.com/thetatoken/ukulele/dispatcher"
@@ -33,6 +35,18 @@ func (mmh *MempoolMessageHandler) GetChannelIDs() []common.ChannelIDEnum {
 	}
 }
 
+// GetChannelRateLimits implements the p2p.RateLimitedMessageHandler interface
+func (mmh *MempoolMessageHandler) GetChannelRateLimits() map[common.ChannelIDEnum]p2p.RateLimit {
+	limits := make(map[common.ChannelIDEnum]p2p.RateLimit)
+	if mmh.mempool.config.PeerTxRate > 0 {
+		limits[common.ChannelIDTransaction] = p2p.RateLimit{
+			Rate:  mmh.mempool.config.PeerTxRate,
+			Burst: mmh.mempool.config.PeerTxBurst,
+		}
+	}
+	return limits
+}
+
 // EncodeMessage implements the p2p.MessageHandler interface
 func (mmh *MempoolMessageHandler) EncodeMessage(message interface{}) (common.Bytes, error) {
 	return rlp.EncodeToBytes(message)
@@ -65,5 +79,23 @@ func (mmh *MempoolMessageHandler) HandleMessage(message types.Message) error {
 	if err == DuplicateTxError {
 		return nil
 	}
+	if isInvalidTransaction(err) {
+		return p2p.InvalidMessageError{Err: err}
+	}
 	return err
 }
+
+// isInvalidTransaction returns whether the transaction is rejected for being invalid in itself.
+// The transactions rejected due to the current state, e.g. with a stale sequence, or due to the
+// mempool limits could be relayed by honest peers, and are not considered invalid.
+func isInvalidTransaction(err error) bool {
+	screeningErr, ok := err.(ScreeningError)
+	if !ok {
+		return false
+	}
+	switch screeningErr.Code() {
+	case result.CodeTxParseError, result.CodeInvalidSignature, result.CodeEmptyPubKeyWithSequence1:
+		return true
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/common/result"
 	dp "github.com/thetatoken/ukulele/dispatcher"
 	p2psim "github.com/thetatoken/ukulele/p2p/simulation"
 	"github.com/thetatoken/ukulele/rlp"
@@ -70,3 +71,16 @@ func TestMempoolMessageHandler(t *testing.T) {
 	assert.Equal("tx2", string(reapedRawTxs[1][:]))
 	assert.Equal("tx3", string(reapedRawTxs[2][:]))
 }
+
+func TestIsInvalidTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	invalidSig := result.Error("Signature verification failed").WithErrorCode(result.CodeInvalidSignature)
+	staleSeq := result.Error("Got 1, expected 2").WithErrorCode(result.CodeInvalidSequence)
+
+	assert.True(isInvalidTransaction(ScreeningError{Result: invalidSig}))
+	assert.False(isInvalidTransaction(ScreeningError{Result: staleSeq}))
+	assert.False(isInvalidTransaction(SenderQuotaExceededError{Quota: 1}))
+	assert.False(isInvalidTransaction(MempoolFullError{MaxTotalBytes: 1}))
+	assert.False(isInvalidTransaction(nil))
+}
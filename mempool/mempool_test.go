@@ -10,10 +10,15 @@ import (
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/common/result"
 	"github.com/thetatoken/ukulele/core"
+	"github.com/thetatoken/ukulele/crypto"
 	dp "github.com/thetatoken/ukulele/dispatcher"
+	"github.com/thetatoken/ukulele/ledger/types"
 	p2psim "github.com/thetatoken/ukulele/p2p/simulation"
 	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
 	"github.com/thetatoken/ukulele/rlp"
+	"github.com/thetatoken/ukulele/store"
+	"github.com/thetatoken/ukulele/store/database/backend"
+	"github.com/thetatoken/ukulele/store/kvstore"
 )
 
 func TestMempoolBasics(t *testing.T) {
@@ -149,6 +154,242 @@ func TestMempoolBasics(t *testing.T) {
 	assert.False(mempool.txBookeepper.hasSeen(tx8))
 }
 
+func TestMempoolSenderQuota(t *testing.T) {
+	assert := assert.New(t)
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := newTestMempool("peer0", p2psimnet)
+	mempool.config.MaxTxsPerSender = 2
+
+	aliceTx1 := createTestSendTx("alice", 1, 100)
+	aliceTx2 := createTestSendTx("alice", 2, 100)
+	aliceTx3 := createTestSendTx("alice", 3, 100)
+	bobTx1 := createTestSendTx("bob", 1, 100)
+
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx1)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx2)))
+
+	// The surplus transaction from alice is rejected
+	err := mempool.InsertTransaction(CreateMempoolTransaction(aliceTx3))
+	quotaErr, ok := err.(SenderQuotaExceededError)
+	assert.True(ok)
+	assert.Equal(result.CodeMempoolSenderQuotaExceeded, quotaErr.Code())
+	assert.Equal(common.BytesToAddress([]byte("alice")), quotaErr.Sender)
+
+	// Other senders are unaffected
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(bobTx1)))
+	assert.Equal(3, mempool.Size())
+
+	// The quota is released once the transactions of alice are removed
+	mempool.Update([]common.Bytes{aliceTx1})
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx3)))
+	err = mempool.InsertTransaction(CreateMempoolTransaction(createTestSendTx("alice", 4, 100)))
+	assert.IsType(SenderQuotaExceededError{}, err)
+
+	mempool.Flush()
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(createTestSendTx("alice", 4, 100))))
+}
+
+func TestMempoolMaxTotalBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := newTestMempool("peer0", p2psimnet)
+	mempool.config.OrderingStrategy = &FeePriorityOrdering{}
+
+	aliceTx1 := createTestSendTx("alice", 1, 1000)
+	aliceTx2 := createTestSendTx("alice", 2, 1000)
+	aliceTx3 := createTestSendTx("alice", 3, 1000)
+	bobTx1 := createTestSendTx("bob", 1, 3000)
+	carolTx1 := createTestSendTx("carol", 1, 2000)
+	daveTx1 := createTestSendTx("dave", 1, 4000)
+	eveTx1 := createTestSendTx("eve", 1, 500)
+
+	// Room for all the transactions but one
+	mempool.config.MaxTotalBytes = len(aliceTx1) + len(aliceTx2) + len(aliceTx3) + len(bobTx1) + len(carolTx1)
+	for _, rawTx := range []common.Bytes{aliceTx1, aliceTx2, aliceTx3, bobTx1, carolTx1} {
+		assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(rawTx)))
+	}
+	assert.Equal(5, mempool.Size())
+	assert.Equal(mempool.config.MaxTotalBytes, mempool.totalBytes)
+
+	// The transaction of alice with the highest sequence makes room for the one of dave
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(daveTx1)))
+	assert.Equal(5, mempool.Size())
+	assert.True(mempool.totalBytes <= mempool.config.MaxTotalBytes)
+	assert.False(mempool.Has(crypto.Keccak256Hash(aliceTx3)))
+	assert.True(mempool.Has(crypto.Keccak256Hash(aliceTx1)))
+	assert.True(mempool.Has(crypto.Keccak256Hash(aliceTx2)))
+	assert.Equal(2, mempool.senderTxCounts[common.BytesToAddress([]byte("alice"))])
+	assert.Equal([]common.Bytes{daveTx1, bobTx1, carolTx1, aliceTx1, aliceTx2}, mempool.Reap(-1))
+
+	// A transaction with the lowest priority is evicted right away
+	err := mempool.InsertTransaction(CreateMempoolTransaction(eveTx1))
+	fullErr, ok := err.(MempoolFullError)
+	assert.True(ok)
+	assert.Equal(result.CodeMempoolFull, fullErr.Code())
+	assert.Equal(5, mempool.Size())
+	assert.True(mempool.totalBytes <= mempool.config.MaxTotalBytes)
+
+	// The evicted transactions can be resubmitted once there is room
+	mempool.Update([]common.Bytes{daveTx1, bobTx1})
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx3)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(eveTx1)))
+	assert.Equal(5, mempool.Size())
+	assert.True(mempool.totalBytes <= mempool.config.MaxTotalBytes)
+
+	mempool.Flush()
+	assert.Equal(0, mempool.Size())
+	assert.Equal(0, mempool.totalBytes)
+}
+
+func TestMempoolEvictExpiredTransactions(t *testing.T) {
+	assert := assert.New(t)
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := newTestMempool("peer0", p2psimnet)
+	mempool.config.MaxTxsPerSender = 2
+
+	aliceTx1 := createTestSendTxWithExpiry("alice", 1, 100, 10)
+	aliceTx2 := createTestSendTxWithExpiry("alice", 2, 100, 20)
+	bobTx1 := createTestSendTx("bob", 1, 100)
+
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx1)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx2)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(bobTx1)))
+
+	// A transaction is kept up to its last valid height
+	assert.Equal(0, mempool.EvictExpiredTransactions(10))
+	assert.Equal(3, mempool.Size())
+
+	assert.Equal(1, mempool.EvictExpiredTransactions(11))
+	assert.Equal([]common.Bytes{aliceTx2, bobTx1}, mempool.Reap(-1))
+
+	// The transactions without expiry are never evicted
+	assert.Equal(1, mempool.EvictExpiredTransactions(1000))
+	assert.Equal([]common.Bytes{bobTx1}, mempool.Reap(-1))
+
+	// The sender quota is released
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(createTestSendTx("alice", 3, 100))))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(createTestSendTx("alice", 4, 100))))
+}
+
+func TestMempoolPersistence(t *testing.T) {
+	assert := assert.New(t)
+
+	db := backend.NewMemDatabase()
+	config := DefaultConfig()
+	config.Persistent = true
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := CreateMempoolWithConfig(dp.NewDispatcher(p2psimnet.AddEndpoint("peer0")), config)
+	mempool.SetLedger(newTestLedger())
+	mempool.SetStore(kvstore.NewKVStore(db))
+
+	aliceTx1 := createTestSendTx("alice", 1, 100)
+	aliceTx2 := createTestSendTx("alice", 2, 100)
+	bobTx1 := createTestSendTx("bob", 1, 100)
+	carolTx1 := createTestSendTx("carol", 1, 100)
+
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx1)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(aliceTx2)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(bobTx1)))
+	assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(carolTx1)))
+
+	// The transactions committed before the restart are not persisted
+	assert.True(mempool.Update([]common.Bytes{aliceTx1}))
+
+	// Simulate a restart, where bob's transaction became invalid in the meantime
+	ledger := &TestLedger{rejectedTxs: map[string]bool{string(bobTx1): true}}
+	restarted := CreateMempoolWithConfig(dp.NewDispatcher(p2psimnet.AddEndpoint("peer1")), config)
+	restarted.SetLedger(ledger)
+	restarted.SetStore(kvstore.NewKVStore(db))
+	assert.Nil(restarted.Start())
+
+	assert.Equal([]common.Bytes{aliceTx2, carolTx1}, restarted.Reap(-1))
+
+	// The restored transactions are persisted again after the previous range, and the invalid
+	// transaction is also discarded from the store
+	txStore := kvstore.NewKVStore(db)
+	txRange := persistedTxRange{}
+	assert.Nil(txStore.Get(persistedTxRangeKey, &txRange))
+	assert.Equal(persistedTxRange{First: 4, Next: 6}, txRange)
+	for seq := uint64(0); seq < 4; seq++ {
+		assert.Equal(store.ErrKeyNotFound, txStore.Get(persistedTxKey(seq), &common.Bytes{}))
+	}
+	rawTx := common.Bytes{}
+	assert.Nil(txStore.Get(persistedTxKey(4), &rawTx))
+	assert.Equal(aliceTx2, rawTx)
+	assert.Nil(txStore.Get(persistedTxKey(5), &rawTx))
+	assert.Equal(carolTx1, rawTx)
+
+	// Removing the oldest transaction moves the range forward
+	assert.True(restarted.Update([]common.Bytes{aliceTx2}))
+	assert.Nil(txStore.Get(persistedTxRangeKey, &txRange))
+	assert.Equal(persistedTxRange{First: 5, Next: 6}, txRange)
+	assert.Equal(store.ErrKeyNotFound, txStore.Get(persistedTxKey(4), &rawTx))
+
+	// Nothing is restored if the mempool is not persistent
+	config.Persistent = false
+	nonPersistent := CreateMempoolWithConfig(dp.NewDispatcher(p2psimnet.AddEndpoint("peer2")), config)
+	nonPersistent.SetLedger(newTestLedger())
+	nonPersistent.SetStore(kvstore.NewKVStore(db))
+	assert.Nil(nonPersistent.Start())
+	assert.Equal(0, nonPersistent.Size())
+}
+
+func TestMempoolHasAndGet(t *testing.T) {
+	assert := assert.New(t)
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := newTestMempool("peer0", p2psimnet)
+
+	tx1 := createTestMempoolTx("tx1")
+	tx2 := createTestMempoolTx("tx2")
+	tx1Hash := crypto.Keccak256Hash([]byte("tx1"))
+	tx2Hash := crypto.Keccak256Hash([]byte("tx2"))
+	tx3Hash := crypto.Keccak256Hash([]byte("tx3"))
+
+	assert.Nil(mempool.InsertTransaction(tx1))
+	assert.Nil(mempool.InsertTransaction(tx2))
+
+	// Pending transaction
+	assert.True(mempool.Has(tx1Hash))
+	rawTx, ok := mempool.Get(tx1Hash)
+	assert.True(ok)
+	assert.Equal("tx1", string(rawTx))
+
+	// Removed transaction
+	mempool.Update([]common.Bytes{[]byte("tx1")})
+	assert.False(mempool.Has(tx1Hash))
+	_, ok = mempool.Get(tx1Hash)
+	assert.False(ok)
+	assert.True(mempool.Has(tx2Hash))
+
+	// Never seen transaction
+	assert.False(mempool.Has(tx3Hash))
+	rawTx, ok = mempool.Get(tx3Hash)
+	assert.False(ok)
+	assert.Nil(rawTx)
+
+	mempool.Flush()
+	assert.False(mempool.Has(tx2Hash))
+}
+
+func createTestSendTxWithExpiry(sender string, sequence uint64, fee int64, validUntilHeight uint64) common.Bytes {
+	tx, err := types.TxFromBytes(createTestSendTx(sender, sequence, fee))
+	if err != nil {
+		panic(err)
+	}
+	sendTx := tx.(*types.SendTx)
+	sendTx.ValidUntilHeight = validUntilHeight
+	sendTxBytes, err := types.TxToBytes(sendTx)
+	if err != nil {
+		panic(err)
+	}
+	return sendTxBytes
+}
+
 func TestMempoolTransactionGossip(t *testing.T) {
 	assert := assert.New(t)
 
@@ -203,9 +444,13 @@ func newTestMempool(peerID string, simnet *p2psim.Simnet) *Mempool {
 }
 
 type TestLedger struct {
+	rejectedTxs map[string]bool // raw transactions that fail the screening
 }
 
 func (tl *TestLedger) ScreenTx(rawTx common.Bytes) result.Result {
+	if tl.rejectedTxs[string(rawTx)] {
+		return result.Error("Transaction rejected")
+	}
 	return result.OK
 }
 
@@ -213,11 +458,11 @@ func (tl *TestLedger) ProposeBlockTxs() (stateRootHash common.Hash, blockRawTxs
 	return common.Hash{}, []common.Bytes{}, result.OK
 }
 
-func (tl *TestLedger) ApplyBlockTxs(blockRawTxs []common.Bytes, expectedStateRoot common.Hash) result.Result {
+func (tl *TestLedger) ApplyBlock(block *core.Block) result.Result {
 	return result.OK
 }
 
-func (tl *TestLedger) ResetState(height uint64, rootHash common.Hash) result.Result {
+func (tl *TestLedger) ResetState(height uint64, rootHash common.Hash, blocks ...*core.Block) result.Result {
 	return result.OK
 }
 
@@ -0,0 +1,160 @@
+package mempool
+
+import (
+	"container/heap"
+	"math/big"
+	"sort"
+
+	"github.com/thetatoken/ukulele/ledger/types"
+)
+
+const (
+	// OrderingFIFO is the name of the FIFO ordering strategy
+	OrderingFIFO = "fifo"
+
+	// OrderingFeePriority is the name of the fee priority ordering strategy
+	OrderingFeePriority = "fee_priority"
+)
+
+//
+// OrderingStrategy determines the order in which the transactions are reaped from the mempool
+//
+type OrderingStrategy interface {
+	// Order returns the given pending transactions, which are decoded and in insertion order, in the
+	// order they should be reaped
+	Order(mptxs []*MempoolTransaction) []*MempoolTransaction
+}
+
+// NewOrderingStrategy returns the ordering strategy with the given name
+func NewOrderingStrategy(name string) (OrderingStrategy, bool) {
+	switch name {
+	case OrderingFIFO:
+		return &FIFOOrdering{}, true
+	case OrderingFeePriority:
+		return &FeePriorityOrdering{}, true
+	default:
+		return nil, false
+	}
+}
+
+// ------------------------------------ FIFO ------------------------------------
+
+var _ OrderingStrategy = (*FIFOOrdering)(nil)
+
+// FIFOOrdering reaps the transactions in the order they were inserted
+type FIFOOrdering struct {
+}
+
+// Order implements the OrderingStrategy interface
+func (fo *FIFOOrdering) Order(mptxs []*MempoolTransaction) []*MempoolTransaction {
+	return mptxs
+}
+
+// -------------------------------- Fee Priority --------------------------------
+
+var _ OrderingStrategy = (*FeePriorityOrdering)(nil)
+
+// FeePriorityOrdering reaps the transactions with higher fees first. The transactions from the
+// same sender are always reaped in the order of their sequences. Transactions with the same fee
+// are reaped in the order they were inserted.
+type FeePriorityOrdering struct {
+}
+
+type orderingItem struct {
+	mptx  *MempoolTransaction
+	index int // insertion order
+}
+
+// Order implements the OrderingStrategy interface
+func (fpo *FeePriorityOrdering) Order(mptxs []*MempoolTransaction) []*MempoolTransaction {
+	senderQueues := make(map[string][]*orderingItem)
+	for idx, mptx := range mptxs {
+		sender := string(mptx.rawTransaction) // transactions without a sender are sent by "unique senders"
+		if mptx.hasSender {
+			sender = string(mptx.sender[:])
+		}
+		senderQueues[sender] = append(senderQueues[sender], &orderingItem{mptx: mptx, index: idx})
+	}
+
+	queues := &orderingQueues{}
+	for _, queue := range senderQueues {
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].mptx.sequence < queue[j].mptx.sequence
+		})
+		*queues = append(*queues, queue)
+	}
+	heap.Init(queues)
+
+	ordered := make([]*MempoolTransaction, 0, len(mptxs))
+	for queues.Len() > 0 {
+		queue := (*queues)[0]
+		ordered = append(ordered, queue[0].mptx)
+		if len(queue) > 1 {
+			(*queues)[0] = queue[1:]
+			heap.Fix(queues, 0)
+		} else {
+			heap.Pop(queues)
+		}
+	}
+
+	return ordered
+}
+
+// orderingQueues implements heap.Interface. Each queue holds the transactions of a sender sorted
+// by sequence, and the queues are prioritized by the transaction at the head of the queue.
+type orderingQueues [][]*orderingItem
+
+func (oq orderingQueues) Len() int { return len(oq) }
+
+func (oq orderingQueues) Less(i, j int) bool {
+	itemi, itemj := oq[i][0], oq[j][0]
+	cmp := itemi.mptx.fee.Cmp(itemj.mptx.fee)
+	if cmp != 0 {
+		return cmp > 0
+	}
+	return itemi.index < itemj.index
+}
+
+func (oq orderingQueues) Swap(i, j int) { oq[i], oq[j] = oq[j], oq[i] }
+
+func (oq *orderingQueues) Push(x interface{}) {
+	*oq = append(*oq, x.([]*orderingItem))
+}
+
+func (oq *orderingQueues) Pop() interface{} {
+	old := *oq
+	n := len(old)
+	x := old[n-1]
+	*oq = old[0 : n-1]
+	return x
+}
+
+// getFee returns the fee in GammaWei the transaction offers to pay. For a smart contract
+// transaction, it is the maximum fee, i.e. GasPrice * GasLimit.
+func getFee(tx types.Tx) *big.Int {
+	var fee types.Coins
+	switch tx := tx.(type) {
+	case *types.SendTx:
+		fee = tx.Fee
+	case *types.MultiSendTx:
+		fee = tx.Fee
+	case *types.ReserveFundTx:
+		fee = tx.Fee
+	case *types.ReleaseFundTx:
+		fee = tx.Fee
+	case *types.ServicePaymentTx:
+		fee = tx.Fee
+	case *types.SplitRuleTx:
+		fee = tx.Fee
+	case *types.ParamUpdateTx:
+		fee = tx.Fee
+	case *types.FreezeAccountTx:
+		fee = tx.Fee
+	case *types.SmartContractTx:
+		if tx.GasPrice == nil {
+			return big.NewInt(0)
+		}
+		return new(big.Int).Mul(tx.GasPrice, new(big.Int).SetUint64(tx.GasLimit))
+	}
+	return fee.NoNil().GammaWei
+}
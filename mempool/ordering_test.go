@@ -0,0 +1,91 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/ledger/types"
+	p2psim "github.com/thetatoken/ukulele/p2p/simulation"
+)
+
+func TestFIFOOrdering(t *testing.T) {
+	assert := assert.New(t)
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := newTestMempool("peer0", p2psimnet)
+	mempool.config = Config{OrderingStrategy: &FIFOOrdering{}}
+
+	tx1 := createTestSendTx("alice", 1, 100)
+	tx2 := createTestSendTx("bob", 1, 300)
+	tx3 := createTestSendTx("carol", 1, 200)
+	for _, tx := range []common.Bytes{tx1, tx2, tx3} {
+		assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(tx)))
+	}
+
+	assert.Equal([]common.Bytes{tx1, tx2, tx3}, mempool.Reap(-1))
+}
+
+func TestFeePriorityOrdering(t *testing.T) {
+	assert := assert.New(t)
+
+	p2psimnet := p2psim.NewSimnetWithHandler(nil)
+	mempool := newTestMempool("peer0", p2psimnet)
+	mempool.config = Config{OrderingStrategy: &FeePriorityOrdering{}}
+
+	aliceTx1 := createTestSendTx("alice", 1, 100)
+	aliceTx2 := createTestSendTx("alice", 2, 500)
+	bobTx1 := createTestSendTx("bob", 1, 300)
+	carolTx2 := createTestSendTx("carol", 2, 400) // inserted before the lower sequence
+	carolTx1 := createTestSendTx("carol", 1, 200)
+	davidTx1 := createTestSendTx("david", 1, 300)
+	for _, tx := range []common.Bytes{aliceTx1, aliceTx2, bobTx1, carolTx2, carolTx1, davidTx1} {
+		assert.Nil(mempool.InsertTransaction(CreateMempoolTransaction(tx)))
+	}
+
+	// Higher fee first, the sequence order of the same sender is respected, and
+	// the same fee goes by insertion order
+	expected := []common.Bytes{bobTx1, davidTx1, carolTx1, carolTx2, aliceTx1, aliceTx2}
+	assert.Equal(expected, mempool.Reap(-1))
+	assert.Equal(expected[:3], mempool.Reap(3))
+}
+
+func TestNewOrderingStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	strategy, ok := NewOrderingStrategy(OrderingFIFO)
+	assert.True(ok)
+	assert.IsType(&FIFOOrdering{}, strategy)
+
+	strategy, ok = NewOrderingStrategy(OrderingFeePriority)
+	assert.True(ok)
+	assert.IsType(&FeePriorityOrdering{}, strategy)
+
+	_, ok = NewOrderingStrategy("unknown")
+	assert.False(ok)
+}
+
+func createTestSendTx(sender string, sequence uint64, fee int64) common.Bytes {
+	senderAddr := common.BytesToAddress([]byte(sender))
+	sendTx := &types.SendTx{
+		Fee: types.NewCoins(0, fee),
+		Inputs: []types.TxInput{
+			{
+				Address:  senderAddr,
+				Coins:    types.NewCoins(0, fee+1),
+				Sequence: sequence,
+			},
+		},
+		Outputs: []types.TxOutput{
+			{
+				Address: common.BytesToAddress([]byte("receiver")),
+				Coins:   types.NewCoins(0, 1),
+			},
+		},
+	}
+	sendTxBytes, err := types.TxToBytes(sendTx)
+	if err != nil {
+		panic(err)
+	}
+	return sendTxBytes
+}
@@ -0,0 +1,121 @@
+package mempool
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/store"
+)
+
+// persistedTxRangeKey is the key under which the range of the persisted transaction sequence
+// numbers is stored
+var persistedTxRangeKey = common.Bytes("mp/txrange")
+
+// persistedTxRange is the range of sequence numbers of the persisted transactions. Since the store
+// does not support iterating over keys, each pending transaction is persisted under its own key
+// derived from a sequence number increasing in insertion order, and the range is kept to find them
+// back on restart. The range may contain the sequence numbers of transactions removed since then.
+type persistedTxRange struct {
+	First uint64 // sequence number of the oldest pending transaction
+	Next  uint64 // sequence number of the next inserted transaction
+}
+
+// persistedTxKey returns the key under which the transaction with the given sequence number is persisted
+func persistedTxKey(seq uint64) common.Bytes {
+	return common.Bytes(fmt.Sprintf("mp/tx/%d", seq))
+}
+
+// isPersistent returns whether the pending transactions are persisted
+func (mp *Mempool) isPersistent() bool {
+	return mp.config.Persistent && mp.store != nil
+}
+
+// persistTransaction writes the inserted transaction to the store under the next sequence number.
+// It should be called with the mutex held.
+func (mp *Mempool) persistTransaction(mptx *MempoolTransaction) {
+	if !mp.isPersistent() {
+		return
+	}
+	mptx.persistSeq = mp.nextPersistSeq
+	mp.nextPersistSeq++
+	if err := mp.store.Put(persistedTxKey(mptx.persistSeq), mptx.rawTransaction); err != nil {
+		log.Errorf("Failed to persist transaction %v: %v", mptx.hash.Hex(), err)
+	}
+}
+
+// unpersistTransaction deletes the removed transaction from the store. It should be called with
+// the mutex held.
+func (mp *Mempool) unpersistTransaction(mptx *MempoolTransaction) {
+	if !mp.isPersistent() {
+		return
+	}
+	if err := mp.store.Delete(persistedTxKey(mptx.persistSeq)); err != nil {
+		log.Errorf("Failed to delete persisted transaction %v: %v", mptx.hash.Hex(), err)
+	}
+}
+
+// persistTxRange writes the range of sequence numbers of the pending transactions, which starts at
+// the oldest one since the transaction candidate list is in insertion order. It should be called
+// with the mutex held, once the transactions of a change have been persisted.
+func (mp *Mempool) persistTxRange() {
+	if !mp.isPersistent() {
+		return
+	}
+	txRange := persistedTxRange{First: mp.nextPersistSeq, Next: mp.nextPersistSeq}
+	if front := mp.txCandidates.Front(); front != nil {
+		txRange.First = front.Value.(*MempoolTransaction).persistSeq
+	}
+	if err := mp.store.Put(persistedTxRangeKey, txRange); err != nil {
+		log.Errorf("Failed to persist the pending transaction range: %v", err)
+	}
+}
+
+// restoreTransactions reloads the persisted transactions. Each of them is screened against the
+// current ledger state again, and the ones no longer valid are discarded. The restored ones are
+// persisted again after the previous range, so that the removed ones do not accumulate in the range
+// across restarts.
+func (mp *Mempool) restoreTransactions() {
+	if !mp.isPersistent() {
+		return
+	}
+
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	txRange := persistedTxRange{}
+	if err := mp.store.Get(persistedTxRangeKey, &txRange); err != nil {
+		if err != store.ErrKeyNotFound {
+			log.Errorf("Failed to load the persisted transaction range: %v", err)
+		}
+		return
+	}
+	mp.nextPersistSeq = txRange.Next
+
+	numPersisted, numRestored := 0, 0
+	for seq := txRange.First; seq < txRange.Next; seq++ {
+		rawTx := common.Bytes{}
+		if err := mp.store.Get(persistedTxKey(seq), &rawTx); err != nil {
+			if err != store.ErrKeyNotFound {
+				log.Errorf("Failed to load persisted transaction %v: %v", seq, err)
+			}
+			continue
+		}
+		numPersisted++
+		if err := mp.insertTransaction(CreateMempoolTransaction(rawTx)); err != nil {
+			log.Debugf("Discarding persisted transaction: %v", err)
+			continue
+		}
+		numRestored++
+	}
+	mp.persistTxRange()
+
+	// The previous keys are deleted only once the new range is persisted
+	for seq := txRange.First; seq < txRange.Next; seq++ {
+		if err := mp.store.Delete(persistedTxKey(seq)); err != nil {
+			log.Errorf("Failed to delete persisted transaction %v: %v", seq, err)
+		}
+	}
+	log.Infof("Restored %v of %v persisted transactions", numRestored, numPersisted)
+}
@@ -80,7 +80,7 @@ func TestSyncManager(t *testing.T) {
 	valSet := core.NewValidatorSet()
 	valMgr := consensus.NewFixedValidatorManager(valSet)
 	db := kvstore.NewKVStore(backend.NewMemDatabase())
-	consensus := consensus.NewConsensusEngine(nil, db, initChain, net1, valMgr)
+	consensus := consensus.NewConsensusEngine(nil, db, initChain, net1, valMgr, &consensus.RealClock{})
 	mockMsgConsumer := NewMockMessageConsumer()
 	dispatch := dispatcher.NewDispatcher(net1)
 
@@ -42,6 +42,7 @@ type Node struct {
 type Params struct {
 	ChainID    string
 	PrivateKey *crypto.PrivateKey
+	Signer     crypto.Signer // Optional signer of the proposed transactions, defaults to PrivateKey
 	Root       *core.Block
 	Validators *core.ValidatorSet
 	Network    p2p.Network
@@ -52,13 +53,14 @@ func NewNode(params *Params) *Node {
 	store := kvstore.NewKVStore(params.DB)
 	chain := blockchain.NewChain(params.ChainID, store, params.Root)
 	validatorManager := consensus.NewFixedValidatorManager(params.Validators)
-	consensus := consensus.NewConsensusEngine(params.PrivateKey, store, chain, params.Network, validatorManager)
+	consensus := consensus.NewConsensusEngine(params.PrivateKey, store, chain, params.Network, validatorManager, &consensus.RealClock{})
 	dispatcher := dp.NewDispatcher(params.Network)
 	syncMgr := netsync.NewSyncManager(chain, consensus, params.Network, dispatcher, consensus)
 	mempool := mp.CreateMempool(dispatcher)
-	ledger := ld.NewLedger(params.ChainID, params.DB, consensus, validatorManager, mempool)
+	ledger := ld.NewLedger(params.ChainID, params.DB, consensus, validatorManager, mempool, params.Signer)
 	consensus.SetLedger(ledger)
 	mempool.SetLedger(ledger)
+	mempool.SetStore(store)
 	txMsgHandler := mp.CreateMempoolMessageHandler(mempool)
 	params.Network.RegisterMessageHandler(txMsgHandler)
 	rpcServer := rpc.NewThetaRPCServer(mempool, ledger, chain)
@@ -34,13 +34,18 @@ type Connection struct {
 	onReceive    ReceiveHandler
 	onError      ErrorHandler
 	errored      uint32
+	stopped      uint32 // set once by Stop, the pulses are not scheduled afterwards (atomic)
 
 	sendPulse chan bool
 	pongPulse chan bool
-	quitPulse chan bool
+	quitPulse chan bool // closed by Stop to terminate the send routine
 
-	flushTimer *timer.ThrottleTimer // flush writes as necessary but throttled
-	pingTimer  *timer.RepeatTimer   // send pings periodically
+	flushTimer    *timer.ThrottleTimer // flush writes as necessary but throttled
+	pingTimer     *timer.RepeatTimer   // send pings periodically
+	latencyTicker *time.Ticker         // send pings to measure the round-trip time, nil if disabled
+
+	pingSentAt int64 // time in unix nanoseconds the unanswered ping was sent, 0 if none (atomic)
+	latency    int64 // smoothed round-trip time in nanoseconds, 0 if not measured yet (atomic)
 
 	config ConnectionConfig
 }
@@ -56,6 +61,7 @@ type ConnectionConfig struct {
 	PacketBatchSize    int64
 	FlushThrottle      time.Duration
 	PingTimeout        time.Duration
+	LatencyInterval    time.Duration // interval between the pings measuring the round-trip time, 0 means no measurement
 }
 
 // MessageParser parses the raw message bytes to type p2ptypes.Message
@@ -126,30 +132,40 @@ func GetDefaultConnectionConfig() ConnectionConfig {
 		PacketBatchSize: int64(10),
 		FlushThrottle:   100 * time.Millisecond,
 		PingTimeout:     40 * time.Second,
+		LatencyInterval: 10 * time.Second,
 	}
 }
 
 // Start is called when the connection starts
 func (conn *Connection) Start() bool {
+	if conn.config.LatencyInterval > 0 {
+		conn.latencyTicker = time.NewTicker(conn.config.LatencyInterval)
+	}
 	go conn.sendRoutine()
 	go conn.recvRoutine()
 	return true
 }
 
-// Stop is called whten the connection stops
+// Stop is called whten the connection stops. It is safe to call Stop more than once, and
+// the messages enqueued after the connection stops are dropped.
 func (conn *Connection) Stop() {
-	if conn.sendPulse != nil {
-		close(conn.sendPulse)
+	if !atomic.CompareAndSwapUint32(&conn.stopped, 0, 1) {
+		return // already stopped
 	}
-	if conn.pongPulse != nil {
-		close(conn.pongPulse)
-	}
-	if conn.quitPulse != nil {
-		close(conn.quitPulse)
+	if conn.latencyTicker != nil {
+		conn.latencyTicker.Stop()
 	}
+	// Only the quitPulse is closed, since the other pulses may still be scheduled
+	// by the goroutines that have not observed the stop yet
+	close(conn.quitPulse)
 	conn.netconn.Close()
 }
 
+// isStopped returns whether the connection has been stopped
+func (conn *Connection) isStopped() bool {
+	return atomic.LoadUint32(&conn.stopped) == 1
+}
+
 // SetMessageParser sets the message parser for the connection
 func (conn *Connection) SetMessageParser(messageParser MessageParser) {
 	conn.onParse = messageParser
@@ -173,6 +189,9 @@ func (conn *Connection) SetErrorHandler(errorHandler ErrorHandler) {
 // EnqueueMessage enqueues the given message to the target channel.
 // The message will be send out later
 func (conn *Connection) EnqueueMessage(channelID common.ChannelIDEnum, message interface{}) bool {
+	if conn.isStopped() {
+		return false
+	}
 	channel := conn.channelGroup.getChannel(channelID)
 	if channel == nil {
 		log.Errorf("[p2p] Failed to get channel for ID: %v", channelID)
@@ -195,6 +214,9 @@ func (conn *Connection) EnqueueMessage(channelID common.ChannelIDEnum, message i
 // AttemptToEnqueueMessage attempts to enqueue the given message to the
 // target channel. The message will be send out later (non-blocking)
 func (conn *Connection) AttemptToEnqueueMessage(channelID common.ChannelIDEnum, message interface{}) bool {
+	if conn.isStopped() {
+		return false
+	}
 	channel := conn.channelGroup.getChannel(channelID)
 	if channel == nil {
 		log.Errorf("[p2p] Failed to get channel for ID: %v", channelID)
@@ -225,11 +247,22 @@ func (conn *Connection) CanEnqueueMessage(channelID common.ChannelIDEnum) bool {
 	return channel.canEnqueueMessage()
 }
 
+// Latency returns the smoothed round-trip time of the ping/pong signals, or 0 if it has not
+// been measured yet
+func (conn *Connection) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&conn.latency))
+}
+
 // --------------------- Send goroutine --------------------- //
 
 func (conn *Connection) sendRoutine() {
 	defer conn.recover()
 
+	var latencyCh <-chan time.Time // never fires if the latency is not measured
+	if conn.latencyTicker != nil {
+		latencyCh = conn.latencyTicker.C
+	}
+
 	for {
 		var err error
 		select {
@@ -237,22 +270,26 @@ func (conn *Connection) sendRoutine() {
 			conn.flush()
 		case <-conn.pingTimer.Ch:
 			err = conn.sendPingSignal()
+		case <-latencyCh:
+			err = conn.sendPingSignal()
 		case <-conn.pongPulse:
 			err = conn.sendPongSignal()
 		case <-conn.sendPulse:
 			conn.sendPacketBatchAndScheduleSendPulse()
 		case <-conn.quitPulse:
-			break
+			return
 		}
 		if err != nil {
 			log.Errorf("[p2p] sendRoutine error: %v", err)
 			conn.stopForError(err)
-			break
+			return
 		}
 	}
 }
 
 func (conn *Connection) sendPingSignal() error {
+	// Only the first of the unanswered pings is timed, as a pong answers all the pings before it
+	atomic.CompareAndSwapInt64(&conn.pingSentAt, 0, time.Now().UnixNano())
 	pingPacket := Packet{
 		ChannelID: common.ChannelIDPing,
 		Bytes:     []byte{p2ptypes.PingSignal},
@@ -296,8 +333,15 @@ func (conn *Connection) recvRoutine() {
 		err := rlp.Decode(conn.bufReader, &packet)
 		conn.recvMonitor.Update(int(1))
 		if err != nil {
+			if conn.isStopped() {
+				return // the network connection is closed by Stop
+			}
 			log.Errorf("[p2p] recvRoutine: failed to decode packet: %v", packet)
-			break
+			conn.stopForError(err)
+			return
+		}
+		if conn.isStopped() {
+			return // the message handlers may no longer hold the peer
 		}
 
 		switch packet.ChannelID {
@@ -309,8 +353,6 @@ func (conn *Connection) recvRoutine() {
 
 		conn.pingTimer.Reset()
 	}
-
-	close(conn.pongPulse)
 }
 
 func (conn *Connection) handlePingPong(packet *Packet) (success bool) {
@@ -328,7 +370,7 @@ func (conn *Connection) handlePingPong(packet *Packet) (success bool) {
 	case p2ptypes.PingSignal:
 		conn.schedulePongPulse()
 	case p2ptypes.PongSignal:
-		// do nothing for now
+		conn.updateLatency()
 	default:
 		log.Errorf("[p2p] Invalid Ping/Pong signal")
 		return false
@@ -337,6 +379,26 @@ func (conn *Connection) handlePingPong(packet *Packet) (success bool) {
 	return true
 }
 
+// updateLatency updates the smoothed round-trip time with the time elapsed since the unanswered
+// ping was sent, weighting the new sample by 1/8 as TCP does
+func (conn *Connection) updateLatency() {
+	sentAt := atomic.SwapInt64(&conn.pingSentAt, 0)
+	if sentAt == 0 {
+		return // unsolicited pong
+	}
+	rtt := time.Now().UnixNano() - sentAt
+	if rtt <= 0 {
+		rtt = 1
+	}
+	latency := atomic.LoadInt64(&conn.latency)
+	if latency == 0 {
+		latency = rtt
+	} else {
+		latency = latency - latency/8 + rtt/8
+	}
+	atomic.StoreInt64(&conn.latency, latency)
+}
+
 func (conn *Connection) handleReceivedPacket(packet *Packet) (success bool) {
 	channelID := packet.ChannelID
 	channel := conn.channelGroup.getChannel(channelID)
@@ -427,6 +489,9 @@ func (conn *Connection) GetNetconn() net.Conn {
 }
 
 func (conn *Connection) stopForError(r interface{}) {
+	if conn.isStopped() {
+		return // the errors after Stop are expected, e.g. writing to the closed network connection
+	}
 	if atomic.CompareAndSwapUint32(&conn.errored, 0, 1) {
 		if conn.onError != nil {
 			conn.onError(r)
@@ -459,10 +524,3 @@ func (conn *Connection) schedulePongPulse() {
 	default:
 	}
 }
-
-func (conn *Connection) scheduleQuitPulse() {
-	select {
-	case conn.quitPulse <- true:
-	default:
-	}
-}
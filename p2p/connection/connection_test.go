@@ -3,6 +3,7 @@ package connection
 import (
 	"errors"
 	"io/ioutil"
+	"net"
 	"testing"
 	"time"
 
@@ -273,3 +274,31 @@ func TestConnectionRecvNodeInfo(t *testing.T) {
 		assert.True(resultMatched)
 	}
 }
+
+func TestConnectionStop(t *testing.T) {
+	assert := assert.New(t)
+
+	// The error handler is called once the remote end closes the connection
+	netconn, remoteNetconn := net.Pipe()
+	errored := make(chan interface{}, 2)
+	conn := CreateConnection(netconn, GetDefaultConnectionConfig())
+	conn.SetErrorHandler(func(r interface{}) { errored <- r })
+	conn.Start()
+	remoteNetconn.Close()
+	assert.NotNil(<-errored)
+	conn.Stop()
+
+	// The connection can be stopped more than once, and drops the messages enqueued afterwards
+	netconn, remoteNetconn = net.Pipe()
+	defer remoteNetconn.Close()
+	conn = CreateConnection(netconn, GetDefaultConnectionConfig())
+	conn.SetErrorHandler(func(r interface{}) { errored <- r })
+	conn.Start()
+	conn.Stop()
+	conn.Stop()
+	assert.False(conn.EnqueueMessage(common.ChannelIDTransaction, "message"))
+	assert.False(conn.AttemptToEnqueueMessage(common.ChannelIDTransaction, "message"))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(0, len(errored))
+}
@@ -23,6 +23,40 @@ type MessageHandler interface {
 	HandleMessage(message types.Message) error
 }
 
+//
+// InvalidMessageError is returned by HandleMessage for a message that is invalid in itself,
+// e.g. a transaction with an invalid signature. Only such messages count against the
+// reputation of the sender, unlike those rejected due to the local state, e.g. a stale
+// transaction or a full mempool.
+//
+type InvalidMessageError struct {
+	Err error
+}
+
+func (e InvalidMessageError) Error() string {
+	return e.Err.Error()
+}
+
+//
+// RateLimit specifies the rate at which a peer may send messages over a channel
+//
+type RateLimit struct {
+	Rate  float64 // messages per second
+	Burst int     // maximum number of messages in a burst
+}
+
+//
+// RateLimitedMessageHandler is a MessageHandler that limits the rate of the messages
+// each peer sends over its channels
+//
+type RateLimitedMessageHandler interface {
+	MessageHandler
+
+	// GetChannelRateLimits returns the rate limits of the channels, the channels not
+	// included are not rate limited
+	GetChannelRateLimits() map[common.ChannelIDEnum]RateLimit
+}
+
 //
 // Network is a handle to the P2P network
 //
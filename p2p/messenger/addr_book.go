@@ -15,6 +15,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -311,6 +312,35 @@ func (a *AddrBook) GetSelection() []*nu.NetAddress {
 	return allAddr[:numAddresses]
 }
 
+// ExportAddresses returns the routable addresses in the book, sorted for a stable output. Suitable for seeding the address books of other nodes.
+func (a *AddrBook) ExportAddresses() []*nu.NetAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	addrs := []*nu.NetAddress{}
+	for _, ka := range a.addrLookup {
+		if !ka.Addr.Routable() {
+			continue
+		}
+		addrs = append(addrs, ka.Addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs
+}
+
+// ImportAddresses merges the given addresses into the book. Each address is recorded as its own
+// source. Non-routable addresses are skipped if the book is routability strict.
+func (a *AddrBook) ImportAddresses(addrs []*nu.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for _, addr := range addrs {
+		a.addAddress(addr, addr)
+	}
+}
+
 /* Loading & Saving */
 
 type addrBookJSON struct {
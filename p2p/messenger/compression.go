@@ -0,0 +1,87 @@
+package messenger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/thetatoken/ukulele/common"
+	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
+)
+
+const (
+	// defaultCompressionThreshold is the size in bytes below which the messages are not compressed
+	defaultCompressionThreshold = 1024
+
+	// maxDecompressedMessageSize limits the size of a decompressed message
+	maxDecompressedMessageSize = 64 * 1024 * 1024
+)
+
+// The compressed messages are prefixed with a flag byte indicating whether the rest of the
+// message is compressed
+const (
+	messageFlagRaw        = byte(0x0)
+	messageFlagCompressed = byte(0x1)
+)
+
+// compressMessage compresses the message bytes if the compression is enabled and the message is
+// not smaller than the threshold
+func compressMessage(compression byte, threshold int, msgBytes common.Bytes) (common.Bytes, error) {
+	if compression == p2ptypes.CompressionNone {
+		return msgBytes, nil
+	}
+	if compression != p2ptypes.CompressionGzip {
+		return nil, fmt.Errorf("Unsupported compression: %v", compression)
+	}
+	if len(msgBytes) < threshold {
+		return append([]byte{messageFlagRaw}, msgBytes...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(messageFlagCompressed)
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(msgBytes); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressMessage reverts compressMessage
+func decompressMessage(compression byte, rawBytes common.Bytes) (common.Bytes, error) {
+	if compression == p2ptypes.CompressionNone {
+		return rawBytes, nil
+	}
+	if compression != p2ptypes.CompressionGzip {
+		return nil, fmt.Errorf("Unsupported compression: %v", compression)
+	}
+	if len(rawBytes) == 0 {
+		return nil, errors.New("Missing message compression flag")
+	}
+
+	switch rawBytes[0] {
+	case messageFlagRaw:
+		return rawBytes[1:], nil
+	case messageFlagCompressed:
+		reader, err := gzip.NewReader(bytes.NewReader(rawBytes[1:]))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		msgBytes, err := ioutil.ReadAll(io.LimitReader(reader, maxDecompressedMessageSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(msgBytes) > maxDecompressedMessageSize {
+			return nil, errors.New("Decompressed message is too large")
+		}
+		return msgBytes, nil
+	default:
+		return nil, fmt.Errorf("Invalid message compression flag: %v", rawBytes[0])
+	}
+}
@@ -0,0 +1,48 @@
+package messenger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thetatoken/ukulele/common"
+	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
+)
+
+func TestMessageCompression(t *testing.T) {
+	assert := assert.New(t)
+
+	threshold := 1024
+	largeMsg := common.Bytes(strings.Repeat("theta", 10000))
+	smallMsg := common.Bytes("theta")
+
+	// Large messages are compressed
+	compressed, err := compressMessage(p2ptypes.CompressionGzip, threshold, largeMsg)
+	assert.Nil(err)
+	assert.Equal(messageFlagCompressed, compressed[0])
+	assert.True(len(compressed) < len(largeMsg))
+	decompressed, err := decompressMessage(p2ptypes.CompressionGzip, compressed)
+	assert.Nil(err)
+	assert.Equal(largeMsg, decompressed)
+
+	// Small messages are not compressed
+	compressed, err = compressMessage(p2ptypes.CompressionGzip, threshold, smallMsg)
+	assert.Nil(err)
+	assert.Equal(append([]byte{messageFlagRaw}, smallMsg...), []byte(compressed))
+	decompressed, err = decompressMessage(p2ptypes.CompressionGzip, compressed)
+	assert.Nil(err)
+	assert.Equal(smallMsg, decompressed)
+
+	// The messages are left unchanged without compression
+	compressed, err = compressMessage(p2ptypes.CompressionNone, threshold, largeMsg)
+	assert.Nil(err)
+	assert.Equal(largeMsg, compressed)
+
+	// Corrupted messages are rejected
+	_, err = decompressMessage(p2ptypes.CompressionGzip, common.Bytes{})
+	assert.NotNil(err)
+	_, err = decompressMessage(p2ptypes.CompressionGzip, common.Bytes{messageFlagCompressed, 0x1, 0x2})
+	assert.NotNil(err)
+	_, err = decompressMessage(p2ptypes.CompressionGzip, common.Bytes{0x7})
+	assert.NotNil(err)
+}
@@ -16,6 +16,9 @@ const (
 	tryListenSeconds    = 5
 )
 
+// discoverNAT discovers the UPNP gateway, replaceable in tests
+var discoverNAT = netutil.Discover
+
 //
 // InboundPeerListener models a listener for inbound peer connections
 //
@@ -44,13 +47,12 @@ type InboundCallback func(peer *pr.Peer, err error)
 // createInboundPeerListener creates a new inbound peer listener instance
 func createInboundPeerListener(discMgr *PeerDiscoveryManager, protocol string, localAddr string,
 	skipUPNP bool, config InboundPeerListenerConfig) (InboundPeerListener, error) {
-	localAddrIP, localAddrPort := splitHostPort(localAddr)
 	netListener := initiateNetListener(protocol, localAddr)
 	netListenerIP, netListenerPort := splitHostPort(netListener.Addr().String())
 	log.Infof("[p2p] Local network listener, ip: %v, port: %v", netListenerIP, netListenerPort)
 
 	internalNetAddr := getInternalNetAddress(localAddr)
-	externalNetAddr := getExternalNetAddress(localAddrIP, localAddrPort, netListenerPort, skipUPNP)
+	externalNetAddr := getExternalNetAddress(localAddr, netListenerPort, skipUPNP)
 
 	inboundPeerListener := InboundPeerListener{
 		discMgr:      discMgr,
@@ -156,15 +158,26 @@ func getInternalNetAddress(localAddr string) *netutil.NetAddress {
 	return internalAddr
 }
 
-func getExternalNetAddress(localAddrIP string, localAddrPort int, listenerPort int, skipUPNP bool) *netutil.NetAddress {
+// getExternalNetAddress returns the external address of the node. Unless skipped, it performs
+// the UPNP port mapping. If the mapping fails, it falls back to the configured self address, or
+// to the address of the network interface if the self address is a loopback address, which is
+// never advertised to the peers.
+func getExternalNetAddress(localAddr string, listenerPort int, skipUPNP bool) *netutil.NetAddress {
 	var externalAddr *netutil.NetAddress
 	if !skipUPNP {
-		// If the lAddrIP is INADDR_ANY, try UPNP
-		if localAddrIP == "" || localAddrIP == "0.0.0.0" {
-			externalAddr = getUPNPExternalAddress(localAddrPort, listenerPort)
+		_, localAddrPort := splitHostPort(localAddr)
+		externalAddr = getUPNPExternalAddress(localAddrPort, listenerPort)
+		if externalAddr == nil {
+			selfAddr := getInternalNetAddress(localAddr)
+			if selfAddr.Valid() && !selfAddr.Local() {
+				log.Warnf("[p2p] UPNP failed, falling back to the self network address: %v", localAddr)
+				externalAddr = selfAddr
+			} else {
+				log.Warnf("[p2p] UPNP failed, falling back to the address of the network interface")
+			}
 		}
 	}
-	// Otherwise just use the local address
+	// Otherwise just use the address of the network interface
 	if externalAddr == nil {
 		externalAddr = getNaiveExternalAddress(listenerPort)
 	}
@@ -177,7 +190,7 @@ func getExternalNetAddress(localAddrIP string, localAddrPort int, listenerPort i
 
 func getUPNPExternalAddress(externalPort, internalPort int) *netutil.NetAddress {
 	log.Infof("[p2p] Getting UPNP external address")
-	nat, err := netutil.Discover()
+	nat, err := discoverNAT()
 	if err != nil {
 		log.Infof("[p2p] Could not perform UPNP discover: %v", err)
 		return nil
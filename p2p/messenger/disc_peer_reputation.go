@@ -0,0 +1,166 @@
+package messenger
+
+import (
+	"sync"
+	"time"
+)
+
+//
+// PeerReputationTracker tracks the reputation scores of the peers, and the peers banned
+// for misbehaving
+//
+type PeerReputationTracker struct {
+	mutex *sync.Mutex
+
+	scores      map[string]*peerScore // peerID -> score, which starts at zero and decreases with penalties
+	bannedUntil map[string]time.Time  // peerID -> ban expiry
+
+	config PeerReputationConfig
+}
+
+//
+// PeerReputationConfig specifies the configuration for the PeerReputationTracker
+//
+type PeerReputationConfig struct {
+	MalformedMessagePenalty int           // penalty for a message that cannot be parsed
+	InvalidMessagePenalty   int           // penalty for a message the handler rejects as a p2p.InvalidMessageError
+	RateLimitPenalty        int           // penalty for a message dropped for exceeding the rate limit of the channel
+	MinScore                int           // a peer is banned when its score drops below this limit
+	BanDuration             time.Duration // duration of the automatic bans
+	ScoreDecayInterval      time.Duration // one penalty point is forgiven per interval, 0 disables the decay
+	MaxTrackedPeers         int           // maximum number of peers with a score, 0 means no limit
+}
+
+// peerScore is the score of a peer, and the time up to which the decay has been applied
+type peerScore struct {
+	score     int
+	decayedAt time.Time
+}
+
+// GetDefaultPeerReputationConfig returns the default configuration for the PeerReputationTracker
+func GetDefaultPeerReputationConfig() PeerReputationConfig {
+	return PeerReputationConfig{
+		MalformedMessagePenalty: 10,
+		InvalidMessagePenalty:   1,
+		RateLimitPenalty:        1,
+		MinScore:                -100,
+		BanDuration:             time.Hour,
+		ScoreDecayInterval:      time.Minute,
+		MaxTrackedPeers:         4096,
+	}
+}
+
+// createPeerReputationTracker creates an instance of the PeerReputationTracker
+func createPeerReputationTracker(config PeerReputationConfig) *PeerReputationTracker {
+	return &PeerReputationTracker{
+		mutex:       &sync.Mutex{},
+		scores:      make(map[string]*peerScore),
+		bannedUntil: make(map[string]time.Time),
+		config:      config,
+	}
+}
+
+// Penalize decreases the score of the peer by the given penalty. It bans the peer and returns
+// true if the score drops below the limit.
+func (prt *PeerReputationTracker) Penalize(peerID string, penalty int) (banned bool) {
+	prt.mutex.Lock()
+	defer prt.mutex.Unlock()
+
+	now := time.Now()
+	entry := prt.decay(peerID, now)
+	if entry == nil {
+		prt.makeRoom(now)
+		entry = &peerScore{decayedAt: now}
+		prt.scores[peerID] = entry
+	}
+	entry.score -= penalty
+	if entry.score >= prt.config.MinScore {
+		return false
+	}
+	prt.ban(peerID, prt.config.BanDuration)
+	return true
+}
+
+// Ban bans the peer for the given duration
+func (prt *PeerReputationTracker) Ban(peerID string, duration time.Duration) {
+	prt.mutex.Lock()
+	defer prt.mutex.Unlock()
+
+	prt.ban(peerID, duration)
+}
+
+// IsBanned returns whether the peer is currently banned
+func (prt *PeerReputationTracker) IsBanned(peerID string) bool {
+	prt.mutex.Lock()
+	defer prt.mutex.Unlock()
+
+	bannedUntil, ok := prt.bannedUntil[peerID]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(bannedUntil) {
+		return true
+	}
+	delete(prt.bannedUntil, peerID)
+	return false
+}
+
+// Score returns the current score of the peer
+func (prt *PeerReputationTracker) Score(peerID string) int {
+	prt.mutex.Lock()
+	defer prt.mutex.Unlock()
+
+	entry := prt.decay(peerID, time.Now())
+	if entry == nil {
+		return 0
+	}
+	return entry.score
+}
+
+// decay forgives the penalty points of the peer accrued since the last decay. It returns the
+// score of the peer, or nil if the peer has no penalty left.
+func (prt *PeerReputationTracker) decay(peerID string, now time.Time) *peerScore {
+	entry, ok := prt.scores[peerID]
+	if !ok {
+		return nil
+	}
+	interval := prt.config.ScoreDecayInterval
+	if interval <= 0 {
+		return entry
+	}
+	numIntervals := now.Sub(entry.decayedAt) / interval
+	if numIntervals <= 0 {
+		return entry
+	}
+	entry.score += int(numIntervals)
+	entry.decayedAt = entry.decayedAt.Add(numIntervals * interval)
+	if entry.score >= 0 {
+		delete(prt.scores, peerID)
+		return nil
+	}
+	return entry
+}
+
+// makeRoom evicts the score of the least penalized peer if the number of scores reaches the limit
+func (prt *PeerReputationTracker) makeRoom(now time.Time) {
+	if prt.config.MaxTrackedPeers <= 0 || len(prt.scores) < prt.config.MaxTrackedPeers {
+		return
+	}
+	evictedPeerID := ""
+	for peerID := range prt.scores {
+		entry := prt.decay(peerID, now)
+		if entry == nil {
+			return // the peer has no penalty left, which makes room
+		}
+		if evictedPeerID == "" || entry.score > prt.scores[evictedPeerID].score {
+			evictedPeerID = peerID
+		}
+	}
+	delete(prt.scores, evictedPeerID)
+}
+
+// ban bans the peer, and resets its score so that it starts afresh once the ban expires
+func (prt *PeerReputationTracker) ban(peerID string, duration time.Duration) {
+	prt.bannedUntil[peerID] = time.Now().Add(duration)
+	delete(prt.scores, peerID)
+}
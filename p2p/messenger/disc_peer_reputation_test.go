@@ -0,0 +1,64 @@
+package messenger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerReputationTracker(t *testing.T) {
+	assert := assert.New(t)
+
+	prt := createPeerReputationTracker(PeerReputationConfig{
+		MalformedMessagePenalty: 10,
+		InvalidMessagePenalty:   1,
+		MinScore:                -15,
+		BanDuration:             time.Minute,
+	})
+
+	// The peer is banned once its score drops below the limit
+	assert.False(prt.Penalize("peerA", 10))
+	assert.Equal(-10, prt.Score("peerA"))
+	assert.False(prt.Penalize("peerA", 5))
+	assert.False(prt.IsBanned("peerA"))
+	assert.True(prt.Penalize("peerA", 1))
+	assert.True(prt.IsBanned("peerA"))
+	assert.Equal(0, prt.Score("peerA"))
+	assert.False(prt.IsBanned("peerB"))
+
+	// The ban expires after the given duration
+	prt.Ban("peerB", 50*time.Millisecond)
+	assert.True(prt.IsBanned("peerB"))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(prt.IsBanned("peerB"))
+}
+
+func TestPeerReputationDecay(t *testing.T) {
+	assert := assert.New(t)
+
+	prt := createPeerReputationTracker(PeerReputationConfig{
+		InvalidMessagePenalty: 1,
+		MinScore:              -15,
+		BanDuration:           time.Minute,
+		ScoreDecayInterval:    time.Minute,
+		MaxTrackedPeers:       2,
+	})
+
+	// The penalties are forgiven over time
+	assert.False(prt.Penalize("peerA", 10))
+	prt.scores["peerA"].decayedAt = time.Now().Add(-3 * time.Minute)
+	assert.Equal(-7, prt.Score("peerA"))
+	prt.scores["peerA"].decayedAt = time.Now().Add(-10 * time.Minute)
+	assert.Equal(0, prt.Score("peerA"))
+	assert.Equal(0, len(prt.scores))
+
+	// The least penalized peer is evicted once the number of scores reaches the limit
+	assert.False(prt.Penalize("peerA", 10))
+	assert.False(prt.Penalize("peerB", 2))
+	assert.False(prt.Penalize("peerC", 5))
+	assert.Equal(2, len(prt.scores))
+	assert.Equal(-10, prt.Score("peerA"))
+	assert.Equal(0, prt.Score("peerB"))
+	assert.Equal(-5, prt.Score("peerC"))
+}
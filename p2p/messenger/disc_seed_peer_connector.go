@@ -2,6 +2,7 @@ package messenger
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -17,15 +18,47 @@ type SeedPeerConnector struct {
 	selfNetAddress       netutil.NetAddress
 	seedPeerNetAddresses []netutil.NetAddress
 
+	mutex    *sync.Mutex
+	attempts map[string]int // seed peer net address -> number of connection attempts so far
+
+	config SeedPeerConnectorConfig
+	wait   func(duration time.Duration) bool // overrides sleep() in tests
+	quit   chan struct{}
+
+	// Connected receives the outcome of the connection attempts to the seed peers. The outcome
+	// of the first attempt to each seed peer is always delivered, while the outcomes of the
+	// retries are dropped if they are not received in time.
 	Connected chan bool
 }
 
+//
+// SeedPeerConnectorConfig specifies the configuration for the SeedPeerConnector
+//
+type SeedPeerConnectorConfig struct {
+	InitialRetryInterval time.Duration // interval before the first retry, doubled for each subsequent retry
+	MaxRetryInterval     time.Duration // cap of the retry interval
+	RetryJitter          float64       // fraction of the retry interval randomly subtracted from it
+}
+
+// GetDefaultSeedPeerConnectorConfig returns the default configuration for the SeedPeerConnector
+func GetDefaultSeedPeerConnectorConfig() SeedPeerConnectorConfig {
+	return SeedPeerConnectorConfig{
+		InitialRetryInterval: 1 * time.Second,
+		MaxRetryInterval:     5 * time.Minute,
+		RetryJitter:          0.2,
+	}
+}
+
 // createSeedPeerConnector creates an instance of the SeedPeerConnector
-func createSeedPeerConnector(discMgr *PeerDiscoveryManager,
-	selfNetAddressStr string, seedPeerNetAddressStrs []string) (SeedPeerConnector, error) {
+func createSeedPeerConnector(discMgr *PeerDiscoveryManager, selfNetAddressStr string,
+	seedPeerNetAddressStrs []string, config SeedPeerConnectorConfig) (SeedPeerConnector, error) {
 	numSeedPeers := len(seedPeerNetAddressStrs)
 	spc := SeedPeerConnector{
 		discMgr:   discMgr,
+		mutex:     &sync.Mutex{},
+		attempts:  make(map[string]int),
+		config:    config,
+		quit:      make(chan struct{}),
 		Connected: make(chan bool, numSeedPeers),
 	}
 
@@ -60,25 +93,103 @@ func (spc *SeedPeerConnector) Start() error {
 	return nil
 }
 
-// Stop is called when the SeedPeerConnector stops
+// Stop is called when the SeedPeerConnector stops. It stops retrying the connections to the
+// seed peers.
 func (spc *SeedPeerConnector) Stop() {
+	spc.mutex.Lock()
+	defer spc.mutex.Unlock()
+	select {
+	case <-spc.quit:
+	default:
+		close(spc.quit)
+	}
+}
+
+// Attempts returns the number of connection attempts made so far to each seed peer, keyed by
+// the network address of the seed peer
+func (spc *SeedPeerConnector) Attempts() map[string]int {
+	spc.mutex.Lock()
+	defer spc.mutex.Unlock()
+	attempts := make(map[string]int, len(spc.attempts))
+	for seedPeerNetAddress, numAttempts := range spc.attempts {
+		attempts[seedPeerNetAddress] = numAttempts
+	}
+	return attempts
 }
 
 func (spc *SeedPeerConnector) connectToSeedPeers() {
 	perm := rand.Perm(len(spc.seedPeerNetAddresses))
 	for i := 0; i < len(perm); i++ { // create outbound peers in a random order
 		go func(i int) {
-			time.Sleep(time.Duration(rand.Int63n(3000)) * time.Millisecond)
+			if !spc.sleep(time.Duration(rand.Int63n(3000)) * time.Millisecond) {
+				return
+			}
 			j := perm[i]
 			peerNetAddress := spc.seedPeerNetAddresses[j]
-			_, err := spc.discMgr.connectToOutboundPeer(&peerNetAddress, true)
-			if err != nil {
-				spc.Connected <- false
-				log.Errorf("[p2p] Failed to connect to seed peer %v: %v", peerNetAddress.String(), err)
-			} else {
-				spc.Connected <- true
-				log.Infof("[p2p] Successfully connected to seed peer %v", peerNetAddress.String())
-			}
+			spc.connectToSeedPeer(&peerNetAddress)
 		}(i)
 	}
 }
+
+// connectToSeedPeer keeps attempting to connect to the seed peer, with exponentially growing
+// intervals between the attempts, until it succeeds or the connector is stopped
+func (spc *SeedPeerConnector) connectToSeedPeer(peerNetAddress *netutil.NetAddress) {
+	for attempt := 1; ; attempt++ {
+		spc.mutex.Lock()
+		spc.attempts[peerNetAddress.String()] = attempt
+		spc.mutex.Unlock()
+
+		_, err := spc.discMgr.connectToOutboundPeer(peerNetAddress, true)
+		if err == nil {
+			spc.reportConnected(true, attempt)
+			log.Infof("[p2p] Successfully connected to seed peer %v", peerNetAddress.String())
+			return
+		}
+		spc.reportConnected(false, attempt)
+
+		retryInterval := spc.retryInterval(attempt)
+		log.Errorf("[p2p] Failed to connect to seed peer %v: %v, retry in %v", peerNetAddress.String(), err, retryInterval)
+		if !spc.sleep(retryInterval) {
+			return
+		}
+	}
+}
+
+func (spc *SeedPeerConnector) reportConnected(connected bool, attempt int) {
+	if attempt == 1 {
+		spc.Connected <- connected // the channel is buffered for the first attempt to each seed peer
+		return
+	}
+	select {
+	case spc.Connected <- connected:
+	default:
+	}
+}
+
+// retryInterval returns the interval after the given number of failed attempts. The interval
+// doubles for each attempt up to the max interval, and then a random jitter is subtracted from it
+// so the nodes do not reconnect to a seed in lockstep.
+func (spc *SeedPeerConnector) retryInterval(attempt int) time.Duration {
+	interval := spc.config.InitialRetryInterval
+	for i := 1; i < attempt && interval < spc.config.MaxRetryInterval; i++ {
+		interval *= 2
+	}
+	if interval > spc.config.MaxRetryInterval {
+		interval = spc.config.MaxRetryInterval
+	}
+	jitter := time.Duration(rand.Float64() * spc.config.RetryJitter * float64(interval))
+	return interval - jitter
+}
+
+// sleep waits for the given duration, and returns false if the connector is stopped meanwhile
+func (spc *SeedPeerConnector) sleep(duration time.Duration) bool {
+	if spc.wait != nil {
+		return spc.wait(duration)
+	}
+	select {
+	case <-time.After(duration):
+		return true
+	case <-spc.quit:
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package messenger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedPeerConnectorExponentialBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	unreachableSeedNetAddr := "127.0.0.1:24681" // nobody listens on this port
+	discMgr := newTestPeerDiscoveryManager([]string{unreachableSeedNetAddr}, "127.0.0.1:24682")
+	spc := &discMgr.seedPeerConnector
+	spc.config = SeedPeerConnectorConfig{
+		InitialRetryInterval: 10 * time.Millisecond,
+		MaxRetryInterval:     80 * time.Millisecond,
+		RetryJitter:          0.2,
+	}
+
+	numRetries := 8
+	numWaits := 0
+	intervals := []time.Duration{}
+	done := make(chan bool)
+	spc.wait = func(duration time.Duration) bool {
+		numWaits++
+		if numWaits == 1 {
+			return true // the random delay before the first attempt
+		}
+		if numWaits > numRetries+1 {
+			close(done) // stop the connector after numRetries retries
+			return false
+		}
+		intervals = append(intervals, duration)
+		return true
+	}
+	spc.Start()
+	assert.False(<-spc.Connected)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		assert.FailNow("Timed out waiting for the retries")
+	}
+	assert.Equal(numRetries, len(intervals))
+
+	// 10ms, 20ms, 40ms, 80ms, 80ms, ..., minus up to 20% jitter
+	for i, interval := range intervals {
+		assert.True(interval <= spc.config.MaxRetryInterval)
+		if i > 0 && i < 4 {
+			assert.True(interval > intervals[i-1], "retry interval should grow: %v", intervals)
+		}
+		if i >= 3 {
+			assert.True(interval >= 64*time.Millisecond, "retry interval should cap out: %v", intervals)
+		}
+	}
+	assert.Equal(numRetries+1, spc.Attempts()[unreachableSeedNetAddr])
+}
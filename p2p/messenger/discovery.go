@@ -2,10 +2,13 @@ package messenger
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/thetatoken/ukulele/crypto"
 	cn "github.com/thetatoken/ukulele/p2p/connection"
 	"github.com/thetatoken/ukulele/p2p/netutil"
 	pr "github.com/thetatoken/ukulele/p2p/peer"
@@ -21,37 +24,58 @@ type PeerDiscoveryManager struct {
 	addrBook  *AddrBook
 	peerTable *pr.PeerTable
 	nodeInfo  *p2ptypes.NodeInfo
+	privKey   *crypto.PrivateKey // signs the node info during the handshakes
 
 	// Three mechanisms for peer discovery
 	seedPeerConnector   SeedPeerConnector           // pro-actively connect to seed peers
 	peerDiscMsgHandler  PeerDiscoveryMessageHandler // pro-actively connect to peer candidates obtained from connected peers
 	inboundPeerListener InboundPeerListener         // listen to incoming peering requests
+
+	reputation *PeerReputationTracker // ban the misbehaving peers
+
+	addPeerMutex *sync.Mutex // serializes the duplicate connection check with adding the peer
+
+	latencyInterval time.Duration // interval between the pings measuring the round-trip time to the peers
 }
 
+// ErrDuplicateConnection is returned when a connection to an already connected peer is dropped
+// in favor of the existing connection.
+var ErrDuplicateConnection = errors.New("DuplicateConnection")
+
 //
 // PeerDiscoveryManagerConfig specifies the configuration for PeerDiscoveryManager
 //
 type PeerDiscoveryManagerConfig struct {
 	MaxNumPeers        uint
 	SufficientNumPeers uint
+	Reputation         PeerReputationConfig
+	SeedPeerConnector  SeedPeerConnectorConfig
+	LatencyInterval    time.Duration // interval between the pings measuring the round-trip time to the peers
 }
 
 // CreatePeerDiscoveryManager creates an instance of the PeerDiscoveryManager
-func CreatePeerDiscoveryManager(msgr *Messenger, nodeInfo *p2ptypes.NodeInfo, addrBookFilePath string,
+func CreatePeerDiscoveryManager(msgr *Messenger, nodeInfo *p2ptypes.NodeInfo, privKey *crypto.PrivateKey, addrBookFilePath string,
 	routabilityRestrict bool, seedPeerNetAddresses []string,
 	networkProtocol string, localNetworkAddr string, skipUPNP bool, peerTable *pr.PeerTable,
 	config PeerDiscoveryManagerConfig) (*PeerDiscoveryManager, error) {
 
 	discMgr := &PeerDiscoveryManager{
-		messenger: msgr,
-		nodeInfo:  nodeInfo,
-		peerTable: peerTable,
+		messenger:  msgr,
+		nodeInfo:   nodeInfo,
+		privKey:    privKey,
+		peerTable:  peerTable,
+		reputation: createPeerReputationTracker(config.Reputation),
+
+		addPeerMutex: &sync.Mutex{},
+
+		latencyInterval: config.LatencyInterval,
 	}
 
 	discMgr.addrBook = NewAddrBook(addrBookFilePath, routabilityRestrict)
 
 	var err error
-	discMgr.seedPeerConnector, err = createSeedPeerConnector(discMgr, localNetworkAddr, seedPeerNetAddresses)
+	discMgr.seedPeerConnector, err = createSeedPeerConnector(discMgr, localNetworkAddr,
+		seedPeerNetAddresses, config.SeedPeerConnector)
 	if err != nil {
 		return discMgr, err
 	}
@@ -74,6 +98,9 @@ func GetDefaultPeerDiscoveryManagerConfig() PeerDiscoveryManagerConfig {
 	return PeerDiscoveryManagerConfig{
 		MaxNumPeers:        128,
 		SufficientNumPeers: 32,
+		Reputation:         GetDefaultPeerReputationConfig(),
+		SeedPeerConnector:  GetDefaultSeedPeerConnectorConfig(),
+		LatencyInterval:    cn.GetDefaultConnectionConfig().LatencyInterval,
 	}
 }
 
@@ -115,7 +142,14 @@ func (discMgr *PeerDiscoveryManager) Stop() {
 // peer. Otherwise, it disconnects from that peer
 func (discMgr *PeerDiscoveryManager) HandlePeerWithErrors(peer *pr.Peer) {
 	peer.Stop()
+	if existing := discMgr.peerTable.GetPeer(peer.ID()); existing != nil && existing != peer {
+		// A redundant connection which has been superseded, the peer is still connected
+		return
+	}
 	discMgr.peerTable.DeletePeer(peer.ID())
+	if discMgr.messenger != nil {
+		discMgr.messenger.rateLimiter.RemovePeer(peer.ID())
+	}
 
 	if peer.IsPersistent() {
 		var err error
@@ -135,10 +169,40 @@ func (discMgr *PeerDiscoveryManager) HandlePeerWithErrors(peer *pr.Peer) {
 	}
 }
 
+// PenalizePeer decreases the reputation score of the peer, and bans the peer if the score
+// drops below the limit
+func (discMgr *PeerDiscoveryManager) PenalizePeer(peerID string, penalty int) {
+	if discMgr.reputation.Penalize(peerID, penalty) {
+		log.Warnf("[p2p] Banning peer %v for misbehaving", peerID)
+		discMgr.disconnectPeer(peerID)
+	}
+}
+
+// BanPeer bans the peer for the given duration, and disconnects from the peer if connected.
+// Connections from or to a banned peer are refused until the ban expires.
+func (discMgr *PeerDiscoveryManager) BanPeer(peerID string, duration time.Duration) {
+	discMgr.reputation.Ban(peerID, duration)
+	discMgr.disconnectPeer(peerID)
+}
+
+// IsPeerBanned returns whether the peer is currently banned
+func (discMgr *PeerDiscoveryManager) IsPeerBanned(peerID string) bool {
+	return discMgr.reputation.IsBanned(peerID)
+}
+
+func (discMgr *PeerDiscoveryManager) disconnectPeer(peerID string) {
+	peer := discMgr.peerTable.GetPeer(peerID)
+	if peer == nil {
+		return
+	}
+	discMgr.peerTable.DeletePeer(peerID)
+	peer.Stop()
+}
+
 func (discMgr *PeerDiscoveryManager) connectToOutboundPeer(peerNetAddress *netutil.NetAddress, persistent bool) (*pr.Peer, error) {
 	log.Infof("[p2p] Connecting to outbound peer: %v...", peerNetAddress)
 	peerConfig := pr.GetDefaultPeerConfig()
-	connConfig := cn.GetDefaultConnectionConfig()
+	connConfig := discMgr.connectionConfig()
 	peer, err := pr.CreateOutboundPeer(peerNetAddress, peerConfig, connConfig)
 	if err != nil {
 		log.Errorf("[p2p] Failed to create outbound peer: %v", peerNetAddress)
@@ -152,7 +216,7 @@ func (discMgr *PeerDiscoveryManager) connectToOutboundPeer(peerNetAddress *netut
 func (discMgr *PeerDiscoveryManager) connectWithInboundPeer(netconn net.Conn, persistent bool) (*pr.Peer, error) {
 	log.Infof("[p2p] Connecting with inbound peer: %v...", netconn.RemoteAddr())
 	peerConfig := pr.GetDefaultPeerConfig()
-	connConfig := cn.GetDefaultConnectionConfig()
+	connConfig := discMgr.connectionConfig()
 	peer, err := pr.CreateInboundPeer(netconn, peerConfig, connConfig)
 	if err != nil {
 		log.Errorf("[p2p] Failed to create inbound peer: %v", netconn.RemoteAddr())
@@ -163,14 +227,38 @@ func (discMgr *PeerDiscoveryManager) connectWithInboundPeer(netconn net.Conn, pe
 	return peer, err
 }
 
+// connectionConfig returns the configuration of the connections to the peers
+func (discMgr *PeerDiscoveryManager) connectionConfig() cn.ConnectionConfig {
+	connConfig := cn.GetDefaultConnectionConfig()
+	connConfig.LatencyInterval = discMgr.latencyInterval
+	return connConfig
+}
+
 // handshakeAndAddPeer performs handshake with a peer. Upon successful handshake,
 // it save the peer to the peer table
 func (discMgr *PeerDiscoveryManager) handshakeAndAddPeer(peer *pr.Peer) error {
-	if err := peer.Handshake(discMgr.nodeInfo); err != nil {
+	if err := peer.Handshake(discMgr.nodeInfo, discMgr.privKey); err != nil {
 		log.Errorf("[p2p] Failed to handshake with peer, error: %v", err)
 		return err
 	}
 
+	if discMgr.reputation.IsBanned(peer.ID()) {
+		peer.Stop()
+		errMsg := fmt.Sprintf("[p2p] Refused to connect with banned peer %v", peer.ID())
+		log.Warn(errMsg)
+		return errors.New(errMsg)
+	}
+
+	discMgr.addPeerMutex.Lock()
+	defer discMgr.addPeerMutex.Unlock()
+
+	existing := discMgr.peerTable.GetPeer(peer.ID())
+	if existing != nil && discMgr.isPreferredConnection(existing) && !discMgr.isPreferredConnection(peer) {
+		peer.Stop()
+		log.Infof("[p2p] Dropped redundant connection to peer %v", peer.ID())
+		return ErrDuplicateConnection
+	}
+
 	if discMgr.messenger != nil {
 		discMgr.messenger.AttachMessageHandlersToPeer(peer)
 	} else {
@@ -183,6 +271,14 @@ func (discMgr *PeerDiscoveryManager) handshakeAndAddPeer(peer *pr.Peer) error {
 		return errors.New(errMsg)
 	}
 
+	if existing != nil {
+		// Remove the superseded peer before stopping it, so that it is no longer
+		// handed out to the message handlers
+		discMgr.peerTable.DeletePeer(existing.ID())
+		existing.Stop()
+		log.Infof("[p2p] Replaced redundant connection to peer %v", peer.ID())
+	}
+
 	if !discMgr.peerTable.AddPeer(peer) {
 		errMsg := "[p2p] Failed to add peer to the peerTable"
 		log.Errorf(errMsg)
@@ -191,3 +287,12 @@ func (discMgr *PeerDiscoveryManager) handshakeAndAddPeer(peer *pr.Peer) error {
 
 	return nil
 }
+
+// isPreferredConnection returns whether the connection to the peer is the one to keep when two
+// connections to the same peer form, e.g. when both nodes dial each other simultaneously. The
+// connection initiated by the node with the lexicographically lower ID is kept, so that both
+// nodes drop the same connection.
+func (discMgr *PeerDiscoveryManager) isPreferredConnection(peer *pr.Peer) bool {
+	selfID := discMgr.nodeInfo.PubKey.Address().Hex()
+	return peer.IsOutbound() == (selfID < peer.ID())
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thetatoken/ukulele/p2p/netutil"
 	pr "github.com/thetatoken/ukulele/p2p/peer"
 	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
 )
@@ -333,19 +336,66 @@ func TestPeerDiscoveryMessageHandler(t *testing.T) {
 	assert.Empty(peerIds)
 }
 
+func TestPeerDiscoveryManagerDuplicateConnection(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	peerANetAddr := "127.0.0.1:24561"
+	peerBNetAddr := "127.0.0.1:24562"
+
+	discMgrA := newTestPeerDiscoveryManager([]string{}, peerANetAddr)
+	require.Nil(discMgrA.Start())
+	discMgrB := newTestPeerDiscoveryManager([]string{}, peerBNetAddr)
+	require.Nil(discMgrB.Start())
+	peerAID := discMgrA.nodeInfo.PubKey.Address().Hex()
+	peerBID := discMgrB.nodeInfo.PubKey.Address().Hex()
+
+	// Both peers dial each other simultaneously
+	netAddrA, err := netutil.NewNetAddressString(peerANetAddr)
+	require.Nil(err)
+	netAddrB, err := netutil.NewNetAddressString(peerBNetAddr)
+	require.Nil(err)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		discMgrA.connectToOutboundPeer(netAddrB, false)
+	}()
+	go func() {
+		defer wg.Done()
+		discMgrB.connectToOutboundPeer(netAddrA, false)
+	}()
+	wg.Wait()
+
+	// Only the connection initiated by the peer with the lower ID survives on both sides
+	survived := func() bool {
+		peerB := discMgrA.peerTable.GetPeer(peerBID)
+		peerA := discMgrB.peerTable.GetPeer(peerAID)
+		return peerA != nil && peerB != nil &&
+			peerB.IsOutbound() == (peerAID < peerBID) &&
+			peerA.IsOutbound() == (peerBID < peerAID)
+	}
+	for i := 0; i < 100 && !survived(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(survived())
+	assert.Equal(uint(1), discMgrA.peerTable.GetTotalNumPeers())
+	assert.Equal(uint(1), discMgrB.peerTable.GetTotalNumPeers())
+}
+
 // --------------- Test Utilities --------------- //
 
 func newTestPeerDiscoveryManager(seedPeerNetAddressStrs []string, localNetworkAddress string) *PeerDiscoveryManager {
 	messenger := (*Messenger)(nil) // not important for the test
-	peerPubKey := p2ptypes.GetTestRandPubKey()
-	peerNodeInfo := p2ptypes.CreateNodeInfo(peerPubKey)
+	peerPrivKey := p2ptypes.GetTestRandPrivKey()
+	peerNodeInfo := p2ptypes.CreateNodeInfo(peerPrivKey.PublicKey())
 	addrbookPath := "./.addrbooks/addrbook_" + localNetworkAddress + ".json"
 	routabilityRestrict := false
 	networkProtocol := "tcp"
 	skipUPNP := true
 	peerTable := pr.CreatePeerTable()
 	config := GetDefaultPeerDiscoveryManagerConfig()
-	discMgr, err := CreatePeerDiscoveryManager(messenger, &peerNodeInfo, addrbookPath, routabilityRestrict,
+	discMgr, err := CreatePeerDiscoveryManager(messenger, &peerNodeInfo, peerPrivKey, addrbookPath, routabilityRestrict,
 		seedPeerNetAddressStrs, networkProtocol, localNetworkAddress,
 		skipUPNP, &peerTable, config)
 	if err != nil {
@@ -366,6 +416,5 @@ func newTestPeerDiscoveryManagerAndMessenger(seedPeerNetAddressStrs []string, lo
 	messenger := newTestMessenger(seedPeerNetAddressStrs, port)
 	peerDiscoveryManager := messenger.discMgr
 	peerDiscoveryManager.peerDiscMsgHandler.peerDiscoveryPulseInterval = 1 * time.Second
-	messenger.RegisterMessageHandler(&peerDiscoveryManager.peerDiscMsgHandler)
 	return peerDiscoveryManager
 }
@@ -1,13 +1,17 @@
 package messenger
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/crypto"
 	"github.com/thetatoken/ukulele/p2p"
+	"github.com/thetatoken/ukulele/p2p/netutil"
 	pr "github.com/thetatoken/ukulele/p2p/peer"
 	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
 )
@@ -20,6 +24,8 @@ var _ p2p.Network = (*Messenger)(nil)
 type Messenger struct {
 	discMgr       *PeerDiscoveryManager
 	msgHandlerMap map[common.ChannelIDEnum](p2p.MessageHandler)
+	rateLimiter   *RateLimiter
+	metrics       *messengerMetrics
 
 	peerTable pr.PeerTable
 	nodeInfo  p2ptypes.NodeInfo // information of our blockchain node
@@ -35,22 +41,35 @@ type MessengerConfig struct {
 	routabilityRestrict bool
 	skipUPNP            bool
 	networkProtocol     string
+	reputation          PeerReputationConfig
+
+	compression          byte // message compression offered to the peers during the handshake
+	compressionThreshold int  // messages smaller than the threshold in bytes are not compressed
+
+	latencyInterval time.Duration // interval between the pings measuring the round-trip time to the peers, 0 means the default
 }
 
 // CreateMessenger creates an instance of Messenger
-func CreateMessenger(pubKey *crypto.PublicKey, seedPeerNetAddresses []string,
+func CreateMessenger(privKey *crypto.PrivateKey, seedPeerNetAddresses []string,
 	port int, msgrConfig MessengerConfig) (*Messenger, error) {
 
 	messenger := &Messenger{
 		msgHandlerMap: make(map[common.ChannelIDEnum](p2p.MessageHandler)),
+		rateLimiter:   createRateLimiter(),
+		metrics:       newMessengerMetrics(),
 		peerTable:     pr.CreatePeerTable(),
-		nodeInfo:      p2ptypes.CreateNodeInfo(pubKey),
+		nodeInfo:      p2ptypes.CreateNodeInfo(privKey.PublicKey()),
 		config:        msgrConfig,
 	}
+	messenger.nodeInfo.Compression = msgrConfig.compression
 
 	localNetAddress := "127.0.0.1:" + strconv.Itoa(port)
 	discMgrConfig := GetDefaultPeerDiscoveryManagerConfig()
-	discMgr, err := CreatePeerDiscoveryManager(messenger, &(messenger.nodeInfo),
+	discMgrConfig.Reputation = msgrConfig.reputation
+	if msgrConfig.latencyInterval > 0 {
+		discMgrConfig.LatencyInterval = msgrConfig.latencyInterval
+	}
+	discMgr, err := CreatePeerDiscoveryManager(messenger, &(messenger.nodeInfo), privKey,
 		msgrConfig.addrBookFilePath, msgrConfig.routabilityRestrict,
 		seedPeerNetAddresses, msgrConfig.networkProtocol,
 		localNetAddress, msgrConfig.skipUPNP, &messenger.peerTable, discMgrConfig)
@@ -62,6 +81,17 @@ func CreateMessenger(pubKey *crypto.PublicKey, seedPeerNetAddresses []string,
 	discMgr.SetMessenger(messenger)
 	messenger.SetPeerDiscoveryManager(discMgr)
 
+	// The discovery routine of the discMgr requests the peer addresses over the
+	// ChannelIDPeerDiscovery channel, which needs a handler on both nodes
+	messenger.RegisterMessageHandler(&discMgr.peerDiscMsgHandler)
+
+	externalAddress, err := messenger.ExternalAddress()
+	if err != nil {
+		return messenger, err
+	}
+	messenger.nodeInfo.NetAddress = externalAddress
+	log.Infof("[p2p] External network address: %v", externalAddress)
+
 	return messenger, nil
 }
 
@@ -72,6 +102,10 @@ func GetDefaultMessengerConfig() MessengerConfig {
 		routabilityRestrict: false,
 		skipUPNP:            false,
 		networkProtocol:     "tcp",
+		reputation:          GetDefaultPeerReputationConfig(),
+
+		compression:          p2ptypes.CompressionGzip,
+		compressionThreshold: defaultCompressionThreshold,
 	}
 }
 
@@ -110,15 +144,21 @@ func (msgr *Messenger) Broadcast(message p2ptypes.Message) (successes chan bool)
 func (msgr *Messenger) Send(peerID string, message p2ptypes.Message) bool {
 	peer := msgr.peerTable.GetPeer(peerID)
 	if peer == nil {
+		msgr.metrics.record(MetricsEvent{Type: MetricsEventMessageDropped, PeerID: peerID, ChannelID: message.ChannelID})
 		return false
 	}
 
 	success := peer.Send(message.ChannelID, message.Content)
+	if !success {
+		msgr.metrics.record(MetricsEvent{Type: MetricsEventMessageDropped, PeerID: peerID, ChannelID: message.ChannelID})
+	}
 
 	return success
 }
 
-// RegisterMessageHandler registers the message handler
+// RegisterMessageHandler registers the message handler. If the message handler implements the
+// p2p.RateLimitedMessageHandler interface, the messages exceeding the rate limits of its channels
+// are dropped.
 func (msgr *Messenger) RegisterMessageHandler(msgHandler p2p.MessageHandler) {
 	channelIDs := msgHandler.GetChannelIDs()
 	for _, channelID := range channelIDs {
@@ -127,6 +167,13 @@ func (msgr *Messenger) RegisterMessageHandler(msgHandler p2p.MessageHandler) {
 			return
 		}
 		msgr.msgHandlerMap[channelID] = msgHandler
+		msgr.nodeInfo.Channels = append(msgr.nodeInfo.Channels, channelID)
+	}
+
+	if rateLimitedHandler, ok := msgHandler.(p2p.RateLimitedMessageHandler); ok {
+		for channelID, limit := range rateLimitedHandler.GetChannelRateLimits() {
+			msgr.rateLimiter.SetLimit(channelID, limit)
+		}
 	}
 }
 
@@ -135,22 +182,138 @@ func (msgr *Messenger) ID() string {
 	return msgr.nodeInfo.PubKey.Address().Hex()
 }
 
+// Metrics returns a snapshot of the message counters. The messages and bytes sent are counted
+// when the messages are encoded for the peers, and the bytes are counted as on the wire, i.e.
+// after compression.
+func (msgr *Messenger) Metrics() MessengerMetrics {
+	return msgr.metrics.snapshot()
+}
+
+// SetMetricsCallback sets the callback invoked on each metrics event, e.g. for Prometheus
+// integration
+func (msgr *Messenger) SetMetricsCallback(callback MetricsCallback) {
+	msgr.metrics.setCallback(callback)
+}
+
+// PeerInfo returns the information of the connected peer negotiated during the handshake
+func (msgr *Messenger) PeerInfo(peerID string) (p2ptypes.PeerInfo, bool) {
+	peer := msgr.peerTable.GetPeer(peerID)
+	if peer == nil {
+		return p2ptypes.PeerInfo{}, false
+	}
+	return peer.Info(), true
+}
+
+// BanPeer bans the peer for the given duration. The peer is disconnected, and refused on
+// reconnect until the ban expires.
+func (msgr *Messenger) BanPeer(peerID string, duration time.Duration) {
+	msgr.discMgr.BanPeer(peerID, duration)
+}
+
+// ExternalAddress returns the external network address of the current node, which is
+// obtained with UPNP port mapping unless UPNP is skipped
+func (msgr *Messenger) ExternalAddress() (string, error) {
+	if msgr.discMgr == nil {
+		return "", errors.New("Peer discovery manager is not set")
+	}
+	externalAddr := msgr.discMgr.inboundPeerListener.ExternalAddress()
+	if externalAddr == nil {
+		return "", errors.New("External network address is not determined")
+	}
+	return externalAddr.String(), nil
+}
+
+// ExportAddrBook returns the routable peer addresses in the address book, e.g. to seed the
+// address books of new nodes with ImportAddrBook
+func (msgr *Messenger) ExportAddrBook() ([]string, error) {
+	if msgr.discMgr == nil {
+		return nil, errors.New("Peer discovery manager is not set")
+	}
+	addrs := []string{}
+	for _, addr := range msgr.discMgr.addrBook.ExportAddresses() {
+		addrs = append(addrs, addr.String())
+	}
+	return addrs, nil
+}
+
+// ImportAddrBook merges the given peer addresses into the address book and saves it. Nothing is
+// imported if any of the addresses is malformed. Non-routable addresses are skipped if routability
+// is restricted.
+func (msgr *Messenger) ImportAddrBook(addrs []string) error {
+	if msgr.discMgr == nil {
+		return errors.New("Peer discovery manager is not set")
+	}
+	netAddrs, err := netutil.NewNetAddressStrings(addrs)
+	if err != nil {
+		return err
+	}
+	msgr.discMgr.addrBook.ImportAddresses(netAddrs)
+	msgr.discMgr.addrBook.Save()
+	return nil
+}
+
 // AttachMessageHandlersToPeer attaches the registerred message handlers to the given peer
 func (msgr *Messenger) AttachMessageHandlersToPeer(peer *pr.Peer) {
 	messageParser := func(channelID common.ChannelIDEnum, rawMessageBytes common.Bytes) (p2ptypes.Message, error) {
 		peerID := peer.ID()
+		if !msgr.rateLimiter.Allow(peerID, channelID, time.Now()) {
+			msgr.metrics.record(MetricsEvent{Type: MetricsEventMessageDropped, PeerID: peerID, ChannelID: channelID})
+			msgr.discMgr.PenalizePeer(peerID, msgr.config.reputation.RateLimitPenalty)
+			return p2ptypes.Message{}, fmt.Errorf("Rate limit exceeded on channelID %v", channelID)
+		}
+		if !peer.SupportsChannel(channelID) {
+			msgr.metrics.record(MetricsEvent{Type: MetricsEventMessageDropped, PeerID: peerID, ChannelID: channelID})
+			return p2ptypes.Message{}, fmt.Errorf("Channel %v is not supported by peer %v", channelID, peerID)
+		}
 		msgHandler := msgr.msgHandlerMap[channelID]
 		if msgHandler == nil {
 			log.Errorf("[p2p] Failed to setup message parser for channelID %v", channelID)
+			msgr.metrics.record(MetricsEvent{Type: MetricsEventMessageDropped, PeerID: peerID, ChannelID: channelID})
+			return p2ptypes.Message{}, fmt.Errorf("No message handler for channelID %v", channelID)
+		}
+		msgBytes, err := decompressMessage(peer.Compression(), rawMessageBytes)
+		if err != nil {
+			msgr.metrics.record(MetricsEvent{Type: MetricsEventParseError, PeerID: peerID, ChannelID: channelID})
+			msgr.discMgr.PenalizePeer(peerID, msgr.config.reputation.MalformedMessagePenalty)
+			return p2ptypes.Message{}, err
+		}
+		message, err := msgHandler.ParseMessage(peerID, channelID, msgBytes)
+		if err != nil {
+			msgr.metrics.record(MetricsEvent{Type: MetricsEventParseError, PeerID: peerID, ChannelID: channelID})
+			msgr.discMgr.PenalizePeer(peerID, msgr.config.reputation.MalformedMessagePenalty)
+			return message, err
 		}
-		message, err := msgHandler.ParseMessage(peerID, channelID, rawMessageBytes)
-		return message, err
+		msgr.metrics.record(MetricsEvent{
+			Type:      MetricsEventMessageReceived,
+			PeerID:    peerID,
+			ChannelID: channelID,
+			NumBytes:  len(rawMessageBytes),
+		})
+		return message, nil
 	}
 	peer.GetConnection().SetMessageParser(messageParser)
 
 	messageEncoder := func(channelID common.ChannelIDEnum, message interface{}) (common.Bytes, error) {
 		msgHandler := msgr.msgHandlerMap[channelID]
-		return msgHandler.EncodeMessage(message)
+		if msgHandler == nil {
+			log.Errorf("[p2p] Failed to setup message encoder for peer %v on channelID %v", peer.ID(), channelID)
+			return nil, fmt.Errorf("No message handler for channelID %v", channelID)
+		}
+		msgBytes, err := msgHandler.EncodeMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		rawMessageBytes, err := compressMessage(peer.Compression(), msgr.config.compressionThreshold, msgBytes)
+		if err != nil {
+			return nil, err
+		}
+		msgr.metrics.record(MetricsEvent{
+			Type:      MetricsEventMessageSent,
+			PeerID:    peer.ID(),
+			ChannelID: channelID,
+			NumBytes:  len(rawMessageBytes),
+		})
+		return rawMessageBytes, nil
 	}
 	peer.GetConnection().SetMessageEncoder(messageEncoder)
 
@@ -159,8 +322,12 @@ func (msgr *Messenger) AttachMessageHandlersToPeer(peer *pr.Peer) {
 		msgHandler := msgr.msgHandlerMap[channelID]
 		if msgHandler == nil {
 			log.Errorf("[p2p] Failed to setup message handler for peer %v on channelID %v", message.PeerID, channelID)
+			return fmt.Errorf("No message handler for channelID %v", channelID)
 		}
 		err := msgHandler.HandleMessage(message)
+		if _, ok := err.(p2p.InvalidMessageError); ok {
+			msgr.discMgr.PenalizePeer(peer.ID(), msgr.config.reputation.InvalidMessagePenalty)
+		}
 		return err
 	}
 	peer.GetConnection().SetReceiveHandler(receiveHandler)
@@ -175,3 +342,26 @@ func (msgr *Messenger) AttachMessageHandlersToPeer(peer *pr.Peer) {
 func (msgrConfig *MessengerConfig) SetAddressBookFilePath(filePath string) {
 	msgrConfig.addrBookFilePath = filePath
 }
+
+// SetSkipUPNP sets whether to skip the UPNP port mapping
+func (msgrConfig *MessengerConfig) SetSkipUPNP(skipUPNP bool) {
+	msgrConfig.skipUPNP = skipUPNP
+}
+
+// SetPeerReputationConfig sets the thresholds for penalizing and banning the misbehaving peers
+func (msgrConfig *MessengerConfig) SetPeerReputationConfig(config PeerReputationConfig) {
+	msgrConfig.reputation = config
+}
+
+// SetLatencyInterval sets the interval between the pings measuring the round-trip time to the
+// peers, which is reported as PeerInfo.Latency
+func (msgrConfig *MessengerConfig) SetLatencyInterval(interval time.Duration) {
+	msgrConfig.latencyInterval = interval
+}
+
+// SetCompression sets the message compression offered to the peers, and the size in bytes
+// below which the messages are not compressed
+func (msgrConfig *MessengerConfig) SetCompression(compression byte, threshold int) {
+	msgrConfig.compression = compression
+	msgrConfig.compressionThreshold = threshold
+}
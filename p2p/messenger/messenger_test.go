@@ -1,14 +1,22 @@
 package messenger
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/p2p"
+	"github.com/thetatoken/ukulele/p2p/netutil"
+	pr "github.com/thetatoken/ukulele/p2p/peer"
 	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
 	"github.com/thetatoken/ukulele/rlp"
 )
@@ -87,6 +95,12 @@ func TestMessengerBroadcastMessages(t *testing.T) {
 	_ = <-peerAReady
 	_ = <-peerBReady
 
+	// Peer B reports ready once its side of the handshake completes, Peer C may add Peer B
+	// to its peer table slightly later
+	for i := 0; i < 100 && messenger.peerTable.GetTotalNumPeers() < 2; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+
 	// ---------------- PeerC broadcasts messages to PeerA and PeerB ---------------- //
 
 	for _, peerCMsg := range peerCMessages {
@@ -158,8 +172,442 @@ func (thm *TestMessageHandler) HandleMessage(message p2ptypes.Message) error {
 	return nil
 }
 
+func TestMessengerExternalAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	// The address of the network interface is used when UPNP is skipped
+	messenger := newTestMessenger([]string{}, 24621)
+	defer messenger.discMgr.inboundPeerListener.Stop()
+	externalAddress, err := messenger.ExternalAddress()
+	assert.Nil(err)
+	assert.NotEqual("", externalAddress)
+	assert.Equal(externalAddress, messenger.nodeInfo.NetAddress)
+
+	// The loopback address is never advertised, the address of the network interface is used
+	// instead when UPNP fails
+	discoverNAT = func() (netutil.NAT, error) { return nil, errors.New("No UPNP gateway") }
+	defer func() { discoverNAT = netutil.Discover }()
+
+	port := 24622
+	msgrConfig := MessengerConfig{
+		addrBookFilePath:    "./.addrbooks/addrbook_upnp.json",
+		routabilityRestrict: false,
+		skipUPNP:            false,
+		networkProtocol:     "tcp",
+	}
+	upnpMessenger, err := CreateMessenger(p2ptypes.GetTestRandPrivKey(), []string{}, port, msgrConfig)
+	assert.Nil(err)
+	defer upnpMessenger.discMgr.inboundPeerListener.Stop()
+	externalAddress, err = upnpMessenger.ExternalAddress()
+	assert.Nil(err)
+	assert.NotEqual("127.0.0.1:"+strconv.Itoa(port), externalAddress)
+	assert.Equal(getNaiveExternalAddress(port).String(), externalAddress)
+	assert.Equal(externalAddress, upnpMessenger.nodeInfo.NetAddress)
+
+	// A configured self address other than loopback is used when UPNP fails
+	assert.Equal("10.0.0.5:24623", getExternalNetAddress("10.0.0.5:24623", 24623, false).String())
+	assert.Equal(getNaiveExternalAddress(24623).String(), getExternalNetAddress("0.0.0.0:24623", 24623, false).String())
+}
+
+func TestMessengerAddrBookExportImport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	messengerA := newTestMessenger([]string{}, 24691)
+	defer messengerA.discMgr.inboundPeerListener.Stop()
+	addrs := []string{}
+	for _, addrSrc := range randNetAddressPairs(t, 10) {
+		messengerA.discMgr.addrBook.AddAddress(addrSrc.addr, addrSrc.src)
+		addrs = append(addrs, addrSrc.addr.String())
+	}
+	localAddr, err := netutil.NewNetAddressString("127.0.0.1:24699")
+	require.Nil(err)
+	messengerA.discMgr.addrBook.AddAddress(localAddr, localAddr)
+
+	// Only the routable addresses are exported
+	exported, err := messengerA.ExportAddrBook()
+	require.Nil(err)
+	sort.Strings(addrs)
+	assert.Equal(addrs, exported)
+
+	messengerB := newTestMessenger([]string{}, 24692)
+	defer messengerB.discMgr.inboundPeerListener.Stop()
+	require.Nil(messengerB.ImportAddrBook(exported))
+	reexported, err := messengerB.ExportAddrBook()
+	require.Nil(err)
+	assert.Equal(exported, reexported)
+
+	// Nothing is imported if any address is malformed
+	messengerC := newTestMessenger([]string{}, 24693)
+	defer messengerC.discMgr.inboundPeerListener.Stop()
+	assert.NotNil(messengerC.ImportAddrBook(append(exported, "not an address")))
+	assert.Equal(0, messengerC.discMgr.addrBook.Size())
+
+	// Non-routable addresses are skipped on import if routability is restricted
+	msgrConfig := MessengerConfig{
+		addrBookFilePath:    "./.addrbooks/addrbook_restricted.json",
+		routabilityRestrict: true,
+		skipUPNP:            true,
+		networkProtocol:     "tcp",
+	}
+	messengerD, err := CreateMessenger(p2ptypes.GetTestRandPrivKey(), []string{}, 24694, msgrConfig)
+	require.Nil(err)
+	defer messengerD.discMgr.inboundPeerListener.Stop()
+	require.Nil(messengerD.ImportAddrBook(append(exported, localAddr.String())))
+	assert.Equal(len(exported), messengerD.discMgr.addrBook.Size())
+}
+
+func TestMessengerBanPeerForMalformedMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	peerAPort := 24631
+	peerBPort := 24632
+	peerANetAddr := "127.0.0.1:" + strconv.Itoa(peerAPort)
+
+	// ---------------- Simulate PeerA, which fails to parse any message ---------------- //
+
+	msgrConfig := MessengerConfig{
+		addrBookFilePath:    "./.addrbooks/addrbook_" + peerANetAddr + ".json",
+		routabilityRestrict: false,
+		skipUPNP:            true,
+		networkProtocol:     "tcp",
+		reputation: PeerReputationConfig{
+			MalformedMessagePenalty: 10,
+			InvalidMessagePenalty:   1,
+			MinScore:                -25,
+			BanDuration:             time.Minute,
+		},
+	}
+	messengerA, err := CreateMessenger(p2ptypes.GetTestRandPrivKey(), []string{}, peerAPort, msgrConfig)
+	assert.Nil(err)
+	messengerA.RegisterMessageHandler(&MalformedMessageHandler{})
+	inboundErrs := make(chan error, 4)
+	messengerA.discMgr.inboundPeerListener.SetInboundCallback(func(peer *pr.Peer, err error) {
+		inboundErrs <- err
+	})
+	messengerA.Start()
+
+	// ---------------- Simulate PeerB, which connects to PeerA ---------------- //
+
+	messengerB := newTestMessenger([]string{peerANetAddr}, peerBPort)
+	messengerB.RegisterMessageHandler(newTestMessageHandler(messengerB.ID(), t, assert))
+	messengerB.Start()
+	assert.True(<-messengerB.discMgr.seedPeerConnector.Connected)
+	assert.Nil(<-inboundErrs)
+
+	// ---------------- PeerB sends malformed messages until it gets banned ---------------- //
+
+	for i := 0; i < 3; i++ {
+		messengerB.Send(messengerA.ID(), p2ptypes.Message{
+			ChannelID: common.ChannelIDTransaction,
+			Content:   "malformed message " + strconv.Itoa(i),
+		})
+	}
+	for i := 0; i < 100 && !messengerA.discMgr.IsPeerBanned(messengerB.ID()); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(messengerA.discMgr.IsPeerBanned(messengerB.ID()))
+	assert.Nil(messengerA.peerTable.GetPeer(messengerB.ID()))
+
+	// ---------------- The banned peer is refused on reconnect ---------------- //
+
+	peerANetAddress, err := netutil.NewNetAddressString(peerANetAddr)
+	assert.Nil(err)
+	messengerB.discMgr.connectToOutboundPeer(peerANetAddress, false)
+	assert.NotNil(<-inboundErrs)
+	assert.Nil(messengerA.peerTable.GetPeer(messengerB.ID()))
+}
+
+func TestMessengerChannelRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	peerAPort := 24641
+	peerBPort := 24642
+	peerANetAddr := "127.0.0.1:" + strconv.Itoa(peerAPort)
+
+	// ---------------- Simulate PeerA, which rate limits the transaction channel ---------------- //
+
+	msgrConfig := MessengerConfig{
+		addrBookFilePath:    "./.addrbooks/addrbook_" + peerANetAddr + ".json",
+		routabilityRestrict: false,
+		skipUPNP:            true,
+		networkProtocol:     "tcp",
+		reputation: PeerReputationConfig{
+			RateLimitPenalty: 1,
+			MinScore:         -100,
+			BanDuration:      time.Minute,
+		},
+	}
+	messengerA, err := CreateMessenger(p2ptypes.GetTestRandPrivKey(), []string{}, peerAPort, msgrConfig)
+	assert.Nil(err)
+	limit := p2p.RateLimit{Rate: 0.01, Burst: 3}
+	msgHandlerA := &RateLimitedTestMessageHandler{
+		TestMessageHandler: *(newTestMessageHandler(messengerA.ID(), t, assert).(*TestMessageHandler)),
+		limit:              limit,
+	}
+	msgHandlerA.recvMsgChan = make(chan string, 10)
+	messengerA.RegisterMessageHandler(msgHandlerA)
+	messengerA.Start()
+
+	// ---------------- Simulate PeerB, which sends a burst above the limit ---------------- //
+
+	messengerB := newTestMessenger([]string{peerANetAddr}, peerBPort)
+	messengerB.RegisterMessageHandler(newTestMessageHandler(messengerB.ID(), t, assert))
+	messengerB.Start()
+	assert.True(<-messengerB.discMgr.seedPeerConnector.Connected)
+
+	numMsgs := 10
+	for i := 0; i < numMsgs; i++ {
+		messengerB.Send(messengerA.ID(), p2ptypes.Message{
+			ChannelID: common.ChannelIDTransaction,
+			Content:   "message " + strconv.Itoa(i),
+		})
+	}
+
+	// ---------------- Only the messages within the limit are processed ---------------- //
+
+	for i := 0; i < limit.Burst; i++ {
+		select {
+		case msg := <-msgHandlerA.recvMsgChan:
+			assert.Equal("message "+strconv.Itoa(i), msg)
+		case <-time.After(5 * time.Second):
+			assert.Fail("Timed out waiting for the messages within the limit")
+		}
+	}
+	for i := 0; i < 50 && messengerA.discMgr.reputation.Score(messengerB.ID()) > limit.Burst-numMsgs; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(limit.Burst-numMsgs, messengerA.discMgr.reputation.Score(messengerB.ID()))
+	assert.Equal(0, len(msgHandlerA.recvMsgChan))
+}
+
+func TestMessengerCompressedMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	peerAPort := 24651
+	peerBPort := 24652
+	peerANetAddr := "127.0.0.1:" + strconv.Itoa(peerAPort)
+
+	newCompressingMessenger := func(seedPeerNetAddressStrs []string, port int) *Messenger {
+		msgrConfig := MessengerConfig{
+			addrBookFilePath:    "./.addrbooks/addrbook_compression_" + strconv.Itoa(port) + ".json",
+			routabilityRestrict: false,
+			skipUPNP:            true,
+			networkProtocol:     "tcp",
+		}
+		msgrConfig.SetCompression(p2ptypes.CompressionGzip, 1024)
+		messenger, err := CreateMessenger(p2ptypes.GetTestRandPrivKey(), seedPeerNetAddressStrs, port, msgrConfig)
+		assert.Nil(err)
+		return messenger
+	}
+
+	messengerA := newCompressingMessenger([]string{}, peerAPort)
+	msgHandlerA := newTestMessageHandler(messengerA.ID(), t, assert).(*TestMessageHandler)
+	messengerA.RegisterMessageHandler(msgHandlerA)
+	messengerA.Start()
+
+	messengerB := newCompressingMessenger([]string{peerANetAddr}, peerBPort)
+	messengerB.RegisterMessageHandler(newTestMessageHandler(messengerB.ID(), t, assert))
+	messengerB.Start()
+	assert.True(<-messengerB.discMgr.seedPeerConnector.Connected)
+	peerA := messengerB.peerTable.GetPeer(messengerA.ID())
+	assert.NotNil(peerA)
+	assert.Equal(p2ptypes.CompressionGzip, peerA.Compression())
+
+	// Both the compressed large message and the uncompressed small message are decoded
+	largeMsg := strings.Repeat("Theta is awesome, period. ", 10000)
+	smallMsg := "Hi this is Peer B"
+	for _, msg := range []string{largeMsg, smallMsg} {
+		messengerB.Send(messengerA.ID(), p2ptypes.Message{
+			ChannelID: common.ChannelIDTransaction,
+			Content:   msg,
+		})
+		select {
+		case recvMsg := <-msgHandlerA.recvMsgChan:
+			assert.Equal(msg, recvMsg)
+		case <-time.After(10 * time.Second):
+			assert.Fail("Timed out waiting for the message")
+		}
+	}
+}
+
+func TestMessengerUnsupportedChannels(t *testing.T) {
+	assert := assert.New(t)
+
+	peerAPort := 24661
+	peerBPort := 24662
+	peerANetAddr := "127.0.0.1:" + strconv.Itoa(peerAPort)
+
+	// PeerA only handles the transaction channel
+	messengerA := newTestMessenger([]string{}, peerAPort)
+	msgHandlerA := newTestMessageHandler(messengerA.ID(), t, assert).(*TestMessageHandler)
+	messengerA.RegisterMessageHandler(msgHandlerA)
+	messengerA.Start()
+
+	// PeerB only handles the vote channel
+	messengerB := newTestMessenger([]string{peerANetAddr}, peerBPort)
+	msgHandlerB := &ChannelTestMessageHandler{
+		TestMessageHandler: *(newTestMessageHandler(messengerB.ID(), t, assert).(*TestMessageHandler)),
+		channelID:          common.ChannelIDVote,
+	}
+	messengerB.RegisterMessageHandler(msgHandlerB)
+	messengerB.Start()
+	assert.True(<-messengerB.discMgr.seedPeerConnector.Connected)
+
+	peerAInfo, ok := messengerB.PeerInfo(messengerA.ID())
+	assert.True(ok)
+	assert.Equal(messengerA.ID(), peerAInfo.ID)
+	assert.Equal(p2ptypes.ProtocolVersion, peerAInfo.ProtocolVersion)
+	assert.Equal([]common.ChannelIDEnum{common.ChannelIDPeerDiscovery}, peerAInfo.Channels) // registered by every messenger
+
+	// The messages on the channels not supported by both peers are not delivered
+	assert.False(messengerB.Send(messengerA.ID(), p2ptypes.Message{
+		ChannelID: common.ChannelIDVote,
+		Content:   "vote message",
+	}))
+	assert.False(messengerB.Send(messengerA.ID(), p2ptypes.Message{
+		ChannelID: common.ChannelIDTransaction,
+		Content:   "transaction message",
+	}))
+	assert.False(messengerA.Send(messengerB.ID(), p2ptypes.Message{
+		ChannelID: common.ChannelIDTransaction,
+		Content:   "transaction message",
+	}))
+	select {
+	case msg := <-msgHandlerA.recvMsgChan:
+		assert.Fail("Unexpected message delivered to PeerA: " + msg)
+	case msg := <-msgHandlerB.recvMsgChan:
+		assert.Fail("Unexpected message delivered to PeerB: " + msg)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestMessengerPeerLatency(t *testing.T) {
+	assert := assert.New(t)
+
+	peerAPort := 24681
+	peerBPort := 24682
+	peerANetAddr := "127.0.0.1:" + strconv.Itoa(peerAPort)
+
+	newPingingMessenger := func(seedPeerNetAddressStrs []string, port int) *Messenger {
+		msgrConfig := MessengerConfig{
+			addrBookFilePath:    "./.addrbooks/addrbook_latency_" + strconv.Itoa(port) + ".json",
+			routabilityRestrict: false,
+			skipUPNP:            true,
+			networkProtocol:     "tcp",
+		}
+		msgrConfig.SetLatencyInterval(50 * time.Millisecond)
+		messenger, err := CreateMessenger(p2ptypes.GetTestRandPrivKey(), seedPeerNetAddressStrs, port, msgrConfig)
+		assert.Nil(err)
+		return messenger
+	}
+
+	messengerA := newPingingMessenger([]string{}, peerAPort)
+	messengerA.RegisterMessageHandler(newTestMessageHandler(messengerA.ID(), t, assert))
+	messengerA.Start()
+
+	messengerB := newPingingMessenger([]string{peerANetAddr}, peerBPort)
+	messengerB.RegisterMessageHandler(newTestMessageHandler(messengerB.ID(), t, assert))
+	messengerB.Start()
+	assert.True(<-messengerB.discMgr.seedPeerConnector.Connected)
+
+	// The latency is populated on both sides after a few ping cycles
+	for _, pair := range []struct {
+		msgr   *Messenger
+		peerID string
+	}{
+		{messengerB, messengerA.ID()},
+		{messengerA, messengerB.ID()},
+	} {
+		var latency time.Duration
+		for i := 0; i < 100 && latency == 0; i++ {
+			time.Sleep(50 * time.Millisecond)
+			if info, ok := pair.msgr.PeerInfo(pair.peerID); ok {
+				latency = info.Latency
+			}
+		}
+		assert.True(latency > 0, "latency to peer %v not measured", pair.peerID)
+		assert.True(latency < 5*time.Second, "latency to peer %v: %v", pair.peerID, latency)
+	}
+}
+
+func TestMessengerMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	peerAPort := 24671
+	peerBPort := 24672
+	peerCPort := 24673
+	peerANetAddr := "127.0.0.1:" + strconv.Itoa(peerAPort)
+
+	messengerA := newTestMessenger([]string{}, peerAPort)
+	messengerA.RegisterMessageHandler(newTestMessageHandler(messengerA.ID(), t, assert))
+	var numEvents uint64
+	messengerA.SetMetricsCallback(func(event MetricsEvent) {
+		if event.Type == MetricsEventMessageSent {
+			atomic.AddUint64(&numEvents, 1)
+		}
+	})
+	messengerA.Start()
+
+	receivers := []*TestMessageHandler{}
+	messengers := []*Messenger{}
+	for _, port := range []int{peerBPort, peerCPort} {
+		messenger := newTestMessenger([]string{peerANetAddr}, port)
+		msgHandler := newTestMessageHandler(messenger.ID(), t, assert).(*TestMessageHandler)
+		msgHandler.recvMsgChan = make(chan string, 10)
+		messenger.RegisterMessageHandler(msgHandler)
+		messenger.Start()
+		assert.True(<-messenger.discMgr.seedPeerConnector.Connected)
+		receivers = append(receivers, msgHandler)
+		messengers = append(messengers, messenger)
+	}
+	for i := 0; i < 100 && messengerA.peerTable.GetTotalNumPeers() < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	numPeers := messengerA.peerTable.GetTotalNumPeers()
+	assert.Equal(uint(2), numPeers)
+
+	messages := []string{
+		"Hi this is Peer A",
+		"Theta is awesome, period",
+		"Bye",
+	}
+	for _, msg := range messages {
+		successes := messengerA.Broadcast(p2ptypes.Message{
+			ChannelID: common.ChannelIDTransaction,
+			Content:   msg,
+		})
+		for i := uint(0); i < numPeers; i++ {
+			assert.True(<-successes)
+		}
+	}
+	for _, receiver := range receivers {
+		for range messages {
+			select {
+			case <-receiver.recvMsgChan:
+			case <-time.After(10 * time.Second):
+				assert.Fail("Timed out waiting for the message")
+			}
+		}
+	}
+
+	metrics := messengerA.Metrics()
+	sent := metrics.Channels[common.ChannelIDTransaction]
+	assert.Equal(uint64(len(messages))*uint64(numPeers), sent.MessagesSent)
+	assert.True(sent.BytesSent > 0)
+	assert.Equal(sent.MessagesSent, metrics.MessagesSent)
+	assert.Equal(sent.MessagesSent, atomic.LoadUint64(&numEvents))
+
+	for _, messenger := range messengers {
+		received := messenger.Metrics().Channels[common.ChannelIDTransaction]
+		assert.Equal(uint64(len(messages)), received.MessagesReceived)
+		assert.Equal(sent.BytesSent/uint64(numPeers), received.BytesReceived)
+		assert.Equal(uint64(0), received.ParseErrors)
+	}
+}
+
 func newTestMessenger(seedPeerNetAddressStrs []string, port int) *Messenger {
-	peerPubKey := p2ptypes.GetTestRandPubKey()
+	peerPrivKey := p2ptypes.GetTestRandPrivKey()
 	localNetworkAddress := "127.0.0.1:" + strconv.Itoa(port)
 	testMsgrConfig := MessengerConfig{
 		addrBookFilePath:    "./.addrbooks/addrbook_" + localNetworkAddress + ".json",
@@ -167,7 +615,7 @@ func newTestMessenger(seedPeerNetAddressStrs []string, port int) *Messenger {
 		skipUPNP:            true,
 		networkProtocol:     "tcp",
 	}
-	messenger, err := CreateMessenger(peerPubKey, seedPeerNetAddressStrs, port, testMsgrConfig)
+	messenger, err := CreateMessenger(peerPrivKey, seedPeerNetAddressStrs, port, testMsgrConfig)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create Messenger instance: %v", err))
 	}
@@ -182,3 +630,34 @@ func checkReceivedMessage(recvMsg *string, expectedMsgs *[]string) bool {
 	}
 	return false
 }
+
+// MalformedMessageHandler fails to parse any message
+type MalformedMessageHandler struct {
+	TestMessageHandler
+}
+
+func (mmh *MalformedMessageHandler) ParseMessage(peerID string, channelID common.ChannelIDEnum, rawMessageBytes common.Bytes) (p2ptypes.Message, error) {
+	return p2ptypes.Message{}, errors.New("Malformed message")
+}
+
+// RateLimitedTestMessageHandler rate limits the messages of the test channel
+type RateLimitedTestMessageHandler struct {
+	TestMessageHandler
+	limit p2p.RateLimit
+}
+
+func (rlmh *RateLimitedTestMessageHandler) GetChannelRateLimits() map[common.ChannelIDEnum]p2p.RateLimit {
+	return map[common.ChannelIDEnum]p2p.RateLimit{
+		common.ChannelIDTransaction: rlmh.limit,
+	}
+}
+
+// ChannelTestMessageHandler handles the messages of the given channel
+type ChannelTestMessageHandler struct {
+	TestMessageHandler
+	channelID common.ChannelIDEnum
+}
+
+func (ctmh *ChannelTestMessageHandler) GetChannelIDs() []common.ChannelIDEnum {
+	return []common.ChannelIDEnum{ctmh.channelID}
+}
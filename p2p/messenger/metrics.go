@@ -0,0 +1,139 @@
+package messenger
+
+import (
+	"sync/atomic"
+
+	"github.com/thetatoken/ukulele/common"
+)
+
+// MetricsEventType is the type of a Messenger metrics event
+type MetricsEventType byte
+
+const (
+	// MetricsEventMessageSent indicates a message is encoded and queued for a peer
+	MetricsEventMessageSent MetricsEventType = iota
+
+	// MetricsEventMessageReceived indicates a message is received and parsed
+	MetricsEventMessageReceived
+
+	// MetricsEventParseError indicates a received message cannot be parsed
+	MetricsEventParseError
+
+	// MetricsEventMessageDropped indicates a message is dropped, e.g. for exceeding the rate
+	// limit or being on a channel not supported by the peer
+	MetricsEventMessageDropped
+)
+
+//
+// MetricsEvent is passed to the MetricsCallback on each Messenger metrics event
+//
+type MetricsEvent struct {
+	Type      MetricsEventType
+	PeerID    string
+	ChannelID common.ChannelIDEnum
+	NumBytes  int
+}
+
+// MetricsCallback is called on each Messenger metrics event, e.g. to export the metrics to
+// Prometheus. It is called from the peer goroutines, so it must be safe for concurrent use.
+type MetricsCallback func(event MetricsEvent)
+
+//
+// ChannelMetrics is a snapshot of the message counters of a channel
+//
+type ChannelMetrics struct {
+	MessagesSent     uint64
+	BytesSent        uint64
+	MessagesReceived uint64
+	BytesReceived    uint64
+	ParseErrors      uint64
+	MessagesDropped  uint64
+}
+
+//
+// MessengerMetrics is a snapshot of the message counters of the Messenger
+//
+type MessengerMetrics struct {
+	Channels map[common.ChannelIDEnum]ChannelMetrics // channels with any activity
+
+	MessagesSent     uint64
+	BytesSent        uint64
+	MessagesReceived uint64
+	BytesReceived    uint64
+	ParseErrors      uint64
+	MessagesDropped  uint64
+}
+
+// messengerMetrics keeps the message counters, which are updated atomically by the peer goroutines
+type messengerMetrics struct {
+	channels [256]channelCounters // indexed by the channel ID
+	callback atomic.Value         // MetricsCallback
+}
+
+type channelCounters struct {
+	messagesSent     uint64
+	bytesSent        uint64
+	messagesReceived uint64
+	bytesReceived    uint64
+	parseErrors      uint64
+	messagesDropped  uint64
+}
+
+func newMessengerMetrics() *messengerMetrics {
+	return &messengerMetrics{}
+}
+
+func (mm *messengerMetrics) setCallback(callback MetricsCallback) {
+	mm.callback.Store(callback)
+}
+
+// record updates the counters for the event, and then invokes the callback if any
+func (mm *messengerMetrics) record(event MetricsEvent) {
+	counters := &mm.channels[event.ChannelID]
+	switch event.Type {
+	case MetricsEventMessageSent:
+		atomic.AddUint64(&counters.messagesSent, 1)
+		atomic.AddUint64(&counters.bytesSent, uint64(event.NumBytes))
+	case MetricsEventMessageReceived:
+		atomic.AddUint64(&counters.messagesReceived, 1)
+		atomic.AddUint64(&counters.bytesReceived, uint64(event.NumBytes))
+	case MetricsEventParseError:
+		atomic.AddUint64(&counters.parseErrors, 1)
+	case MetricsEventMessageDropped:
+		atomic.AddUint64(&counters.messagesDropped, 1)
+	}
+
+	if callback, ok := mm.callback.Load().(MetricsCallback); ok && callback != nil {
+		callback(event)
+	}
+}
+
+// snapshot returns the current values of the counters
+func (mm *messengerMetrics) snapshot() MessengerMetrics {
+	metrics := MessengerMetrics{
+		Channels: make(map[common.ChannelIDEnum]ChannelMetrics),
+	}
+	for i := range mm.channels {
+		counters := &mm.channels[i]
+		cm := ChannelMetrics{
+			MessagesSent:     atomic.LoadUint64(&counters.messagesSent),
+			BytesSent:        atomic.LoadUint64(&counters.bytesSent),
+			MessagesReceived: atomic.LoadUint64(&counters.messagesReceived),
+			BytesReceived:    atomic.LoadUint64(&counters.bytesReceived),
+			ParseErrors:      atomic.LoadUint64(&counters.parseErrors),
+			MessagesDropped:  atomic.LoadUint64(&counters.messagesDropped),
+		}
+		if cm == (ChannelMetrics{}) {
+			continue
+		}
+		metrics.Channels[common.ChannelIDEnum(i)] = cm
+
+		metrics.MessagesSent += cm.MessagesSent
+		metrics.BytesSent += cm.BytesSent
+		metrics.MessagesReceived += cm.MessagesReceived
+		metrics.BytesReceived += cm.BytesReceived
+		metrics.ParseErrors += cm.ParseErrors
+		metrics.MessagesDropped += cm.MessagesDropped
+	}
+	return metrics
+}
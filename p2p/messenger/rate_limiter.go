@@ -0,0 +1,91 @@
+package messenger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/p2p"
+)
+
+//
+// RateLimiter limits the rate of the messages per peer per channel with token buckets
+//
+type RateLimiter struct {
+	mutex *sync.Mutex
+
+	limits  map[common.ChannelIDEnum]p2p.RateLimit
+	buckets map[rateLimitKey]*tokenBucket
+}
+
+type rateLimitKey struct {
+	peerID    string
+	channelID common.ChannelIDEnum
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// createRateLimiter creates an instance of the RateLimiter
+func createRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		mutex:   &sync.Mutex{},
+		limits:  make(map[common.ChannelIDEnum]p2p.RateLimit),
+		buckets: make(map[rateLimitKey]*tokenBucket),
+	}
+}
+
+// SetLimit sets the rate limit of the channel
+func (rl *RateLimiter) SetLimit(channelID common.ChannelIDEnum, limit p2p.RateLimit) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.limits[channelID] = limit
+}
+
+// Allow consumes a token from the bucket of the peer for the channel. It returns false if the
+// bucket is empty, i.e. the message exceeds the rate limit and should be dropped.
+func (rl *RateLimiter) Allow(peerID string, channelID common.ChannelIDEnum, now time.Time) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	limit, ok := rl.limits[channelID]
+	if !ok {
+		return true
+	}
+
+	key := rateLimitKey{peerID, channelID}
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), lastRefill: now}
+		rl.buckets[key] = bucket
+	}
+
+	if elapsed := now.Sub(bucket.lastRefill).Seconds(); elapsed > 0 {
+		bucket.tokens += elapsed * limit.Rate
+		if bucket.tokens > float64(limit.Burst) {
+			bucket.tokens = float64(limit.Burst)
+		}
+		bucket.lastRefill = now
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// RemovePeer removes the buckets of the peer
+func (rl *RateLimiter) RemovePeer(peerID string) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	for key := range rl.buckets {
+		if key.peerID == peerID {
+			delete(rl.buckets, key)
+		}
+	}
+}
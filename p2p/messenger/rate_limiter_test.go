@@ -0,0 +1,44 @@
+package messenger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/p2p"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	rl := createRateLimiter()
+	rl.SetLimit(common.ChannelIDTransaction, p2p.RateLimit{Rate: 2, Burst: 3})
+	now := time.Now()
+
+	// A burst up to the limit is allowed
+	for i := 0; i < 3; i++ {
+		assert.True(rl.Allow("peerA", common.ChannelIDTransaction, now))
+	}
+	assert.False(rl.Allow("peerA", common.ChannelIDTransaction, now))
+
+	// The buckets are per peer and per channel
+	assert.True(rl.Allow("peerB", common.ChannelIDTransaction, now))
+	for i := 0; i < 10; i++ {
+		assert.True(rl.Allow("peerA", common.ChannelIDVote, now))
+	}
+
+	// The bucket refills at the given rate, up to the burst size
+	now = now.Add(500 * time.Millisecond)
+	assert.True(rl.Allow("peerA", common.ChannelIDTransaction, now))
+	assert.False(rl.Allow("peerA", common.ChannelIDTransaction, now))
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(rl.Allow("peerA", common.ChannelIDTransaction, now))
+	}
+	assert.False(rl.Allow("peerA", common.ChannelIDTransaction, now))
+
+	// A removed peer starts with a full bucket
+	rl.RemovePeer("peerA")
+	assert.True(rl.Allow("peerA", common.ChannelIDTransaction, now))
+}
@@ -2,6 +2,8 @@ package peer
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"time"
 
@@ -24,7 +26,9 @@ type Peer struct {
 	isOutbound   bool
 	netAddress   *nu.NetAddress
 
-	nodeInfo p2ptypes.NodeInfo // information of the blockchain node of the peer
+	nodeInfo    p2ptypes.NodeInfo          // information of the blockchain node of the peer
+	compression byte                       // message compression negotiated during the handshake
+	channels    map[cmn.ChannelIDEnum]bool // channels supported by both nodes
 
 	config PeerConfig
 }
@@ -80,17 +84,73 @@ func (peer *Peer) Stop() {
 	peer.connection.Stop()
 }
 
-// Handshake handles the initial signaling between two peers
+// Handshake handles the initial signaling between two peers. The peers first exchange random
+// challenges, then their NodeInfos, each signed over the challenge of the other peer, so a
+// NodeInfo captured from another connection cannot be replayed.
 // NOTE: need to call peer.Handshake() before peer.Start()
-func (peer *Peer) Handshake(sourceNodeInfo *p2ptypes.NodeInfo) error {
+func (peer *Peer) Handshake(sourceNodeInfo *p2ptypes.NodeInfo, privKey *crypto.PrivateKey) error {
 	timeout := peer.config.HandshakeTimeout
 	peer.connection.GetNetconn().SetDeadline(time.Now().Add(timeout))
+
+	challenge, err := p2ptypes.NewChallenge()
+	if err != nil {
+		log.Errorf("[p2p] error during handshake: %v", err)
+		return err
+	}
+	// The challenges have a fixed size, and are exchanged as raw bytes, since the RLP decoder
+	// may read ahead into the node info sent next
+	targetChallenge := make(cmn.Bytes, p2ptypes.ChallengeSize)
+	if err = peer.exchange(
+		func(w io.Writer) error { _, err := w.Write(challenge); return err },
+		func(r io.Reader) error { _, err := io.ReadFull(r, targetChallenge); return err },
+	); err != nil {
+		return err
+	}
+
+	// The source node info is shared by the concurrent handshakes, so a copy is signed
+	signedNodeInfo := *sourceNodeInfo
+	if err = signedNodeInfo.Sign(privKey, targetChallenge); err != nil {
+		log.Errorf("[p2p] error during handshake: %v", err)
+		return err
+	}
+	targetPeerNodeInfo := p2ptypes.NodeInfo{}
+	if err = peer.exchange(
+		func(w io.Writer) error { return rlp.Encode(w, &signedNodeInfo) },
+		func(r io.Reader) error { return rlp.Decode(r, &targetPeerNodeInfo) },
+	); err != nil {
+		return err
+	}
+	peer.connection.GetNetconn().SetDeadline(time.Time{})
+	targetNodePubKey, err := crypto.PublicKeyFromBytes(targetPeerNodeInfo.PubKeyBytes)
+	if err != nil {
+		log.Errorf("[p2p] error during handshake/recv: %v", err)
+		return err
+	}
+	if targetPeerNodeInfo.ProtocolVersion != sourceNodeInfo.ProtocolVersion {
+		err = fmt.Errorf("incompatible protocol version: %v, expected: %v",
+			targetPeerNodeInfo.ProtocolVersion, sourceNodeInfo.ProtocolVersion)
+		log.Errorf("[p2p] error during handshake: %v", err)
+		return err
+	}
+	if err = targetPeerNodeInfo.VerifySignature(challenge); err != nil {
+		log.Errorf("[p2p] error during handshake: %v", err)
+		return err
+	}
+	targetPeerNodeInfo.PubKey = targetNodePubKey
+	peer.nodeInfo = targetPeerNodeInfo
+	peer.compression = negotiateCompression(sourceNodeInfo, &targetPeerNodeInfo)
+	peer.channels = negotiateChannels(sourceNodeInfo, &targetPeerNodeInfo)
+
+	return nil
+}
+
+// exchange sends a handshake message to the peer while receiving the message of the peer
+func (peer *Peer) exchange(send func(w io.Writer) error, recv func(r io.Reader) error) error {
 	var sendError error
 	var recvError error
-	targetPeerNodeInfo := p2ptypes.NodeInfo{}
 	cmn.Parallel(
-		func() { sendError = rlp.Encode(peer.connection.GetNetconn(), sourceNodeInfo) },
-		func() { recvError = rlp.Decode(peer.connection.GetNetconn(), &targetPeerNodeInfo) },
+		func() { sendError = send(peer.connection.GetNetconn()) },
+		func() { recvError = recv(peer.connection.GetNetconn()) },
 	)
 	if sendError != nil {
 		log.Errorf("[p2p] error during handshake/send: %v", sendError)
@@ -100,26 +160,26 @@ func (peer *Peer) Handshake(sourceNodeInfo *p2ptypes.NodeInfo) error {
 		log.Errorf("[p2p] error during handshake/recv: %v", recvError)
 		return recvError
 	}
-	peer.connection.GetNetconn().SetDeadline(time.Time{})
-	targetNodePubKey, err := crypto.PublicKeyFromBytes(targetPeerNodeInfo.PubKeyBytes)
-	if err != nil {
-		log.Errorf("[p2p] error during handshake/recv: %v", err)
-		return err
-	}
-	targetPeerNodeInfo.PubKey = targetNodePubKey
-	peer.nodeInfo = targetPeerNodeInfo
-
 	return nil
 }
 
-// Send sends the given message through the specified channel to the target peer
+// Send sends the given message through the specified channel to the target peer. Messages on
+// the channels not supported by both nodes are not sent.
 func (peer *Peer) Send(channelID cmn.ChannelIDEnum, message interface{}) bool {
+	if !peer.SupportsChannel(channelID) {
+		log.Debugf("[p2p] Channel %v is not supported by peer %v", channelID, peer.ID())
+		return false
+	}
 	success := peer.connection.EnqueueMessage(channelID, message)
 	return success
 }
 
 // AttemptToSend attempts to send the given message through the specified channel to the target peer (non-blocking)
 func (peer *Peer) AttemptToSend(channelID cmn.ChannelIDEnum, message interface{}) bool {
+	if !peer.SupportsChannel(channelID) {
+		log.Debugf("[p2p] Channel %v is not supported by peer %v", channelID, peer.ID())
+		return false
+	}
 	success := peer.connection.AttemptToEnqueueMessage(channelID, message)
 	return success
 }
@@ -167,6 +227,62 @@ func (peer *Peer) ID() string {
 	return id
 }
 
+// Compression returns the message compression negotiated with the peer during the handshake
+func (peer *Peer) Compression() byte {
+	return peer.compression
+}
+
+// SupportsChannel returns whether the channel is supported by both nodes
+func (peer *Peer) SupportsChannel(channelID cmn.ChannelIDEnum) bool {
+	return peer.channels[channelID]
+}
+
+// Info returns the information of the peer negotiated during the handshake
+func (peer *Peer) Info() p2ptypes.PeerInfo {
+	channels := []cmn.ChannelIDEnum{}
+	for _, channelID := range peer.nodeInfo.Channels {
+		if peer.channels[channelID] {
+			channels = append(channels, channelID)
+		}
+	}
+	netAddress := peer.nodeInfo.NetAddress
+	if netAddress == "" && peer.netAddress != nil {
+		netAddress = peer.netAddress.String()
+	}
+	return p2ptypes.PeerInfo{
+		ID:              peer.ID(),
+		NetAddress:      netAddress,
+		IsOutbound:      peer.isOutbound,
+		ProtocolVersion: peer.nodeInfo.ProtocolVersion,
+		Channels:        channels,
+		Compression:     peer.compression,
+		Latency:         peer.connection.Latency(),
+	}
+}
+
+// negotiateChannels returns the channels supported by both nodes
+func negotiateChannels(sourceNodeInfo, targetNodeInfo *p2ptypes.NodeInfo) map[cmn.ChannelIDEnum]bool {
+	supported := make(map[cmn.ChannelIDEnum]bool)
+	for _, channelID := range sourceNodeInfo.Channels {
+		supported[channelID] = true
+	}
+	channels := make(map[cmn.ChannelIDEnum]bool)
+	for _, channelID := range targetNodeInfo.Channels {
+		if supported[channelID] {
+			channels[channelID] = true
+		}
+	}
+	return channels
+}
+
+// negotiateCompression returns the message compression supported by both nodes
+func negotiateCompression(sourceNodeInfo, targetNodeInfo *p2ptypes.NodeInfo) byte {
+	if sourceNodeInfo.Compression != targetNodeInfo.Compression {
+		return p2ptypes.CompressionNone
+	}
+	return sourceNodeInfo.Compression
+}
+
 func dial(addr *nu.NetAddress, config PeerConfig) (net.Conn, error) {
 	netconn, err := addr.DialTimeout(config.DialTimeout)
 	if err != nil {
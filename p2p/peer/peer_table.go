@@ -83,12 +83,15 @@ func (pt *PeerTable) PeerExists(peerID string) bool {
 	return exists
 }
 
-// GetAllPeers returns all the peers
+// GetAllPeers returns a snapshot of all the peers, which is not affected by the later
+// additions and deletions
 func (pt *PeerTable) GetAllPeers() *([]*Peer) {
 	pt.mutex.Lock()
 	defer pt.mutex.Unlock()
 
-	return &pt.peers
+	peers := make([]*Peer, len(pt.peers))
+	copy(peers, pt.peers)
+	return &peers
 }
 
 // GetTotalNumPeers returns the total number of peers in the PeerTable
@@ -3,13 +3,16 @@ package peer
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/thetatoken/ukulele/common"
+	"github.com/thetatoken/ukulele/crypto"
 	cn "github.com/thetatoken/ukulele/p2p/connection"
 	nu "github.com/thetatoken/ukulele/p2p/netutil"
 	p2ptypes "github.com/thetatoken/ukulele/p2p/types"
@@ -35,9 +38,8 @@ func TestPeerHandshakeAndCommunication(t *testing.T) {
 
 	go func() {
 		outboundPeer := newOutboundPeer("127.0.0.1:" + strconv.Itoa(port))
-		randPeerPubKey := p2ptypes.GetTestRandPubKey()
-		peerANodeInfo := p2ptypes.CreateNodeInfo(randPeerPubKey)
-		err := outboundPeer.Handshake(&peerANodeInfo) // send out PeerA's node info
+		peerANodeInfo, peerAPrivKey := newTestNodeInfo(common.ChannelIDTransaction)
+		err := outboundPeer.Handshake(&peerANodeInfo, peerAPrivKey) // send out PeerA's node info
 		assert.Nil(err)
 		assert.True(outboundPeer.IsOutbound())
 
@@ -70,9 +72,8 @@ func TestPeerHandshakeAndCommunication(t *testing.T) {
 
 	// Handshake checks
 	inboundPeer := newInboundPeer(netconn)
-	peerBPubKey := p2ptypes.GetTestRandPubKey()
-	peerBNodeInfo := p2ptypes.CreateNodeInfo(peerBPubKey)
-	err = inboundPeer.Handshake(&peerBNodeInfo) // send out PeerB's node info
+	peerBNodeInfo, peerBPrivKey := newTestNodeInfo(common.ChannelIDTransaction, common.ChannelIDVote)
+	err = inboundPeer.Handshake(&peerBNodeInfo, peerBPrivKey) // send out PeerB's node info
 	assert.Nil(err)
 	assert.False(inboundPeer.IsOutbound())
 
@@ -90,6 +91,14 @@ func TestPeerHandshakeAndCommunication(t *testing.T) {
 	// ID checks
 	assert.Equal(receivedPeerAAddr, inboundPeer.ID())
 
+	// Negotiated channel checks
+	assert.True(inboundPeer.SupportsChannel(common.ChannelIDTransaction))
+	assert.False(inboundPeer.SupportsChannel(common.ChannelIDVote))
+	peerAInfo := inboundPeer.Info()
+	assert.Equal(inboundPeer.ID(), peerAInfo.ID)
+	assert.Equal(p2ptypes.ProtocolVersion, peerAInfo.ProtocolVersion)
+	assert.Equal([]common.ChannelIDEnum{common.ChannelIDTransaction}, peerAInfo.Channels)
+
 	// Persistency checks
 	inboundPeer.SetPersistency(false)
 	assert.False(inboundPeer.IsPersistent())
@@ -143,6 +152,106 @@ func TestPeerHandshakeAndCommunication(t *testing.T) {
 	}
 }
 
+func TestPeerHandshakeIncompatibleProtocolVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	port := 38858
+	outboundErrChan := make(chan error)
+
+	// ------ Simulate PeerA, a remote node with a different protocol version ------ //
+
+	go func() {
+		outboundPeer := newOutboundPeer("127.0.0.1:" + strconv.Itoa(port))
+		peerANodeInfo, peerAPrivKey := newTestNodeInfo()
+		peerANodeInfo.ProtocolVersion = p2ptypes.ProtocolVersion + 1
+		outboundErrChan <- outboundPeer.Handshake(&peerANodeInfo, peerAPrivKey)
+	}()
+
+	// ------ Simulate PeerB (i.e. us) ------ //
+
+	listener := p2ptypes.GetTestListener(port)
+	netconn, err := listener.Accept()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to listen to the netconn: %v", err))
+	}
+	defer netconn.Close()
+
+	inboundPeer := newInboundPeer(netconn)
+	peerBNodeInfo, peerBPrivKey := newTestNodeInfo()
+	assert.NotNil(inboundPeer.Handshake(&peerBNodeInfo, peerBPrivKey))
+	assert.NotNil(<-outboundErrChan)
+}
+
+func TestPeerHandshakeForgedNodeInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	port := 38859
+	outboundErrChan := make(chan error)
+	victimNodeInfo, _ := newTestNodeInfo()
+
+	// ------ Simulate PeerA, a remote node that impersonates the victim node ------ //
+
+	go func() {
+		outboundPeer := newOutboundPeer("127.0.0.1:" + strconv.Itoa(port))
+		forgedNodeInfo := p2ptypes.CreateNodeInfo(victimNodeInfo.PubKey)
+		attackerPrivKey := p2ptypes.GetTestRandPrivKey() // not the private key of the victim
+		outboundErrChan <- outboundPeer.Handshake(&forgedNodeInfo, attackerPrivKey)
+	}()
+
+	// ------ Simulate PeerB (i.e. us) ------ //
+
+	listener := p2ptypes.GetTestListener(port)
+	netconn, err := listener.Accept()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to listen to the netconn: %v", err))
+	}
+	defer netconn.Close()
+
+	inboundPeer := newInboundPeer(netconn)
+	peerBNodeInfo, peerBPrivKey := newTestNodeInfo()
+	assert.NotNil(inboundPeer.Handshake(&peerBNodeInfo, peerBPrivKey))
+	assert.Nil(<-outboundErrChan) // PeerB's node info is valid
+}
+
+func TestPeerHandshakeReplayedNodeInfo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	port := 38860
+	victimNodeInfo, victimPrivKey := newTestNodeInfo()
+
+	// The node info the victim signed during an earlier handshake, e.g. with the attacker
+	earlierChallenge, err := p2ptypes.NewChallenge()
+	require.Nil(err)
+	require.Nil(victimNodeInfo.Sign(victimPrivKey, earlierChallenge))
+	require.Nil(victimNodeInfo.VerifySignature(earlierChallenge))
+
+	// ------ Simulate PeerA, a remote node that replays the node info of the victim ------ //
+
+	go func() {
+		netconn := p2ptypes.GetTestNetconn(port)
+		defer netconn.Close()
+		challenge, _ := p2ptypes.NewChallenge()
+		netconn.Write(challenge)
+		io.ReadFull(netconn, make([]byte, p2ptypes.ChallengeSize)) // PeerA cannot sign over it
+		rlp.Encode(netconn, &victimNodeInfo)
+		rlp.Decode(netconn, &p2ptypes.NodeInfo{})
+	}()
+
+	// ------ Simulate PeerB (i.e. us) ------ //
+
+	listener := p2ptypes.GetTestListener(port)
+	netconn, err := listener.Accept()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to listen to the netconn: %v", err))
+	}
+	defer netconn.Close()
+
+	inboundPeer := newInboundPeer(netconn)
+	peerBNodeInfo, peerBPrivKey := newTestNodeInfo()
+	assert.NotNil(inboundPeer.Handshake(&peerBNodeInfo, peerBPrivKey))
+}
+
 // --------------- Test Utilities --------------- //
 
 func newOutboundPeer(ipAddr string) *Peer {
@@ -160,6 +269,13 @@ func newOutboundPeer(ipAddr string) *Peer {
 	return outboundPeer
 }
 
+func newTestNodeInfo(channelIDs ...common.ChannelIDEnum) (p2ptypes.NodeInfo, *crypto.PrivateKey) {
+	privKey := p2ptypes.GetTestRandPrivKey()
+	nodeInfo := p2ptypes.CreateNodeInfo(privKey.PublicKey())
+	nodeInfo.Channels = channelIDs
+	return nodeInfo, privKey
+}
+
 func newInboundPeer(netconn net.Conn) *Peer {
 	peerConfig := GetDefaultPeerConfig()
 	connConfig := cn.GetDefaultConnectionConfig()
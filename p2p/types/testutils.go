@@ -47,3 +47,12 @@ func GetTestRandPubKey() *crypto.PublicKey {
 	}
 	return randPubKey
 }
+
+// GetTestRandPrivKey returns a randomly generated private key
+func GetTestRandPrivKey() *crypto.PrivateKey {
+	randPrivKey, _, err := crypto.GenerateKeyPair()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to generate a random private key: %v", err))
+	}
+	return randPrivKey
+}
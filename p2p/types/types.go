@@ -1,8 +1,13 @@
 package types
 
 import (
+	"crypto/rand"
+	"errors"
+	"time"
+
 	"github.com/thetatoken/ukulele/common"
 	"github.com/thetatoken/ukulele/crypto"
+	"github.com/thetatoken/ukulele/rlp"
 )
 
 //
@@ -14,23 +19,102 @@ type Message struct {
 	Content   interface{}
 }
 
+// ProtocolVersion is the version of the P2P protocol. Nodes with different protocol versions
+// are incompatible and refuse to connect with each other.
+const ProtocolVersion = uint32(1)
+
 //
 // NodeInfo provides the information of the corresponding blockchain node of the peer
 //
 type NodeInfo struct {
 	PubKey      *crypto.PublicKey `rlp:"-"`
 	PubKeyBytes common.Bytes      // needed for RLP serialization
+	NetAddress  string            // external network address of the node
+	Compression byte              // message compression supported by the node
+
+	ProtocolVersion uint32
+	Channels        []common.ChannelIDEnum // channels the node has message handlers for
+
+	Signature *crypto.Signature // signed by the node with the private key of PubKey, over the challenge of the peer
 }
 
+// ChallengeSize is the size in bytes of the random challenge each node sends to its peer during
+// the handshake. The peer signs its NodeInfo over the challenge, so the signature is only valid
+// for the connection, and cannot be replayed to other nodes.
+const ChallengeSize = 32
+
 // CreateNodeInfo creates an instance of NodeInfo
 func CreateNodeInfo(pubKey *crypto.PublicKey) NodeInfo {
 	nodeInfo := NodeInfo{
-		PubKey:      pubKey,
-		PubKeyBytes: pubKey.ToBytes(),
+		PubKey:          pubKey,
+		PubKeyBytes:     pubKey.ToBytes(),
+		ProtocolVersion: ProtocolVersion,
 	}
 	return nodeInfo
 }
 
+// NewChallenge returns a random challenge for the peer to sign its NodeInfo over
+func NewChallenge() (common.Bytes, error) {
+	challenge := make(common.Bytes, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// SignBytes returns the bytes of the NodeInfo to be signed in response to the given challenge,
+// i.e. the RLP encoding of the NodeInfo without the signature, followed by the challenge
+func (nodeInfo *NodeInfo) SignBytes(challenge common.Bytes) common.Bytes {
+	sig := nodeInfo.Signature
+	nodeInfo.Signature = nil
+	signBytes, _ := rlp.EncodeToBytes(nodeInfo)
+	nodeInfo.Signature = sig
+	return append(signBytes, challenge...)
+}
+
+// Sign signs the NodeInfo over the challenge of the peer with the private key of the node
+func (nodeInfo *NodeInfo) Sign(privKey *crypto.PrivateKey, challenge common.Bytes) error {
+	nodeInfo.Signature = nil
+	sig, err := privKey.Sign(nodeInfo.SignBytes(challenge))
+	if err != nil {
+		return err
+	}
+	nodeInfo.Signature = sig
+	return nil
+}
+
+// VerifySignature checks that the NodeInfo is signed over the given challenge by the owner of
+// the advertised public key
+func (nodeInfo *NodeInfo) VerifySignature(challenge common.Bytes) error {
+	if len(challenge) != ChallengeSize {
+		return errors.New("invalid node info challenge")
+	}
+	if nodeInfo.Signature == nil || nodeInfo.Signature.IsEmpty() {
+		return errors.New("node info is not signed")
+	}
+	pubKey, err := crypto.PublicKeyFromBytes(nodeInfo.PubKeyBytes)
+	if err != nil {
+		return err
+	}
+	if !pubKey.VerifySignature(nodeInfo.SignBytes(challenge), nodeInfo.Signature) {
+		return errors.New("invalid node info signature")
+	}
+	return nil
+}
+
+//
+// PeerInfo provides the information of a connected peer negotiated during the handshake
+//
+type PeerInfo struct {
+	ID              string
+	NetAddress      string
+	IsOutbound      bool
+	ProtocolVersion uint32
+	Channels        []common.ChannelIDEnum // channels supported by both nodes
+	Compression     byte
+	Latency         time.Duration // smoothed round-trip time to the peer, 0 if not measured yet
+}
+
 const (
 	// PingSignal represents a ping signal to a peer
 	PingSignal = byte(0x0)
@@ -38,3 +122,11 @@ const (
 	// PongSignal represents a pong respond to a peer
 	PongSignal = byte(0x1)
 )
+
+const (
+	// CompressionNone indicates the messages are not compressed
+	CompressionNone = byte(0x0)
+
+	// CompressionGzip indicates the messages are compressed with gzip
+	CompressionGzip = byte(0x1)
+)
@@ -55,3 +55,52 @@ func TestNodeInfoRLPEncoding2(t *testing.T) {
 
 	assert.Equal(nodeInfo.PubKey.Address(), decodedNodeInfo.PubKey.Address())
 }
+
+func TestNodeInfoSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	privKey, pubKey, err := crypto.GenerateKeyPair()
+	assert.Nil(err)
+	challenge, err := NewChallenge()
+	assert.Nil(err)
+	assert.Equal(ChallengeSize, len(challenge))
+	nodeInfo := CreateNodeInfo(pubKey)
+	nodeInfo.NetAddress = "127.0.0.1:50001"
+	assert.NotNil(nodeInfo.VerifySignature(challenge))
+
+	assert.Nil(nodeInfo.Sign(privKey, challenge))
+	assert.Nil(nodeInfo.VerifySignature(challenge))
+
+	// The signature survives the RLP encoding
+	encodedNodeInfoBytes, err := rlp.EncodeToBytes(nodeInfo)
+	assert.Nil(err)
+	var decodedNodeInfo NodeInfo
+	assert.Nil(rlp.DecodeBytes(encodedNodeInfoBytes, &decodedNodeInfo))
+	assert.Nil(decodedNodeInfo.VerifySignature(challenge))
+
+	// The signature is only valid for the challenge it was signed over
+	anotherChallenge, err := NewChallenge()
+	assert.Nil(err)
+	assert.NotNil(decodedNodeInfo.VerifySignature(anotherChallenge))
+	assert.NotNil(decodedNodeInfo.VerifySignature(nil))
+
+	// Tampering with the signed fields invalidates the signature
+	decodedNodeInfo.NetAddress = "127.0.0.1:50002"
+	assert.NotNil(decodedNodeInfo.VerifySignature(challenge))
+}
+
+func TestNodeInfoForgedSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	_, victimPubKey, err := crypto.GenerateKeyPair()
+	assert.Nil(err)
+	attackerPrivKey, _, err := crypto.GenerateKeyPair()
+	assert.Nil(err)
+	challenge, err := NewChallenge()
+	assert.Nil(err)
+
+	// A node advertising the public key of another node cannot produce a valid signature
+	forgedNodeInfo := CreateNodeInfo(victimPubKey)
+	assert.Nil(forgedNodeInfo.Sign(attackerPrivKey, challenge))
+	assert.NotNil(forgedNodeInfo.VerifySignature(challenge))
+}
@@ -81,12 +81,18 @@ type Decoder interface {
 // error if there are too few or too many elements.
 //
 // The decoding of struct fields honours certain struct tags, "tail",
-// "nil" and "-".
+// "optional", "nil" and "-".
 //
 // The "-" tag ignores fields.
 //
 // For an explanation of "tail", see the example.
 //
+// The "optional" tag allows the field to be missing at the end of the
+// input list, in which case it decodes to its zero value. All the fields
+// following an optional field must be optional as well, except for a
+// "tail" field. When encoding, the trailing optional fields with zero
+// values are omitted.
+//
 // The "nil" tag applies to pointer-typed fields and changes the decoding
 // rules for the field such that input values of size zero decode as a nil
 // pointer. This tag can be useful when decoding recursive types.
@@ -438,8 +444,15 @@ func makeStructDecoder(typ reflect.Type) (decoder, error) {
 		if _, err := s.List(); err != nil {
 			return wrapStreamError(err, typ)
 		}
-		for _, f := range fields {
+		for i, f := range fields {
 			err := f.info.decoder(s, val.Field(f.index))
+			if err == EOL && f.optional {
+				// The remaining optional fields are missing from the input
+				for _, f := range fields[i:] {
+					val.Field(f.index).Set(reflect.Zero(val.Field(f.index).Type()))
+				}
+				break
+			}
 			if err == EOL {
 				return &decodeError{msg: "too few elements", typ: typ}
 			} else if err != nil {
@@ -529,9 +529,16 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 	if err != nil {
 		return nil, err
 	}
+	firstOptional := firstOptionalField(fields)
 	writer := func(val reflect.Value, w *encbuf) error {
+		// Omit the trailing optional fields with zero values. An empty
+		// tail field encodes to nothing, so it can be omitted as well.
+		lastField := len(fields) - 1
+		for lastField >= firstOptional && isZero(val.Field(fields[lastField].index)) {
+			lastField--
+		}
 		lh := w.list()
-		for _, f := range fields {
+		for _, f := range fields[:lastField+1] {
 			if err := f.info.writer(val.Field(f.index), w); err != nil {
 				return err
 			}
@@ -542,6 +549,17 @@ func makeStructWriter(typ reflect.Type) (writer, error) {
 	return writer, nil
 }
 
+// isZero reports whether the value encodes the same as its zero value, which
+// is the value an omitted optional field decodes to.
+func isZero(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Slice:
+		return val.Len() == 0
+	default:
+		return val.IsZero()
+	}
+}
+
 func makePtrWriter(typ reflect.Type) (writer, error) {
 	etypeinfo, err := cachedTypeInfo1(typ.Elem(), tags{})
 	if err != nil {
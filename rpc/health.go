@@ -0,0 +1,20 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleHealth reports the ledger status for liveness and readiness probes. It responds
+// with 503 Service Unavailable until the ledger has caught up with the consensus tip.
+func (t *ThetaRPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status := t.ledger.Status()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !status.CaughtUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logger.Errorf("Failed to encode the ledger status: %v", err)
+	}
+}
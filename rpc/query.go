@@ -107,3 +107,29 @@ func (t *ThetaRPCServer) GetTransaction(r *http.Request, args *GetTransactionArg
 
 	return nil
 }
+
+// ------------------------------ GetPendingBlock -----------------------------------
+
+type GetPendingBlockArgs struct{}
+
+type GetPendingBlockResult struct {
+	StateHash common.Hash `json:"state_hash"`
+	Txs       []types.Tx  `json:"transactions"`
+}
+
+func (t *ThetaRPCServer) GetPendingBlock(r *http.Request, args *GetPendingBlockArgs, result *GetPendingBlockResult) (err error) {
+	rawTxs, stateHash, res := t.ledger.PreviewNextBlock()
+	if res.IsError() {
+		return errors.New(res.Message)
+	}
+	result.StateHash = stateHash
+	result.Txs = []types.Tx{}
+	for _, raw := range rawTxs {
+		tx, err := types.TxFromBytes(raw)
+		if err != nil {
+			return err
+		}
+		result.Txs = append(result.Txs, tx)
+	}
+	return nil
+}
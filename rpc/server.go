@@ -56,6 +56,7 @@ func NewThetaRPCServer(mempool *mempool.Mempool, ledger *ledger.Ledger, chain *b
 
 	t.router = mux.NewRouter()
 	t.router.Handle("/rpc", t.handler)
+	t.router.HandleFunc("/health", t.handleHealth)
 
 	t.server = &http.Server{
 		Handler: t.router,
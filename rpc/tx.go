@@ -2,10 +2,10 @@ package rpc
 
 import (
 	"encoding/hex"
+	"errors"
 	"net/http"
 
-	"github.com/thetatoken/ukulele/crypto"
-	"github.com/thetatoken/ukulele/mempool"
+	"github.com/thetatoken/ukulele/common"
 )
 
 // ------------------------------- BroadcastRawTransaction -----------------------------------
@@ -24,8 +24,11 @@ func (t *ThetaRPCServer) BroadcastRawTransaction(r *http.Request, args *Broadcas
 		return err
 	}
 
-	hash := crypto.Keccak256Hash(txBytes)
-	result.TxHash = hash.Hex()
+	res := t.ledger.SubmitTx(txBytes)
+	if res.IsError() {
+		return errors.New(res.Message)
+	}
+	result.TxHash = res.Info.(common.Hash).Hex()
 
-	return t.mempool.InsertTransaction(mempool.CreateMempoolTransaction(txBytes))
+	return nil
 }
@@ -63,9 +63,9 @@ func (store *TreeStore) Revert(root common.Hash) (*TreeStore, error) {
 	return revertedStore, nil
 }
 
-// Copy returns a copy of the TreeStore
+// Copy returns a copy-on-write copy of the TreeStore. The uncommitted changes
+// of the store are carried over to the copy without being committed.
 func (store *TreeStore) Copy() (*TreeStore, error) {
-	store.Trie.Commit(nil)
 	copiedTrie, err := store.Trie.Copy()
 	if err != nil {
 		return nil, err
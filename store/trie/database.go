@@ -601,6 +601,20 @@ func (db *Database) Cap(limit common.StorageSize) error {
 //
 // As a side effect, all pre-images accumulated up to this point are also written.
 func (db *Database) Commit(node common.Hash, report bool) error {
+	return db.CommitRoots([]common.Hash{node}, report)
+}
+
+// CommitRoots is the same as Commit, except that it writes out the tries of all
+// the given roots with a single database batch.
+func (db *Database) CommitRoots(roots []common.Hash, report bool) error {
+	return db.CommitRootsWith(roots, report, nil)
+}
+
+// CommitRootsWith is the same as CommitRoots, except that the writes of the given
+// function, if not nil, are added to the last database batch, which also holds the
+// roots. Hence the writes are persisted atomically with the tries, e.g. the indexes
+// of the data in the tries.
+func (db *Database) CommitRootsWith(roots []common.Hash, report bool, writes func(batch database.Putter) error) error {
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -626,10 +640,19 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.nodes), db.nodesSize
-	if err := db.commit(node, batch); err != nil {
-		log.Error("Failed to commit trie from trie database", "err", err)
-		db.lock.RUnlock()
-		return err
+	for _, node := range roots {
+		if err := db.commit(node, batch); err != nil {
+			log.Error("Failed to commit trie from trie database", "err", err)
+			db.lock.RUnlock()
+			return err
+		}
+	}
+	if writes != nil {
+		if err := writes(batch); err != nil {
+			log.Error("Failed to add the writes to the trie database batch", "err", err)
+			db.lock.RUnlock()
+			return err
+		}
 	}
 	// Write batch ready, unlock for readers during persistence
 	if err := batch.Write(); err != nil {
@@ -646,7 +669,9 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 	db.preimages = make(map[common.Hash][]byte)
 	db.preimagesSize = 0
 
-	db.uncache(node)
+	for _, node := range roots {
+		db.uncache(node)
+	}
 
 	memcacheCommitTimeTimer.Update(time.Since(start))
 	memcacheCommitSizeMeter.Mark(int64(storage - db.nodesSize))
@@ -119,11 +119,13 @@ func New(root common.Hash, db *Database) (*Trie, error) {
 	return trie, nil
 }
 
-// Copy creates a copy of the trie
+// Copy creates a copy-on-write copy of the trie. The copy shares the nodes of
+// the original trie, which is safe since the nodes are never modified in place:
+// updates copy every node along the modified path. Hence creating the copy is
+// cheap, and only the nodes touched by subsequent mutations are allocated.
 func (t *Trie) Copy() (*Trie, error) {
-	rootHash := t.Hash()
-	copiedTrie, err := New(rootHash, t.db)
-	return copiedTrie, err
+	copiedTrie := *t
+	return &copiedTrie, nil
 }
 
 // NodeIterator returns an iterator that returns nodes of the trie. Iteration starts at